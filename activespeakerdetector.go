@@ -0,0 +1,175 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"sync"
+
+	native "github.com/notedit/media-server-go/wrapper"
+)
+
+type activeTrackListener interface {
+	native.ActiveTrackListener
+	deleteActiveTrackListener()
+}
+
+type goActiveTrackListener struct {
+	native.ActiveTrackListener
+}
+
+func (l *goActiveTrackListener) deleteActiveTrackListener() {
+	native.DeleteDirectorActiveTrackListener(l.ActiveTrackListener)
+}
+
+type overwrittenActiveTrackListener struct {
+	detector *ActiveSpeakerDetector
+}
+
+func (p *overwrittenActiveTrackListener) OnActiveTrackchanged(id uint) {
+	p.detector.onActiveTrackChanged(id)
+}
+
+// ActiveSpeakerDetector detect the active speaker among audio tracks, using the audio level header extension
+type ActiveSpeakerDetector struct {
+	detector  native.ActiveSpeakerDetectorFacade
+	listener  activeTrackListener
+	tracks    map[uint]*IncomingStreamTrack
+	ids       map[*IncomingStreamTrack]uint
+	maxId     uint
+	listeners []ActiveSpeakerChangedListener
+	lock      sync.Mutex
+}
+
+// NewActiveSpeakerDetector create a new active speaker detector
+func NewActiveSpeakerDetector() *ActiveSpeakerDetector {
+
+	detector := &ActiveSpeakerDetector{
+		tracks:    make(map[uint]*IncomingStreamTrack),
+		ids:       make(map[*IncomingStreamTrack]uint),
+		listeners: make([]ActiveSpeakerChangedListener, 0),
+	}
+
+	p := native.NewDirectorActiveTrackListener(&overwrittenActiveTrackListener{detector: detector})
+	detector.listener = &goActiveTrackListener{ActiveTrackListener: p}
+	detector.detector = native.NewActiveSpeakerDetectorFacade(detector.listener)
+	trackNative("ActiveSpeakerDetectorFacade", detector.detector)
+
+	return detector
+}
+
+// SetMinChangePeriod set minimum period in ms between active speaker changes
+func (a *ActiveSpeakerDetector) SetMinChangePeriod(minChangePeriod uint) {
+	a.detector.SetMinChangePeriod(minChangePeriod)
+}
+
+// SetMaxAccumulatedScore set the maximum activity score accumulated by a speaker
+func (a *ActiveSpeakerDetector) SetMaxAccumulatedScore(maxAcummulatedScore uint64) {
+	a.detector.SetMaxAccumulatedScore(maxAcummulatedScore)
+}
+
+// SetNoiseGatingThreshold set the minimum audio level, in -dBov, to be considered as speech
+func (a *ActiveSpeakerDetector) SetNoiseGatingThreshold(noiseGatingThreshold byte) {
+	a.detector.SetNoiseGatingThreshold(noiseGatingThreshold)
+}
+
+// SetMinActivationScore set the minimum score needed to become the active speaker
+func (a *ActiveSpeakerDetector) SetMinActivationScore(minActivationScore uint) {
+	a.detector.SetMinActivationScore(minActivationScore)
+}
+
+// AddSpeaker start detecting activity on the audio track
+func (a *ActiveSpeakerDetector) AddSpeaker(track *IncomingStreamTrack) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.detector == nil {
+		return
+	}
+
+	if _, ok := a.ids[track]; ok {
+		return
+	}
+
+	a.maxId++
+
+	a.tracks[a.maxId] = track
+	a.ids[track] = a.maxId
+
+	for _, encoding := range track.GetEncodings() {
+		a.detector.AddIncomingSourceGroup(encoding.GetSource(), a.maxId)
+	}
+}
+
+// RemoveSpeaker stop detecting activity on the audio track
+func (a *ActiveSpeakerDetector) RemoveSpeaker(track *IncomingStreamTrack) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.detector == nil {
+		return
+	}
+
+	id, ok := a.ids[track]
+	if !ok {
+		return
+	}
+
+	for _, encoding := range track.GetEncodings() {
+		a.detector.RemoveIncomingSourceGroup(encoding.GetSource())
+	}
+
+	delete(a.ids, track)
+	delete(a.tracks, id)
+}
+
+// OnActiveSpeakerChanged register a listener for active speaker changes
+func (a *ActiveSpeakerDetector) OnActiveSpeakerChanged(listener ActiveSpeakerChangedListener) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.listeners = append(a.listeners, listener)
+}
+
+func (a *ActiveSpeakerDetector) onActiveTrackChanged(id uint) {
+
+	a.lock.Lock()
+	track := a.tracks[id]
+	listeners := make([]ActiveSpeakerChangedListener, len(a.listeners))
+	copy(listeners, a.listeners)
+	a.lock.Unlock()
+
+	if track == nil {
+		return
+	}
+
+	for _, listener := range listeners {
+		listener(track)
+	}
+}
+
+// Stop stop detecting
+func (a *ActiveSpeakerDetector) Stop() {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.detector == nil {
+		return
+	}
+
+	for track := range a.ids {
+		for _, encoding := range track.GetEncodings() {
+			a.detector.RemoveIncomingSourceGroup(encoding.GetSource())
+		}
+	}
+
+	untrackNative(a.detector)
+	native.DeleteActiveSpeakerDetectorFacade(a.detector)
+	a.listener.deleteActiveTrackListener()
+
+	a.detector = nil
+	a.tracks = nil
+	a.ids = nil
+}
@@ -0,0 +1,79 @@
+package mediaserver
+
+import (
+	"github.com/notedit/sdp"
+)
+
+// The Go logic uses the native objects through the narrow interfaces below. They are implemented over the
+// wrapper in backend_native.go, built with cgo only, and by an in-memory fake in the tests, so the transponder
+// layer selection, the stats aggregation, AttachTo, the recorder and the room bookkeeping are tested without cgo.
+
+// stopper anything that can be stopped, like a key frame waiter
+type stopper interface {
+	Stop()
+}
+
+// encodingBackend the source group of an incoming encoding
+type encodingBackend interface {
+	// update refresh the counters of the sources
+	update()
+	// stats the stats of the media, rtx and fec sources, the layers of each source are not aggregated
+	stats() *IncomingAllStats
+	// ssrc the media ssrc
+	ssrc() uint
+	// listen call onFrame for the frames of the mode until stopped
+	listen(mode frameListenerMode, onFrame func(frame mediaFrame)) stopper
+	// delete release the source group
+	delete()
+}
+
+// receiverBackend the receiver sending the feedback of incoming tracks to the remote sender
+type receiverBackend interface {
+	sendPLI(ssrc uint)
+	sendFIR(ssrc uint, seq byte)
+	sendMaxBitrate(ssrc uint, bitrate uint, tmmbr bool)
+	// delete release the receiver
+	delete()
+}
+
+// transponderBackend the transponder forwarding an encoding to an outgoing track
+type transponderBackend interface {
+	setIncoming(encoding *Encoding, receiver receiverBackend) bool
+	selectLayer(spatialLayerId, temporalLayerId int)
+	mute(muting bool)
+	// close stop forwarding and release the transponder
+	close()
+}
+
+// outgoingSourceBackend the source group of an outgoing track
+type outgoingSourceBackend interface {
+	// stats the stats of the media, rtx and fec sources
+	stats() *OutgoingStatss
+}
+
+// senderBackend the sender of an outgoing track
+type senderBackend interface {
+	newTransponder(source outgoingSourceBackend) transponderBackend
+	// delete release the sender
+	delete()
+}
+
+// recorderBackend the mp4 file of a recorder
+type recorderBackend interface {
+	create(filename string) bool
+	record(waitForIntra bool) bool
+	// addEncoding record the frames of the encoding
+	addEncoding(encoding *Encoding)
+	// close close the file and release the recorder
+	close()
+}
+
+// transportBackend the transport the tracks of the streams are added to
+type transportBackend interface {
+	// newIncomingTrack add the source groups of the track, its feedback is sent through the receiver
+	newIncomingTrack(track *sdp.TrackInfo, receiver receiverBackend) *IncomingStreamTrack
+	// newOutgoingTrack add the source group of the track
+	newOutgoingTrack(track *sdp.TrackInfo) *OutgoingStreamTrack
+	// removeOutgoingTrack remove the source group of the track and release it
+	removeOutgoingTrack(track *OutgoingStreamTrack)
+}
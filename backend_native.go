@@ -0,0 +1,371 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"strconv"
+
+	native "github.com/notedit/media-server-go/wrapper"
+	"github.com/notedit/sdp"
+)
+
+// nativeEncoding an encoding over its native source group
+type nativeEncoding struct {
+	source       native.RTPIncomingSourceGroup
+	depacketizer native.StreamTrackDepacketizer
+}
+
+func newNativeEncoding(source native.RTPIncomingSourceGroup) *nativeEncoding {
+	depacketizer := native.NewStreamTrackDepacketizer(source)
+	trackNative("StreamTrackDepacketizer", depacketizer)
+	return &nativeEncoding{source: source, depacketizer: depacketizer}
+}
+
+func (n *nativeEncoding) update() {
+	n.source.Update()
+}
+
+func (n *nativeEncoding) stats() *IncomingAllStats {
+	return &IncomingAllStats{
+		Rtt:         n.source.GetRtt(),
+		MinWaitTime: n.source.GetMinWaitedTime(),
+		MaxWaitTime: n.source.GetMaxWaitedTime(),
+		AvgWaitTime: n.source.GetAvgWaitedTime(),
+		Media:       getStatsFromIncomingSource(n.source.GetMedia()),
+		Rtx:         getStatsFromIncomingSource(n.source.GetRtx()),
+		Fec:         getStatsFromIncomingSource(n.source.GetFec()),
+	}
+}
+
+func (n *nativeEncoding) ssrc() uint {
+	return n.source.GetMedia().GetSsrc()
+}
+
+func (n *nativeEncoding) listen(mode frameListenerMode, onFrame func(frame mediaFrame)) stopper {
+	return newFrameListener(n.source, mode, onFrame)
+}
+
+func (n *nativeEncoding) delete() {
+	n.depacketizer.Stop()
+	untrackNative(n.depacketizer)
+	native.DeleteStreamTrackDepacketizer(n.depacketizer)
+	untrackNative(n.source)
+	native.DeleteRTPIncomingSourceGroup(n.source)
+}
+
+// nativeReceiverBackend a receiverBackend over a native receiver
+type nativeReceiverBackend interface {
+	receiverBackend
+	facade() native.RTPReceiverFacade
+}
+
+// receiverFacade the native receiver of the backend, nil for the other backends
+func receiverFacade(receiver receiverBackend) native.RTPReceiverFacade {
+	if n, ok := receiver.(nativeReceiverBackend); ok {
+		return n.facade()
+	}
+	return nil
+}
+
+// nativeReceiver a native receiver, tracked by its creator
+type nativeReceiver struct {
+	receiver native.RTPReceiverFacade
+}
+
+func (n *nativeReceiver) facade() native.RTPReceiverFacade {
+	return n.receiver
+}
+
+func (n *nativeReceiver) sendPLI(ssrc uint) {
+	n.receiver.SendPLI(ssrc)
+}
+
+func (n *nativeReceiver) sendFIR(ssrc uint, seq byte) {
+	n.receiver.SendFIR(ssrc, seq)
+}
+
+func (n *nativeReceiver) sendMaxBitrate(ssrc uint, bitrate uint, tmmbr bool) {
+	n.receiver.SendMaxBitrate(ssrc, bitrate, tmmbr)
+}
+
+func (n *nativeReceiver) delete() {
+	untrackNative(n.receiver)
+	native.DeleteRTPReceiverFacade(n.receiver)
+}
+
+// nativeOutgoingSource an outgoing source group, tracked by its creator
+type nativeOutgoingSource struct {
+	group native.RTPOutgoingSourceGroup
+}
+
+// outgoingSourceGroup the native group of the source, nil for the other backends
+func outgoingSourceGroup(source outgoingSourceBackend) native.RTPOutgoingSourceGroup {
+	if n, ok := source.(*nativeOutgoingSource); ok {
+		return n.group
+	}
+	return nil
+}
+
+func (n *nativeOutgoingSource) stats() *OutgoingStatss {
+	return &OutgoingStatss{
+		Media: getStatsFromOutgoingSource(n.group.GetMedia()),
+		Rtx:   getStatsFromOutgoingSource(n.group.GetRtx()),
+		Fec:   getStatsFromOutgoingSource(n.group.GetFec()),
+	}
+}
+
+// nativeTransponder a native transponder, released by close
+type nativeTransponder struct {
+	transponder native.RTPStreamTransponderFacade
+	// leakGuard logs the native transponder if it is collected without being closed
+	leakGuard *nativeLeakGuard
+}
+
+func newNativeTransponder(transponder native.RTPStreamTransponderFacade) *nativeTransponder {
+	return &nativeTransponder{
+		transponder: transponder,
+		leakGuard:   newNativeLeakGuard("Transponder", transponder),
+	}
+}
+
+func (n *nativeTransponder) setIncoming(encoding *Encoding, receiver receiverBackend) bool {
+	return n.transponder.SetIncoming(encoding.GetSource(), receiverFacade(receiver))
+}
+
+func (n *nativeTransponder) selectLayer(spatialLayerId, temporalLayerId int) {
+	n.transponder.SelectLayer(spatialLayerId, temporalLayerId)
+}
+
+func (n *nativeTransponder) mute(muting bool) {
+	n.transponder.Mute(muting)
+}
+
+func (n *nativeTransponder) close() {
+	n.transponder.Close()
+	untrackNative(n.transponder)
+	native.DeleteRTPStreamTransponderFacade(n.transponder)
+}
+
+// nativeSender a native sender, owned by its outgoing track
+type nativeSender struct {
+	sender native.RTPSenderFacade
+}
+
+func newNativeSender(sender native.RTPSenderFacade) *nativeSender {
+	trackNative("RTPSenderFacade", sender)
+	return &nativeSender{sender: sender}
+}
+
+func (n *nativeSender) newTransponder(source outgoingSourceBackend) transponderBackend {
+	transponder := native.NewRTPStreamTransponderFacade(outgoingSourceGroup(source), n.sender)
+	trackNative("RTPStreamTransponderFacade", transponder)
+	return newNativeTransponder(transponder)
+}
+
+func (n *nativeSender) delete() {
+	untrackNative(n.sender)
+	native.DeleteRTPSenderFacade(n.sender)
+}
+
+// nativeRecorder a native mp4 recorder, released by close
+type nativeRecorder struct {
+	recorder native.MP4RecorderFacade
+	// leakGuard logs the native recorder if it is collected without being closed
+	leakGuard *nativeLeakGuard
+}
+
+func newNativeRecorder() *nativeRecorder {
+	recorder := native.NewMP4RecorderFacade()
+	trackNative("MP4RecorderFacade", recorder)
+	return &nativeRecorder{
+		recorder:  recorder,
+		leakGuard: newNativeLeakGuard("Recorder", recorder),
+	}
+}
+
+func (n *nativeRecorder) create(filename string) bool {
+	return n.recorder.Create(filename)
+}
+
+func (n *nativeRecorder) record(waitForIntra bool) bool {
+	return n.recorder.Record(waitForIntra)
+}
+
+func (n *nativeRecorder) addEncoding(encoding *Encoding) {
+	encoding.GetDepacketizer().AddMediaListener(n.recorder)
+}
+
+func (n *nativeRecorder) close() {
+	n.recorder.Close()
+	untrackNative(n.recorder)
+	native.DeleteMP4RecorderFacade(n.recorder)
+}
+
+// nativeTransport the native transport of the streams
+type nativeTransport struct {
+	transport native.DTLSICETransport
+}
+
+func (n *nativeTransport) newIncomingTrack(track *sdp.TrackInfo, receiver receiverBackend) *IncomingStreamTrack {
+
+	var mediaType native.MediaFrameType = 0
+	if track.GetMedia() == "video" {
+		mediaType = 1
+	}
+
+	sources := map[string]native.RTPIncomingSourceGroup{}
+
+	encodings := track.GetEncodings()
+
+	if len(encodings) > 0 {
+
+		for _, items := range encodings {
+
+			for _, encoding := range items {
+
+				source := native.NewRTPIncomingSourceGroup(mediaType, n.transport.GetTimeService())
+				trackNative("RTPIncomingSourceGroup", source)
+
+				mid := track.GetMediaID()
+
+				rid := encoding.GetID()
+
+				source.SetRid(rid)
+
+				if mid != "" {
+					source.SetMid(mid)
+				}
+
+				params := encoding.GetParams()
+
+				if ssrc, ok := params["ssrc"]; ok {
+					ssrcUint, err := strconv.ParseUint(ssrc, 10, 32)
+					if err != nil {
+						componentLogger("incomingstream").Warn("ssrc parse error", "err", err)
+						untrackNative(source)
+						native.DeleteRTPIncomingSourceGroup(source)
+						continue
+					}
+					source.GetMedia().SetSsrc(uint(ssrcUint))
+					groups := track.GetSourceGroupS()
+					for _, group := range groups {
+						// check if it is from us
+						if group.GetSSRCs() != nil && group.GetSSRCs()[0] == source.GetMedia().GetSsrc() {
+							if group.GetSemantics() == "FID" {
+								source.GetRtx().SetSsrc(group.GetSSRCs()[1])
+							}
+
+							if group.GetSemantics() == "FEC-FR" {
+								source.GetFec().SetSsrc(group.GetSSRCs()[1])
+							}
+						}
+					}
+				}
+
+				n.transport.AddIncomingSourceGroup(source)
+				sources[rid] = source
+
+			}
+		}
+
+	} else if track.GetSourceGroup("SIM") != nil {
+		// chrome like simulcast
+		SIM := track.GetSourceGroup("SIM")
+
+		ssrcs := SIM.GetSSRCs()
+
+		groups := track.GetSourceGroupS()
+
+		for j, ssrc := range ssrcs {
+
+			source := native.NewRTPIncomingSourceGroup(mediaType, n.transport.GetTimeService())
+			trackNative("RTPIncomingSourceGroup", source)
+
+			source.GetMedia().SetSsrc(ssrc)
+
+			for _, group := range groups {
+
+				if group.GetSSRCs()[0] == ssrc {
+
+					if group.GetSemantics() == "FID" {
+						source.GetRtx().SetSsrc(group.GetSSRCs()[1])
+					}
+
+					if group.GetSemantics() == "FEC-FR" {
+						source.GetFec().SetSsrc(group.GetSSRCs()[1])
+					}
+				}
+			}
+
+			n.transport.AddIncomingSourceGroup(source)
+
+			sources[strconv.Itoa(j)] = source
+		}
+
+	} else {
+		source := native.NewRTPIncomingSourceGroup(mediaType, n.transport.GetTimeService())
+		trackNative("RTPIncomingSourceGroup", source)
+
+		source.GetMedia().SetSsrc(track.GetSSRCS()[0])
+
+		fid := track.GetSourceGroup("FID")
+		fec_fr := track.GetSourceGroup("FEC-FR")
+
+		if fid != nil {
+			source.GetRtx().SetSsrc(fid.GetSSRCs()[1])
+		} else {
+			source.GetRtx().SetSsrc(0)
+		}
+
+		if fec_fr != nil {
+			source.GetFec().SetSsrc(fec_fr.GetSSRCs()[1])
+		} else {
+			source.GetFec().SetSsrc(0)
+		}
+
+		n.transport.AddIncomingSourceGroup(source)
+
+		// Append to soruces with empty rid
+		sources[""] = source
+
+	}
+
+	return NewIncomingStreamTrack(track.GetMedia(), track.GetID(), receiverFacade(receiver), sources)
+}
+
+func (n *nativeTransport) newOutgoingTrack(track *sdp.TrackInfo) *OutgoingStreamTrack {
+
+	var mediaType native.MediaFrameType = 0
+	if track.GetMedia() == "video" {
+		mediaType = 1
+	}
+
+	source := native.NewRTPOutgoingSourceGroup(mediaType)
+	trackNative("RTPOutgoingSourceGroup", source)
+
+	source.GetMedia().SetSsrc(track.GetSSRCS()[0])
+
+	fid := track.GetSourceGroup("FID")
+	fec_fr := track.GetSourceGroup("FEC-FR")
+
+	if fid != nil {
+		source.GetRtx().SetSsrc(fid.GetSSRCs()[1])
+	} else {
+		source.GetRtx().SetSsrc(0)
+	}
+
+	if fec_fr != nil {
+		source.GetFec().SetSsrc(fec_fr.GetSSRCs()[1])
+	} else {
+		source.GetFec().SetSsrc(0)
+	}
+
+	n.transport.AddOutgoingSourceGroup(source)
+
+	return newOutgoingStreamTrack(track.GetMedia(), track.GetID(), native.TransportToSender(n.transport), source)
+}
+
+func (n *nativeTransport) removeOutgoingTrack(track *OutgoingStreamTrack) {
+	track.DeleteOutgoingSourceGroup(n.transport)
+}
@@ -0,0 +1,254 @@
+package mediaserver
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_FakeIncomingStreamTrackStats(t *testing.T) {
+
+	track := newFakeIncomingStreamTrack("video", "video", map[string]*fakeEncoding{
+		"a": newFakeEncoding(1, 300000,
+			&Layer{SpatialLayerId: 0, TemporalLayerId: 0, Bitrate: 100000},
+			&Layer{SpatialLayerId: 0, TemporalLayerId: 1, Bitrate: 50000},
+			&Layer{SpatialLayerId: 1, TemporalLayerId: 0, Bitrate: 150000},
+		),
+		"b": newFakeEncoding(2, 1000000),
+		"c": newFakeEncoding(3, 0),
+	})
+
+	stats := track.GetStats()
+
+	if len(stats) != 3 {
+		t.Fatal("wrong encodings", stats)
+	}
+
+	if stats["b"].SimulcastIdx != 1 || stats["a"].SimulcastIdx != 2 || stats["c"].SimulcastIdx != -1 {
+		t.Fatal("wrong simulcast indexes", stats["a"].SimulcastIdx, stats["b"].SimulcastIdx, stats["c"].SimulcastIdx)
+	}
+
+	// each layer counts the lower layers it depends on
+	expected := map[[2]int]uint{{0, 0}: 100000, {0, 1}: 150000, {1, 0}: 250000}
+	for _, layer := range stats["a"].Media.Layers {
+		if layer.EncodingId != "a" || layer.SimulcastIdx != 2 || layer.Bitrate != expected[[2]int{layer.SpatialLayerId, layer.TemporalLayerId}] {
+			t.Fatal("wrong aggregated layer", *layer)
+		}
+	}
+
+	active := track.GetActiveLayers()
+	if len(active.Active) != 2 || len(active.Inactive) != 1 || active.Inactive[0].EncodingId != "c" {
+		t.Fatal("wrong active encodings", active)
+	}
+	// the three layers of a and the whole encoding b
+	if len(active.Layers) != 4 || active.Layers[3].EncodingId != "b" {
+		t.Fatal("wrong layers", active.Layers)
+	}
+}
+
+func Test_FakeTransponderSetTargetBitrate(t *testing.T) {
+
+	low := newFakeEncoding(1, 200000)
+	high := newFakeEncoding(2, 1000000)
+	incoming := newFakeIncomingStreamTrack("video", "video", map[string]*fakeEncoding{"low": low, "high": high})
+
+	outgoing, sender := newFakeOutgoingStreamTrack("video", "out")
+	transponder := outgoing.AttachTo(incoming)
+	fake := sender.last()
+
+	if !incoming.IsAttached() || fake.forwarding() != "high" {
+		t.Fatal("first encoding not forwarded", fake.forwarding())
+	}
+
+	if bitrate := transponder.SetTargetBitrate(500000, TraversalDefault, false); bitrate != 200000 || fake.forwarding() != "low" {
+		t.Fatal("wrong encoding for the bitrate", bitrate, fake.forwarding())
+	}
+
+	if bitrate := transponder.SetTargetBitrate(2000000, TraversalDefault, false); bitrate != 1000000 || fake.forwarding() != "high" {
+		t.Fatal("wrong encoding for the bitrate", bitrate, fake.forwarding())
+	}
+
+	// nothing fits, the lowest one is kept unless strict
+	if bitrate := transponder.SetTargetBitrate(100000, TraversalDefault, false); bitrate != 200000 || fake.forwarding() != "low" {
+		t.Fatal("lowest encoding not used", bitrate, fake.forwarding())
+	}
+
+	if bitrate := transponder.SetTargetBitrate(100000, TraversalDefault, true); bitrate != 0 || fake.forwarding() != "" {
+		t.Fatal("not muted when strict", bitrate, fake.forwarding())
+	}
+
+	outgoing.Stop()
+
+	if !fake.closed || !sender.deleted || incoming.IsAttached() {
+		t.Fatal("transponder and sender not released")
+	}
+}
+
+func Test_FakeTransponderUpswitchOnKeyFrame(t *testing.T) {
+
+	low := newFakeEncoding(1, 200000)
+	high := newFakeEncoding(2, 1000000)
+	incoming := newFakeIncomingStreamTrack("video", "video", map[string]*fakeEncoding{"low": low, "high": high})
+
+	outgoing, sender := newFakeOutgoingStreamTrack("video", "out")
+	transponder := outgoing.AttachTo(incoming)
+	fake := sender.last()
+
+	transponder.SetSwitchingPolicy(&SwitchingPolicy{DowngradeThreshold: 1, KeyFrameTimeout: time.Second})
+
+	transponder.SetTargetBitrate(500000, TraversalDefault, false)
+	if fake.forwarding() != "low" {
+		t.Fatal("wrong encoding", fake.forwarding())
+	}
+
+	// the upswitch waits for a key frame of the higher encoding
+	transponder.SetTargetBitrate(2000000, TraversalDefault, false)
+	if fake.forwarding() != "low" || high.waiting() != 1 {
+		t.Fatal("switched before the key frame", fake.forwarding(), high.waiting())
+	}
+
+	low.keyFrame()
+	if fake.forwarding() != "low" {
+		t.Fatal("switched on a key frame of another encoding")
+	}
+
+	high.keyFrame()
+	if fake.forwarding() != "high" || transponder.GetSelectedEncoding() != "high" {
+		t.Fatal("not switched on the key frame", fake.forwarding())
+	}
+
+	// a pending upswitch is dropped when the target goes down
+	transponder.SetTargetBitrate(500000, TraversalDefault, false)
+	transponder.SetTargetBitrate(2000000, TraversalDefault, false)
+	transponder.SetTargetBitrate(100000, TraversalDefault, true)
+	if high.waiting() != 0 {
+		t.Fatal("key frame still waited", high.waiting())
+	}
+
+	outgoing.Stop()
+}
+
+func Test_FakeRefresherKeyFrames(t *testing.T) {
+
+	var refreshes int32
+	refresher := newTestRefresher(context.Background(), time.Second, &refreshes)
+	defer refresher.Stop()
+
+	encoding := newFakeEncoding(1, 0)
+	track := newFakeIncomingStreamTrack("video", "video", map[string]*fakeEncoding{"": encoding})
+	refresher.AddWithPeriod(track, 20*time.Millisecond)
+
+	// every key frame counts, not only the first one
+	for i := 0; i < 10; i++ {
+		encoding.keyFrame()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&refreshes) != 0 {
+		t.Fatal("track refreshed despite recent key frames")
+	}
+
+	refresher.Remove(track)
+
+	if encoding.listening() != 0 {
+		t.Fatal("key frame listener not stopped")
+	}
+}
+
+func Test_FakeOutgoingStreamAttachTo(t *testing.T) {
+
+	incoming := &IncomingStream{id: "in", tracks: map[string]*IncomingStreamTrack{}}
+	for _, id := range []string{"video-2", "video-1", "audio-1"} {
+		media := "video"
+		if id == "audio-1" {
+			media = "audio"
+		}
+		incoming.tracks[id] = newFakeIncomingStreamTrack(media, id, map[string]*fakeEncoding{"": newFakeEncoding(1, 0)})
+	}
+
+	outgoing := &OutgoingStream{id: "out", tracks: map[string]*OutgoingStreamTrack{}}
+	senders := map[string]*fakeSender{}
+	for _, id := range []string{"b", "a", "c"} {
+		media := "video"
+		if id == "c" {
+			media = "audio"
+		}
+		track, sender := newFakeOutgoingStreamTrack(media, id)
+		outgoing.tracks[id] = track
+		senders[id] = sender
+	}
+
+	for i := 0; i < 10; i++ {
+
+		transponders := outgoing.AttachTo(incoming)
+		if len(transponders) != 3 {
+			t.Fatal("wrong transponders", len(transponders))
+		}
+
+		// paired in the order of the ids, on every call
+		expected := map[string]string{"a": "video-1", "b": "video-2", "c": "audio-1"}
+		for id, incomingId := range expected {
+			if attached := outgoing.GetTrack(id).GetTransponder().GetIncomingTrack(); attached == nil || attached.GetID() != incomingId {
+				t.Fatal("wrong pair", id, attached)
+			}
+		}
+	}
+
+	// the extra outgoing track is left alone
+	track, sender := newFakeOutgoingStreamTrack("video", "d")
+	outgoing.tracks["d"] = track
+	if len(outgoing.AttachTo(incoming)) != 3 || track.GetTransponder() != nil || sender.last() != nil {
+		t.Fatal("extra track attached")
+	}
+
+	outgoing.Detach()
+	for _, track := range incoming.GetTracks() {
+		if track.IsAttached() {
+			t.Fatal("track still attached", track.GetID())
+		}
+	}
+}
+
+func Test_FakeRecorder(t *testing.T) {
+
+	backend := &fakeRecorder{}
+	recorder := newRecorder("test.mp4", 0, backend)
+
+	track := newFakeIncomingStreamTrack("video", "video", map[string]*fakeEncoding{"a": newFakeEncoding(1, 0), "b": newFakeEncoding(2, 0)})
+
+	if err := recorder.Record(track); err != nil {
+		t.Fatal(err)
+	}
+
+	tracks := recorder.GetTracks()
+	if len(tracks) != 2 || len(backend.encodings) != 2 {
+		t.Fatal("wrong recorded tracks", len(tracks), len(backend.encodings))
+	}
+
+	ids := map[string]bool{}
+	for _, recorded := range tracks {
+		ids[recorded.GetID()] = true
+		if recorded.GetTrack() != track {
+			t.Fatal("wrong track")
+		}
+	}
+	if !ids["2"] || !ids["3"] {
+		t.Fatal("wrong ids", ids)
+	}
+
+	if err := recorder.Record(newFakeIncomingStreamTrack("video", "empty", nil)); !errors.Is(err, ErrInvalidTrack) {
+		t.Fatal("track without encoding recorded", err)
+	}
+
+	recorder.Stop()
+
+	if !backend.closed || recorder.IsRecording() || len(recorder.GetTracks()) != 0 || tracks[0].GetTrack() != nil {
+		t.Fatal("recorder not stopped")
+	}
+
+	if err := recorder.Record(track); !errors.Is(err, ErrStopped) {
+		t.Fatal("recorded once stopped", err)
+	}
+}
@@ -0,0 +1,357 @@
+package mediaserver
+
+import (
+	"sort"
+	"sync"
+)
+
+// BandwidthAllocator share the bandwidth estimation of a transport between its outgoing tracks.
+// Audio tracks are served first, then the pinned and speaker tracks, then the rest by priority.
+// Every track gets its minimum before any track is moved up, video tracks are moved up one layer at a time
+type BandwidthAllocator struct {
+	tracks    []*allocatedTrack
+	speaker   *OutgoingStreamTrack
+	estimate  uint
+	traversal BitrateTraversal
+	stopped   bool
+	lock      sync.Mutex
+}
+
+// allocatedTrack allocation settings and result of a track
+type allocatedTrack struct {
+	track      *OutgoingStreamTrack
+	audio      bool
+	priority   int
+	minBitrate uint
+	maxBitrate uint
+	pinned     bool
+	allocated  uint
+	// allowed layer bitrates, ascending
+	bitrates []uint
+}
+
+// newBandwidthAllocator create an allocator sharing the estimate, fed with SetEstimate
+func newBandwidthAllocator(estimate uint) *BandwidthAllocator {
+	return &BandwidthAllocator{
+		tracks:    make([]*allocatedTrack, 0),
+		estimate:  estimate,
+		traversal: TraversalDefault,
+	}
+}
+
+// AddTrack add a track with its priority, higher first, and bitrate limits, 0 means no limit
+func (a *BandwidthAllocator) AddTrack(track *OutgoingStreamTrack, priority int, minBitrate uint, maxBitrate uint) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.find(track) != nil {
+		return
+	}
+
+	a.tracks = append(a.tracks, &allocatedTrack{
+		track:      track,
+		audio:      track.GetMedia() == "audio",
+		priority:   priority,
+		minBitrate: minBitrate,
+		maxBitrate: maxBitrate,
+	})
+
+	a.allocate()
+}
+
+// RemoveTrack remove a track, its bandwidth is given to the others
+func (a *BandwidthAllocator) RemoveTrack(track *OutgoingStreamTrack) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for i, entry := range a.tracks {
+		if entry.track == track {
+			a.tracks = append(a.tracks[:i], a.tracks[i+1:]...)
+			break
+		}
+	}
+
+	if a.speaker == track {
+		a.speaker = nil
+	}
+
+	a.allocate()
+}
+
+// SetPriority set the priority of a track, higher first
+func (a *BandwidthAllocator) SetPriority(track *OutgoingStreamTrack, priority int) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if entry := a.find(track); entry != nil {
+		entry.priority = priority
+		a.allocate()
+	}
+}
+
+// SetBitrateLimits set the minimum and maximum bitrate of a track, 0 means no limit
+func (a *BandwidthAllocator) SetBitrateLimits(track *OutgoingStreamTrack, minBitrate uint, maxBitrate uint) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if entry := a.find(track); entry != nil {
+		entry.minBitrate = minBitrate
+		entry.maxBitrate = maxBitrate
+		a.allocate()
+	}
+}
+
+// Pin serve the track right after audio
+func (a *BandwidthAllocator) Pin(track *OutgoingStreamTrack, pinned bool) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if entry := a.find(track); entry != nil {
+		entry.pinned = pinned
+		a.allocate()
+	}
+}
+
+// SetSpeaker set the track of the active speaker, served along the pinned ones. nil to unset
+func (a *BandwidthAllocator) SetSpeaker(track *OutgoingStreamTrack) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.speaker == track {
+		return
+	}
+
+	a.speaker = track
+
+	a.allocate()
+}
+
+// SetTraversal set the layer traversal used on the transponders
+func (a *BandwidthAllocator) SetTraversal(traversal BitrateTraversal) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.traversal = traversal
+
+	a.allocate()
+}
+
+// SetEstimate set the available bitrate and reallocate, called on every transport estimation
+func (a *BandwidthAllocator) SetEstimate(bitrate uint) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.estimate = bitrate
+
+	a.allocate()
+}
+
+// GetEstimate get the bitrate being shared
+func (a *BandwidthAllocator) GetEstimate() uint {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.estimate
+}
+
+// GetAllocation get the bitrate given to the track
+func (a *BandwidthAllocator) GetAllocation(track *OutgoingStreamTrack) uint {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if entry := a.find(track); entry != nil {
+		return entry.allocated
+	}
+	return 0
+}
+
+// Allocate share the estimation again, use it when the layers of the tracks change
+func (a *BandwidthAllocator) Allocate() {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.allocate()
+}
+
+// Stop stop reallocating, the transponders keep their last target
+func (a *BandwidthAllocator) Stop() {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.stopped = true
+	a.tracks = nil
+	a.speaker = nil
+}
+
+func (a *BandwidthAllocator) find(track *OutgoingStreamTrack) *allocatedTrack {
+
+	for _, entry := range a.tracks {
+		if entry.track == track {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (a *BandwidthAllocator) allocate() {
+
+	if a.stopped || a.estimate == 0 {
+		return
+	}
+
+	entries := make([]*allocatedTrack, 0, len(a.tracks))
+
+	for _, entry := range a.tracks {
+
+		transponder := entry.track.GetTransponder()
+		// not forwarding anything
+		if transponder == nil {
+			entry.allocated = 0
+			continue
+		}
+
+		entry.bitrates = nil
+		if !entry.audio {
+			entry.bitrates = transponder.allowedBitrates()
+		}
+
+		entries = append(entries, entry)
+	}
+
+	distributeBitrate(a.estimate, entries, a.speaker)
+
+	for _, entry := range entries {
+		if entry.audio {
+			continue
+		}
+		// strict, a track without bandwidth is muted
+		entry.track.GetTransponder().SetTargetBitrate(entry.allocated, a.traversal, true)
+	}
+}
+
+// allocationGroup 0 for audio, 1 for pinned and speaker tracks, 2 for the rest
+func (entry *allocatedTrack) allocationGroup(speaker *OutgoingStreamTrack) int {
+
+	switch {
+	case entry.audio:
+		return 0
+	case entry.pinned || (speaker != nil && entry.track == speaker):
+		return 1
+	}
+	return 2
+}
+
+// minimum bitrate needed to forward anything
+func (entry *allocatedTrack) minimum() uint {
+
+	if entry.audio {
+		return entry.audioBitrate()
+	}
+
+	minimum := entry.minBitrate
+	if len(entry.bitrates) > 0 && entry.bitrates[0] > minimum {
+		minimum = entry.bitrates[0]
+	}
+	if entry.maxBitrate > 0 && minimum > entry.maxBitrate {
+		return 0
+	}
+	return minimum
+}
+
+func (entry *allocatedTrack) audioBitrate() uint {
+
+	if entry.maxBitrate > 0 {
+		return entry.maxBitrate
+	}
+	if entry.minBitrate > DefaultAudioBitrate {
+		return entry.minBitrate
+	}
+	return DefaultAudioBitrate
+}
+
+// next layer bitrate above the allocated one, 0 if none
+func (entry *allocatedTrack) next() uint {
+
+	for _, bitrate := range entry.bitrates {
+		if bitrate <= entry.allocated {
+			continue
+		}
+		if entry.maxBitrate > 0 && bitrate > entry.maxBitrate {
+			return 0
+		}
+		return bitrate
+	}
+	return 0
+}
+
+// distributeBitrate fill the allocated bitrate of the entries
+func distributeBitrate(estimate uint, entries []*allocatedTrack, speaker *OutgoingStreamTrack) {
+
+	ordered := make([]*allocatedTrack, len(entries))
+	copy(ordered, entries)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		gi := ordered[i].allocationGroup(speaker)
+		gj := ordered[j].allocationGroup(speaker)
+		if gi != gj {
+			return gi < gj
+		}
+		return ordered[i].priority > ordered[j].priority
+	})
+
+	remaining := estimate
+
+	// minimums first, in order
+	for _, entry := range ordered {
+
+		entry.allocated = 0
+
+		minimum := entry.minimum()
+		if minimum == 0 || minimum > remaining {
+			continue
+		}
+
+		entry.allocated = minimum
+		remaining -= minimum
+	}
+
+	// then move up one layer at a time, group by group
+	for start := 0; start < len(ordered); {
+
+		end := start
+		for end < len(ordered) && ordered[end].allocationGroup(speaker) == ordered[start].allocationGroup(speaker) {
+			end++
+		}
+
+		for upgraded := true; upgraded; {
+			upgraded = false
+			for _, entry := range ordered[start:end] {
+				// tracks without their minimum stay off
+				if entry.allocated == 0 {
+					continue
+				}
+				next := entry.next()
+				if next == 0 || next-entry.allocated > remaining {
+					continue
+				}
+				remaining -= next - entry.allocated
+				entry.allocated = next
+				upgraded = true
+			}
+		}
+
+		start = end
+	}
+}
@@ -0,0 +1,16 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+// NewBandwidthAllocator create an allocator fed by the sender side estimation of the transport
+func NewBandwidthAllocator(transport *Transport) *BandwidthAllocator {
+
+	allocator := newBandwidthAllocator(transport.GetTargetBitrate())
+
+	transport.OnTargetBitrate(func(bitrate uint) {
+		allocator.SetEstimate(bitrate)
+	})
+
+	return allocator
+}
@@ -0,0 +1,130 @@
+package mediaserver
+
+import (
+	"testing"
+)
+
+func newAllocatedVideo(priority int) *allocatedTrack {
+	return &allocatedTrack{
+		track:    &OutgoingStreamTrack{media: "video"},
+		priority: priority,
+		bitrates: []uint{150000, 500000, 1500000},
+	}
+}
+
+func Test_DistributeBitrateAudioFirst(t *testing.T) {
+
+	audio := &allocatedTrack{track: &OutgoingStreamTrack{media: "audio"}, audio: true}
+	video := newAllocatedVideo(10)
+
+	distributeBitrate(200000, []*allocatedTrack{video, audio}, nil)
+
+	if audio.allocated != DefaultAudioBitrate {
+		t.Fatal("audio not served first", audio.allocated)
+	}
+
+	// 136000 left, not enough for the lowest layer
+	if video.allocated != 0 {
+		t.Fatal("video should be off", video.allocated)
+	}
+}
+
+func Test_DistributeBitrateSpeakerAndPinned(t *testing.T) {
+
+	thumbnails := []*allocatedTrack{}
+	for i := 0; i < 6; i++ {
+		thumbnails = append(thumbnails, newAllocatedVideo(0))
+	}
+
+	screen := newAllocatedVideo(0)
+	screen.pinned = true
+
+	speaker := newAllocatedVideo(0)
+
+	entries := append([]*allocatedTrack{}, thumbnails...)
+	entries = append(entries, speaker, screen)
+
+	distributeBitrate(4000000, entries, speaker.track)
+
+	if screen.allocated != 1500000 || speaker.allocated != 1500000 {
+		t.Fatal("pinned and speaker should get the top layer", screen.allocated, speaker.allocated)
+	}
+
+	// 100000 left for the thumbnails, not enough to move any of them up
+	for _, thumbnail := range thumbnails {
+		if thumbnail.allocated != 150000 {
+			t.Fatal("thumbnail should get the lowest layer", thumbnail.allocated)
+		}
+	}
+}
+
+func Test_DistributeBitratePriorityAndLimits(t *testing.T) {
+
+	low := newAllocatedVideo(1)
+	high := newAllocatedVideo(5)
+	high.maxBitrate = 600000
+
+	distributeBitrate(2000000, []*allocatedTrack{low, high}, nil)
+
+	if high.allocated != 500000 {
+		t.Fatal("max bitrate not honoured", high.allocated)
+	}
+
+	if low.allocated != 1500000 {
+		t.Fatal("remaining bitrate not used", low.allocated)
+	}
+
+	// not enough for both minimums, priority wins
+	low.minBitrate = 300000
+	distributeBitrate(400000, []*allocatedTrack{low, high}, nil)
+
+	if high.allocated != 150000 || low.allocated != 0 {
+		t.Fatal("priority not honoured", high.allocated, low.allocated)
+	}
+}
+
+func Test_BandwidthAllocatorTransponders(t *testing.T) {
+
+	allocator := newBandwidthAllocator(2000000)
+	defer allocator.Stop()
+
+	audio, _ := newFakeOutgoingStreamTrack("audio", "audio")
+	audio.AttachTo(newFakeIncomingStreamTrack("audio", "audio", map[string]*fakeEncoding{"": newFakeEncoding(4, 30000)}))
+	defer audio.Stop()
+
+	speaker, speakerSender := newFakeOutgoingStreamTrack("video", "speaker")
+	speaker.AttachTo(newFakeSimulcastTrack())
+	defer speaker.Stop()
+
+	other, otherSender := newFakeOutgoingStreamTrack("video", "other")
+	other.AttachTo(newFakeSimulcastTrack())
+	defer other.Stop()
+
+	detached, _ := newFakeOutgoingStreamTrack("video", "detached")
+	defer detached.Stop()
+
+	allocator.AddTrack(audio, 0, 0, 0)
+	allocator.AddTrack(other, 0, 0, 0)
+	allocator.AddTrack(detached, 0, 0, 0)
+	allocator.AddTrack(speaker, 0, 0, 0)
+	allocator.SetSpeaker(speaker)
+
+	if allocator.GetAllocation(audio) != DefaultAudioBitrate || allocator.GetAllocation(detached) != 0 {
+		t.Fatal("wrong allocation", allocator.GetAllocation(audio), allocator.GetAllocation(detached))
+	}
+
+	// the speaker is moved up first, the rest goes to the other track
+	if speakerSender.last().forwarding() != "high" || otherSender.last().forwarding() != "mid" {
+		t.Fatal("wrong encodings", speakerSender.last().forwarding(), otherSender.last().forwarding())
+	}
+
+	// not enough for both, the other track is muted
+	allocator.SetEstimate(300000)
+
+	if allocator.GetAllocation(speaker) != 200000 || speakerSender.last().forwarding() != "low" {
+		t.Fatal("speaker not kept", allocator.GetAllocation(speaker), speakerSender.last().forwarding())
+	}
+	if allocator.GetAllocation(other) != 0 || otherSender.last().forwarding() != "" {
+		t.Fatal("track without bandwidth not muted", otherSender.last().forwarding())
+	}
+}
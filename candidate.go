@@ -0,0 +1,95 @@
+package mediaserver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/notedit/sdp"
+)
+
+// MDNSCandidatePolicy what to do with remote candidates hiding their address behind a mDNS ".local" name
+type MDNSCandidatePolicy int
+
+const (
+	// MDNSCandidateReject drop them, the peer is still reachable through its peer reflexive address
+	MDNSCandidateReject MDNSCandidatePolicy = iota
+	// MDNSCandidateDefer keep them pending until the name is resolved with Transport.ResolveMDNSCandidate
+	MDNSCandidateDefer
+)
+
+// ParseCandidate parse a trickled candidate, with or without the "a=" prefix
+// candidate:<foundation> <component> <transport> <priority> <address> <port> typ <type> [raddr <address> rport <port>] ...
+func ParseCandidate(candidate string) (*sdp.CandidateInfo, error) {
+
+	line := strings.TrimSpace(candidate)
+	line = strings.TrimPrefix(line, "a=")
+
+	if !strings.HasPrefix(line, "candidate:") {
+		return nil, fmt.Errorf("invalid candidate %q", candidate)
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "candidate:"))
+
+	if len(fields) < 8 || fields[6] != "typ" {
+		return nil, fmt.Errorf("invalid candidate %q", candidate)
+	}
+
+	component, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid candidate component %q", fields[1])
+	}
+
+	priority, err := strconv.ParseUint(fields[3], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid candidate priority %q", fields[3])
+	}
+
+	port, err := strconv.Atoi(fields[5])
+	if err != nil || port < 0 || port > 0xFFFF {
+		return nil, fmt.Errorf("invalid candidate port %q", fields[5])
+	}
+
+	relAddr := ""
+	relPort := 0
+
+	// extensions come as key value pairs
+	for i := 8; i+1 < len(fields); i += 2 {
+		switch fields[i] {
+		case "raddr":
+			relAddr = fields[i+1]
+		case "rport":
+			relPort, err = strconv.Atoi(fields[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid candidate rport %q", fields[i+1])
+			}
+		}
+	}
+
+	return sdp.NewCandidateInfo(fields[0], component, fields[2], int(priority), fields[4], port, fields[7], relAddr, relPort), nil
+}
+
+// isMDNSCandidate check if the candidate address is a mDNS name
+func isMDNSCandidate(candidate *sdp.CandidateInfo) bool {
+	return strings.HasSuffix(strings.ToLower(candidate.GetAddress()), ".local")
+}
+
+// checkRemoteCandidate filter the candidates an ICE-lite server can not use.
+// We never open TCP or reach a TURN server, so we only answer UDP checks coming from host or reflexive addresses
+func checkRemoteCandidate(candidate *sdp.CandidateInfo) error {
+
+	if !strings.EqualFold(candidate.GetTransport(), "udp") {
+		return fmt.Errorf("unsupported candidate transport %s", candidate.GetTransport())
+	}
+
+	if candidate.GetType() == "relay" {
+		return errors.New("relay candidates are not supported")
+	}
+
+	if candidate.GetComponentID() != 1 {
+		return fmt.Errorf("unsupported candidate component %d, rtcp-mux is required", candidate.GetComponentID())
+	}
+
+	return nil
+}
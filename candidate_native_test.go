@@ -0,0 +1,68 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"testing"
+
+	"github.com/notedit/sdp"
+)
+
+func Test_TransportAddRemoteCandidateString(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	sdpInfo := sdp.NewSDPInfo()
+	sdpInfo.SetICE(sdp.ICEInfoGenerate(true))
+	sdpInfo.SetDTLS(sdp.NewDTLSInfo(sdp.SETUPACTPASS, "sha-256", "F2:AA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F"))
+
+	transport := endpoint.CreateTransport(sdpInfo, nil)
+	defer transport.Stop()
+
+	if err := transport.AddRemoteCandidateString("candidate:1 1 udp 2122260223 127.0.0.1 50000 typ host generation 0"); err != nil {
+		t.Error(err)
+	}
+
+	refused := []string{
+		"candidate:2 1 tcp 1518280447 127.0.0.1 9 typ host tcptype active",
+		"candidate:3 1 udp 41885439 198.51.100.1 3478 typ relay raddr 203.0.113.7 rport 61665",
+		"candidate:4 2 udp 2122260222 127.0.0.1 50001 typ host",
+		"candidate:5 1 udp 2122260223 5d8f6e2c-1d3b-4c7a.local 50002 typ host",
+	}
+
+	for _, line := range refused {
+		if err := transport.AddRemoteCandidateString(line); err == nil {
+			t.Errorf("%q should be refused", line)
+		}
+	}
+
+	if len(transport.GetRemoteCandidates()) != 1 {
+		t.Error("only the udp host candidate should be added")
+	}
+
+	transport.SetMDNSCandidatePolicy(MDNSCandidateDefer)
+
+	if err := transport.AddRemoteCandidateString("candidate:5 1 udp 2122260223 5d8f6e2c-1d3b-4c7a.local 50002 typ host"); err != nil {
+		t.Error(err)
+	}
+
+	if len(transport.GetPendingRemoteCandidates()) != 1 {
+		t.Fatal("mdns candidate should be deferred")
+	}
+
+	if err := transport.ResolveMDNSCandidate("5d8f6e2c-1d3b-4c7a.local", "127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+
+	if len(transport.GetPendingRemoteCandidates()) != 0 || len(transport.GetRemoteCandidates()) != 2 {
+		t.Error("resolved mdns candidate should be added")
+	}
+
+	transport.AddRemoteCandidateString("a=end-of-candidates")
+
+	if !transport.IsRemoteEndOfCandidates() {
+		t.Error("end of candidates should be signalled")
+	}
+}
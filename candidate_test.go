@@ -0,0 +1,27 @@
+package mediaserver
+
+import (
+	"testing"
+)
+
+func Test_ParseCandidate(t *testing.T) {
+
+	candidate, err := ParseCandidate("a=candidate:842163049 1 udp 1677729535 203.0.113.7 61665 typ srflx raddr 192.168.1.10 rport 61665 generation 0 ufrag EsAw network-cost 999")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if candidate.GetAddress() != "203.0.113.7" || candidate.GetPort() != 61665 || candidate.GetType() != "srflx" {
+		t.Error("candidate address mismatch")
+	}
+
+	if candidate.GetRelAddr() != "192.168.1.10" || candidate.GetRelPort() != 61665 {
+		t.Error("candidate related address mismatch")
+	}
+
+	for _, line := range []string{"", "candidate:1 1 udp", "candidate:1 1 udp 2122260223 10.0.0.1 port typ host"} {
+		if _, err := ParseCandidate(line); err == nil {
+			t.Errorf("%q should not parse", line)
+		}
+	}
+}
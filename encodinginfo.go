@@ -0,0 +1,157 @@
+package mediaserver
+
+import (
+	"sync"
+	"time"
+)
+
+const videoClockRate = 90000
+
+// video codecs returned by native.MediaFrameGetVideoCodec
+const (
+	videoCodecH264 = 1
+	videoCodecVP8  = 2
+	videoCodecVP9  = 3
+)
+
+// encodingInfo what is known of an encoding from its frames, zero values when unknown
+type encodingInfo struct {
+	width            int
+	height           int
+	frameRate        float64
+	keyFrameInterval time.Duration
+	// spatialSizes size of each vp9 spatial layer
+	spatialSizes [][2]int
+}
+
+// layerInfo the info of a layer of the encoding, temporal layers have a fraction of the encoding frame rate
+func (e encodingInfo) layerInfo(spatialLayerId int, temporalLayerId int, maxTemporalLayerId int) (int, int, float64) {
+
+	width, height := e.width, e.height
+	if spatialLayerId != MaxLayerId && spatialLayerId >= 0 && spatialLayerId < len(e.spatialSizes) {
+		width, height = e.spatialSizes[spatialLayerId][0], e.spatialSizes[spatialLayerId][1]
+	}
+
+	frameRate := e.frameRate
+	if temporalLayerId != MaxLayerId && temporalLayerId >= 0 && temporalLayerId < maxTemporalLayerId {
+		frameRate = frameRate / float64(uint(1)<<uint(maxTemporalLayerId-temporalLayerId))
+	}
+
+	return width, height, frameRate
+}
+
+// encodingInfoTracker parse the frames of an encoding as they flow
+type encodingInfoTracker struct {
+	info encodingInfo
+	lock sync.Mutex
+
+	started      bool
+	lastTs       uint32
+	windowStart  uint32
+	windowFrames int
+	hasKeyFrame  bool
+	lastKeyFrame uint32
+	// vp9Refs the reference sizes of the last key picture, for its layer frames sent apart
+	vp9Refs vp9References
+
+	listener stopper
+}
+
+func newEncodingInfoTracker(encoding *Encoding) *encodingInfoTracker {
+
+	tracker := &encodingInfoTracker{}
+	tracker.listener = encoding.backend.listen(listenFrames, func(frame mediaFrame) {
+		tracker.onFrame(frame.codec, frame.timestamp, frame.keyFrame, frame.data)
+	})
+
+	return tracker
+}
+
+func (t *encodingInfoTracker) getInfo() encodingInfo {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.info
+}
+
+// onFrame account a frame, ts in the 90khz video clock
+func (t *encodingInfoTracker) onFrame(codec int, ts uint32, keyFrame bool, data []byte) {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// frames of the same picture, like vp9 spatial layers, the layers of a key picture are sized
+	if t.started && ts == t.lastTs {
+		if codec == videoCodecVP9 && t.hasKeyFrame && ts == t.lastKeyFrame {
+			t.addVP9Layers(data)
+		}
+		return
+	}
+
+	if !t.started || ts-t.windowStart > 10*videoClockRate {
+		// first frame, or a gap, like a paused encoding
+		t.started = true
+		t.windowStart = ts
+		t.windowFrames = 0
+		t.hasKeyFrame = false
+	}
+
+	t.lastTs = ts
+	t.windowFrames++
+
+	if elapsed := ts - t.windowStart; elapsed >= videoClockRate {
+		t.info.frameRate = float64(t.windowFrames-1) * videoClockRate / float64(elapsed)
+		t.windowStart = ts
+		t.windowFrames = 1
+	}
+
+	if !keyFrame {
+		return
+	}
+
+	if t.hasKeyFrame {
+		t.info.keyFrameInterval = time.Duration(ts-t.lastKeyFrame) * time.Second / videoClockRate
+	}
+	t.hasKeyFrame = true
+	t.lastKeyFrame = ts
+
+	switch codec {
+	case videoCodecH264:
+		if width, height, err := parseH264FrameSize(data); err == nil {
+			t.info.width, t.info.height = width, height
+		}
+	case videoCodecVP8:
+		if width, height, err := parseVP8FrameSize(data); err == nil {
+			t.info.width, t.info.height = width, height
+		}
+	case videoCodecVP9:
+		t.vp9Refs = vp9References{}
+		if sizes, err := parseVP9FrameSizes(data, &t.vp9Refs); err == nil {
+			t.info.spatialSizes = sizes
+			t.info.width, t.info.height = sizes[len(sizes)-1][0], sizes[len(sizes)-1][1]
+		}
+	}
+}
+
+// addVP9Layers size the upper layers of the key picture, the base layer was parsed first
+func (t *encodingInfoTracker) addVP9Layers(data []byte) {
+
+	if !t.vp9Refs.keyFrame {
+		return
+	}
+
+	sizes, err := parseVP9FrameSizes(data, &t.vp9Refs)
+	if err != nil {
+		return
+	}
+
+	// the info given out keeps the previous slice
+	t.info.spatialSizes = append(append([][2]int{}, t.info.spatialSizes...), sizes...)
+	t.info.width, t.info.height = sizes[len(sizes)-1][0], sizes[len(sizes)-1][1]
+}
+
+// Stop stop listening
+func (t *encodingInfoTracker) Stop() {
+	if t.listener != nil {
+		t.listener.Stop()
+	}
+}
@@ -1,7 +1,11 @@
+//go:build cgo
+// +build cgo
+
 package mediaserver
 
 import (
 	"sync"
+	"time"
 
 	native "github.com/notedit/media-server-go/wrapper"
 	"github.com/notedit/sdp"
@@ -9,7 +13,7 @@ import (
 
 // Endpoint is an endpoint represent an UDP server socket.
 // The endpoint will process STUN requests in order to be able to associate the remote ip:port with the registered transport and forward any further data comming from that transport.
-// Being a server it is ICE-lite.
+// Being a server it is ICE-lite. It is safe for concurrent use.
 type Endpoint struct {
 	ip              string
 	bundle          native.RTPBundleTransport
@@ -17,40 +21,127 @@ type Endpoint struct {
 	mirroredStreams map[string]*IncomingStream
 	mirroredTracks  map[string]*IncomingStreamTrack
 	fingerprint     string
+	transports      map[*Transport]bool
+	stopped         bool
+	// Mutex guards the fields above, it is never held while calling the transports
 	sync.Mutex
+	// nativeLock held for reading while using the bundle, for writing by Stop while deleting it
+	nativeLock sync.RWMutex
+}
+
+type endpointOptions struct {
+	port       int
+	iceTimeout time.Duration
+}
+
+// EndpointOption configure an Endpoint, see NewEndpointWithOptions
+type EndpointOption func(*endpointOptions)
+
+// WithEndpointPort listen on the port instead of a random one
+func WithEndpointPort(port int) EndpointOption {
+	return func(o *endpointOptions) {
+		o.port = port
+	}
+}
+
+// WithIceTimeout ice timeout of all the transports of the endpoint, see Endpoint.SetIceTimeout
+func WithIceTimeout(timeout time.Duration) EndpointOption {
+	return func(o *endpointOptions) {
+		o.iceTimeout = timeout
+	}
 }
 
 // NewEndpoint create a new endpoint with given ip
 func NewEndpoint(ip string) *Endpoint {
-	endpoint := &Endpoint{}
-	endpoint.bundle = native.NewRTPBundleTransport()
-	endpoint.bundle.Init()
-	endpoint.fingerprint = native.MediaServerGetFingerprint()
-	endpoint.mirroredStreams = make(map[string]*IncomingStream)
-	endpoint.mirroredTracks = make(map[string]*IncomingStreamTrack)
-	endpoint.candidate = sdp.NewCandidateInfo("1", 1, "UDP", 33554431, ip, endpoint.bundle.GetLocalPort(), "host", "", 0)
-	return endpoint
+	return NewEndpointWithOptions(ip)
 }
 
 // NewEndpointWithPort create a new endpint with given ip and port
 func NewEndpointWithPort(ip string, port int) *Endpoint {
+	return NewEndpointWithOptions(ip, WithEndpointPort(port))
+}
+
+// NewEndpointWithOptions create a new endpoint with given ip, like NewEndpointWithOptions(ip, WithEndpointPort(50000))
+func NewEndpointWithOptions(ip string, options ...EndpointOption) *Endpoint {
+
+	opts := &endpointOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
 	endpoint := &Endpoint{}
 	endpoint.bundle = native.NewRTPBundleTransport()
-	endpoint.bundle.Init(port)
+	trackNative("RTPBundleTransport", endpoint.bundle)
+	if opts.port > 0 {
+		endpoint.bundle.Init(opts.port)
+	} else {
+		endpoint.bundle.Init()
+	}
+	if opts.iceTimeout > 0 {
+		endpoint.bundle.SetIceTimeout(uint(opts.iceTimeout / time.Millisecond))
+	}
 	endpoint.fingerprint = native.MediaServerGetFingerprint()
+	endpoint.mirroredStreams = make(map[string]*IncomingStream)
+	endpoint.mirroredTracks = make(map[string]*IncomingStreamTrack)
 	endpoint.candidate = sdp.NewCandidateInfo("1", 1, "UDP", 33554431, ip, endpoint.bundle.GetLocalPort(), "host", "", 0)
 	return endpoint
 }
 
 //SetAffinity Set cpu affinity
 func (e *Endpoint) SetAffinity(cpu int) {
-	e.bundle.SetAffinity(cpu)
+	e.nativeLock.RLock()
+	defer e.nativeLock.RUnlock()
+	if e.bundle != nil {
+		e.bundle.SetAffinity(cpu)
+	}
+}
+
+// SetIceTimeout ice timeout of all the transports of the endpoint
+func (e *Endpoint) SetIceTimeout(timeout time.Duration) {
+	e.nativeLock.RLock()
+	defer e.nativeLock.RUnlock()
+	if e.bundle != nil {
+		e.bundle.SetIceTimeout(uint(timeout / time.Millisecond))
+	}
 }
 
 // CreateTransport create a new transport object and register it with the remote ICE username and password
-// disableSTUNKeepAlive - Disable ICE/STUN keep alives, required for server to server transports, set this to false if you do not how to use it
+// disableSTUNKeepAlive - Disable ICE/STUN keep alives, required for server to server transports, set this to false if you do not how to use it.
+// See CreateTransportWithOptions for the other transport options and the errors
 func (e *Endpoint) CreateTransport(remoteSdp *sdp.SDPInfo, localSdp *sdp.SDPInfo, options ...bool) *Transport {
 
+	var transportOptions []TransportOption
+	if len(options) > 0 {
+		transportOptions = append(transportOptions, WithDisableSTUNKeepAlive(options[0]))
+	}
+
+	transport, err := e.CreateTransportWithOptions(remoteSdp, localSdp, transportOptions...)
+	if err != nil {
+		componentLogger("endpoint").Error("can not create transport", "error", err)
+		return nil
+	}
+	return transport
+}
+
+// CreateTransportWithOptions create a new transport object and register it with the remote ICE username and password,
+// like CreateTransportWithOptions(offer, nil, WithBandwidthProbing(true), WithMaxProbingBitrate(1000000)).
+// ErrInvalidSDP when the remote or local sdp has no ice or dtls info,
+// ErrInvalidArgument when an option is wrong and ErrNative when the dump can not be started
+func (e *Endpoint) CreateTransportWithOptions(remoteSdp *sdp.SDPInfo, localSdp *sdp.SDPInfo, options ...TransportOption) (*Transport, error) {
+
+	opts, err := newTransportOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if remoteSdp == nil || remoteSdp.GetICE() == nil || remoteSdp.GetDTLS() == nil {
+		return nil, newError(ErrInvalidSDP, "Remote sdp without ice or dtls info")
+	}
+
+	if localSdp != nil && (localSdp.GetICE() == nil || localSdp.GetDTLS() == nil) {
+		return nil, newError(ErrInvalidSDP, "Local sdp without ice or dtls info")
+	}
+
 	var localIce *sdp.ICEInfo
 	var localDtls *sdp.DTLSInfo
 	var localCandidates []*sdp.CandidateInfo
@@ -69,19 +160,64 @@ func (e *Endpoint) CreateTransport(remoteSdp *sdp.SDPInfo, localSdp *sdp.SDPInfo
 	remoteDtls := remoteSdp.GetDTLS().Clone()
 	remoteCandidates := remoteSdp.GetCandidates()
 
+	// Stop waits for the transports being created before stopping them
+	e.nativeLock.RLock()
+	defer e.nativeLock.RUnlock()
+
+	if e.isStopped() {
+		return nil, newError(ErrStopped, "Endpoint is stopped")
+	}
+
 	localIce.SetLite(true)
 	localIce.SetEndOfCandidate(true)
 
-	disableSTUNKeepAlive := false
+	transport, err := newTransport(e.bundle, remoteIce, remoteDtls, remoteCandidates,
+		localIce, localDtls, localCandidates, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	if len(options) > 0 {
-		disableSTUNKeepAlive = options[0]
+	// removed by the transport Stop
+	transport.endpoint = e
+
+	e.Lock()
+	if e.transports == nil {
+		e.transports = make(map[*Transport]bool)
 	}
+	e.transports[transport] = true
+	e.Unlock()
 
-	transport := NewTransport(e.bundle, remoteIce, remoteDtls, remoteCandidates,
-		localIce, localDtls, localCandidates, disableSTUNKeepAlive)
+	return transport, nil
+}
 
-	return transport
+// removeTransport the transport is stopping, called with nativeLock held for reading
+func (e *Endpoint) removeTransport(transport *Transport) {
+	e.Lock()
+	delete(e.transports, transport)
+	e.Unlock()
+}
+
+// isStopped check if Stop has been called
+func (e *Endpoint) isStopped() bool {
+	e.Lock()
+	defer e.Unlock()
+	return e.stopped
+}
+
+// GetTransports get the transports created by this endpoint and not stopped yet
+func (e *Endpoint) GetTransports() []*Transport {
+	e.Lock()
+	defer e.Unlock()
+	transports := []*Transport{}
+	for transport := range e.transports {
+		transports = append(transports, transport)
+	}
+	return transports
+}
+
+// GetLocalPort get the local port of the UDP server socket
+func (e *Endpoint) GetLocalPort() int {
+	return e.candidate.GetPort()
 }
 
 // GetLocalCandidates Get local ICE candidates for this endpoint. It will be shared by all the transport associated to this endpoint.
@@ -117,18 +253,53 @@ func (e *Endpoint) CreateOffer(video *sdp.Capability, audio *sdp.Capability) *sd
 	return sdp.Create(ice, dtls, candidates, capabilities)
 }
 
+// Answer create a transport for the remote offer and answer it with the given capabilities, keyed by media type.
+// Codecs, header extensions, rtcp-fb and simulcast are negotiated and the offered streams are created.
+// Later offers can be answered with Transport.Answer, ErrInvalidSDP when the offer can not be answered
+func (e *Endpoint) Answer(remoteSdp *sdp.SDPInfo, capabilities map[string]*sdp.Capability) (*Transport, *sdp.SDPInfo, error) {
+
+	transport, err := e.CreateTransportWithOptions(remoteSdp, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transport.SetCapabilities(capabilities)
+
+	answer, err := transport.Answer(remoteSdp)
+	if err != nil {
+		transport.Stop()
+		return nil, nil, err
+	}
+
+	return transport, answer, nil
+}
 
 // Stop stop the endpoint UDP server and terminate any associated transport
 func (e *Endpoint) Stop() {
 
-	if e.bundle == nil {
+	e.Lock()
+	if e.stopped {
+		e.Unlock()
 		return
 	}
+	e.stopped = true
+	e.Unlock()
+
+	// wait for the transports being created
+	e.nativeLock.Lock()
+	e.nativeLock.Unlock()
+
+	for _, transport := range e.GetTransports() {
+		transport.Stop()
+	}
+
+	e.nativeLock.Lock()
+	defer e.nativeLock.Unlock()
 
 	e.bundle.End()
 
+	untrackNative(e.bundle)
 	native.DeleteRTPBundleTransport(e.bundle)
 
 	e.bundle = nil
-
 }
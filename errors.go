@@ -0,0 +1,55 @@
+package mediaserver
+
+import (
+	"errors"
+)
+
+// Sentinel errors of the error returning API, test them with errors.Is.
+// The options-based constructors return the error, and the Create methods returning nil on failure
+// have a New counterpart returning it, like Transport.NewIncomingStream for Transport.CreateIncomingStream
+var (
+	// ErrStopped the object, or the one it belongs to, is stopped
+	ErrStopped = errors.New("stopped")
+	// ErrDuplicateTrack a track with the same id already exists
+	ErrDuplicateTrack = errors.New("duplicate track")
+	// ErrDuplicateStream a stream with the same id already exists
+	ErrDuplicateStream = errors.New("duplicate stream")
+	// ErrInvalidSDP the sdp, or the stream, track or media info taken from it, is missing or malformed
+	ErrInvalidSDP = errors.New("invalid sdp")
+	// ErrInvalidTrack the track can not be used, like a track without encoding
+	ErrInvalidTrack = errors.New("invalid track")
+	// ErrEncodingNotFound no encoding with this id in the track
+	ErrEncodingNotFound = errors.New("encoding not found")
+	// ErrInvalidArgument an argument is nil or out of range
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrNative the native media server failed
+	ErrNative = errors.New("native media server error")
+)
+
+// mediaError an error matching a sentinel error, and the error causing it if any
+type mediaError struct {
+	sentinel error
+	cause    error
+	msg      string
+}
+
+func (e *mediaError) Error() string {
+	return e.msg
+}
+
+func (e *mediaError) Unwrap() []error {
+	if e.cause != nil {
+		return []error{e.sentinel, e.cause}
+	}
+	return []error{e.sentinel}
+}
+
+// newError an error with its own message matching the sentinel
+func newError(sentinel error, msg string) error {
+	return &mediaError{sentinel: sentinel, msg: msg}
+}
+
+// wrapError the cause, also matching the sentinel
+func wrapError(sentinel error, msg string, cause error) error {
+	return &mediaError{sentinel: sentinel, cause: cause, msg: msg + ": " + cause.Error()}
+}
@@ -0,0 +1,39 @@
+package mediaserver
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/notedit/sdp"
+)
+
+func Test_SentinelErrors(t *testing.T) {
+
+	err := newError(ErrStopped, "track is stopped")
+	if !errors.Is(err, ErrStopped) || errors.Is(err, ErrInvalidSDP) {
+		t.Fatal("wrong sentinel", err)
+	}
+	if err.Error() != "track is stopped" {
+		t.Fatal("wrong message", err)
+	}
+
+	_, cause := strconv.ParseUint("abc", 10, 32)
+	err = wrapError(ErrInvalidSDP, "invalid ssrc", cause)
+	if !errors.Is(err, ErrInvalidSDP) || !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatal("wrong wrapped error", err)
+	}
+}
+
+func Test_ValidateIncomingTrackInfo(t *testing.T) {
+
+	track := sdp.NewTrackInfo("video", "video")
+	if err := validateIncomingTrackInfo(track); !errors.Is(err, ErrInvalidSDP) {
+		t.Fatal("track without ssrc", err)
+	}
+
+	track.AddSSRC(1234)
+	if err := validateIncomingTrackInfo(track); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,428 @@
+package mediaserver
+
+import (
+	"sync"
+
+	"github.com/notedit/sdp"
+)
+
+// fakeEncoding in-memory encodingBackend, the tests set its stats and fire its frames
+type fakeEncoding struct {
+	l         sync.Mutex
+	mediaSsrc uint
+	current   *IncomingAllStats
+	updates   int
+	listeners []*fakeFrameListener
+	deleted   bool
+}
+
+type fakeFrameListener struct {
+	encoding *fakeEncoding
+	mode     frameListenerMode
+	onFrame  func(frame mediaFrame)
+	stopped  bool
+}
+
+func newFakeEncoding(ssrc uint, bitrate uint, layers ...*Layer) *fakeEncoding {
+	encoding := &fakeEncoding{mediaSsrc: ssrc}
+	encoding.setStats(bitrate, layers...)
+	return encoding
+}
+
+// setStats the media bitrate and its individual layers
+func (f *fakeEncoding) setStats(bitrate uint, layers ...*Layer) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.current = &IncomingAllStats{
+		Media: &IncomingStats{Bitrate: bitrate, Layers: layers},
+		Rtx:   &IncomingStats{Layers: []*Layer{}},
+		Fec:   &IncomingStats{Layers: []*Layer{}},
+	}
+}
+
+func (f *fakeEncoding) update() {
+	f.l.Lock()
+	f.updates++
+	f.l.Unlock()
+}
+
+func (f *fakeEncoding) stats() *IncomingAllStats {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return f.current.clone()
+}
+
+func (f *fakeEncoding) ssrc() uint {
+	return f.mediaSsrc
+}
+
+func (f *fakeEncoding) listen(mode frameListenerMode, onFrame func(frame mediaFrame)) stopper {
+	f.l.Lock()
+	defer f.l.Unlock()
+	listener := &fakeFrameListener{encoding: f, mode: mode, onFrame: onFrame}
+	f.listeners = append(f.listeners, listener)
+	return listener
+}
+
+// frame call the listeners of the frame, from the test goroutine
+func (f *fakeEncoding) frame(frame mediaFrame) {
+
+	f.l.Lock()
+	listeners := []*fakeFrameListener{}
+	kept := f.listeners[:0]
+	for _, listener := range f.listeners {
+		if listener.stopped {
+			continue
+		}
+		if listener.mode == listenFrames || frame.keyFrame {
+			listeners = append(listeners, listener)
+		}
+		if listener.mode == listenKeyFrameOnce && frame.keyFrame {
+			listener.stopped = true
+			continue
+		}
+		kept = append(kept, listener)
+	}
+	f.listeners = kept
+	f.l.Unlock()
+
+	for _, listener := range listeners {
+		listener.onFrame(frame)
+	}
+}
+
+// keyFrame a key frame received on the encoding
+func (f *fakeEncoding) keyFrame() {
+	f.frame(mediaFrame{keyFrame: true})
+}
+
+// waiting the one shot listeners neither fired nor stopped
+func (f *fakeEncoding) waiting() int {
+	f.l.Lock()
+	defer f.l.Unlock()
+	waiting := 0
+	for _, listener := range f.listeners {
+		if listener.mode == listenKeyFrameOnce && !listener.stopped {
+			waiting++
+		}
+	}
+	return waiting
+}
+
+// listening the listeners not stopped
+func (f *fakeEncoding) listening() int {
+	f.l.Lock()
+	defer f.l.Unlock()
+	listening := 0
+	for _, listener := range f.listeners {
+		if !listener.stopped {
+			listening++
+		}
+	}
+	return listening
+}
+
+func (f *fakeEncoding) delete() {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.deleted = true
+}
+
+func (l *fakeFrameListener) Stop() {
+	l.encoding.l.Lock()
+	defer l.encoding.l.Unlock()
+	l.stopped = true
+}
+
+// fakeTransponder in-memory transponderBackend remembering what it forwards
+type fakeTransponder struct {
+	l               sync.Mutex
+	encoding        *Encoding
+	spatialLayerId  int
+	temporalLayerId int
+	muted           bool
+	closed          bool
+}
+
+func (f *fakeTransponder) setIncoming(encoding *Encoding, receiver receiverBackend) bool {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.encoding = encoding
+	return true
+}
+
+func (f *fakeTransponder) selectLayer(spatialLayerId, temporalLayerId int) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.spatialLayerId = spatialLayerId
+	f.temporalLayerId = temporalLayerId
+}
+
+func (f *fakeTransponder) mute(muting bool) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.muted = muting
+}
+
+func (f *fakeTransponder) close() {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.closed = true
+}
+
+// forwarding the encoding forwarded, empty when none or muted
+func (f *fakeTransponder) forwarding() string {
+	f.l.Lock()
+	defer f.l.Unlock()
+	if f.encoding == nil || f.muted || f.closed {
+		return ""
+	}
+	return f.encoding.GetID()
+}
+
+// selectedLayers the spatial and temporal layers forwarded
+func (f *fakeTransponder) selectedLayers() (int, int) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return f.spatialLayerId, f.temporalLayerId
+}
+
+// fakeSender in-memory senderBackend keeping the transponders it created
+type fakeSender struct {
+	l            sync.Mutex
+	transponders []*fakeTransponder
+	deleted      bool
+}
+
+func (f *fakeSender) newTransponder(source outgoingSourceBackend) transponderBackend {
+	f.l.Lock()
+	defer f.l.Unlock()
+	transponder := &fakeTransponder{spatialLayerId: MaxLayerId, temporalLayerId: MaxLayerId}
+	f.transponders = append(f.transponders, transponder)
+	return transponder
+}
+
+func (f *fakeSender) delete() {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.deleted = true
+}
+
+// last the transponder created last
+func (f *fakeSender) last() *fakeTransponder {
+	f.l.Lock()
+	defer f.l.Unlock()
+	if len(f.transponders) == 0 {
+		return nil
+	}
+	return f.transponders[len(f.transponders)-1]
+}
+
+// fakeRecorder in-memory recorderBackend
+type fakeRecorder struct {
+	l         sync.Mutex
+	filename  string
+	recording bool
+	encodings []*Encoding
+	closed    bool
+	fail      bool
+}
+
+func (f *fakeRecorder) create(filename string) bool {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.filename = filename
+	return !f.fail
+}
+
+func (f *fakeRecorder) record(waitForIntra bool) bool {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.recording = !f.fail
+	return !f.fail
+}
+
+func (f *fakeRecorder) addEncoding(encoding *Encoding) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.encodings = append(f.encodings, encoding)
+}
+
+func (f *fakeRecorder) close() {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.recording = false
+	f.closed = true
+}
+
+// fakeReceiver in-memory receiverBackend counting the feedback sent upstream
+type fakeReceiver struct {
+	l        sync.Mutex
+	plis     int
+	firs     int
+	bitrates map[uint]uint
+	deleted  bool
+}
+
+func (f *fakeReceiver) sendPLI(ssrc uint) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.plis++
+}
+
+func (f *fakeReceiver) sendFIR(ssrc uint, seq byte) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.firs++
+}
+
+func (f *fakeReceiver) sendMaxBitrate(ssrc uint, bitrate uint, tmmbr bool) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	if f.bitrates == nil {
+		f.bitrates = map[uint]uint{}
+	}
+	f.bitrates[ssrc] = bitrate
+}
+
+func (f *fakeReceiver) delete() {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.deleted = true
+}
+
+// newFakeIncomingStreamTrack a track over fake encodings, its frames are not parsed
+func newFakeIncomingStreamTrack(media string, id string, encodings map[string]*fakeEncoding) *IncomingStreamTrack {
+
+	trackEncodings := []*Encoding{}
+	for encodingId, backend := range encodings {
+		trackEncodings = append(trackEncodings, &Encoding{id: encodingId, backend: backend})
+	}
+
+	track := newBackendIncomingStreamTrack(media, id, &fakeReceiver{}, trackEncodings)
+
+	// no native frames to parse
+	track.encodingInfosOnce.Do(func() {
+		track.encodingInfos = map[string]*encodingInfoTracker{}
+	})
+
+	return track
+}
+
+// setFakeEncodingInfo the size and frame rate of an encoding, as if parsed from its frames
+func setFakeEncodingInfo(track *IncomingStreamTrack, encodingId string, width int, height int, frameRate float64) {
+	track.getEncodingInfos()[encodingId] = &encodingInfoTracker{info: encodingInfo{width: width, height: height, frameRate: frameRate}}
+}
+
+// newFakeOutgoingStreamTrack a track sending through a fake sender
+func newFakeOutgoingStreamTrack(media string, id string) (*OutgoingStreamTrack, *fakeSender) {
+	sender := &fakeSender{}
+	return newBackendOutgoingStreamTrack(media, id, sender), sender
+}
+
+// fakeTransport in-memory transportBackend, the incoming tracks have a single fake encoding
+type fakeTransport struct {
+	l       sync.Mutex
+	ssrc    uint
+	removed []*OutgoingStreamTrack
+}
+
+func (f *fakeTransport) newIncomingTrack(track *sdp.TrackInfo, receiver receiverBackend) *IncomingStreamTrack {
+
+	f.l.Lock()
+	f.ssrc++
+	encoding := &Encoding{id: "", backend: newFakeEncoding(f.ssrc, 0)}
+	f.l.Unlock()
+
+	incomingTrack := newBackendIncomingStreamTrack(track.GetMedia(), track.GetID(), receiver, []*Encoding{encoding})
+
+	// no native frames to parse
+	incomingTrack.encodingInfosOnce.Do(func() {
+		incomingTrack.encodingInfos = map[string]*encodingInfoTracker{}
+	})
+
+	return incomingTrack
+}
+
+func (f *fakeTransport) newOutgoingTrack(track *sdp.TrackInfo) *OutgoingStreamTrack {
+	return newBackendOutgoingStreamTrack(track.GetMedia(), track.GetID(), &fakeSender{})
+}
+
+func (f *fakeTransport) removeOutgoingTrack(track *OutgoingStreamTrack) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.removed = append(f.removed, track)
+}
+
+// fakePlaceholderSession a placeholder session over a fake track, the packets are dropped
+type fakePlaceholderSession struct {
+	track *IncomingStreamTrack
+}
+
+func (f *fakePlaceholderSession) GetIncomingStreamTrack() *IncomingStreamTrack {
+	return f.track
+}
+
+func (f *fakePlaceholderSession) Push(rtp []byte) {}
+
+func (f *fakePlaceholderSession) Stop() {}
+
+// newFakePlaceholder a placeholder forwarding the "placeholder" encoding of a fake track, it does not loop its asset
+func newFakePlaceholder(media string) *Placeholder {
+	track := newFakeIncomingStreamTrack(media, "placeholder", map[string]*fakeEncoding{"placeholder": newFakeEncoding(99, 0)})
+	return &Placeholder{session: &fakePlaceholderSession{track: track}, stop: make(chan struct{})}
+}
+
+// fakeSpeakerDetector in-memory speakerDetector, the tests pick the active speaker
+type fakeSpeakerDetector struct {
+	speakers  map[*IncomingStreamTrack]bool
+	listeners []ActiveSpeakerChangedListener
+	stopped   bool
+	l         sync.Mutex
+}
+
+func newFakeSpeakerDetector() *fakeSpeakerDetector {
+	return &fakeSpeakerDetector{speakers: make(map[*IncomingStreamTrack]bool)}
+}
+
+func (f *fakeSpeakerDetector) AddSpeaker(track *IncomingStreamTrack) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.speakers[track] = true
+}
+
+func (f *fakeSpeakerDetector) RemoveSpeaker(track *IncomingStreamTrack) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	delete(f.speakers, track)
+}
+
+func (f *fakeSpeakerDetector) OnActiveSpeakerChanged(listener ActiveSpeakerChangedListener) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.listeners = append(f.listeners, listener)
+}
+
+func (f *fakeSpeakerDetector) Stop() {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.stopped = true
+}
+
+// activate the track becomes the active speaker
+func (f *fakeSpeakerDetector) activate(track *IncomingStreamTrack) {
+
+	f.l.Lock()
+	listeners := append([]ActiveSpeakerChangedListener{}, f.listeners...)
+	f.l.Unlock()
+
+	for _, listener := range listeners {
+		listener(track)
+	}
+}
+
+func (f *fakeSpeakerDetector) has(track *IncomingStreamTrack) bool {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return f.speakers[track]
+}
@@ -0,0 +1,21 @@
+package mediaserver
+
+// frameListenerMode which frames a frameListener calls back for
+type frameListenerMode int
+
+const (
+	// listenKeyFrameOnce call back once, on the first key frame, from its own goroutine
+	listenKeyFrameOnce frameListenerMode = iota
+	// listenKeyFrames call back on every key frame, from the native thread
+	listenKeyFrames
+	// listenFrames call back on every frame, from the native thread
+	listenFrames
+)
+
+// mediaFrame what a frameListener gets of a frame, the data is only copied for the key frames in the listenFrames mode
+type mediaFrame struct {
+	codec     int
+	timestamp uint32
+	keyFrame  bool
+	data      []byte
+}
@@ -0,0 +1,95 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"sync/atomic"
+
+	native "github.com/notedit/media-server-go/wrapper"
+)
+
+// frameListener listen the frames of an encoding through its own multiplexer
+type frameListener struct {
+	multiplexer native.MediaFrameMultiplexer
+	listener    native.MediaFrameListenerFacade
+	mode        frameListenerMode
+	// done set once fired in the listenKeyFrameOnce mode, or stopped
+	done    int32
+	onFrame func(frame mediaFrame)
+}
+
+type overwrittenFrameListener struct {
+	listener *frameListener
+}
+
+func (p *overwrittenFrameListener) OnMediaFrame(frame native.MediaFrame) {
+
+	l := p.listener
+	keyFrame := native.MediaFrameIsKeyFrame(frame)
+
+	switch l.mode {
+	case listenKeyFrameOnce:
+		if keyFrame && atomic.CompareAndSwapInt32(&l.done, 0, 1) {
+			// we are on the native thread, do not call back into the source from here
+			go l.onFrame(mediaFrame{keyFrame: true})
+		}
+	case listenKeyFrames:
+		if keyFrame && atomic.LoadInt32(&l.done) == 0 {
+			l.onFrame(mediaFrame{keyFrame: true})
+		}
+	case listenFrames:
+		if atomic.LoadInt32(&l.done) != 0 {
+			return
+		}
+		var data []byte
+		// only key frames carry the picture size
+		if keyFrame {
+			if length := native.MediaFrameGetLength(frame); length > 0 {
+				data = make([]byte, length)
+				data = data[:native.MediaFrameCopyData(frame, &data[0], length)]
+			}
+		}
+		l.onFrame(mediaFrame{
+			codec:     native.MediaFrameGetVideoCodec(frame),
+			timestamp: uint32(native.MediaFrameGetTimestamp(frame)),
+			keyFrame:  keyFrame,
+			data:      data,
+		})
+	}
+}
+
+func newFrameListener(source native.RTPIncomingSourceGroup, mode frameListenerMode, onFrame func(frame mediaFrame)) *frameListener {
+
+	listener := &frameListener{
+		mode:    mode,
+		onFrame: onFrame,
+	}
+
+	listener.multiplexer = native.NewMediaFrameMultiplexer(source)
+	trackNative("MediaFrameMultiplexer", listener.multiplexer)
+	listener.listener = native.NewDirectorMediaFrameListenerFacade(&overwrittenFrameListener{listener: listener})
+	listener.multiplexer.AddMediaListener(listener.listener)
+
+	return listener
+}
+
+// Stop stop listening, the callback will not be done after this
+func (l *frameListener) Stop() {
+
+	if l.multiplexer == nil {
+		return
+	}
+
+	atomic.StoreInt32(&l.done, 1)
+
+	l.multiplexer.RemoveMediaListener(l.listener)
+	l.multiplexer.Stop()
+
+	untrackNative(l.multiplexer)
+	native.DeleteMediaFrameMultiplexer(l.multiplexer)
+	native.DeleteDirectorMediaFrameListenerFacade(l.listener)
+
+	l.multiplexer = nil
+	l.listener = nil
+}
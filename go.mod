@@ -5,4 +5,6 @@ require (
 	github.com/notedit/sdp v0.0.4
 )
 
-go 1.13
+require github.com/Jeffail/gabs v1.1.1 // indirect
+
+go 1.21
@@ -1,31 +1,31 @@
 package mediaserver
 
 import (
-	"errors"
-	"fmt"
 	"strconv"
 	"strings"
 	"sync"
 
-	native "github.com/notedit/media-server-go/wrapper"
 	"github.com/notedit/sdp"
 )
 
-// IncomingStream The incoming streams represent the recived media stream from a remote peer.
+// IncomingStream The incoming streams represent the recived media stream from a remote peer, safe for concurrent use.
 type IncomingStream struct {
 	id                                string
 	info                              *sdp.StreamInfo
-	transport                         native.DTLSICETransport
-	receiver                          native.RTPReceiverFacade
+	transport                         transportBackend
+	receiver                          receiverBackend
 	tracks                            map[string]*IncomingStreamTrack
 	onStreamAddIncomingTrackListeners []func(*IncomingStreamTrack)
+	onStopListeners                   []func()
+	// l guards the fields above, it is never held while calling the tracks
 	l sync.Mutex
 }
 
 
 // NewIncomingStream  Create new incoming stream
 // TODO: make this public
-func newIncomingStream(transport native.DTLSICETransport, receiver native.RTPReceiverFacade, info *sdp.StreamInfo) *IncomingStream {
+// strict fails on the first track it can not create and stops the stream, otherwise the track is skipped
+func newIncomingStream(transport transportBackend, receiver receiverBackend, info *sdp.StreamInfo, strict bool) (*IncomingStream, error) {
 	stream := &IncomingStream{}
 	stream.id = info.GetID()
 	stream.transport = transport
@@ -35,12 +35,17 @@ func newIncomingStream(transport native.DTLSICETransport, receiver native.RTPRec
 	stream.onStreamAddIncomingTrackListeners = make([]func(*IncomingStreamTrack), 0)
 
 	for _, track := range info.GetTracks() {
-		stream.CreateTrack(track)
+		if _, err := stream.createTrack(track, strict); err != nil {
+			if strict {
+				stream.Stop()
+				return nil, err
+			}
+			componentLogger("incomingstream").Warn("can not create track", "stream", stream.id, "error", err)
+		}
 	}
-	return stream
+	return stream, nil
 }
 
-
 // GetID get id
 func (i *IncomingStream) GetID() string {
 	return i.id
@@ -51,7 +56,7 @@ func (i *IncomingStream) GetStreamInfo() *sdp.StreamInfo {
 
 	info := sdp.NewStreamInfo(i.id)
 
-	for _, track := range i.tracks {
+	for _, track := range i.GetTracks() {
 		info.AddTrack(track.GetTrackInfo().Clone())
 	}
 	return info
@@ -62,7 +67,7 @@ func (i *IncomingStream) GetStats() map[string]map[string]*IncomingAllStats {
 
 	stats := map[string]map[string]*IncomingAllStats{}
 
-	for _, track := range i.tracks {
+	for _, track := range i.GetTracks() {
 		stats[track.GetID()] = track.GetStats()
 	}
 
@@ -113,13 +118,64 @@ func (i *IncomingStream) GetVideoTracks() []*IncomingStreamTrack {
 	return videoTracks
 }
 
+// SetMaxBitrate cap the whole stream, 0 removes the cap. Audio tracks are left uncapped and their
+// DefaultAudioBitrate is taken from bitrate, the rest is split evenly between the video tracks
+func (i *IncomingStream) SetMaxBitrate(bitrate uint) {
+
+	audioTracks := i.GetAudioTracks()
+	videoTracks := i.GetVideoTracks()
+
+	if len(videoTracks) == 0 {
+		return
+	}
+
+	videoBitrate := uint(0)
+
+	if bitrate > 0 {
+		reserved := DefaultAudioBitrate * uint(len(audioTracks))
+		videoBitrate = IdleEncodingBitrate
+		if bitrate > reserved+IdleEncodingBitrate {
+			videoBitrate = bitrate - reserved
+		}
+		videoBitrate = videoBitrate / uint(len(videoTracks))
+	}
+
+	for _, track := range videoTracks {
+		track.SetMaxBitrate(videoBitrate)
+	}
+}
+
+// SetBitrateFeedback choose REMB or TMMBR to send the caps of all the tracks
+func (i *IncomingStream) SetBitrateFeedback(feedback BitrateFeedback) {
+
+	for _, track := range i.GetTracks() {
+		track.SetBitrateFeedback(feedback)
+	}
+}
+
+// SetDemandCapping cap down the video encodings nobody watches on all the tracks
+func (i *IncomingStream) SetDemandCapping(enabled bool) {
+
+	for _, track := range i.GetVideoTracks() {
+		track.SetDemandCapping(enabled)
+	}
+}
+
+// SetLazyUpstream enable lazy upstream on all the tracks, see IncomingStreamTrack.SetLazyUpstream
+func (i *IncomingStream) SetLazyUpstream(enabled bool) {
+
+	for _, track := range i.GetTracks() {
+		track.SetLazyUpstream(enabled)
+	}
+}
+
 // AddTrack Adds an incoming stream track created using the Transpocnder.CreateIncomingStreamTrack to this stream
 func (i *IncomingStream) AddTrack(track *IncomingStreamTrack) error {
 
 	i.l.Lock()
 	defer i.l.Unlock()
 	if _, ok := i.tracks[track.GetID()]; ok {
-		return errors.New("Track id already present in stream")
+		return newError(ErrDuplicateTrack, "Track id already present in stream")
 	}
 
 	i.tracks[track.GetID()] = track
@@ -138,161 +194,120 @@ func (i *IncomingStream) RemoveTrack(track *IncomingStreamTrack) error {
 // CreateTrack Create new track from a TrackInfo object and add it to this stream
 func (i *IncomingStream) CreateTrack(track *sdp.TrackInfo) *IncomingStreamTrack {
 
-	if _, ok := i.tracks[track.GetID()]; ok {
+	incomingTrack, err := i.createTrack(track, false)
+	if err != nil {
+		componentLogger("incomingstream").Warn("can not create track", "stream", i.id, "error", err)
 		return nil
 	}
+	return incomingTrack
+}
 
-	var mediaType native.MediaFrameType = 0
-	if track.GetMedia() == "video" {
-		mediaType = 1
-	}
-
-	sources := map[string]native.RTPIncomingSourceGroup{}
-
-	encodings := track.GetEncodings()
-
-	if len(encodings) > 0 {
-
-		for _, items := range encodings {
-
-			for _, encoding := range items {
-
-				source := native.NewRTPIncomingSourceGroup(mediaType, i.transport.GetTimeService())
-
-				mid := track.GetMediaID()
-
-				rid := encoding.GetID()
-
-				source.SetRid(rid)
-
-				if mid != "" {
-					source.SetMid(mid)
-				}
-
-				params := encoding.GetParams()
-
-				if ssrc, ok := params["ssrc"]; ok {
-					ssrcUint, err := strconv.ParseUint(ssrc, 10, 32)
-					if err != nil {
-						fmt.Println("ssrc parse error ", err)
-						continue
-					}
-					source.GetMedia().SetSsrc(uint(ssrcUint))
-					groups := track.GetSourceGroupS()
-					for _, group := range groups {
-						// check if it is from us
-						if group.GetSSRCs() != nil && group.GetSSRCs()[0] == source.GetMedia().GetSsrc() {
-							if group.GetSemantics() == "FID" {
-								source.GetRtx().SetSsrc(group.GetSSRCs()[1])
-							}
-
-							if group.GetSemantics() == "FEC-FR" {
-								source.GetFec().SetSsrc(group.GetSSRCs()[1])
-							}
-						}
-					}
-				}
+// NewTrack same as CreateTrack returning the error, ErrDuplicateTrack when the stream already has a track with this id
+// and ErrInvalidSDP when the track has no ssrc or an invalid encoding ssrc
+func (i *IncomingStream) NewTrack(track *sdp.TrackInfo) (*IncomingStreamTrack, error) {
+	return i.createTrack(track, true)
+}
 
-				i.transport.AddIncomingSourceGroup(source)
-				sources[rid] = source
+// createTrack ErrDuplicateTrack when the stream already has a track with this id and ErrInvalidSDP when the track has no ssrc.
+// strict also rejects the tracks with an invalid encoding ssrc, otherwise the encoding is skipped
+func (i *IncomingStream) createTrack(track *sdp.TrackInfo, strict bool) (*IncomingStreamTrack, error) {
 
-				// runtime.SetFinalizer(source, func(source native.RTPIncomingSourceGroup) {
-				// 	i.transport.RemoveIncomingSourceGroup(source)
-				// })
+	if track == nil {
+		return nil, newError(ErrInvalidArgument, "Track info can not be nil")
+	}
 
-			}
+	if strict {
+		if err := validateIncomingTrackInfo(track); err != nil {
+			return nil, err
 		}
+	} else if len(track.GetEncodings()) == 0 && track.GetSourceGroup("SIM") == nil && len(track.GetSSRCS()) == 0 {
+		return nil, newError(ErrInvalidSDP, "Track "+track.GetID()+" has no ssrc")
+	}
 
-	} else if track.GetSourceGroup("SIM") != nil {
-		// chrome like simulcast
-		SIM := track.GetSourceGroup("SIM")
-
-		ssrcs := SIM.GetSSRCs()
+	// held until the track is added, so the same id can not be created twice
+	i.l.Lock()
+	defer i.l.Unlock()
 
-		groups := track.GetSourceGroupS()
+	if i.transport == nil {
+		return nil, newError(ErrStopped, "Stream is stopped")
+	}
 
-		for j, ssrc := range ssrcs {
+	if _, ok := i.tracks[track.GetID()]; ok {
+		return nil, newError(ErrDuplicateTrack, "Track id already present in stream")
+	}
 
-			source := native.NewRTPIncomingSourceGroup(mediaType, i.transport.GetTimeService())
+	incomingTrack := i.transport.newIncomingTrack(track, i.receiver)
 
-			source.GetMedia().SetSsrc(ssrc)
+	i.tracks[track.GetID()] = incomingTrack
 
-			for _, group := range groups {
+	return incomingTrack, nil
+}
 
-				if group.GetSSRCs()[0] == ssrc {
+// validateIncomingTrackInfo check the ssrcs of a track announced by the remote side are usable
+func validateIncomingTrackInfo(track *sdp.TrackInfo) error {
 
-					if group.GetSemantics() == "FID" {
-						source.GetRtx().SetSsrc(group.GetSSRCs()[1])
-					}
+	encodings := track.GetEncodings()
 
-					if group.GetSemantics() == "FEC-FR" {
-						source.GetFec().SetSsrc(group.GetSSRCs()[1])
+	if len(encodings) > 0 {
+		for _, items := range encodings {
+			for _, encoding := range items {
+				if ssrc, ok := encoding.GetParams()["ssrc"]; ok {
+					if _, err := strconv.ParseUint(ssrc, 10, 32); err != nil {
+						return wrapError(ErrInvalidSDP, "Invalid ssrc for encoding "+encoding.GetID(), err)
 					}
 				}
 			}
-
-			i.transport.AddIncomingSourceGroup(source)
-
-			sources[strconv.Itoa(j)] = source
-
-			// runtime.SetFinalizer(source, func(source native.RTPIncomingSourceGroup) {
-			// 	i.transport.RemoveIncomingSourceGroup(source)
-			// })
-		}
-
-	} else {
-		source := native.NewRTPIncomingSourceGroup(mediaType, i.transport.GetTimeService())
-
-		source.GetMedia().SetSsrc(track.GetSSRCS()[0])
-
-		fid := track.GetSourceGroup("FID")
-		fec_fr := track.GetSourceGroup("FEC-FR")
-
-		if fid != nil {
-			source.GetRtx().SetSsrc(fid.GetSSRCs()[1])
-		} else {
-			source.GetRtx().SetSsrc(0)
-		}
-
-		if fec_fr != nil {
-			source.GetFec().SetSsrc(fec_fr.GetSSRCs()[1])
-		} else {
-			source.GetFec().SetSsrc(0)
 		}
+		return nil
+	}
 
-		i.transport.AddIncomingSourceGroup(source)
-
-		// Append to soruces with empty rid
-		sources[""] = source
+	if track.GetSourceGroup("SIM") != nil {
+		return nil
+	}
 
+	if len(track.GetSSRCS()) == 0 {
+		return newError(ErrInvalidSDP, "Track "+track.GetID()+" has no ssrc")
 	}
 
-	incomingTrack := NewIncomingStreamTrack(track.GetMedia(), track.GetID(), i.receiver, sources)
+	return nil
+}
 
+// OnStop run this func once the stream is stopped, right away if it already is
+func (i *IncomingStream) OnStop(stop func()) {
 	i.l.Lock()
-	i.tracks[track.GetID()] = incomingTrack
+	if i.transport == nil {
+		i.l.Unlock()
+		stop()
+		return
+	}
+	i.onStopListeners = append(i.onStopListeners, stop)
 	i.l.Unlock()
-
-	return incomingTrack
 }
 
 // Stop Removes the media strem from the transport and also detaches from any attached incoming stream
 func (i *IncomingStream) Stop() {
 
+	i.l.Lock()
 	if i.transport == nil {
+		i.l.Unlock()
 		return
 	}
+	tracks := i.tracks
+	receiver := i.receiver
+	listeners := i.onStopListeners
+	i.tracks = make(map[string]*IncomingStreamTrack)
+	i.receiver = nil
+	i.transport = nil
+	i.onStopListeners = nil
+	i.l.Unlock()
 
-	i.l.Lock()
-	defer i.l.Unlock()
-
-	for k, track := range i.tracks {
+	for _, track := range tracks {
 		track.Stop()
-		delete(i.tracks, k)
 	}
 
+	receiver.delete() // other module maybe need delete
 
-	native.DeleteRTPReceiverFacade(i.receiver) // other module maybe need delete
-	i.receiver = nil
-	i.transport = nil
+	for _, stop := range listeners {
+		stop()
+	}
 }
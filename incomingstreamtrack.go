@@ -2,10 +2,10 @@ package mediaserver
 
 import (
 	"sort"
-	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	native "github.com/notedit/media-server-go/wrapper"
 	"github.com/notedit/sdp"
 )
 
@@ -25,13 +25,20 @@ type Layer struct {
 	NumPackets uint
 	// Bitrate  uint
 	Bitrate uint
+	// Width int, 0 when unknown
+	Width int
+	// Height int, 0 when unknown
+	Height int
+	// FrameRate float64, 0 when unknown
+	FrameRate float64
+	// KeyFrameInterval time.Duration, 0 when unknown
+	KeyFrameInterval time.Duration
 }
 
 // Encoding info
 type Encoding struct {
-	id           string
-	source       native.RTPIncomingSourceGroup
-	depacketizer native.StreamTrackDepacketizer
+	id      string
+	backend encodingBackend
 }
 
 // GetID encoding id
@@ -39,32 +46,34 @@ func (e *Encoding) GetID() string {
 	return e.id
 }
 
-// GetSource  get native RTPIncomingSourceGroup
-func (e *Encoding) GetSource() native.RTPIncomingSourceGroup {
-	return e.source
-}
-
-// GetDepacketizer  get native StreamTrackDepacketizer
-func (e *Encoding) GetDepacketizer() native.StreamTrackDepacketizer {
-	return e.depacketizer
-}
-
 // IncomingTrackStopListener stop listener
 type IncomingTrackStopListener func()
 
-// IncomingStreamTrack Audio or Video track of a remote media stream
+// IncomingStreamTrack Audio or Video track of a remote media stream, safe for concurrent use
 type IncomingStreamTrack struct {
 	id                    string
 	media                 string
-	receiver              native.RTPReceiverFacade
-	counter               int
+	receiver              receiverBackend
+	counter               int32
+	lazy                  int32
 	encodings             []*Encoding
 	trackInfo             *sdp.TrackInfo
 	stats                 map[string]*IncomingAllStats
-	mediaframeMultiplexer *MediaFrameMultiplexer
+	mediaframeMultiplexer stopper
 	onStopListeners       []func()
 	onAttachedListeners   []func()
 	onDetachedListeners   []func()
+	maxBitrate            *maxBitrateController
+	keyFrames             *keyFrameRequester
+	encodingInfos         map[string]*encodingInfoTracker
+	encodingInfosOnce     sync.Once
+	// ownsReceiver the receiver is deleted by Stop, it is shared by the tracks of an incoming stream otherwise
+	ownsReceiver bool
+	// leakGuard logs the sources and depacketizers if the track is collected without being stopped
+	leakGuard *nativeLeakGuard
+	// l guards receiver, encodings, stats, the listeners and the multiplexer.
+	// The native sources and the receiver are only used with it held, Stop releases them once it is not
+	l sync.Mutex
 }
 
 // IncomingStats info
@@ -103,6 +112,11 @@ type ActiveEncoding struct {
 	SimulcastIdx int
 	Bitrate      uint
 	Layers       []*Layer
+	// Width Height FrameRate KeyFrameInterval parsed from the key frames, 0 when unknown
+	Width            int
+	Height           int
+	FrameRate        float64
+	KeyFrameInterval time.Duration
 }
 
 // ActiveLayersInfo info
@@ -112,43 +126,15 @@ type ActiveLayersInfo struct {
 	Layers   []*Layer
 }
 
-func getStatsFromIncomingSource(source native.RTPIncomingSource) *IncomingStats {
+// aggregateLayers each layer counts the lower layers it depends on
+func aggregateLayers(encodingId string, individual []*Layer) []*Layer {
 
-	stats := &IncomingStats{
-		LostPackets:    source.GetLostPackets(),
-		DropPackets:    source.GetDropPackets(),
-		NumPackets:     source.GetNumPackets(),
-		NumRTCPPackets: source.GetNumRTCPPackets(),
-		TotalBytes:     source.GetTotalBytes(),
-		TotalRTCPBytes: source.GetTotalRTCPBytes(),
-		TotalPLIs:      source.GetTotalPLIs(),
-		TotalNACKs:     source.GetTotalNACKs(),
-		Bitrate:        source.GetBitrate(),
-		Layers:         []*Layer{},
-	}
-
-	layers := source.Layers()
-
-	individual := []*Layer{}
-
-	var i int64
-	for i = 0; i < layers.Size(); i++ {
-		layer := layers.Get(int64(i))
-
-		layerInfo := &Layer{
-			SpatialLayerId:  int(layer.GetSpatialLayerId()),
-			TemporalLayerId: int(layer.GetTemporalLayerId()),
-			TotalBytes:      layer.GetTotalBytes(),
-			NumPackets:      layer.GetNumPackets(),
-			Bitrate:         layer.GetBitrate(),
-		}
-
-		individual = append(individual, layerInfo)
-	}
+	layers := []*Layer{}
 
 	for _, layer := range individual {
 
 		aggregated := &Layer{
+			EncodingId:      encodingId,
 			SpatialLayerId:  layer.SpatialLayerId,
 			TemporalLayerId: layer.TemporalLayerId,
 			TotalBytes:      0,
@@ -164,72 +150,28 @@ func getStatsFromIncomingSource(source native.RTPIncomingSource) *IncomingStats
 			}
 		}
 
-		stats.Layers = append(stats.Layers, aggregated)
+		layers = append(layers, aggregated)
 	}
 
-	return stats
+	return layers
 }
 
-// NewIncomingStreamTrack Create incoming audio/video track
-func NewIncomingStreamTrack(media string, id string, receiver native.RTPReceiverFacade, sources map[string]native.RTPIncomingSourceGroup) *IncomingStreamTrack {
+// newBackendIncomingStreamTrack create a track over the encodings, the feedback is sent through the receiver
+func newBackendIncomingStreamTrack(media string, id string, receiver receiverBackend, encodings []*Encoding) *IncomingStreamTrack {
 	track := &IncomingStreamTrack{}
 
 	track.id = id
 	track.media = media
 	track.receiver = receiver
-	track.counter = 0
-	track.encodings = make([]*Encoding, 0)
+	track.encodings = encodings
 
 	track.trackInfo = sdp.NewTrackInfo(id, media)
 
-	for k, source := range sources {
-		encoding := &Encoding{
-			id:           k,
-			source:       source,
-			depacketizer: native.NewStreamTrackDepacketizer(source),
-		}
-
-		track.encodings = append(track.encodings, encoding)
-
-		//Add ssrcs to track info
-		if source.GetMedia().GetSsrc() > 0 {
-			track.trackInfo.AddSSRC(source.GetMedia().GetSsrc())
-		}
-
-		if source.GetRtx().GetSsrc() > 0 {
-			track.trackInfo.AddSSRC(source.GetRtx().GetSsrc())
-		}
-
-		if source.GetFec().GetSsrc() > 0 {
-			track.trackInfo.AddSSRC(source.GetFec().GetSsrc())
-		}
-
-		//Add RTX and FEC groups
-		if source.GetRtx().GetSsrc() > 0 {
-			sourceGroup := sdp.NewSourceGroupInfo("FID", []uint{source.GetMedia().GetSsrc(), source.GetRtx().GetSsrc()})
-			track.trackInfo.AddSourceGroup(sourceGroup)
-		}
-
-		if source.GetFec().GetSsrc() > 0 {
-			sourceGroup := sdp.NewSourceGroupInfo("FEC-FR", []uint{source.GetMedia().GetSsrc(), source.GetFec().GetSsrc()})
-			track.trackInfo.AddSourceGroup(sourceGroup)
-		}
-
-		// if simulcast
-		if len(k) > 0 {
-			// make soure the pasused
-			encodingInfo := sdp.NewTrackEncodingInfo(k, false)
-			if source.GetMedia().GetSsrc() > 0 {
-				ssrc := strconv.FormatUint(uint64(source.GetMedia().GetSsrc()), 10)
-				encodingInfo.AddParam("ssrc", ssrc)
-			}
-			track.trackInfo.AddEncoding(encodingInfo)
-		}
-	}
-
 	track.onAttachedListeners = make([]func(), 0)
 	track.onDetachedListeners = make([]func(), 0)
 	track.onStopListeners = make([]func(), 0)
+	track.maxBitrate = newMaxBitrateController(track)
+	track.keyFrames = newTrackKeyFrameRequester(track)
 
 	sort.SliceStable(track.encodings, func(i, j int) bool {
 		return track.encodings[i].id < track.encodings[j].id
@@ -253,24 +195,12 @@ func (i *IncomingStreamTrack) GetTrackInfo() *sdp.TrackInfo {
 	return i.trackInfo
 }
 
-// GetSSRCs get all RTPIncomingSource include "media" "rtx" "fec"
-func (i *IncomingStreamTrack) GetSSRCs() []map[string]native.RTPIncomingSource {
-
-	ssrcs := make([]map[string]native.RTPIncomingSource, 0)
-
-	for _, encoding := range i.encodings {
-		ssrcs = append(ssrcs, map[string]native.RTPIncomingSource{
-			"media": encoding.source.GetMedia(),
-			"rtx":   encoding.source.GetRtx(),
-			"fec":   encoding.source.GetFec(),
-		})
-	}
-	return ssrcs
-}
-
-// GetStats Get stats for all encodings
+// GetStats Get stats for all encodings, a copy is returned each time
 func (i *IncomingStreamTrack) GetStats() map[string]*IncomingAllStats {
 
+	i.l.Lock()
+	defer i.l.Unlock()
+
 	if i.stats == nil {
 		i.stats = map[string]*IncomingAllStats{}
 	}
@@ -279,24 +209,18 @@ func (i *IncomingStreamTrack) GetStats() map[string]*IncomingAllStats {
 		state := i.stats[encoding.id]
 		if state == nil || (state != nil && time.Now().UnixNano()-state.timestamp > 200000000) {
 
-			encoding.GetSource().Update()
-
-			media := getStatsFromIncomingSource(encoding.GetSource().GetMedia())
-			fec := getStatsFromIncomingSource(encoding.GetSource().GetFec())
-			rtx := getStatsFromIncomingSource(encoding.GetSource().GetRtx())
-
-			i.stats[encoding.id] = &IncomingAllStats{
-				Rtt:         encoding.GetSource().GetRtt(),
-				MinWaitTime: encoding.GetSource().GetMinWaitedTime(),
-				MaxWaitTime: encoding.GetSource().GetMaxWaitedTime(),
-				AvgWaitTime: encoding.GetSource().GetAvgWaitedTime(),
-				Media:       media,
-				Rtx:         rtx,
-				Fec:         fec,
-				Bitrate:     media.Bitrate,
-				Total:       media.Bitrate + fec.Bitrate + rtx.Bitrate,
-				timestamp:   time.Now().UnixNano(),
+			encoding.backend.update()
+
+			stats := encoding.backend.stats()
+			for _, source := range []*IncomingStats{stats.Media, stats.Rtx, stats.Fec} {
+				source.Layers = aggregateLayers(encoding.id, source.Layers)
 			}
+
+			stats.Bitrate = stats.Media.Bitrate
+			stats.Total = stats.Media.Bitrate + stats.Fec.Bitrate + stats.Rtx.Bitrate
+			stats.timestamp = time.Now().UnixNano()
+
+			i.stats[encoding.id] = stats
 		}
 	}
 
@@ -323,10 +247,37 @@ func (i *IncomingStreamTrack) GetStats() map[string]*IncomingAllStats {
 		}
 	}
 
-	return i.stats
+	copies := make(map[string]*IncomingAllStats, len(i.stats))
+	for id, state := range i.stats {
+		copies[id] = state.clone()
+	}
+	return copies
+}
+
+// clone copy the stats and their layers, the cached ones keep being updated
+func (s *IncomingAllStats) clone() *IncomingAllStats {
+	copied := *s
+	copied.Media = s.Media.clone()
+	copied.Rtx = s.Rtx.clone()
+	copied.Fec = s.Fec.clone()
+	return &copied
+}
+
+func (s *IncomingStats) clone() *IncomingStats {
+	if s == nil {
+		return nil
+	}
+	copied := *s
+	copied.Layers = make([]*Layer, len(s.Layers))
+	for i, layer := range s.Layers {
+		copiedLayer := *layer
+		copied.Layers[i] = &copiedLayer
+	}
+	return &copied
 }
 
-// GetActiveLayers Get active encodings and layers ordered by bitrate
+// GetActiveLayers Get active encodings and layers ordered by bitrate.
+// Video sizes, frame rates and key frame intervals are parsed from the frames once this has been called
 func (i *IncomingStreamTrack) GetActiveLayers() *ActiveLayersInfo {
 
 	active := []*ActiveEncoding{}
@@ -334,6 +285,7 @@ func (i *IncomingStreamTrack) GetActiveLayers() *ActiveLayersInfo {
 	all := []*Layer{}
 
 	stats := i.GetStats()
+	infos := i.getEncodingInfos()
 
 	for id, state := range stats {
 
@@ -344,29 +296,56 @@ func (i *IncomingStreamTrack) GetActiveLayers() *ActiveLayersInfo {
 			continue
 		}
 
+		info := encodingInfo{}
+		if tracker, ok := infos[id]; ok {
+			info = tracker.getInfo()
+		}
+
 		encoding := &ActiveEncoding{
-			EncodingId:   id,
-			SimulcastIdx: state.SimulcastIdx,
-			Bitrate:      state.Bitrate,
-			Layers:       []*Layer{},
+			EncodingId:       id,
+			SimulcastIdx:     state.SimulcastIdx,
+			Bitrate:          state.Bitrate,
+			Layers:           []*Layer{},
+			Width:            info.width,
+			Height:           info.height,
+			FrameRate:        info.frameRate,
+			KeyFrameInterval: info.keyFrameInterval,
 		}
 
 		layers := state.Media.Layers
 
+		maxTemporalLayerId := 0
+		for _, layer := range layers {
+			if layer.TemporalLayerId != MaxLayerId && layer.TemporalLayerId > maxTemporalLayerId {
+				maxTemporalLayerId = layer.TemporalLayerId
+			}
+		}
+
 		for _, layer := range layers {
+			width, height, frameRate := info.layerInfo(layer.SpatialLayerId, layer.TemporalLayerId, maxTemporalLayerId)
+
 			encoding.Layers = append(encoding.Layers, &Layer{
-				SimulcastIdx:    layer.SimulcastIdx,
-				SpatialLayerId:  layer.SpatialLayerId,
-				TemporalLayerId: layer.TemporalLayerId,
-				Bitrate:         layer.Bitrate,
+				EncodingId:       id,
+				SimulcastIdx:     layer.SimulcastIdx,
+				SpatialLayerId:   layer.SpatialLayerId,
+				TemporalLayerId:  layer.TemporalLayerId,
+				Bitrate:          layer.Bitrate,
+				Width:            width,
+				Height:           height,
+				FrameRate:        frameRate,
+				KeyFrameInterval: info.keyFrameInterval,
 			})
 
 			all = append(all, &Layer{
-				EncodingId:      id,
-				SimulcastIdx:    layer.SimulcastIdx,
-				SpatialLayerId:  layer.SpatialLayerId,
-				TemporalLayerId: layer.TemporalLayerId,
-				Bitrate:         layer.Bitrate,
+				EncodingId:       id,
+				SimulcastIdx:     layer.SimulcastIdx,
+				SpatialLayerId:   layer.SpatialLayerId,
+				TemporalLayerId:  layer.TemporalLayerId,
+				Bitrate:          layer.Bitrate,
+				Width:            width,
+				Height:           height,
+				FrameRate:        frameRate,
+				KeyFrameInterval: info.keyFrameInterval,
 			})
 
 		}
@@ -376,11 +355,15 @@ func (i *IncomingStreamTrack) GetActiveLayers() *ActiveLayersInfo {
 		} else {
 
 			all = append(all, &Layer{
-				EncodingId:      encoding.EncodingId,
-				SimulcastIdx:    encoding.SimulcastIdx,
-				SpatialLayerId:  MaxLayerId,
-				TemporalLayerId: MaxLayerId,
-				Bitrate:         encoding.Bitrate,
+				EncodingId:       encoding.EncodingId,
+				SimulcastIdx:     encoding.SimulcastIdx,
+				SpatialLayerId:   MaxLayerId,
+				TemporalLayerId:  MaxLayerId,
+				Bitrate:          encoding.Bitrate,
+				Width:            info.width,
+				Height:           info.height,
+				FrameRate:        info.frameRate,
+				KeyFrameInterval: info.keyFrameInterval,
 			})
 		}
 		active = append(active, encoding)
@@ -402,15 +385,43 @@ func (i *IncomingStreamTrack) GetActiveLayers() *ActiveLayersInfo {
 
 }
 
+// getEncodingInfos start parsing the frames of the video encodings on first call
+func (i *IncomingStreamTrack) getEncodingInfos() map[string]*encodingInfoTracker {
+
+	i.encodingInfosOnce.Do(func() {
+		infos := make(map[string]*encodingInfoTracker)
+		i.l.Lock()
+		if i.media == "video" && i.receiver != nil {
+			for _, encoding := range i.encodings {
+				infos[encoding.id] = newEncodingInfoTracker(encoding)
+			}
+		}
+		i.l.Unlock()
+		i.encodingInfos = infos
+	})
+
+	return i.encodingInfos
+}
+
 // GetEncodings  get all encodings
 func (i *IncomingStreamTrack) GetEncodings() []*Encoding {
 
+	i.l.Lock()
+	defer i.l.Unlock()
 	return i.encodings
 }
 
 // GetFirstEncoding get the first Encoding
 func (i *IncomingStreamTrack) GetFirstEncoding() *Encoding {
 
+	i.l.Lock()
+	defer i.l.Unlock()
+	return i.firstEncoding()
+}
+
+// firstEncoding needs the lock
+func (i *IncomingStreamTrack) firstEncoding() *Encoding {
+
 	for _, encoding := range i.encodings {
 		if encoding != nil {
 			return encoding
@@ -422,7 +433,7 @@ func (i *IncomingStreamTrack) GetFirstEncoding() *Encoding {
 // GetEncoding get Encoding by id
 func (i *IncomingStreamTrack) GetEncoding(encodingID string) *Encoding {
 
-	for _, encoding := range i.encodings {
+	for _, encoding := range i.GetEncodings() {
 		if encoding.id == encodingID {
 			return encoding
 		}
@@ -433,31 +444,137 @@ func (i *IncomingStreamTrack) GetEncoding(encodingID string) *Encoding {
 // Attached Signal that this track has been attached.
 func (i *IncomingStreamTrack) Attached() {
 
-	i.counter = i.counter + 1
-
-	if i.counter == 1 {
-		for _, attach := range i.onAttachedListeners {
+	if atomic.AddInt32(&i.counter, 1) == 1 {
+		// restore the bitrate of a lazy track
+		i.maxBitrate.updateIdle()
+		if i.IsLazyUpstream() {
+			// and get a picture for the new subscriber
+			i.Refresh()
+		}
+		i.l.Lock()
+		listeners := append([]func(){}, i.onAttachedListeners...)
+		i.l.Unlock()
+		for _, attach := range listeners {
 			attach()
 		}
 	}
 }
 
-// Refresh Request an intra refres
+// IsAttached check if something is attached to the track
+func (i *IncomingStreamTrack) IsAttached() bool {
+	return atomic.LoadInt32(&i.counter) > 0
+}
+
+// SetLazyUpstream when enabled and nothing is attached, the sender bitrate is capped to IdleEncodingBitrate
+// and no key frame is requested. Both are restored on next attach
+func (i *IncomingStreamTrack) SetLazyUpstream(enabled bool) {
+
+	lazy := int32(0)
+	if enabled {
+		lazy = 1
+	}
+
+	if atomic.SwapInt32(&i.lazy, lazy) == lazy {
+		return
+	}
+
+	i.maxBitrate.updateIdle()
+}
+
+// IsLazyUpstream check if lazy upstream is enabled
+func (i *IncomingStreamTrack) IsLazyUpstream() bool {
+	return atomic.LoadInt32(&i.lazy) == 1
+}
+
+// Refresh Request an intra refres, skipped on lazy tracks with nothing attached
 func (i *IncomingStreamTrack) Refresh() {
 
-	for _, encoding := range i.encodings {
+	if i.IsLazyUpstream() && !i.IsAttached() {
+		return
+	}
+
+	i.l.Lock()
+	ssrcs := []uint{}
+	if i.receiver != nil {
+		for _, encoding := range i.encodings {
+			ssrcs = append(ssrcs, encoding.backend.ssrc())
+		}
+	}
+	i.l.Unlock()
+
+	for _, ssrc := range ssrcs {
 		//Request an iframe on main ssrc
-		i.receiver.SendPLI(encoding.source.GetMedia().GetSsrc())
+		i.keyFrames.request(ssrc, false)
+	}
+}
+
+// withReceiver call f with the receiver and the encodings, the lock held so Stop can not release them meanwhile.
+// Nothing is called once stopped
+func (i *IncomingStreamTrack) withReceiver(f func(receiver receiverBackend, encodings []*Encoding)) {
+
+	i.l.Lock()
+	defer i.l.Unlock()
+
+	if i.receiver == nil {
+		return
 	}
+
+	f(i.receiver, i.encodings)
+}
+
+// isStopped check if the track has been stopped
+func (i *IncomingStreamTrack) isStopped() bool {
+	i.l.Lock()
+	defer i.l.Unlock()
+	return i.receiver == nil
+}
+
+// SetKeyFrameRequestMethod choose pli, fir or auto for the key frame requests sent to the sender
+func (i *IncomingStreamTrack) SetKeyFrameRequestMethod(method KeyFrameRequestMethod) {
+	i.keyFrames.setMethod(method)
+}
+
+// SetKeyFrameRequestInterval set the minimum time between two key frame requests sent to the sender,
+// requests in between, from Refresh or from the subscribers, are coalesced
+func (i *IncomingStreamTrack) SetKeyFrameRequestInterval(interval time.Duration) {
+	i.keyFrames.setMinInterval(interval)
+}
+
+// GetKeyFrameRequestStats get the key frame request counters
+func (i *IncomingStreamTrack) GetKeyFrameRequestStats() KeyFrameRequestStats {
+	return i.keyFrames.getStats()
+}
+
+// SetMaxBitrate ask the sender to not send more than bitrate bps, 0 removes the cap.
+// The cap is sent with REMB by default and repeated until removed
+func (i *IncomingStreamTrack) SetMaxBitrate(bitrate uint) {
+	i.maxBitrate.setMaxBitrate(bitrate)
+}
+
+// GetMaxBitrate get the cap set with SetMaxBitrate
+func (i *IncomingStreamTrack) GetMaxBitrate() uint {
+	return i.maxBitrate.getMaxBitrate()
+}
+
+// SetBitrateFeedback choose REMB or TMMBR to send the caps
+func (i *IncomingStreamTrack) SetBitrateFeedback(feedback BitrateFeedback) {
+	i.maxBitrate.setFeedback(feedback)
+}
+
+// SetDemandCapping cap down the encodings above the best one forwarded by an attached transponder
+func (i *IncomingStreamTrack) SetDemandCapping(enabled bool) {
+	i.maxBitrate.setDemandCapping(enabled)
 }
 
 // Detached Signal that this track has been detached.
 func (i *IncomingStreamTrack) Detached() {
 
-	i.counter = i.counter - 1
-
-	if i.counter == 0 {
-		for _, detach := range i.onDetachedListeners {
+	if atomic.AddInt32(&i.counter, -1) == 0 {
+		i.maxBitrate.updateIdle()
+		i.l.Lock()
+		listeners := append([]func(){}, i.onDetachedListeners...)
+		i.l.Unlock()
+		for _, detach := range listeners {
 			detach()
 		}
 	}
@@ -465,47 +582,74 @@ func (i *IncomingStreamTrack) Detached() {
 
 // OnDetach
 func (i *IncomingStreamTrack) OnDetach(detach func()) {
+	i.l.Lock()
+	defer i.l.Unlock()
 	i.onDetachedListeners = append(i.onDetachedListeners, detach)
 }
 
 // OnAttach  run this func when attached
 func (i *IncomingStreamTrack) OnAttach(attach func()) {
+	i.l.Lock()
+	defer i.l.Unlock()
 	i.onAttachedListeners = append(i.onAttachedListeners, attach)
 }
 
-// OnMediaFrame callback
-func (i *IncomingStreamTrack) OnMediaFrame(listener func([]byte, uint64)) {
-
-	if i.mediaframeMultiplexer == nil {
-		i.mediaframeMultiplexer = NewMediaFrameMultiplexer(i)
+// OnStop run this func once the track is stopped, right away if it already is
+func (i *IncomingStreamTrack) OnStop(stop IncomingTrackStopListener) {
+	i.l.Lock()
+	if i.receiver == nil {
+		i.l.Unlock()
+		stop()
+		return
 	}
-
-	i.mediaframeMultiplexer.SetMediaFrameListener(listener)
+	i.onStopListeners = append(i.onStopListeners, stop)
+	i.l.Unlock()
 }
 
 // Stop Removes the track from the incoming stream and also detaches any attached outgoing track or recorder
 func (i *IncomingStreamTrack) Stop() {
 
+	i.l.Lock()
+
 	if i.receiver == nil {
+		i.l.Unlock()
 		return
 	}
 
-	if i.mediaframeMultiplexer != nil {
-		i.mediaframeMultiplexer.Stop()
-		i.mediaframeMultiplexer = nil
+	multiplexer := i.mediaframeMultiplexer
+	encodings := i.encodings
+	receiver := i.receiver
+	listeners := i.onStopListeners
+
+	i.mediaframeMultiplexer = nil
+	i.encodings = nil
+	i.receiver = nil
+	i.onStopListeners = nil
+
+	i.l.Unlock()
+
+	if multiplexer != nil {
+		multiplexer.Stop()
 	}
 
-	for _, encoding := range i.encodings {
-		if encoding.depacketizer != nil {
-			encoding.depacketizer.Stop()
-			native.DeleteStreamTrackDepacketizer(encoding.depacketizer)
-		}
-		if encoding.source != nil {
-			native.DeleteRTPIncomingSourceGroup(encoding.source)
-		}
+	i.maxBitrate.close()
+	i.keyFrames.stop()
+
+	// no more parsing once stopped
+	i.encodingInfosOnce.Do(func() {})
+	for _, tracker := range i.encodingInfos {
+		tracker.Stop()
 	}
 
-	i.encodings = nil
+	for _, encoding := range encodings {
+		encoding.backend.delete()
+	}
 
-	i.receiver = nil
+	if i.ownsReceiver {
+		receiver.delete()
+	}
+
+	for _, stop := range listeners {
+		stop()
+	}
 }
@@ -0,0 +1,154 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"strconv"
+
+	native "github.com/notedit/media-server-go/wrapper"
+	"github.com/notedit/sdp"
+)
+
+// GetSource  get native RTPIncomingSourceGroup
+func (e *Encoding) GetSource() native.RTPIncomingSourceGroup {
+	if n, ok := e.backend.(*nativeEncoding); ok {
+		return n.source
+	}
+	return nil
+}
+
+// GetDepacketizer  get native StreamTrackDepacketizer
+func (e *Encoding) GetDepacketizer() native.StreamTrackDepacketizer {
+	if n, ok := e.backend.(*nativeEncoding); ok {
+		return n.depacketizer
+	}
+	return nil
+}
+
+// getStatsFromIncomingSource read the counters of the source, with its layers not aggregated
+func getStatsFromIncomingSource(source native.RTPIncomingSource) *IncomingStats {
+
+	stats := &IncomingStats{
+		LostPackets:    source.GetLostPackets(),
+		DropPackets:    source.GetDropPackets(),
+		NumPackets:     source.GetNumPackets(),
+		NumRTCPPackets: source.GetNumRTCPPackets(),
+		TotalBytes:     source.GetTotalBytes(),
+		TotalRTCPBytes: source.GetTotalRTCPBytes(),
+		TotalPLIs:      source.GetTotalPLIs(),
+		TotalNACKs:     source.GetTotalNACKs(),
+		Bitrate:        source.GetBitrate(),
+		Layers:         []*Layer{},
+	}
+
+	layers := source.Layers()
+
+	var i int64
+	for i = 0; i < layers.Size(); i++ {
+		layer := layers.Get(int64(i))
+
+		stats.Layers = append(stats.Layers, &Layer{
+			SpatialLayerId:  int(layer.GetSpatialLayerId()),
+			TemporalLayerId: int(layer.GetTemporalLayerId()),
+			TotalBytes:      layer.GetTotalBytes(),
+			NumPackets:      layer.GetNumPackets(),
+			Bitrate:         layer.GetBitrate(),
+		})
+	}
+
+	return stats
+}
+
+// NewIncomingStreamTrack Create incoming audio/video track
+func NewIncomingStreamTrack(media string, id string, receiver native.RTPReceiverFacade, sources map[string]native.RTPIncomingSourceGroup) *IncomingStreamTrack {
+
+	encodings := make([]*Encoding, 0)
+	for k, source := range sources {
+		encodings = append(encodings, &Encoding{id: k, backend: newNativeEncoding(source)})
+	}
+
+	track := newBackendIncomingStreamTrack(media, id, &nativeReceiver{receiver: receiver}, encodings)
+
+	track.leakGuard = newNativeLeakGuard("IncomingStreamTrack", receiver)
+	track.keyFrames.receiver = newKeyFrameRequestReceiver(track.keyFrames)
+
+	for _, encoding := range track.encodings {
+		k := encoding.id
+		source := encoding.GetSource()
+		track.leakGuard.add(source, encoding.GetDepacketizer())
+
+		//Add ssrcs to track info
+		if source.GetMedia().GetSsrc() > 0 {
+			track.trackInfo.AddSSRC(source.GetMedia().GetSsrc())
+		}
+
+		if source.GetRtx().GetSsrc() > 0 {
+			track.trackInfo.AddSSRC(source.GetRtx().GetSsrc())
+		}
+
+		if source.GetFec().GetSsrc() > 0 {
+			track.trackInfo.AddSSRC(source.GetFec().GetSsrc())
+		}
+
+		//Add RTX and FEC groups
+		if source.GetRtx().GetSsrc() > 0 {
+			sourceGroup := sdp.NewSourceGroupInfo("FID", []uint{source.GetMedia().GetSsrc(), source.GetRtx().GetSsrc()})
+			track.trackInfo.AddSourceGroup(sourceGroup)
+		}
+
+		if source.GetFec().GetSsrc() > 0 {
+			sourceGroup := sdp.NewSourceGroupInfo("FEC-FR", []uint{source.GetMedia().GetSsrc(), source.GetFec().GetSsrc()})
+			track.trackInfo.AddSourceGroup(sourceGroup)
+		}
+
+		// if simulcast
+		if len(k) > 0 {
+			// make soure the pasused
+			encodingInfo := sdp.NewTrackEncodingInfo(k, false)
+			if source.GetMedia().GetSsrc() > 0 {
+				ssrc := strconv.FormatUint(uint64(source.GetMedia().GetSsrc()), 10)
+				encodingInfo.AddParam("ssrc", ssrc)
+			}
+			track.trackInfo.AddEncoding(encodingInfo)
+		}
+	}
+
+	return track
+}
+
+// GetSSRCs get all RTPIncomingSource include "media" "rtx" "fec"
+func (i *IncomingStreamTrack) GetSSRCs() []map[string]native.RTPIncomingSource {
+
+	i.l.Lock()
+	defer i.l.Unlock()
+
+	ssrcs := make([]map[string]native.RTPIncomingSource, 0)
+
+	for _, encoding := range i.encodings {
+		ssrcs = append(ssrcs, map[string]native.RTPIncomingSource{
+			"media": encoding.GetSource().GetMedia(),
+			"rtx":   encoding.GetSource().GetRtx(),
+			"fec":   encoding.GetSource().GetFec(),
+		})
+	}
+	return ssrcs
+}
+
+// OnMediaFrame callback
+func (i *IncomingStreamTrack) OnMediaFrame(listener func([]byte, uint64)) {
+
+	i.l.Lock()
+	defer i.l.Unlock()
+
+	encoding := i.firstEncoding()
+	if i.receiver == nil || encoding == nil {
+		return
+	}
+
+	if i.mediaframeMultiplexer == nil {
+		i.mediaframeMultiplexer = newMediaFrameMultiplexer(i, encoding)
+	}
+
+	i.mediaframeMultiplexer.(*MediaFrameMultiplexer).SetMediaFrameListener(listener)
+}
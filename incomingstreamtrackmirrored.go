@@ -1,6 +1,11 @@
+//go:build cgo
+// +build cgo
+
 package mediaserver
 
 import (
+	"sync/atomic"
+
 	"github.com/notedit/media-server-go/wrapper"
 	"github.com/notedit/sdp"
 )
@@ -16,8 +21,8 @@ type mirrorEncoding struct {
 
 type IncomingStreamTrackMirrored struct {
 	track     *IncomingStreamTrack
-	receiver  native.RTPReceiverFacade
-	counter   int
+	receiver  receiverBackend
+	counter   int32
 	encodings []*mirrorEncoding
 }
 
@@ -26,13 +31,18 @@ func NewMirrorIncomingTrack(track *IncomingStreamTrack, timeService native.TimeS
 	mirror := &IncomingStreamTrackMirrored{}
 
 	mirror.track = track
-	mirror.receiver = track.receiver
-	mirror.counter = 0
 	mirror.encodings = []*mirrorEncoding{}
 
-	for _,encoding :=  range track.GetEncodings() {
-		source := native.NewRTPIncomingMediaStreamMultiplexer(encoding.source.GetMedia().GetSsrc(), timeService)
-		encoding.source.AddListener(source)
+	var encodings []*Encoding
+	track.withReceiver(func(receiver receiverBackend, trackEncodings []*Encoding) {
+		mirror.receiver = receiver
+		encodings = trackEncodings
+	})
+
+	for _,encoding :=  range encodings {
+		source := native.NewRTPIncomingMediaStreamMultiplexer(encoding.GetSource().GetMedia().GetSsrc(), timeService)
+		trackNative("RTPIncomingMediaStreamMultiplexer", source)
+		encoding.GetSource().AddListener(source)
 
 		newEncoding := &mirrorEncoding{
 			id:           encoding.id,
@@ -40,6 +50,8 @@ func NewMirrorIncomingTrack(track *IncomingStreamTrack, timeService native.TimeS
 			depacketizer: native.NewStreamTrackDepacketizer(source.SwigGetRTPIncomingMediaStream()),
 		}
 
+		trackNative("StreamTrackDepacketizer", newEncoding.depacketizer)
+
 		mirror.encodings = append(mirror.encodings, newEncoding)
 
 	}
@@ -79,13 +91,7 @@ func (t *IncomingStreamTrackMirrored) GetMedia() string {
 
 func (t *IncomingStreamTrackMirrored) Attached() bool {
 
-	t.counter += 1
-
-	if t.counter == 1 {
-		return true
-	}
-
-	return false
+	return atomic.AddInt32(&t.counter, 1) == 1
 }
 
 
@@ -97,17 +103,15 @@ func (t *IncomingStreamTrackMirrored) Refresh()  {
 
 func (t *IncomingStreamTrackMirrored) Detached() bool {
 
-	if t.counter == 0 {
-		return true
-	}
-
-	t.counter -= 1
-
-	if t.counter == 0 {
-		return true
+	for {
+		counter := atomic.LoadInt32(&t.counter)
+		if counter == 0 {
+			return true
+		}
+		if atomic.CompareAndSwapInt32(&t.counter, counter, counter-1) {
+			return counter == 1
+		}
 	}
-
-	return false
 }
 
 
@@ -120,8 +124,10 @@ func (t *IncomingStreamTrackMirrored) Stop() {
 	for i,encoding := range t.track.GetEncodings()  {
 		mencoding := t.encodings[i]
 		encoding.GetSource().RemoveListener(mencoding.source)
+		untrackNative(t.encodings[i].source)
 		native.DeleteRTPIncomingMediaStreamMultiplexer(t.encodings[i].source)
 		mencoding.depacketizer.Stop()
+		untrackNative(mencoding.depacketizer)
 		native.DeleteStreamTrackDepacketizer(mencoding.depacketizer)
 	}
 
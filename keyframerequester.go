@@ -0,0 +1,201 @@
+package mediaserver
+
+import (
+	"sync"
+	"time"
+)
+
+// KeyFrameRequestMethod rtcp message used to ask the sender for a key frame
+type KeyFrameRequestMethod string
+
+const (
+	// KeyFrameRequestPLI always send a picture loss indication
+	KeyFrameRequestPLI KeyFrameRequestMethod = "pli"
+	// KeyFrameRequestFIR always send a full intra request
+	KeyFrameRequestFIR KeyFrameRequestMethod = "fir"
+	// KeyFrameRequestAuto send a fir when a new decoder starts on the track, a pli when a subscriber lost packets
+	KeyFrameRequestAuto KeyFrameRequestMethod = "auto"
+)
+
+// DefaultKeyFrameRequestInterval minimum time between two key frame requests sent upstream for the same ssrc
+const DefaultKeyFrameRequestInterval = 500 * time.Millisecond
+
+// KeyFrameRequestStats key frame request counters.
+// Received requests are either Sent upstream, right away or once the interval elapsed, or Suppressed
+type KeyFrameRequestStats struct {
+	Received   uint64
+	Sent       uint64
+	Suppressed uint64
+}
+
+type keyFrameRequestState struct {
+	last   time.Time
+	timer  *time.Timer
+	firSeq byte
+	// newDecoder a request waiting for the timer came from a new decoder
+	newDecoder bool
+}
+
+// keyFrameRequester coalesce the key frame requests of an incoming track, from the subscribers and from the server
+type keyFrameRequester struct {
+	method      KeyFrameRequestMethod
+	minInterval time.Duration
+	ssrcs       map[uint]*keyFrameRequestState
+	stats       KeyFrameRequestStats
+	send        func(ssrc uint, fir bool, seq byte)
+	stopped     bool
+	lock        sync.Mutex
+
+	// receiver given to the transponders, its plis come back to the requester
+	receiver receiverBackend
+}
+
+func newKeyFrameRequester(send func(ssrc uint, fir bool, seq byte)) *keyFrameRequester {
+	return &keyFrameRequester{
+		method:      KeyFrameRequestPLI,
+		minInterval: DefaultKeyFrameRequestInterval,
+		ssrcs:       make(map[uint]*keyFrameRequestState),
+		send:        send,
+	}
+}
+
+// newTrackKeyFrameRequester create a requester sending through the track receiver
+func newTrackKeyFrameRequester(track *IncomingStreamTrack) *keyFrameRequester {
+
+	return newKeyFrameRequester(func(ssrc uint, fir bool, seq byte) {
+		track.withReceiver(func(receiver receiverBackend, encodings []*Encoding) {
+			if fir {
+				receiver.sendFIR(ssrc, seq)
+			} else {
+				receiver.sendPLI(ssrc)
+			}
+		})
+	})
+}
+
+func (k *keyFrameRequester) setMethod(method KeyFrameRequestMethod) {
+	k.lock.Lock()
+	k.method = method
+	k.lock.Unlock()
+}
+
+func (k *keyFrameRequester) setMinInterval(interval time.Duration) {
+	k.lock.Lock()
+	k.minInterval = interval
+	k.lock.Unlock()
+}
+
+func (k *keyFrameRequester) getStats() KeyFrameRequestStats {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return k.stats
+}
+
+// request ask for a key frame on the ssrc, newDecoder when a subscriber starts decoding the track
+func (k *keyFrameRequester) request(ssrc uint, newDecoder bool) {
+
+	k.lock.Lock()
+
+	if k.stopped {
+		k.lock.Unlock()
+		return
+	}
+
+	k.stats.Received++
+
+	state, ok := k.ssrcs[ssrc]
+	if !ok {
+		state = &keyFrameRequestState{}
+		k.ssrcs[ssrc] = state
+	}
+
+	// a request is already waiting for the interval
+	if state.timer != nil {
+		state.newDecoder = state.newDecoder || newDecoder
+		k.stats.Suppressed++
+		k.lock.Unlock()
+		return
+	}
+
+	wait := time.Until(state.last.Add(k.minInterval))
+
+	// the key frame already requested may have been sent before this subscriber asked, request again later
+	if wait > 0 {
+		state.newDecoder = newDecoder
+		state.timer = time.AfterFunc(wait, func() {
+			k.fire(ssrc)
+		})
+		k.lock.Unlock()
+		return
+	}
+
+	fir, seq := k.sent(state, newDecoder)
+
+	k.lock.Unlock()
+
+	k.send(ssrc, fir, seq)
+}
+
+func (k *keyFrameRequester) fire(ssrc uint) {
+
+	k.lock.Lock()
+
+	state := k.ssrcs[ssrc]
+	if k.stopped || state == nil || state.timer == nil {
+		k.lock.Unlock()
+		return
+	}
+
+	state.timer = nil
+
+	fir, seq := k.sent(state, state.newDecoder)
+
+	k.lock.Unlock()
+
+	k.send(ssrc, fir, seq)
+}
+
+// sent account a request sent now, needs the lock
+func (k *keyFrameRequester) sent(state *keyFrameRequestState, newDecoder bool) (bool, byte) {
+
+	state.last = time.Now()
+	state.newDecoder = false
+	k.stats.Sent++
+
+	fir := k.method == KeyFrameRequestFIR || (k.method == KeyFrameRequestAuto && newDecoder)
+	if fir {
+		state.firSeq++
+	}
+
+	return fir, state.firSeq
+}
+
+func (k *keyFrameRequester) stop() {
+
+	k.lock.Lock()
+
+	k.stopped = true
+
+	for _, state := range k.ssrcs {
+		if state.timer != nil {
+			state.timer.Stop()
+			state.timer = nil
+		}
+	}
+
+	receiver := k.receiver
+	k.receiver = nil
+
+	k.lock.Unlock()
+
+	if receiver != nil {
+		receiver.delete()
+	}
+}
+
+// getReceiver get the receiver given to the transponders, nil once stopped
+func (k *keyFrameRequester) getReceiver() receiverBackend {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return k.receiver
+}
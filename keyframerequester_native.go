@@ -0,0 +1,53 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	native "github.com/notedit/media-server-go/wrapper"
+)
+
+type keyFrameRequestListener interface {
+	native.KeyFrameRequestListener
+	deleteKeyFrameRequestListener()
+}
+
+type goKeyFrameRequestListener struct {
+	native.KeyFrameRequestListener
+}
+
+func (l *goKeyFrameRequestListener) deleteKeyFrameRequestListener() {
+	native.DeleteDirectorKeyFrameRequestListener(l.KeyFrameRequestListener)
+}
+
+type overwrittenKeyFrameRequestListener struct {
+	requester *keyFrameRequester
+}
+
+func (p *overwrittenKeyFrameRequestListener) OnKeyFrameRequest(ssrc uint) {
+	p.requester.request(ssrc, false)
+}
+
+// keyFrameRequestReceiver the native receiver given to the transponders, its plis come back to the requester
+type keyFrameRequestReceiver struct {
+	*nativeReceiver
+	listener keyFrameRequestListener
+}
+
+func newKeyFrameRequestReceiver(requester *keyFrameRequester) *keyFrameRequestReceiver {
+
+	p := native.NewDirectorKeyFrameRequestListener(&overwrittenKeyFrameRequestListener{requester: requester})
+	listener := &goKeyFrameRequestListener{KeyFrameRequestListener: p}
+	receiver := native.KeyFrameRequestToReceiver(listener)
+	trackNative("RTPReceiverFacade", receiver)
+
+	return &keyFrameRequestReceiver{
+		nativeReceiver: &nativeReceiver{receiver: receiver},
+		listener:       listener,
+	}
+}
+
+func (k *keyFrameRequestReceiver) delete() {
+	k.nativeReceiver.delete()
+	k.listener.deleteKeyFrameRequestListener()
+}
@@ -0,0 +1,88 @@
+package mediaserver
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_KeyFrameRequesterCoalesce(t *testing.T) {
+
+	var lock sync.Mutex
+	sent := 0
+
+	requester := newKeyFrameRequester(func(ssrc uint, fir bool, seq byte) {
+		lock.Lock()
+		sent++
+		lock.Unlock()
+	})
+	requester.setMinInterval(50 * time.Millisecond)
+
+	// fifty subscribers joining at once
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			requester.request(1, false)
+		}()
+	}
+	wg.Wait()
+
+	time.Sleep(100 * time.Millisecond)
+
+	stats := requester.getStats()
+	lock.Lock()
+	defer lock.Unlock()
+
+	// one right away, one once the interval elapsed for the late ones
+	if sent != 2 || stats.Sent != 2 {
+		t.Fatal("wrong number of requests sent", sent, stats.Sent)
+	}
+
+	if stats.Received != 50 || stats.Suppressed != 48 {
+		t.Fatal("wrong counters", stats)
+	}
+
+	requester.stop()
+}
+
+func Test_KeyFrameRequesterMethod(t *testing.T) {
+
+	type sentRequest struct {
+		fir bool
+		seq byte
+	}
+	requests := []sentRequest{}
+
+	requester := newKeyFrameRequester(func(ssrc uint, fir bool, seq byte) {
+		requests = append(requests, sentRequest{fir, seq})
+	})
+	requester.setMinInterval(0)
+
+	requester.request(1, true)
+
+	requester.setMethod(KeyFrameRequestAuto)
+	requester.request(1, false)
+	requester.request(1, true)
+
+	requester.setMethod(KeyFrameRequestFIR)
+	requester.request(1, false)
+
+	expected := []sentRequest{{false, 0}, {false, 0}, {true, 1}, {true, 2}}
+	if len(requests) != len(expected) {
+		t.Fatal("wrong number of requests", requests)
+	}
+	for i := range expected {
+		if requests[i] != expected[i] {
+			t.Fatal("wrong request", i, requests[i])
+		}
+	}
+
+	requester.stop()
+	requester.request(1, false)
+
+	if len(requests) != len(expected) {
+		t.Fatal("request sent after stop")
+	}
+}
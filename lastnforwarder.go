@@ -0,0 +1,463 @@
+package mediaserver
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ActiveSpeakerChangedListener listener, called with the audio track of the new active speaker
+type ActiveSpeakerChangedListener func(track *IncomingStreamTrack)
+
+// speakerDetector detect the active speaker among the audio tracks, the ActiveSpeakerDetector outside the tests
+type speakerDetector interface {
+	AddSpeaker(track *IncomingStreamTrack)
+	RemoveSpeaker(track *IncomingStreamTrack)
+	OnActiveSpeakerChanged(listener ActiveSpeakerChangedListener)
+	Stop()
+}
+
+// LastNForwarder forward to each subscriber the video of the N most recently active speakers.
+// Subscribers own N outgoing video tracks, the forwarder swaps the source of those tracks as speakers change
+type LastNForwarder struct {
+	n           int
+	detector    speakerDetector
+	speakers    map[string]*lastNSpeaker
+	recent      []string
+	subscribers map[string]*lastNSubscriber
+	lock        sync.Mutex
+	// applying the slots are being changed, dirty they changed again meanwhile
+	applying bool
+	dirty    bool
+}
+
+type lastNSpeaker struct {
+	id    string
+	audio *IncomingStreamTrack
+	video *IncomingStreamTrack
+}
+
+type lastNSubscriber struct {
+	id     string
+	slots  []*lastNSlot
+	pinned []string
+	// speaker id forwarded on each slot, empty when muted
+	forwarded []string
+}
+
+// lastNSlot an outgoing track of a subscriber, guarded by the forwarder lock
+type lastNSlot struct {
+	track *OutgoingStreamTrack
+	// muted by the forwarder, a track muted by the application is left muted
+	muted bool
+	// current the video forwarded, pending the one it is switching to
+	current *IncomingStreamTrack
+	pending *IncomingStreamTrack
+	// failed no key frame in time to switch to this video
+	failed *IncomingStreamTrack
+}
+
+// newLastNForwarder create a forwarder of n videos per subscriber, following the speakers of the detector
+func newLastNForwarder(n int, detector speakerDetector) *LastNForwarder {
+
+	forwarder := &LastNForwarder{
+		n:           n,
+		detector:    detector,
+		speakers:    make(map[string]*lastNSpeaker),
+		recent:      make([]string, 0),
+		subscribers: make(map[string]*lastNSubscriber),
+	}
+
+	forwarder.detector.OnActiveSpeakerChanged(func(track *IncomingStreamTrack) {
+		forwarder.onActiveSpeakerChanged(track)
+	})
+
+	return forwarder
+}
+
+// AddSpeaker add a speaker, its audio is used for detection and its video is forwarded. It starts as the least recent speaker
+func (l *LastNForwarder) AddSpeaker(id string, audio *IncomingStreamTrack, video *IncomingStreamTrack) error {
+
+	l.lock.Lock()
+
+	if _, ok := l.speakers[id]; ok {
+		l.lock.Unlock()
+		return fmt.Errorf("speaker %s already added", id)
+	}
+
+	l.speakers[id] = &lastNSpeaker{
+		id:    id,
+		audio: audio,
+		video: video,
+	}
+
+	l.recent = append(l.recent, id)
+
+	if audio != nil {
+		l.detector.AddSpeaker(audio)
+	}
+
+	l.update()
+	l.unlock()
+
+	return nil
+}
+
+// RemoveSpeaker remove a speaker, its video is replaced on every subscriber
+func (l *LastNForwarder) RemoveSpeaker(id string) {
+
+	l.lock.Lock()
+
+	speaker, ok := l.speakers[id]
+	if !ok {
+		l.lock.Unlock()
+		return
+	}
+
+	if speaker.audio != nil {
+		l.detector.RemoveSpeaker(speaker.audio)
+	}
+
+	delete(l.speakers, id)
+	l.recent = removeString(l.recent, id)
+
+	l.update()
+	l.unlock()
+}
+
+// AddSubscriber add a subscriber with the video tracks to forward on, the speaker with the same id is never forwarded to it
+func (l *LastNForwarder) AddSubscriber(id string, slots []*OutgoingStreamTrack) error {
+
+	if len(slots) == 0 {
+		return errors.New("subscriber needs at least one track")
+	}
+
+	if len(slots) > l.n {
+		slots = slots[:l.n]
+	}
+
+	subscriber := &lastNSubscriber{
+		id:        id,
+		slots:     make([]*lastNSlot, 0, len(slots)),
+		pinned:    make([]string, 0),
+		forwarded: make([]string, len(slots)),
+	}
+
+	for _, track := range slots {
+		slot := &lastNSlot{track: track}
+		// before the first switch
+		track.OnSwitched(func(incomingTrack *IncomingStreamTrack, err error) {
+			l.onSlotSwitched(slot, incomingTrack, err)
+		})
+		subscriber.slots = append(subscriber.slots, slot)
+	}
+
+	l.lock.Lock()
+
+	if _, ok := l.subscribers[id]; ok {
+		l.lock.Unlock()
+		return fmt.Errorf("subscriber %s already added", id)
+	}
+
+	l.subscribers[id] = subscriber
+
+	l.update()
+	l.unlock()
+
+	return nil
+}
+
+// RemoveSubscriber remove a subscriber, its tracks are left as they are
+func (l *LastNForwarder) RemoveSubscriber(id string) {
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.subscribers, id)
+}
+
+// Pin always forward the speaker to the subscriber, pinned speakers come before the active ones
+func (l *LastNForwarder) Pin(subscriberId string, speakerId string) error {
+
+	l.lock.Lock()
+
+	subscriber, ok := l.subscribers[subscriberId]
+	if !ok {
+		l.lock.Unlock()
+		return fmt.Errorf("subscriber %s not found", subscriberId)
+	}
+
+	for _, pinned := range subscriber.pinned {
+		if pinned == speakerId {
+			l.lock.Unlock()
+			return nil
+		}
+	}
+
+	subscriber.pinned = append(subscriber.pinned, speakerId)
+
+	l.update()
+	l.unlock()
+
+	return nil
+}
+
+// Unpin forward the speaker to the subscriber only when it is recently active
+func (l *LastNForwarder) Unpin(subscriberId string, speakerId string) {
+
+	l.lock.Lock()
+
+	subscriber, ok := l.subscribers[subscriberId]
+	if !ok {
+		l.lock.Unlock()
+		return
+	}
+
+	subscriber.pinned = removeString(subscriber.pinned, speakerId)
+
+	l.update()
+	l.unlock()
+}
+
+// GetForwarded get the speaker id forwarded on each track of the subscriber, empty for muted tracks
+func (l *LastNForwarder) GetForwarded(subscriberId string) []string {
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	subscriber, ok := l.subscribers[subscriberId]
+	if !ok {
+		return nil
+	}
+
+	forwarded := make([]string, len(subscriber.forwarded))
+	copy(forwarded, subscriber.forwarded)
+	return forwarded
+}
+
+// GetRecentSpeakers get the speaker ids, most recently active first
+func (l *LastNForwarder) GetRecentSpeakers() []string {
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	recent := make([]string, len(l.recent))
+	copy(recent, l.recent)
+	return recent
+}
+
+// Stop stop detecting, the subscriber tracks are left as they are
+func (l *LastNForwarder) Stop() {
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.detector.Stop()
+
+	l.speakers = make(map[string]*lastNSpeaker)
+	l.subscribers = make(map[string]*lastNSubscriber)
+	l.recent = nil
+}
+
+func (l *LastNForwarder) onActiveSpeakerChanged(track *IncomingStreamTrack) {
+
+	l.lock.Lock()
+
+	for id, speaker := range l.speakers {
+		if speaker.audio == track {
+			l.recent = append([]string{id}, removeString(l.recent, id)...)
+			l.update()
+			break
+		}
+	}
+
+	l.unlock()
+}
+
+// update recompute the forwarded speakers of every subscriber, they are applied by unlock
+func (l *LastNForwarder) update() {
+
+	for _, subscriber := range l.subscribers {
+
+		selected := selectLastN(subscriber.id, subscriber.pinned, l.recent, len(subscriber.slots), func(id string) bool {
+			speaker, ok := l.speakers[id]
+			return ok && speaker.video != nil
+		})
+
+		subscriber.forwarded = assignSlots(subscriber.forwarded, selected)
+	}
+
+	l.dirty = true
+}
+
+// unlock release the lock and apply the forwarded speakers to the slots.
+// The tracks are not called locked, their listeners come back to the forwarder
+func (l *LastNForwarder) unlock() {
+
+	if l.applying || !l.dirty {
+		// the running apply loops again
+		l.lock.Unlock()
+		return
+	}
+
+	l.applying = true
+
+	for l.dirty {
+		l.dirty = false
+
+		actions := []func(){}
+		for _, subscriber := range l.subscribers {
+			for i, slot := range subscriber.slots {
+				actions = append(actions, l.applySlot(slot, subscriber.forwarded[i])...)
+			}
+		}
+
+		l.lock.Unlock()
+
+		for _, action := range actions {
+			action()
+		}
+
+		l.lock.Lock()
+	}
+
+	l.applying = false
+	l.lock.Unlock()
+}
+
+// applySlot get what to do for the slot to forward the speaker, the slot is updated as if it was done
+func (l *LastNForwarder) applySlot(slot *lastNSlot, speakerId string) []func() {
+
+	track := slot.track
+	actions := []func(){}
+
+	if speakerId == "" {
+		// the track placeholder is sent meanwhile, if any
+		if !slot.muted && !track.IsMuted() {
+			slot.muted = true
+			actions = append(actions, func() { track.Mute(true) })
+		}
+		return actions
+	}
+
+	video := l.speakers[speakerId].video
+
+	transponder := track.GetTransponder()
+
+	switch {
+	case transponder == nil || slot.failed == video:
+		// nothing forwarded to keep, or it would never switch
+		slot.current, slot.pending, slot.failed = video, nil, nil
+		actions = append(actions, func() { track.AttachTo(video) })
+	case slot.current == video || slot.pending == video:
+	case transponder.GetIncomingTrack() == video:
+		slot.current = video
+	default:
+		// the previous speaker is forwarded until the new one sends a key frame, onSlotSwitched is called then
+		slot.pending = video
+		actions = append(actions, func() {
+			if err := track.Switch(video); err != nil {
+				componentLogger("lastn").Error("last n forward error", "err", err)
+			}
+		})
+	}
+
+	// unmuted once the previous speaker, deselected, can not be sent anymore
+	if slot.muted && slot.current == video {
+		slot.muted = false
+		actions = append(actions, func() { track.Mute(false) })
+	}
+
+	return actions
+}
+
+// onSlotSwitched the slot forwards the new speaker, or gave up waiting for its key frame
+func (l *LastNForwarder) onSlotSwitched(slot *lastNSlot, video *IncomingStreamTrack, err error) {
+
+	l.lock.Lock()
+
+	if slot.pending != video {
+		// switched by the application, or overridden
+		l.lock.Unlock()
+		return
+	}
+
+	slot.pending = nil
+	if err != nil {
+		slot.failed = video
+	} else {
+		slot.current = video
+	}
+
+	l.dirty = true
+	l.unlock()
+}
+
+// selectLastN get the pinned speakers and then the most recent ones, excluding the subscriber itself
+func selectLastN(self string, pinned []string, recent []string, n int, available func(string) bool) []string {
+
+	selected := make([]string, 0, n)
+	taken := map[string]bool{self: true}
+
+	for _, list := range [][]string{pinned, recent} {
+		for _, id := range list {
+			if len(selected) == n {
+				return selected
+			}
+			if taken[id] || !available(id) {
+				continue
+			}
+			taken[id] = true
+			selected = append(selected, id)
+		}
+	}
+
+	return selected
+}
+
+// assignSlots keep the selected speakers already forwarded on their slot and put the new ones on the free slots
+func assignSlots(current []string, selected []string) []string {
+
+	assigned := make([]string, len(current))
+	wanted := make(map[string]bool)
+
+	for _, id := range selected {
+		wanted[id] = true
+	}
+
+	kept := make(map[string]bool)
+
+	for i, id := range current {
+		if id != "" && wanted[id] {
+			assigned[i] = id
+			kept[id] = true
+		}
+	}
+
+	slot := 0
+	for _, id := range selected {
+		if kept[id] {
+			continue
+		}
+		for slot < len(assigned) && assigned[slot] != "" {
+			slot++
+		}
+		if slot == len(assigned) {
+			break
+		}
+		assigned[slot] = id
+	}
+
+	return assigned
+}
+
+func removeString(list []string, value string) []string {
+
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != value {
+			result = append(result, item)
+		}
+	}
+	return result
+}
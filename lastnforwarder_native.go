@@ -0,0 +1,15 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+// NewLastNForwarder create a forwarder of n videos per subscriber
+func NewLastNForwarder(n int) *LastNForwarder {
+	return newLastNForwarder(n, NewActiveSpeakerDetector())
+}
+
+// GetActiveSpeakerDetector get the detector, to tune it
+func (l *LastNForwarder) GetActiveSpeakerDetector() *ActiveSpeakerDetector {
+	detector, _ := l.detector.(*ActiveSpeakerDetector)
+	return detector
+}
@@ -0,0 +1,178 @@
+package mediaserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_SelectLastN(t *testing.T) {
+
+	all := func(string) bool { return true }
+
+	recent := []string{"alice", "bob", "carol", "dave"}
+
+	if selected := selectLastN("bob", nil, recent, 2, all); !reflect.DeepEqual(selected, []string{"alice", "carol"}) {
+		t.Fatal("wrong selection", selected)
+	}
+
+	if selected := selectLastN("bob", []string{"dave"}, recent, 2, all); !reflect.DeepEqual(selected, []string{"dave", "alice"}) {
+		t.Fatal("pinned speaker not first", selected)
+	}
+
+	// speakers without video are skipped
+	noCarol := func(id string) bool { return id != "carol" }
+	if selected := selectLastN("alice", nil, recent, 3, noCarol); !reflect.DeepEqual(selected, []string{"bob", "dave"}) {
+		t.Fatal("wrong selection", selected)
+	}
+}
+
+func Test_AssignSlots(t *testing.T) {
+
+	current := []string{"alice", "bob", "carol"}
+
+	// dave becomes active, carol is dropped, alice and bob stay on their slot
+	if assigned := assignSlots(current, []string{"dave", "alice", "bob"}); !reflect.DeepEqual(assigned, []string{"alice", "bob", "dave"}) {
+		t.Fatal("wrong assignment", assigned)
+	}
+
+	// fewer speakers than slots
+	if assigned := assignSlots(current, []string{"bob"}); !reflect.DeepEqual(assigned, []string{"", "bob", ""}) {
+		t.Fatal("wrong assignment", assigned)
+	}
+
+	if assigned := assignSlots([]string{"", ""}, []string{"alice", "bob", "carol"}); !reflect.DeepEqual(assigned, []string{"alice", "bob"}) {
+		t.Fatal("wrong assignment", assigned)
+	}
+}
+
+func Test_LastNForwarderMutedSlot(t *testing.T) {
+
+	forwarder := newLastNForwarder(1, newFakeSpeakerDetector())
+
+	encodingA := newFakeEncoding(1, 0)
+	videoA := newFakeIncomingStreamTrack("video", "a", map[string]*fakeEncoding{"a": encodingA})
+	encodingB := newFakeEncoding(2, 0)
+	videoB := newFakeIncomingStreamTrack("video", "b", map[string]*fakeEncoding{"b": encodingB})
+
+	slot, sender := newFakeOutgoingStreamTrack("video", "slot")
+
+	mutes := []bool{}
+	slot.OnMute(func(muted bool) { mutes = append(mutes, muted) })
+
+	// nobody to forward yet
+	forwarder.AddSubscriber("s", []*OutgoingStreamTrack{slot})
+	if !slot.IsMuted() || !reflect.DeepEqual(mutes, []bool{true}) {
+		t.Fatal("empty slot not muted", mutes)
+	}
+
+	forwarder.AddSpeaker("a", nil, videoA)
+	if slot.IsMuted() || sender.last().forwarding() != "a" {
+		t.Fatal("a not forwarded")
+	}
+
+	forwarder.RemoveSpeaker("a")
+	if !slot.IsMuted() || sender.last().forwarding() != "" || !reflect.DeepEqual(mutes, []bool{true, false, true}) {
+		t.Fatal("slot not muted once a left", mutes)
+	}
+
+	// a, deselected, is not sent until b sends a key frame
+	forwarder.AddSpeaker("b", nil, videoB)
+	if !slot.IsMuted() || sender.last().forwarding() != "" {
+		t.Fatal("slot unmuted before the switch", sender.last().forwarding())
+	}
+
+	encodingB.keyFrame()
+	if slot.IsMuted() || sender.last().forwarding() != "b" || !reflect.DeepEqual(forwarder.GetForwarded("s"), []string{"b"}) {
+		t.Fatal("b not forwarded after its key frame", sender.last().forwarding())
+	}
+
+	// muted by the application, it stays muted
+	slot.Mute(true)
+	forwarder.RemoveSpeaker("b")
+	forwarder.AddSpeaker("a", nil, videoA)
+	encodingA.keyFrame()
+	if !slot.IsMuted() || sender.last().forwarding() != "" {
+		t.Fatal("slot muted by the application unmuted")
+	}
+
+	slot.Mute(false)
+	if sender.last().forwarding() != "a" {
+		t.Fatal("a not forwarded", sender.last().forwarding())
+	}
+}
+
+func Test_LastNForwarderPlaceholder(t *testing.T) {
+
+	forwarder := newLastNForwarder(1, newFakeSpeakerDetector())
+
+	videoA := newFakeIncomingStreamTrack("video", "a", map[string]*fakeEncoding{"a": newFakeEncoding(1, 0)})
+
+	slot, sender := newFakeOutgoingStreamTrack("video", "slot")
+	if err := slot.SetPlaceholder(newFakePlaceholder("video")); err != nil {
+		t.Fatal(err)
+	}
+
+	mutes := []bool{}
+	slot.OnMute(func(muted bool) { mutes = append(mutes, muted) })
+
+	forwarder.AddSubscriber("s", []*OutgoingStreamTrack{slot})
+	if sender.last().forwarding() != "placeholder" || !reflect.DeepEqual(mutes, []bool{true}) {
+		t.Fatal("placeholder not sent on the empty slot", mutes)
+	}
+
+	// nothing to wait for, the placeholder was sent
+	forwarder.AddSpeaker("a", nil, videoA)
+	if slot.IsMuted() || sender.last().forwarding() != "a" {
+		t.Fatal("a not forwarded", sender.last().forwarding())
+	}
+
+	forwarder.RemoveSpeaker("a")
+	if sender.last().forwarding() != "placeholder" || !reflect.DeepEqual(mutes, []bool{true, false, true}) {
+		t.Fatal("placeholder not sent once a left", mutes)
+	}
+}
+
+func Test_LastNForwarderActiveSpeaker(t *testing.T) {
+
+	detector := newFakeSpeakerDetector()
+	forwarder := newLastNForwarder(1, detector)
+
+	audioA := newFakeIncomingStreamTrack("audio", "audio-a", map[string]*fakeEncoding{"": newFakeEncoding(1, 0)})
+	videoA := newFakeIncomingStreamTrack("video", "video-a", map[string]*fakeEncoding{"a": newFakeEncoding(2, 0)})
+	audioB := newFakeIncomingStreamTrack("audio", "audio-b", map[string]*fakeEncoding{"": newFakeEncoding(3, 0)})
+	encodingB := newFakeEncoding(4, 0)
+	videoB := newFakeIncomingStreamTrack("video", "video-b", map[string]*fakeEncoding{"b": encodingB})
+
+	forwarder.AddSpeaker("a", audioA, videoA)
+	forwarder.AddSpeaker("b", audioB, videoB)
+
+	slot, sender := newFakeOutgoingStreamTrack("video", "slot")
+	forwarder.AddSubscriber("s", []*OutgoingStreamTrack{slot})
+
+	if !reflect.DeepEqual(forwarder.GetForwarded("s"), []string{"a"}) || slot.GetTransponder().GetIncomingTrack() != videoA {
+		t.Fatal("least recent speaker forwarded", forwarder.GetForwarded("s"))
+	}
+
+	detector.activate(audioB)
+
+	if !reflect.DeepEqual(forwarder.GetRecentSpeakers(), []string{"b", "a"}) {
+		t.Fatal("active speaker not first", forwarder.GetRecentSpeakers())
+	}
+
+	// a is forwarded until b sends a key frame
+	if slot.GetTransponder().GetIncomingTrack() != videoA {
+		t.Fatal("switched before the key frame")
+	}
+
+	encodingB.keyFrame()
+	if slot.GetTransponder().GetIncomingTrack() != videoB || sender.last().forwarding() != "b" {
+		t.Fatal("b not forwarded")
+	}
+
+	forwarder.RemoveSpeaker("b")
+	forwarder.Stop()
+
+	if detector.has(audioB) || !detector.stopped {
+		t.Fatal("detector not updated")
+	}
+}
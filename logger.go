@@ -0,0 +1,113 @@
+package mediaserver
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// NativeComponent component tag of the log records of the native media server
+const NativeComponent = "native"
+
+// logger *slog.Logger of the package, slog.Default when nil
+var logger atomic.Value
+
+var nativeLogOnce sync.Once
+
+// SetLogger route the package logs and the native logs to the logger, nil to go back to slog.Default.
+// Once called the native logs are not printed to stdout anymore, EnableLog EnableDebug and EnableUltraDebug still choose what is logged
+func SetLogger(l *slog.Logger) {
+
+	logger.Store(l)
+
+	nativeLogOnce.Do(hookNativeLogs)
+}
+
+// SetLogHandler route the package logs and the native logs to the handler
+func SetLogHandler(handler slog.Handler) {
+	SetLogger(slog.New(handler))
+}
+
+func getLogger() *slog.Logger {
+	if l, _ := logger.Load().(*slog.Logger); l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+// componentLogger logger tagging the records with the component
+func componentLogger(component string) *slog.Logger {
+	return getLogger().With("component", component)
+}
+
+// nativeLogLevels levels of the native log tags
+var nativeLogLevels = map[string]slog.Level{
+	"LOG":   slog.LevelInfo,
+	"DBG":   slog.LevelDebug,
+	"DEBUG": slog.LevelDebug,
+	"UDBG":  slog.LevelDebug - 4,
+	"WRN":   slog.LevelWarn,
+	"WARN":  slog.LevelWarn,
+	"ERR":   slog.LevelError,
+	"ERROR": slog.LevelError,
+}
+
+// parseNativeLogLine get the level, the class and the message of a native log line like
+// "[0x7f10][20190101 10:00:00.000][DBG]-DTLSICETransport::onData() message"
+func parseNativeLogLine(line string) (slog.Level, string, string) {
+
+	level := slog.LevelInfo
+	line = strings.TrimRight(line, "\r\n")
+
+	// thread, time and level tags
+	for strings.HasPrefix(line, "[") {
+		end := strings.Index(line, "]")
+		if end < 0 {
+			break
+		}
+		if tagLevel, ok := nativeLogLevels[strings.ToUpper(line[1:end])]; ok {
+			level = tagLevel
+		}
+		line = line[end+1:]
+	}
+
+	message := strings.TrimSpace(line)
+
+	// -Class::Method(), <Class and >Class when entering and leaving
+	name := strings.TrimLeft(message, "-<>")
+	if end := strings.IndexAny(name, " (["); end >= 0 {
+		name = name[:end]
+	}
+
+	class := ""
+	if end := strings.Index(name, "::"); end > 0 {
+		class = name[:end]
+	} else if name != "" && (message[0] == '<' || message[0] == '>') {
+		class = name
+	}
+
+	return level, class, message
+}
+
+// logNativeLine log a native line with the native component
+func logNativeLine(l *slog.Logger, line string) {
+
+	level, class, message := parseNativeLogLine(line)
+	if message == "" {
+		return
+	}
+
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+
+	if class != "" {
+		l.Log(ctx, level, message, "component", NativeComponent, "class", class)
+		return
+	}
+
+	l.Log(ctx, level, message, "component", NativeComponent)
+}
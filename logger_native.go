@@ -0,0 +1,23 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	native "github.com/notedit/media-server-go/wrapper"
+)
+
+var nativeLogListener native.LogListener
+
+type overwrittenLogListener struct{}
+
+func (p *overwrittenLogListener) OnLog(line string) {
+	logNativeLine(getLogger(), line)
+}
+
+// hookNativeLogs route the native logs to the package logger
+func hookNativeLogs() {
+	// kept for the whole process, the native side may log at any time
+	nativeLogListener = native.NewDirectorLogListener(&overwrittenLogListener{})
+	native.SetLogListener(nativeLogListener)
+}
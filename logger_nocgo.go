@@ -0,0 +1,7 @@
+//go:build !cgo
+// +build !cgo
+
+package mediaserver
+
+// hookNativeLogs nothing to hook, the native media server is only built with cgo
+func hookNativeLogs() {}
@@ -0,0 +1,63 @@
+package mediaserver
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_ParseNativeLogLine(t *testing.T) {
+
+	cases := []struct {
+		line    string
+		level   slog.Level
+		class   string
+		message string
+	}{
+		{"[0x7f10][20190101 10:00:00.000][DBG]-DTLSICETransport::onData() received\n", slog.LevelDebug, "DTLSICETransport", "-DTLSICETransport::onData() received"},
+		{"[0x7f10][20190101 10:00:00.000][LOG]<RTPBundleTransport\n", slog.LevelInfo, "RTPBundleTransport", "<RTPBundleTransport"},
+		{"[0x7f10][20190101 10:00:00.000][ERR]-Error binding port", slog.LevelError, "", "-Error binding port"},
+		{"[0x7f10][WRN]-RTPIncomingSourceGroup::AddPacket() out of order", slog.LevelWarn, "RTPIncomingSourceGroup", "-RTPIncomingSourceGroup::AddPacket() out of order"},
+		{"plain line", slog.LevelInfo, "", "plain line"},
+	}
+
+	for _, c := range cases {
+		level, class, message := parseNativeLogLine(c.line)
+		if level != c.level || class != c.class || message != c.message {
+			t.Fatalf("wrong parse of %q: %v %q %q", c.line, level, class, message)
+		}
+	}
+}
+
+func Test_LogNativeLine(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	logNativeLine(l, "[0x7f10][DBG]-DTLSICETransport::onData() filtered out")
+	logNativeLine(l, "[0x7f10][ERR]-DTLSICETransport::Send() failed")
+
+	out := buf.String()
+
+	if strings.Contains(out, "filtered out") {
+		t.Fatal("debug line logged at info level", out)
+	}
+
+	if !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "component=native") || !strings.Contains(out, "class=DTLSICETransport") {
+		t.Fatal("wrong record", out)
+	}
+}
+
+func Test_ComponentLogger(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	logger.Store(slog.New(slog.NewTextHandler(buf, nil)))
+	defer logger.Store((*slog.Logger)(nil))
+
+	componentLogger("transport").Info("hello")
+
+	if !strings.Contains(buf.String(), "component=transport") {
+		t.Fatal("missing component", buf.String())
+	}
+}
@@ -0,0 +1,283 @@
+package mediaserver
+
+import (
+	"sync"
+	"time"
+)
+
+// BitrateFeedback rtcp message used to cap the sender bitrate
+type BitrateFeedback string
+
+const (
+	// BitrateFeedbackREMB one cap for the whole track, honored by browsers
+	BitrateFeedbackREMB BitrateFeedback = "remb"
+	// BitrateFeedbackTMMBR one cap per encoding
+	BitrateFeedbackTMMBR BitrateFeedback = "tmmbr"
+)
+
+// MaxBitrateFeedbackInterval how often the cap is sent again, senders forget it otherwise
+var MaxBitrateFeedbackInterval = time.Second
+
+// IdleEncodingBitrate cap of the encodings nobody watches when demand capping is enabled, and of lazy tracks with nothing attached
+const IdleEncodingBitrate uint = 30000
+
+// UncappedBitrate sent once when a cap is removed, so the sender goes back to its own estimation
+const UncappedBitrate uint = 100000000
+
+// DefaultAudioBitrate bitrate reserved for an audio track without a maximum bitrate
+const DefaultAudioBitrate uint = 64000
+
+// demandHeadroom percent added to the bitrate of the watched encodings, so they are not starved
+const demandHeadroom = 15
+
+// maxBitrateController send the max bitrate feedback of an incoming track
+type maxBitrateController struct {
+	track         *IncomingStreamTrack
+	maxBitrate    uint
+	feedback      BitrateFeedback
+	demandCapping bool
+	// idle nothing is attached to a lazy track
+	idle         bool
+	transponders map[*Transponder]bool
+	capped       bool
+	stop         chan struct{}
+	lock         sync.Mutex
+}
+
+func newMaxBitrateController(track *IncomingStreamTrack) *maxBitrateController {
+	return &maxBitrateController{
+		track:        track,
+		feedback:     BitrateFeedbackREMB,
+		transponders: make(map[*Transponder]bool),
+	}
+}
+
+func (c *maxBitrateController) setMaxBitrate(bitrate uint) {
+	c.lock.Lock()
+	c.maxBitrate = bitrate
+	c.lock.Unlock()
+	c.update()
+}
+
+func (c *maxBitrateController) getMaxBitrate() uint {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.maxBitrate
+}
+
+func (c *maxBitrateController) setFeedback(feedback BitrateFeedback) {
+	c.lock.Lock()
+	c.feedback = feedback
+	c.lock.Unlock()
+	c.update()
+}
+
+func (c *maxBitrateController) setDemandCapping(enabled bool) {
+	c.lock.Lock()
+	c.demandCapping = enabled
+	c.lock.Unlock()
+	c.update()
+}
+
+// updateIdle derive idle from the lazy flag and the attach counter under the lock,
+// so the last attach or detach wins whatever the order the calls get the lock in
+func (c *maxBitrateController) updateIdle() {
+	c.lock.Lock()
+	c.idle = c.track.IsLazyUpstream() && !c.track.IsAttached()
+	c.lock.Unlock()
+	c.update()
+}
+
+func (c *maxBitrateController) isIdle() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.idle
+}
+
+func (c *maxBitrateController) addTransponder(transponder *Transponder) {
+	c.lock.Lock()
+	c.transponders[transponder] = true
+	c.lock.Unlock()
+}
+
+func (c *maxBitrateController) removeTransponder(transponder *Transponder) {
+	c.lock.Lock()
+	delete(c.transponders, transponder)
+	c.lock.Unlock()
+}
+
+// update send the caps, and keep sending them while there are some
+func (c *maxBitrateController) update() {
+
+	c.lock.Lock()
+
+	if c.maxBitrate == 0 && !c.demandCapping && !c.idle {
+		c.stopTicker()
+		c.lock.Unlock()
+		c.send()
+		return
+	}
+
+	if c.stop == nil {
+		c.stop = make(chan struct{})
+		go c.run(c.stop)
+	}
+
+	c.lock.Unlock()
+
+	c.send()
+}
+
+func (c *maxBitrateController) run(stop chan struct{}) {
+
+	ticker := time.NewTicker(MaxBitrateFeedbackInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.send()
+		}
+	}
+}
+
+// stopTicker needs the lock
+func (c *maxBitrateController) stopTicker() {
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+// send compute the caps from the track layers and the watched encodings and send them.
+// The transponders and the track are called without the lock, they may call back
+func (c *maxBitrateController) send() {
+
+	if c.track.GetMedia() != "video" || c.track.isStopped() {
+		return
+	}
+
+	active := c.track.GetActiveLayers().Active
+
+	c.lock.Lock()
+	transponders := make([]*Transponder, 0, len(c.transponders))
+	for transponder := range c.transponders {
+		transponders = append(transponders, transponder)
+	}
+	c.lock.Unlock()
+
+	watched := make(map[string]bool)
+	for _, transponder := range transponders {
+		if !transponder.IsMuted() {
+			watched[transponder.GetSelectedEncoding()] = true
+		}
+	}
+
+	encodings := c.track.GetEncodings()
+
+	c.lock.Lock()
+
+	limits, total := maxBitrateLimits(active, watched, c.demandCapping, c.maxBitrate)
+	feedback := c.feedback
+
+	if c.idle {
+		for _, encoding := range encodings {
+			limits[encoding.GetID()] = IdleEncodingBitrate
+		}
+		total = IdleEncodingBitrate
+	}
+
+	// nothing to cap, release the previous cap once
+	capped := total > 0
+	if feedback == BitrateFeedbackTMMBR {
+		capped = len(limits) > 0
+	}
+	release := !capped && c.capped
+	c.capped = capped
+
+	c.lock.Unlock()
+
+	if !capped && !release {
+		return
+	}
+
+	c.track.withReceiver(func(receiver receiverBackend, encodings []*Encoding) {
+
+		if feedback == BitrateFeedbackTMMBR {
+			for _, encoding := range encodings {
+				limit, ok := limits[encoding.GetID()]
+				if !ok {
+					limit = UncappedBitrate
+				}
+				receiver.sendMaxBitrate(encoding.backend.ssrc(), limit, true)
+			}
+			return
+		}
+
+		if total == 0 {
+			total = UncappedBitrate
+		}
+
+		// remb is applied by the sender to all its encodings
+		if len(encodings) > 0 {
+			receiver.sendMaxBitrate(encodings[0].backend.ssrc(), total, false)
+		}
+	})
+}
+
+func (c *maxBitrateController) close() {
+	c.lock.Lock()
+	c.stopTicker()
+	c.transponders = make(map[*Transponder]bool)
+	c.lock.Unlock()
+}
+
+// maxBitrateLimits compute the cap of each capped encoding and the cap of the whole track, 0 for none.
+// active encodings are ordered by bitrate. With demand capping the encodings above the best watched one are idle,
+// the lowest one is always kept so subscribers have something to switch to
+func maxBitrateLimits(active []*ActiveEncoding, watched map[string]bool, demandCapping bool, maxBitrate uint) (map[string]uint, uint) {
+
+	limits := make(map[string]uint)
+	total := maxBitrate
+
+	if maxBitrate > 0 {
+		for _, encoding := range active {
+			limits[encoding.EncodingId] = maxBitrate
+		}
+	}
+
+	if !demandCapping || len(active) == 0 {
+		return limits, total
+	}
+
+	best := 0
+	for i, encoding := range active {
+		if watched[encoding.EncodingId] {
+			best = i
+		}
+	}
+
+	if best == len(active)-1 {
+		return limits, total
+	}
+
+	demand := uint(0)
+	for _, encoding := range active[:best+1] {
+		demand += encoding.Bitrate
+	}
+	demand = demand * (100 + demandHeadroom) / 100
+
+	for _, encoding := range active[best+1:] {
+		if limit, ok := limits[encoding.EncodingId]; !ok || limit > IdleEncodingBitrate {
+			limits[encoding.EncodingId] = IdleEncodingBitrate
+		}
+	}
+
+	if total == 0 || demand < total {
+		total = demand
+	}
+
+	return limits, total
+}
@@ -0,0 +1,99 @@
+package mediaserver
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_MaxBitrateLimits(t *testing.T) {
+
+	active := []*ActiveEncoding{
+		{EncodingId: "low", Bitrate: 100000},
+		{EncodingId: "mid", Bitrate: 300000},
+		{EncodingId: "high", Bitrate: 1000000},
+	}
+
+	// no cap
+	limits, total := maxBitrateLimits(active, map[string]bool{"high": true}, true, 0)
+	if len(limits) != 0 || total != 0 {
+		t.Fatal("capped with the best encoding watched", limits, total)
+	}
+
+	// explicit cap on every encoding
+	limits, total = maxBitrateLimits(active, nil, false, 500000)
+	if total != 500000 || limits["low"] != 500000 || limits["high"] != 500000 {
+		t.Fatal("wrong explicit cap", limits, total)
+	}
+
+	// nobody watches high
+	limits, total = maxBitrateLimits(active, map[string]bool{"mid": true}, true, 0)
+	if total != 460000 {
+		t.Fatal("wrong demand cap", total)
+	}
+	if limits["high"] != IdleEncodingBitrate || len(limits) != 1 {
+		t.Fatal("wrong encoding caps", limits)
+	}
+
+	// nobody watches, the lowest is kept
+	limits, total = maxBitrateLimits(active, map[string]bool{}, true, 0)
+	if total != 115000 || limits["mid"] != IdleEncodingBitrate || limits["high"] != IdleEncodingBitrate {
+		t.Fatal("wrong idle caps", limits, total)
+	}
+
+	// the explicit cap wins when lower
+	limits, total = maxBitrateLimits(active, map[string]bool{"mid": true}, true, 200000)
+	if total != 200000 || limits["low"] != 200000 || limits["high"] != IdleEncodingBitrate {
+		t.Fatal("wrong combined caps", limits, total)
+	}
+}
+
+func Test_MaxBitrateIdleAttachRace(t *testing.T) {
+
+	track := newFakeIncomingStreamTrack("video", "video", map[string]*fakeEncoding{"": newFakeEncoding(1, 500000)})
+	defer track.maxBitrate.close()
+
+	track.SetLazyUpstream(true)
+	if !track.maxBitrate.isIdle() {
+		t.Fatal("lazy track with nothing attached should be idle")
+	}
+
+	// a detach late to update the idle state, after a new attach
+	track.Attached()
+	atomic.AddInt32(&track.counter, -1)
+	track.Attached()
+	track.maxBitrate.updateIdle()
+
+	if track.maxBitrate.isIdle() {
+		t.Fatal("late detach should not cap an attached track")
+	}
+
+	track.Detached()
+
+	for round := 0; round < 50; round++ {
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					track.Attached()
+					track.Detached()
+				}
+			}()
+		}
+
+		track.Attached()
+		wg.Wait()
+
+		if track.maxBitrate.isIdle() {
+			t.Fatal("attached track should not stay capped")
+		}
+
+		track.Detached()
+		if !track.maxBitrate.isIdle() {
+			t.Fatal("detached lazy track should be idle")
+		}
+	}
+}
@@ -1,3 +1,6 @@
+//go:build cgo
+// +build cgo
+
 package mediaserver
 
 import "C"
@@ -52,13 +55,18 @@ func (p *overwrittenMediaFrameListener) OnMediaFrame(frame native.MediaFrame) {
 
 // NewMediaStreamDuplicater duplicate this IncomingStreamTrack and callback the mediaframe
 func NewMediaFrameMultiplexer(track *IncomingStreamTrack) *MediaFrameMultiplexer {
+	// We should make sure this source is the main source
+	return newMediaFrameMultiplexer(track, track.GetFirstEncoding())
+}
+
+func newMediaFrameMultiplexer(track *IncomingStreamTrack, encoding *Encoding) *MediaFrameMultiplexer {
 
 	duplicater := &MediaFrameMultiplexer{}
 	duplicater.track = track
 
-	// We should make sure this source is the main source
-	source := track.GetFirstEncoding().GetSource()
+	source := encoding.GetSource()
 	duplicater.multiplexer = native.NewMediaFrameMultiplexer(source)
+	trackNative("MediaFrameMultiplexer", duplicater.multiplexer)
 
 	listener := &overwrittenMediaFrameListener{
 		multiplexer: duplicater,
@@ -90,5 +98,8 @@ func (d *MediaFrameMultiplexer) Stop() {
 		d.listener.deleteMediaFrameListener()
 	}
 
+	untrackNative(d.multiplexer)
+	native.DeleteMediaFrameMultiplexer(d.multiplexer)
+
 	d.track = nil
 }
@@ -1,22 +1,50 @@
+//go:build cgo
+// +build cgo
+
 package mediaserver
 
 import (
-	"fmt"
 	"strings"
+	"sync"
 
 	native "github.com/notedit/media-server-go/wrapper"
 	"github.com/notedit/sdp"
 )
 
+// MediaFrameSession depacketize the rtp packets pushed to it, safe for concurrent use
 type MediaFrameSession struct {
 	sources  map[string]native.RTPIncomingSourceGroup
 	incoming *IncomingStreamTrack
 	session  native.MediaFrameSessionFacade
+	// l guards session, held for reading while calling it
+	l sync.RWMutex
+	// leakGuard logs the native session if the session is collected without being stopped
+	leakGuard *nativeLeakGuard
 }
 
 // NewMediaFrameSession create media frame session
 func NewMediaFrameSession(media *sdp.MediaInfo) *MediaFrameSession {
 
+	mediaSession, err := newMediaFrameSession(media)
+	if err != nil {
+		componentLogger("mediaframesession").Error("can not create media frame session", "err", err)
+		return nil
+	}
+	return mediaSession
+}
+
+// newMediaFrameSession create media frame session, ErrInvalidSDP when the media can not be used
+func newMediaFrameSession(media *sdp.MediaInfo) (*MediaFrameSession, error) {
+
+	if media == nil {
+		return nil, newError(ErrInvalidSDP, "media can not be nil")
+	}
+
+	params := NewRTPParameters(media)
+	if err := params.Validate(); err != nil {
+		return nil, wrapError(ErrInvalidSDP, "media frame session rtp parameters error", err)
+	}
+
 	mediaSession := &MediaFrameSession{}
 	var mediaType native.MediaFrameType = 0
 	if strings.ToLower(media.GetType()) == "video" {
@@ -24,17 +52,11 @@ func NewMediaFrameSession(media *sdp.MediaInfo) *MediaFrameSession {
 	}
 
 	session := native.NewMediaFrameSessionFacade(mediaType)
+	trackNative("MediaFrameSessionFacade", session)
 
 	properties := native.NewPropertiesFacade()
-	if media != nil {
-		num := 0
-		for _, codec := range media.GetCodecs() {
-			item := fmt.Sprintf("codecs.%d", num)
-			properties.SetPropertyStr(item+".codec", codec.GetCodec())
-			properties.SetPropertyInt(item+".pt", codec.GetType())
-			num = num + 1
-		}
-		properties.SetPropertyInt("codecs.length", num)
+	if params != nil {
+		params.setProperties(properties, "")
 	}
 
 	session.Init(properties)
@@ -43,9 +65,15 @@ func NewMediaFrameSession(media *sdp.MediaInfo) *MediaFrameSession {
 	sources := map[string]native.RTPIncomingSourceGroup{"": session.GetIncomingSourceGroup()}
 	mediaSession.sources = sources
 	mediaSession.session = session
-	mediaSession.incoming = NewIncomingStreamTrack(media.GetType(), media.GetType(), native.RTPSessionToReceiver(session), sources)
+	mediaSession.leakGuard = newNativeLeakGuard("MediaFrameSession", session)
 
-	return mediaSession
+	receiver := native.RTPSessionToReceiver(session)
+	trackNative("RTPReceiverFacade", receiver)
+
+	mediaSession.incoming = NewIncomingStreamTrack(media.GetType(), media.GetType(), receiver, sources)
+	mediaSession.incoming.ownsReceiver = true
+
+	return mediaSession, nil
 }
 
 // GetIncomingStreamTrack get incoming stream track
@@ -58,13 +86,22 @@ func (s *MediaFrameSession) Push(rtp []byte) {
 	if rtp == nil || len(rtp) == 0 {
 		return
 	}
-	s.session.OnRTPPacket(&rtp[0], len(rtp))
+	s.l.RLock()
+	defer s.l.RUnlock()
+	if s.session != nil {
+		s.session.OnRTPPacket(&rtp[0], len(rtp))
+	}
 }
 
 // Stop stop this
 func (s *MediaFrameSession) Stop() {
 
-	if s.session == nil {
+	s.l.Lock()
+	session := s.session
+	s.session = nil
+	s.l.Unlock()
+
+	if session == nil {
 		return
 	}
 
@@ -72,9 +109,8 @@ func (s *MediaFrameSession) Stop() {
 		s.incoming.Stop()
 	}
 
-	s.session.End()
+	session.End()
 
-	native.DeleteMediaFrameSessionFacade(s.session)
-
-	s.session = nil
+	untrackNative(session)
+	native.DeleteMediaFrameSessionFacade(session)
 }
@@ -0,0 +1,195 @@
+package mediaserver
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// MetricsContentType content type of the OpenMetrics text format
+const MetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// MetricType type of a metric family
+type MetricType string
+
+const (
+	// MetricCounter monotonic value, the sample name gets the _total suffix
+	MetricCounter MetricType = "counter"
+	// MetricGauge value going up and down
+	MetricGauge MetricType = "gauge"
+)
+
+// MetricsCollector add samples to the writer on every scrape
+type MetricsCollector func(w *MetricsWriter)
+
+// Label name and value of a sample label
+type Label struct {
+	Name  string
+	Value string
+}
+
+type metricSample struct {
+	labels []Label
+	value  float64
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	kind    MetricType
+	samples []metricSample
+}
+
+// MetricsWriter group the samples by family and write them in the OpenMetrics text format
+type MetricsWriter struct {
+	families []*metricFamily
+	index    map[string]*metricFamily
+}
+
+// NewMetricsWriter create an empty writer
+func NewMetricsWriter() *MetricsWriter {
+	return &MetricsWriter{
+		index: make(map[string]*metricFamily),
+	}
+}
+
+// Counter add a counter sample, name without the _total suffix
+func (w *MetricsWriter) Counter(name string, help string, value float64, labels ...Label) {
+	w.add(name, help, MetricCounter, value, labels)
+}
+
+// Gauge add a gauge sample
+func (w *MetricsWriter) Gauge(name string, help string, value float64, labels ...Label) {
+	w.add(name, help, MetricGauge, value, labels)
+}
+
+func (w *MetricsWriter) add(name string, help string, kind MetricType, value float64, labels []Label) {
+
+	family, ok := w.index[name]
+	if !ok {
+		family = &metricFamily{name: name, help: help, kind: kind}
+		w.index[name] = family
+		w.families = append(w.families, family)
+	}
+
+	family.samples = append(family.samples, metricSample{labels: labels, value: value})
+}
+
+// Bytes the exposition, families in the order they were first added
+func (w *MetricsWriter) Bytes() []byte {
+
+	buf := &bytes.Buffer{}
+
+	for _, family := range w.families {
+
+		buf.WriteString("# TYPE " + family.name + " " + string(family.kind) + "\n")
+		if family.help != "" {
+			buf.WriteString("# HELP " + family.name + " " + escapeMetricHelp(family.help) + "\n")
+		}
+
+		suffix := ""
+		if family.kind == MetricCounter {
+			suffix = "_total"
+		}
+
+		for _, sample := range family.samples {
+			buf.WriteString(family.name + suffix)
+			if len(sample.labels) > 0 {
+				buf.WriteString("{")
+				for i, label := range sample.labels {
+					if i > 0 {
+						buf.WriteString(",")
+					}
+					buf.WriteString(label.Name + "=\"" + escapeMetricLabel(label.Value) + "\"")
+				}
+				buf.WriteString("}")
+			}
+			buf.WriteString(" " + strconv.FormatFloat(sample.value, 'g', -1, 64) + "\n")
+		}
+	}
+
+	buf.WriteString("# EOF\n")
+
+	return buf.Bytes()
+}
+
+var metricLabelEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+var metricHelpEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+func escapeMetricLabel(value string) string {
+	return metricLabelEscaper.Replace(value)
+}
+
+func escapeMetricHelp(help string) string {
+	return metricHelpEscaper.Replace(help)
+}
+
+// collectStatsReport add the samples of a transport stats report
+func collectStatsReport(w *MetricsWriter, report *StatsReport) {
+
+	transport := Label{"transport", report.Transport.ID}
+
+	w.Gauge("mediaserver_transport_info", "Transport dtls state", 1, transport, Label{"dtls_state", report.Transport.DTLSState})
+	w.Counter("mediaserver_transport_received_packets", "Packets received on the transport", float64(report.Transport.PacketsReceived), transport)
+	w.Counter("mediaserver_transport_received_bytes", "Bytes received on the transport", float64(report.Transport.BytesReceived), transport)
+	w.Counter("mediaserver_transport_sent_packets", "Packets sent on the transport", float64(report.Transport.PacketsSent), transport)
+	w.Counter("mediaserver_transport_sent_bytes", "Bytes sent on the transport", float64(report.Transport.BytesSent), transport)
+
+	if pair := report.CandidatePair; pair != nil {
+		w.Gauge("mediaserver_transport_rtt_seconds", "Round trip time of the transport", pair.CurrentRoundTripTime, transport)
+		w.Gauge("mediaserver_transport_available_outgoing_bitrate", "Sender side bandwidth estimation in bps", float64(pair.AvailableOutgoingBitrate), transport)
+	}
+
+	for _, inbound := range report.InboundRTP {
+		labels := []Label{transport, {"stream", inbound.StreamIdentifier}, {"track", inbound.TrackIdentifier}, {"encoding", inbound.Rid}, {"media", inbound.Kind}}
+		w.Counter("mediaserver_incoming_track_packets", "Packets received", float64(inbound.PacketsReceived), labels...)
+		w.Counter("mediaserver_incoming_track_bytes", "Bytes received", float64(inbound.BytesReceived), labels...)
+		w.Counter("mediaserver_incoming_track_lost_packets", "Packets lost", float64(inbound.PacketsLost), labels...)
+		w.Counter("mediaserver_incoming_track_nacks", "NACKs sent", float64(inbound.NackCount), labels...)
+		w.Counter("mediaserver_incoming_track_plis", "PLIs sent", float64(inbound.PliCount), labels...)
+		w.Gauge("mediaserver_incoming_track_bitrate", "Received media bitrate in bps", float64(inbound.Bitrate), labels...)
+		w.Gauge("mediaserver_incoming_track_fraction_lost", "Fraction of the packets lost since the previous scrape", inbound.FractionLost, labels...)
+		w.Gauge("mediaserver_incoming_track_jitter_seconds", "Interarrival jitter", inbound.Jitter, labels...)
+		w.Gauge("mediaserver_incoming_track_rtt_seconds", "Round trip time measured with the sender reports", inbound.RoundTripTime, labels...)
+	}
+
+	for _, outbound := range report.OutboundRTP {
+		labels := []Label{transport, {"stream", outbound.StreamIdentifier}, {"track", outbound.TrackIdentifier}, {"media", outbound.Kind}}
+		w.Counter("mediaserver_outgoing_track_packets", "Packets sent", float64(outbound.PacketsSent), labels...)
+		w.Counter("mediaserver_outgoing_track_bytes", "Bytes sent", float64(outbound.BytesSent), labels...)
+		w.Counter("mediaserver_outgoing_track_retransmitted_packets", "Packets retransmitted", float64(outbound.RetransmittedPacketsSent), labels...)
+		w.Gauge("mediaserver_outgoing_track_bitrate", "Sent media bitrate in bps", float64(outbound.Bitrate), labels...)
+	}
+}
+
+// collectTransponders add the layers selected by the transponders of the outgoing tracks of a transport
+func collectTransponders(w *MetricsWriter, transportID string, streams []*OutgoingStream) {
+
+	for _, stream := range streams {
+		for _, track := range stream.GetTracks() {
+
+			transponder := track.GetTransponder()
+			if transponder == nil {
+				continue
+			}
+
+			incomingID := ""
+			if incoming := transponder.GetIncomingTrack(); incoming != nil {
+				incomingID = incoming.GetID()
+			}
+
+			labels := []Label{{"transport", transportID}, {"stream", stream.GetID()}, {"track", track.GetID()}, {"incoming_track", incomingID}}
+
+			muted := 0.0
+			if transponder.IsMuted() {
+				muted = 1
+			}
+
+			w.Gauge("mediaserver_transponder_muted", "1 while the transponder forwards nothing", muted, labels...)
+			w.Gauge("mediaserver_transponder_selected_encoding", "Encoding forwarded by the transponder", 1, append(labels, Label{"encoding", transponder.GetSelectedEncoding()})...)
+			w.Gauge("mediaserver_transponder_spatial_layer", "Spatial layer forwarded by the transponder", float64(transponder.GetSelectedSpatialLayerId()), labels...)
+			w.Gauge("mediaserver_transponder_temporal_layer", "Temporal layer forwarded by the transponder", float64(transponder.GetSelectedTemporalLayerId()), labels...)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// MetricsHandler http.Handler exposing the metrics of the registered endpoints, transports and recorders.
+// Transports created by a registered endpoint are exported until they are stopped
+type MetricsHandler struct {
+	endpoints  map[*Endpoint]bool
+	transports map[*Transport]bool
+	recorders  map[*Recorder]bool
+	collectors []MetricsCollector
+	// loss counters of the previous scrape of each transport, GetStatsReport callers keep theirs
+	loss map[*Transport]map[uint]lossCounters
+	lock sync.Mutex
+}
+
+// NewMetricsHandler create an handler with nothing registered
+func NewMetricsHandler() *MetricsHandler {
+	return &MetricsHandler{
+		endpoints:  make(map[*Endpoint]bool),
+		transports: make(map[*Transport]bool),
+		recorders:  make(map[*Recorder]bool),
+		loss:       make(map[*Transport]map[uint]lossCounters),
+	}
+}
+
+// AddEndpoint export the endpoint and all its transports
+func (m *MetricsHandler) AddEndpoint(endpoint *Endpoint) {
+	m.lock.Lock()
+	m.endpoints[endpoint] = true
+	m.lock.Unlock()
+}
+
+// RemoveEndpoint stop exporting the endpoint
+func (m *MetricsHandler) RemoveEndpoint(endpoint *Endpoint) {
+	m.lock.Lock()
+	delete(m.endpoints, endpoint)
+	m.lock.Unlock()
+}
+
+// AddTransport export a transport not created by a registered endpoint, until it is stopped
+func (m *MetricsHandler) AddTransport(transport *Transport) {
+
+	m.lock.Lock()
+	m.transports[transport] = true
+	m.lock.Unlock()
+
+	transport.OnStop(func() {
+		m.RemoveTransport(transport)
+	})
+}
+
+// RemoveTransport stop exporting the transport
+func (m *MetricsHandler) RemoveTransport(transport *Transport) {
+	m.lock.Lock()
+	delete(m.transports, transport)
+	delete(m.loss, transport)
+	m.lock.Unlock()
+}
+
+// AddRecorder export the recorder
+func (m *MetricsHandler) AddRecorder(recorder *Recorder) {
+	m.lock.Lock()
+	m.recorders[recorder] = true
+	m.lock.Unlock()
+}
+
+// RemoveRecorder stop exporting the recorder
+func (m *MetricsHandler) RemoveRecorder(recorder *Recorder) {
+	m.lock.Lock()
+	delete(m.recorders, recorder)
+	m.lock.Unlock()
+}
+
+// AddCollector add application samples to every scrape
+func (m *MetricsHandler) AddCollector(collector MetricsCollector) {
+	m.lock.Lock()
+	m.collectors = append(m.collectors, collector)
+	m.lock.Unlock()
+}
+
+// ServeHTTP write the metrics in the OpenMetrics text format
+func (m *MetricsHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		rw.Header().Set("Allow", "GET, HEAD")
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w := NewMetricsWriter()
+	m.Collect(w)
+
+	rw.Header().Set("Content-Type", MetricsContentType)
+	rw.WriteHeader(http.StatusOK)
+
+	if req.Method == http.MethodGet {
+		rw.Write(w.Bytes())
+	}
+}
+
+// Collect add the samples of all the registered objects to the writer
+func (m *MetricsHandler) Collect(w *MetricsWriter) {
+
+	m.lock.Lock()
+
+	endpoints := []*Endpoint{}
+	for endpoint := range m.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+
+	transports := map[*Transport]bool{}
+	for transport := range m.transports {
+		transports[transport] = true
+	}
+
+	recorders := []*Recorder{}
+	for recorder := range m.recorders {
+		recorders = append(recorders, recorder)
+	}
+
+	collectors := append([]MetricsCollector{}, m.collectors...)
+
+	m.lock.Unlock()
+
+	w.Gauge("mediaserver_endpoints", "Registered endpoints", float64(len(endpoints)))
+
+	for _, endpoint := range endpoints {
+		endpointTransports := endpoint.GetTransports()
+		w.Gauge("mediaserver_endpoint_transports", "Live transports of the endpoint", float64(len(endpointTransports)),
+			Label{"endpoint", endpoint.candidate.GetAddress() + ":" + strconv.Itoa(endpoint.GetLocalPort())})
+		for _, transport := range endpointTransports {
+			transports[transport] = true
+		}
+	}
+
+	m.lock.Lock()
+	prevLoss := m.loss
+	m.lock.Unlock()
+
+	reports := []*StatsReport{}
+	reported := map[*StatsReport]*Transport{}
+	// the transports gone are forgotten
+	loss := make(map[*Transport]map[uint]lossCounters)
+	for transport := range transports {
+		if report, counters := transport.getStatsReport(prevLoss[transport]); report != nil {
+			reports = append(reports, report)
+			reported[report] = transport
+			loss[transport] = counters
+		}
+	}
+
+	m.lock.Lock()
+	m.loss = loss
+	m.lock.Unlock()
+
+	// stable output between scrapes
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Transport.ID < reports[j].Transport.ID })
+
+	w.Gauge("mediaserver_transports", "Exported transports", float64(len(reports)))
+
+	for _, report := range reports {
+		collectStatsReport(w, report)
+	}
+
+	for _, report := range reports {
+		transport := reported[report]
+		collectTransponders(w, transport.GetID(), transport.GetOutgoingStreams())
+	}
+
+	sort.Slice(recorders, func(i, j int) bool { return recorders[i].filename < recorders[j].filename })
+
+	for _, recorder := range recorders {
+		label := Label{"recorder", recorder.GetFilename()}
+		recording := 0.0
+		if recorder.IsRecording() {
+			recording = 1
+		}
+		w.Gauge("mediaserver_recorder_recording", "1 while the recorder is recording", recording, label)
+		w.Gauge("mediaserver_recorder_tracks", "Tracks recorded", float64(len(recorder.GetTracks())), label)
+	}
+
+	for _, collector := range collectors {
+		collector(w)
+	}
+}
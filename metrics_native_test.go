@@ -0,0 +1,113 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/notedit/sdp"
+)
+
+func Test_MetricsHandler(t *testing.T) {
+
+	report := &StatsReport{
+		Transport: &TransportStats{
+			RTCStats:        RTCStats{ID: "local:remote", Type: StatsTypeTransport},
+			DTLSState:       "connected",
+			PacketsReceived: 100,
+		},
+		InboundRTP: []*InboundRTPStreamStats{{
+			StreamIdentifier: "stream",
+			TrackIdentifier:  "track",
+			Kind:             "video",
+			PacketsReceived:  100,
+			PacketsLost:      3,
+			Jitter:           0.02,
+		}},
+		OutboundRTP: []*OutboundRTPStreamStats{{
+			StreamIdentifier: "stream",
+			TrackIdentifier:  "out",
+			Kind:             "audio",
+			BytesSent:        4000,
+		}},
+	}
+
+	handler := NewMetricsHandler()
+	handler.AddCollector(func(w *MetricsWriter) {
+		collectStatsReport(w, report)
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != MetricsContentType {
+		t.Fatal("wrong response", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	for _, line := range []string{
+		"mediaserver_endpoints 0",
+		`mediaserver_transport_received_packets_total{transport="local:remote"} 100`,
+		`mediaserver_outgoing_track_bytes_total{transport="local:remote",stream="stream",track="out",media="audio"} 4000`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("missing %s in\n%s", line, body)
+		}
+	}
+
+	if !strings.HasSuffix(string(body), "# EOF\n") {
+		t.Fatal("missing eof")
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatal("wrong status", recorder.Code)
+	}
+}
+
+func Test_MetricsHandlerLossBaseline(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	offer, err := sdp.Parse(sdpStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := endpoint.CreateTransport(offer, nil)
+
+	handler := NewMetricsHandler()
+	handler.AddTransport(transport)
+	handler.Collect(NewMetricsWriter())
+
+	// the handler baseline is its own, not the one of GetStatsReport
+	handler.lock.Lock()
+	_, ok := handler.loss[transport]
+	handler.lock.Unlock()
+	if !ok || transport.statsReportLoss != nil {
+		t.Fatal("loss counters not kept by the handler")
+	}
+
+	transport.Stop()
+	handler.Collect(NewMetricsWriter())
+
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+	if _, ok := handler.loss[transport]; ok {
+		t.Fatal("loss counters kept for a stopped transport")
+	}
+}
@@ -0,0 +1,111 @@
+package mediaserver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/notedit/sdp"
+)
+
+func Test_MetricsWriter(t *testing.T) {
+
+	w := NewMetricsWriter()
+	w.Counter("test_bytes", "Bytes", 10, Label{"track", "a"})
+	w.Gauge("test_bitrate", "Bitrate", 1.5, Label{"track", "a"})
+	w.Counter("test_bytes", "Bytes", 20, Label{"track", "b\"\\\n"})
+
+	expected := `# TYPE test_bytes counter
+# HELP test_bytes Bytes
+test_bytes_total{track="a"} 10
+test_bytes_total{track="b\"\\\n"} 20
+# TYPE test_bitrate gauge
+# HELP test_bitrate Bitrate
+test_bitrate{track="a"} 1.5
+# EOF
+`
+
+	if string(w.Bytes()) != expected {
+		t.Fatalf("wrong exposition\n%s", w.Bytes())
+	}
+}
+
+func Test_CollectStatsReport(t *testing.T) {
+
+	report := &StatsReport{
+		Transport: &TransportStats{
+			RTCStats:        RTCStats{ID: "local:remote", Type: StatsTypeTransport},
+			DTLSState:       "connected",
+			PacketsReceived: 100,
+		},
+		InboundRTP: []*InboundRTPStreamStats{{
+			StreamIdentifier: "stream",
+			TrackIdentifier:  "track",
+			Kind:             "video",
+			PacketsReceived:  100,
+			PacketsLost:      3,
+			Jitter:           0.02,
+		}},
+		OutboundRTP: []*OutboundRTPStreamStats{{
+			StreamIdentifier: "stream",
+			TrackIdentifier:  "out",
+			Kind:             "audio",
+			BytesSent:        4000,
+		}},
+	}
+
+	w := NewMetricsWriter()
+	collectStatsReport(w, report)
+	body := string(w.Bytes())
+
+	for _, line := range []string{
+		`mediaserver_transport_info{transport="local:remote",dtls_state="connected"} 1`,
+		`mediaserver_transport_received_packets_total{transport="local:remote"} 100`,
+		`mediaserver_incoming_track_lost_packets_total{transport="local:remote",stream="stream",track="track",encoding="",media="video"} 3`,
+		`mediaserver_incoming_track_jitter_seconds{transport="local:remote",stream="stream",track="track",encoding="",media="video"} 0.02`,
+		`mediaserver_outgoing_track_bytes_total{transport="local:remote",stream="stream",track="out",media="audio"} 4000`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("missing %s in\n%s", line, body)
+		}
+	}
+}
+
+func Test_CollectTransponders(t *testing.T) {
+
+	info := sdp.NewStreamInfo("stream")
+	track := sdp.NewTrackInfo("out", "video")
+	track.AddSSRC(NextSSRC())
+	info.AddTrack(track)
+
+	stream, err := newOutgoingStream(&fakeTransport{}, info, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	incoming := newFakeIncomingStreamTrack("video", "in", map[string]*fakeEncoding{"a": newFakeEncoding(1, 0)})
+	stream.GetTrack("out").AttachTo(incoming)
+
+	// not attached, nothing to report
+	unattached := sdp.NewTrackInfo("idle", "audio")
+	unattached.AddSSRC(NextSSRC())
+	if _, err := stream.NewTrack(unattached); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewMetricsWriter()
+	collectTransponders(w, "local:remote", []*OutgoingStream{stream})
+	body := string(w.Bytes())
+
+	for _, line := range []string{
+		`mediaserver_transponder_muted{transport="local:remote",stream="stream",track="out",incoming_track="in"} 0`,
+		`mediaserver_transponder_selected_encoding{transport="local:remote",stream="stream",track="out",incoming_track="in",encoding="a"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("missing %s in\n%s", line, body)
+		}
+	}
+
+	if strings.Contains(body, `track="idle"`) {
+		t.Fatal("unattached track reported", body)
+	}
+}
@@ -1,3 +1,6 @@
+//go:build cgo
+// +build cgo
+
 package mediaserver
 
 import (
@@ -8,10 +11,12 @@ func init() {
 	native.MediaServerInitialize()
 }
 
+// EnableLog enable the native log, printed to stdout until SetLogger is called
 func EnableLog(flag bool) {
 	native.MediaServerEnableLog(flag)
 }
 
+// EnableDebug enable the native debug log
 func EnableDebug(flag bool) {
 	native.MediaServerEnableDebug(flag)
 }
@@ -20,6 +25,7 @@ func SetPortRange(minPort, maxPort int) bool {
 	return native.MediaServerSetPortRange(minPort, maxPort)
 }
 
+// EnableUltraDebug enable the native verbose debug log, logged below slog.LevelDebug
 func EnableUltraDebug(flag bool) {
 	native.MediaServerEnableUltraDebug(flag)
 }
@@ -0,0 +1,182 @@
+package mediaserver
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// nativeObject any object of the native wrapper
+type nativeObject interface {
+	Swigcptr() uintptr
+}
+
+type nativeRecord struct {
+	kind  string
+	stack []uintptr
+}
+
+// nativeTracker live native objects created by the package, keyed by their pointer
+var nativeTracker struct {
+	enabled    atomic.Bool
+	finalizers atomic.Bool
+
+	lock    sync.Mutex
+	objects map[uintptr]*nativeRecord
+}
+
+// EnableNativeTracking count the native objects created from now on by type, see GetNativeObjectCounts.
+// With finalizers the allocation stacks are kept, and the objects still alive when the Go object owning them
+// (track, transponder, transport, recorder, session) is garbage collected are logged as leaked with their stack.
+// It is a debug mode, it slows down the creation of the streams and tracks
+func EnableNativeTracking(finalizers bool) {
+	nativeTracker.lock.Lock()
+	if nativeTracker.objects == nil {
+		nativeTracker.objects = map[uintptr]*nativeRecord{}
+	}
+	nativeTracker.lock.Unlock()
+
+	nativeTracker.finalizers.Store(finalizers)
+	nativeTracker.enabled.Store(true)
+}
+
+// DisableNativeTracking stop counting and forget the objects counted
+func DisableNativeTracking() {
+	nativeTracker.enabled.Store(false)
+	nativeTracker.finalizers.Store(false)
+
+	nativeTracker.lock.Lock()
+	nativeTracker.objects = nil
+	nativeTracker.lock.Unlock()
+}
+
+// GetNativeObjectCounts live native objects by type, like RTPIncomingSourceGroup or DTLSICETransport.
+// Types without live object are not in the map, it is empty when the tracking is disabled
+func GetNativeObjectCounts() map[string]int {
+	nativeTracker.lock.Lock()
+	defer nativeTracker.lock.Unlock()
+
+	counts := map[string]int{}
+	for _, record := range nativeTracker.objects {
+		counts[record.kind]++
+	}
+	return counts
+}
+
+// GetNativeObjectCount live native objects, all types together
+func GetNativeObjectCount() int {
+	nativeTracker.lock.Lock()
+	defer nativeTracker.lock.Unlock()
+	return len(nativeTracker.objects)
+}
+
+// trackNative record a native object just created by the package
+func trackNative(kind string, object nativeObject) {
+
+	if !nativeTracker.enabled.Load() || object == nil || object.Swigcptr() == 0 {
+		return
+	}
+
+	record := &nativeRecord{kind: kind}
+	if nativeTracker.finalizers.Load() {
+		stack := make([]uintptr, 32)
+		record.stack = stack[:runtime.Callers(2, stack)]
+	}
+
+	nativeTracker.lock.Lock()
+	if nativeTracker.objects != nil {
+		nativeTracker.objects[object.Swigcptr()] = record
+	}
+	nativeTracker.lock.Unlock()
+}
+
+// untrackNative forget a native object about to be deleted
+func untrackNative(object nativeObject) {
+
+	if !nativeTracker.enabled.Load() || object == nil {
+		return
+	}
+
+	nativeTracker.lock.Lock()
+	delete(nativeTracker.objects, object.Swigcptr())
+	nativeTracker.lock.Unlock()
+}
+
+// nativeLeakGuard log the native objects of its owner still alive once the owner is garbage collected.
+// It references nothing but the records, so it is collected with its owner even when the owner is part of a cycle
+type nativeLeakGuard struct {
+	owner   string
+	records map[uintptr]*nativeRecord
+}
+
+// newNativeLeakGuard guard of the tracked objects, nil unless the finalizers are enabled
+func newNativeLeakGuard(owner string, objects ...nativeObject) *nativeLeakGuard {
+
+	if !nativeTracker.finalizers.Load() {
+		return nil
+	}
+
+	guard := &nativeLeakGuard{owner: owner, records: map[uintptr]*nativeRecord{}}
+	guard.add(objects...)
+
+	runtime.SetFinalizer(guard, (*nativeLeakGuard).check)
+
+	return guard
+}
+
+// add guard more objects of the owner, no-op on a nil guard
+func (g *nativeLeakGuard) add(objects ...nativeObject) {
+
+	if g == nil {
+		return
+	}
+
+	nativeTracker.lock.Lock()
+	defer nativeTracker.lock.Unlock()
+
+	for _, object := range objects {
+		if object == nil {
+			continue
+		}
+		if record, ok := nativeTracker.objects[object.Swigcptr()]; ok {
+			g.records[object.Swigcptr()] = record
+		}
+	}
+}
+
+func (g *nativeLeakGuard) check() {
+
+	nativeTracker.lock.Lock()
+	leaked := []*nativeRecord{}
+	for pointer, record := range g.records {
+		// the same record, not a new object allocated at the same address
+		if nativeTracker.objects[pointer] == record {
+			leaked = append(leaked, record)
+		}
+	}
+	nativeTracker.lock.Unlock()
+
+	for _, record := range leaked {
+		componentLogger("native").Warn("native object leaked, its owner was not stopped", "owner", g.owner, "type", record.kind, "stack", formatStack(record.stack))
+	}
+}
+
+func formatStack(stack []uintptr) string {
+
+	if len(stack) == 0 {
+		return ""
+	}
+
+	lines := []string{}
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		lines = append(lines, frame.Function+" "+frame.File+":"+strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
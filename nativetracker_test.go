@@ -0,0 +1,113 @@
+package mediaserver
+
+import (
+	"bytes"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeNativeObject uintptr
+
+func (f fakeNativeObject) Swigcptr() uintptr {
+	return uintptr(f)
+}
+
+// lockedBuffer the finalizers log from their own goroutine
+type lockedBuffer struct {
+	buf bytes.Buffer
+	l   sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.buf.String()
+}
+
+func Test_NativeTracker(t *testing.T) {
+
+	trackNative("RTPIncomingSourceGroup", fakeNativeObject(1))
+	if GetNativeObjectCount() != 0 {
+		t.Fatal("tracked while disabled")
+	}
+
+	EnableNativeTracking(false)
+	defer DisableNativeTracking()
+
+	trackNative("RTPIncomingSourceGroup", fakeNativeObject(1))
+	trackNative("RTPIncomingSourceGroup", fakeNativeObject(2))
+	trackNative("StreamTrackDepacketizer", fakeNativeObject(3))
+	trackNative("StreamTrackDepacketizer", fakeNativeObject(0))
+	trackNative("StreamTrackDepacketizer", nil)
+
+	counts := GetNativeObjectCounts()
+	if len(counts) != 2 || counts["RTPIncomingSourceGroup"] != 2 || counts["StreamTrackDepacketizer"] != 1 {
+		t.Fatal("wrong counts", counts)
+	}
+
+	untrackNative(fakeNativeObject(1))
+	untrackNative(fakeNativeObject(3))
+	untrackNative(fakeNativeObject(4))
+	untrackNative(nil)
+
+	counts = GetNativeObjectCounts()
+	if len(counts) != 1 || counts["RTPIncomingSourceGroup"] != 1 || GetNativeObjectCount() != 1 {
+		t.Fatal("wrong counts", counts)
+	}
+
+	if newNativeLeakGuard("IncomingStreamTrack", fakeNativeObject(2)) != nil {
+		t.Fatal("guard without finalizers")
+	}
+
+	DisableNativeTracking()
+
+	if GetNativeObjectCount() != 0 {
+		t.Fatal("objects kept once disabled")
+	}
+}
+
+func Test_NativeLeakGuard(t *testing.T) {
+
+	buf := &lockedBuffer{}
+	logger.Store(slog.New(slog.NewTextHandler(buf, nil)))
+	defer logger.Store((*slog.Logger)(nil))
+
+	EnableNativeTracking(true)
+	defer DisableNativeTracking()
+
+	trackNative("RTPSenderFacade", fakeNativeObject(10))
+	trackNative("RTPOutgoingSourceGroup", fakeNativeObject(11))
+
+	func() {
+		guard := newNativeLeakGuard("OutgoingStreamTrack", fakeNativeObject(10))
+		guard.add(fakeNativeObject(11))
+	}()
+
+	// the sender is deleted, the source is leaked
+	untrackNative(fakeNativeObject(10))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(buf.String(), "leaked") && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "owner=OutgoingStreamTrack") || !strings.Contains(out, "type=RTPOutgoingSourceGroup") || !strings.Contains(out, "Test_NativeLeakGuard") {
+		t.Fatal("leak not logged", out)
+	}
+
+	if strings.Contains(out, "RTPSenderFacade") {
+		t.Fatal("deleted object logged", out)
+	}
+}
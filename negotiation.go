@@ -0,0 +1,127 @@
+package mediaserver
+
+import (
+	"sort"
+
+	"github.com/notedit/sdp"
+)
+
+// offerMedia create the media section we offer for a new mid
+func offerMedia(mid string, media string, capability *sdp.Capability) *sdp.MediaInfo {
+
+	offered := sdp.NewMediaInfo(mid, media)
+
+	rtcpfbs := []*sdp.RTCPFeedbackInfo{}
+	for _, rtcpfb := range capability.Rtcpfbs {
+		rtcpfbs = append(rtcpfbs, sdp.NewRTCPFeedbackInfo(rtcpfb.ID, rtcpfb.Params))
+	}
+
+	for _, codec := range sdp.CodecMapFromNames(capability.Codecs, capability.Rtx, rtcpfbs) {
+		offered.AddCodec(codec)
+	}
+
+	id := 1
+	for _, uri := range capability.Extensions {
+		if supportedHeaderExtensions[uri] {
+			offered.AddExtension(id, uri)
+			id++
+		}
+	}
+
+	return offered
+}
+
+// answerMedia answer an offered media with our capability.
+// Codecs, header extensions and rtcp-fb are the intersection of both sides and simulcast rids are reversed.
+func answerMedia(offered *sdp.MediaInfo, capability *sdp.Capability) *sdp.MediaInfo {
+
+	answered := offered.AnswerCapability(capability)
+
+	media := sdp.NewMediaInfo(answered.GetID(), answered.GetType())
+	media.SetDirection(answered.GetDirection())
+	media.SetBitrate(answered.GetBitrate())
+
+	for pt, codec := range answered.GetCodecs() {
+		media.AddCodec(intersectCodec(offered.GetCodecForType(pt), codec))
+	}
+
+	for id, uri := range answered.GetExtensions() {
+		// do not announce what the native side will not honour
+		if supportedHeaderExtensions[uri] {
+			media.AddExtension(id, uri)
+		}
+	}
+
+	if answered.GetSimulcastInfo() != nil && offered.GetSimulcastInfo() != nil {
+
+		simulcast := sdp.NewSimulcastInfo()
+
+		for _, streams := range offered.GetSimulcastInfo().GetSimulcastStreams(sdp.SEND) {
+			simulcast.AddSimulcastAlternativeStreams(sdp.RECV, streams)
+		}
+
+		for _, streams := range offered.GetSimulcastInfo().GetSimulcastStreams(sdp.RECV) {
+			simulcast.AddSimulcastAlternativeStreams(sdp.SEND, streams)
+		}
+
+		for _, rid := range answered.GetRIDS() {
+			media.AddRID(rid)
+		}
+
+		media.SetSimulcastInfo(simulcast)
+	}
+
+	return media
+}
+
+// intersectCodec keep only the rtcp-fb both sides support
+func intersectCodec(offered *sdp.CodecInfo, answered *sdp.CodecInfo) *sdp.CodecInfo {
+
+	codec := sdp.NewCodecInfo(answered.GetCodec(), answered.GetType())
+	codec.AddParams(answered.GetParams())
+
+	if answered.HasRTX() {
+		codec.SetRTX(answered.GetRTX())
+	}
+
+	for _, rtcpfb := range answered.GetRTCPFeedbacks() {
+		if offered == nil || hasRTCPFeedback(offered, rtcpfb) {
+			codec.AddRTCPFeedback(rtcpfb.Clone())
+		}
+	}
+
+	return codec
+}
+
+func hasRTCPFeedback(codec *sdp.CodecInfo, rtcpfb *sdp.RTCPFeedbackInfo) bool {
+
+	for _, other := range codec.GetRTCPFeedbacks() {
+		if other.GetID() != rtcpfb.GetID() || len(other.GetParams()) != len(rtcpfb.GetParams()) {
+			continue
+		}
+		match := true
+		for i, param := range other.GetParams() {
+			if param != rtcpfb.GetParams()[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedStreams remote streams ordered by id, so tracks are created in a stable order
+func sortedStreams(info *sdp.SDPInfo) []*sdp.StreamInfo {
+
+	streams := []*sdp.StreamInfo{}
+	for _, stream := range info.GetStreams() {
+		streams = append(streams, stream)
+	}
+
+	sort.Slice(streams, func(i, j int) bool { return streams[i].GetID() < streams[j].GetID() })
+
+	return streams
+}
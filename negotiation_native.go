@@ -0,0 +1,465 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/notedit/sdp"
+)
+
+// GetTransceivers get the transceivers in media section order
+func (t *Transport) GetTransceivers() []*Transceiver {
+	t.Lock()
+	defer t.Unlock()
+	return append([]*Transceiver{}, t.transceivers...)
+}
+
+// GetTransceiver get the transceiver of a mid
+func (t *Transport) GetTransceiver(mid string) *Transceiver {
+	t.Lock()
+	defer t.Unlock()
+	for _, transceiver := range t.transceivers {
+		if transceiver.mid == mid {
+			return transceiver
+		}
+	}
+	return nil
+}
+
+// Answer answer a (re)offer from the remote peer.
+// RTP properties are negotiated against the transport capabilities, incoming streams are created or removed
+// to match the offer and the outgoing tracks are placed on the offered media sections.
+func (t *Transport) Answer(remoteSdp *sdp.SDPInfo) (*sdp.SDPInfo, error) {
+
+	if remoteSdp == nil {
+		return nil, newError(ErrInvalidSDP, "remote sdp can not be nil")
+	}
+
+	t.negotiation.Lock()
+	defer t.negotiation.Unlock()
+
+	if t.isStopped() {
+		return nil, newError(ErrStopped, "Transport is stopped")
+	}
+
+	for _, offered := range remoteSdp.GetMedias() {
+
+		transceiver := t.GetTransceiver(offered.GetID())
+
+		if transceiver == nil {
+			transceiver = newTransceiver(t, offered.GetID(), offered.GetType())
+			t.Lock()
+			t.transceivers = append(t.transceivers, transceiver)
+			t.Unlock()
+		} else if transceiver.media != offered.GetType() {
+			// the remote side reused the mid for another media
+			transceiver.stop()
+			transceiver.reuse(offered.GetType())
+		} else if transceiver.released && offered.GetDirection() != sdp.INACTIVE {
+			// the remote side reused the mid of a stopped section
+			transceiver.reuse(offered.GetType())
+		}
+
+		transceiver.remote = offered
+
+		direction := offered.GetDirection()
+		transceiver.recv = direction == sdp.SENDRECV || direction == sdp.SENDONLY
+
+		capability, ok := t.getCapability(offered.GetType())
+		if !ok {
+			// reject it
+			transceiver.stop()
+			transceiver.local = sdp.NewMediaInfo(offered.GetID(), offered.GetType())
+			continue
+		}
+
+		if transceiver.stopped {
+			transceiver.local = sdp.NewMediaInfo(offered.GetID(), offered.GetType())
+			continue
+		}
+
+		transceiver.local = answerMedia(offered, capability)
+	}
+
+	if err := t.applyNegotiation(remoteSdp); err != nil {
+		return nil, err
+	}
+
+	return t.createUpdatedAnswer(), nil
+}
+
+// CreateUpdatedAnswer create the local answer to the last remote offer with the current outgoing tracks.
+// Tracks which do not fit in an offered media section are announced on the next CreateUpdatedOffer
+func (t *Transport) CreateUpdatedAnswer() *sdp.SDPInfo {
+
+	t.negotiation.Lock()
+	defer t.negotiation.Unlock()
+
+	return t.createUpdatedAnswer()
+}
+
+// createUpdatedAnswer needs the negotiation lock
+func (t *Transport) createUpdatedAnswer() *sdp.SDPInfo {
+
+	t.placeOutgoingTracks(false)
+
+	answer := t.newLocalDescription()
+
+	for _, transceiver := range t.GetTransceivers() {
+		if transceiver.local == nil || transceiver.remote == nil {
+			continue
+		}
+		t.addMediaSection(answer, transceiver, transceiver.direction())
+	}
+
+	return answer
+}
+
+// CreateUpdatedOffer create a local offer with a media section for every transceiver.
+// New outgoing tracks get a media section, reusing the mid of a stopped one when possible,
+// and removed tracks leave their media section as recvonly. The answer must be set with SetRemoteAnswer
+func (t *Transport) CreateUpdatedOffer() *sdp.SDPInfo {
+
+	t.negotiation.Lock()
+	defer t.negotiation.Unlock()
+
+	t.placeOutgoingTracks(true)
+
+	offer := t.newLocalDescription()
+
+	for _, transceiver := range t.GetTransceivers() {
+
+		if transceiver.local == nil {
+			capability, ok := t.getCapability(transceiver.media)
+			if !ok {
+				continue
+			}
+			transceiver.local = offerMedia(transceiver.mid, transceiver.media, capability)
+		}
+
+		direction := sdp.RECVONLY
+		if transceiver.stopped {
+			direction = sdp.INACTIVE
+		} else if transceiver.outgoing != nil {
+			direction = sdp.SENDRECV
+		}
+
+		t.addMediaSection(offer, transceiver, direction)
+	}
+
+	return offer
+}
+
+// SetRemoteAnswer apply the remote answer to our last offer
+func (t *Transport) SetRemoteAnswer(remoteSdp *sdp.SDPInfo) error {
+
+	if remoteSdp == nil {
+		return newError(ErrInvalidSDP, "remote sdp can not be nil")
+	}
+
+	t.negotiation.Lock()
+	defer t.negotiation.Unlock()
+
+	if t.isStopped() {
+		return newError(ErrStopped, "Transport is stopped")
+	}
+
+	for _, answered := range remoteSdp.GetMedias() {
+
+		transceiver := t.GetTransceiver(answered.GetID())
+		if transceiver == nil || transceiver.local == nil {
+			// not offered by us
+			continue
+		}
+
+		transceiver.remote = answered
+
+		direction := answered.GetDirection()
+		transceiver.recv = direction == sdp.SENDRECV || direction == sdp.SENDONLY
+
+		if len(answered.GetCodecs()) == 0 {
+			// rejected
+			transceiver.stop()
+		}
+	}
+
+	return t.applyNegotiation(remoteSdp)
+}
+
+// applyNegotiation set the rtp properties of the first active audio and video sections and sync the incoming streams.
+// The remote side uses its own payload types, the local side the ones we announced
+func (t *Transport) applyNegotiation(remoteSdp *sdp.SDPInfo) error {
+
+	var remoteAudio, remoteVideo, localAudio, localVideo *sdp.MediaInfo
+
+	for _, transceiver := range t.GetTransceivers() {
+
+		if transceiver.stopped {
+			// negotiated, the mid is free to be reused
+			transceiver.released = true
+			continue
+		}
+
+		if transceiver.local == nil || transceiver.remote == nil {
+			continue
+		}
+
+		if transceiver.media == "audio" && remoteAudio == nil {
+			remoteAudio, localAudio = transceiver.remote, transceiver.local
+		}
+
+		if transceiver.media == "video" && remoteVideo == nil {
+			remoteVideo, localVideo = transceiver.remote, transceiver.local
+		}
+	}
+
+	// an offer can list header extensions we did not answer, the remote side does not send them
+	remoteAudioParameters := NewRTPParameters(remoteAudio)
+	remoteAudioParameters.DropUnsupportedHeaderExtensions()
+
+	remoteVideoParameters := NewRTPParameters(remoteVideo)
+	remoteVideoParameters.DropUnsupportedHeaderExtensions()
+
+	if err := t.SetRemoteRTPParameters(remoteAudioParameters, remoteVideoParameters); err != nil {
+		return wrapError(ErrInvalidSDP, "invalid remote rtp parameters", err)
+	}
+
+	if err := t.SetLocalProperties(localAudio, localVideo); err != nil {
+		return wrapError(ErrInvalidSDP, "invalid local rtp parameters", err)
+	}
+
+	t.syncIncomingStreams(remoteSdp)
+
+	for _, transceiver := range t.GetTransceivers() {
+
+		transceiver.incoming = nil
+		transceiver.incomingStream = nil
+
+		info := remoteSdp.GetStreamByMediaID(transceiver.mid)
+		if transceiver.stopped || info == nil {
+			continue
+		}
+
+		incoming := t.GetIncomingStream(info.GetID())
+		if incoming == nil {
+			continue
+		}
+
+		transceiver.incomingStream = incoming
+		transceiver.incoming = incoming.GetTrack(remoteSdp.GetTrackByMediaID(transceiver.mid).GetID())
+	}
+
+	return nil
+}
+
+// syncIncomingStreams create the streams and tracks announced by the remote side and stop the ones gone away
+func (t *Transport) syncIncomingStreams(remoteSdp *sdp.SDPInfo) {
+
+	for _, incoming := range t.GetIncomingStreams() {
+
+		info := remoteSdp.GetStream(incoming.GetID())
+
+		for _, track := range incoming.GetTracks() {
+			if info != nil && info.GetTrack(track.GetID()) != nil {
+				continue
+			}
+			incoming.RemoveTrack(track)
+			t.removeIncomingTrack(track)
+		}
+
+		if info == nil {
+			t.RemoveIncomingStream(incoming)
+			incoming.Stop()
+		}
+	}
+
+	for _, info := range sortedStreams(remoteSdp) {
+
+		// tracks of stopped media sections are not received anymore
+		for _, track := range info.GetTracks() {
+			if transceiver := t.GetTransceiver(track.GetMediaID()); transceiver != nil && transceiver.stopped {
+				info = info.Clone()
+				info.RemoveTrackById(track.GetID())
+			}
+		}
+
+		if len(info.GetTracks()) == 0 {
+			continue
+		}
+
+		incoming := t.GetIncomingStream(info.GetID())
+
+		if incoming == nil {
+			stream, err := t.createIncomingStream(info, true)
+			if err != nil {
+				componentLogger("transport").Warn("can not create remote incoming stream", "stream", info.GetID(), "error", err)
+				continue
+			}
+			for _, track := range stream.GetTracks() {
+				for _, trackFunc := range t.incomingTrackListeners() {
+					trackFunc(track, stream)
+				}
+			}
+			continue
+		}
+
+		for _, track := range info.GetTracks() {
+
+			if incoming.GetTrack(track.GetID()) != nil {
+				continue
+			}
+
+			incomingTrack, err := incoming.createTrack(track, true)
+			if err != nil {
+				componentLogger("transport").Warn("can not create remote incoming track", "stream", info.GetID(), "error", err)
+				continue
+			}
+
+			for _, trackFunc := range t.incomingTrackListeners() {
+				trackFunc(incomingTrack, incoming)
+			}
+		}
+	}
+}
+
+// placeOutgoingTracks bind the outgoing tracks to transceivers, new transceivers are only created when offering
+func (t *Transport) placeOutgoingTracks(offer bool) {
+
+	streams := t.GetOutgoingStreams()
+	sort.Slice(streams, func(i, j int) bool { return streams[i].GetID() < streams[j].GetID() })
+
+	current := map[*OutgoingStreamTrack]bool{}
+	for _, stream := range streams {
+		for _, track := range stream.GetTracks() {
+			current[track] = true
+		}
+	}
+
+	placed := map[*OutgoingStreamTrack]bool{}
+
+	for _, transceiver := range t.GetTransceivers() {
+		if transceiver.outgoing == nil {
+			continue
+		}
+		if transceiver.stopped || !current[transceiver.outgoing] {
+			transceiver.outgoing = nil
+			transceiver.outgoingStream = nil
+			continue
+		}
+		placed[transceiver.outgoing] = true
+	}
+
+	for _, stream := range streams {
+
+		tracks := stream.GetTracks()
+		sort.Slice(tracks, func(i, j int) bool { return tracks[i].GetID() < tracks[j].GetID() })
+
+		for _, track := range tracks {
+
+			if placed[track] {
+				continue
+			}
+
+			transceiver := t.freeTransceiver(track.GetMedia(), offer)
+			if transceiver == nil {
+				continue
+			}
+
+			transceiver.outgoing = track
+			transceiver.outgoingStream = stream
+		}
+	}
+}
+
+// freeTransceiver find a transceiver able to send a new track of the media
+func (t *Transport) freeTransceiver(media string, offer bool) *Transceiver {
+
+	transceivers := t.GetTransceivers()
+
+	for _, transceiver := range transceivers {
+		if !transceiver.stopped && transceiver.media == media && transceiver.outgoing == nil && transceiver.remote != nil {
+			return transceiver
+		}
+	}
+
+	if !offer {
+		return nil
+	}
+
+	if _, ok := t.getCapability(media); !ok {
+		return nil
+	}
+
+	for _, transceiver := range transceivers {
+		if transceiver.released {
+			transceiver.reuse(media)
+			return transceiver
+		}
+	}
+
+	transceiver := newTransceiver(t, t.newMid(), media)
+
+	t.Lock()
+	t.transceivers = append(t.transceivers, transceiver)
+	t.Unlock()
+
+	return transceiver
+}
+
+// getCapability get the capability of the media set with SetCapabilities
+func (t *Transport) getCapability(media string) (*sdp.Capability, bool) {
+	t.Lock()
+	defer t.Unlock()
+	capability, ok := t.capabilities[media]
+	return capability, ok
+}
+
+func (t *Transport) newMid() string {
+	for i := len(t.GetTransceivers()); ; i++ {
+		mid := strconv.Itoa(i)
+		if t.GetTransceiver(mid) == nil {
+			return mid
+		}
+	}
+}
+
+func (t *Transport) newLocalDescription() *sdp.SDPInfo {
+
+	t.Lock()
+	t.sdpVersion++
+	version := t.sdpVersion
+	t.Unlock()
+
+	info := sdp.NewSDPInfo()
+	info.SetVersion(version)
+	info.SetICE(t.localIce)
+	info.SetDTLS(t.localDtls)
+	info.AddCandidates(t.localCandidates)
+
+	return info
+}
+
+// addMediaSection add the transceiver media and its outgoing track to the description
+func (t *Transport) addMediaSection(info *sdp.SDPInfo, transceiver *Transceiver, direction sdp.Direction) {
+
+	media := transceiver.local.Clone()
+	media.SetDirection(direction)
+	info.AddMedia(media)
+
+	if transceiver.outgoing == nil || (direction != sdp.SENDRECV && direction != sdp.SENDONLY) {
+		return
+	}
+
+	stream := info.GetStream(transceiver.outgoingStream.GetID())
+	if stream == nil {
+		stream = sdp.NewStreamInfo(transceiver.outgoingStream.GetID())
+		info.AddStream(stream)
+	}
+
+	track := transceiver.outgoing.GetTrackInfo().Clone()
+	track.SetMediaID(transceiver.mid)
+	stream.AddTrack(track)
+}
@@ -0,0 +1,59 @@
+package mediaserver
+
+import (
+	"testing"
+
+	"github.com/notedit/sdp"
+)
+
+func Test_OfferAndAnswerMedia(t *testing.T) {
+
+	absSendTime := "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+
+	offered := offerMedia("0", "video", &sdp.Capability{
+		Codecs:     []string{"vp8"},
+		Rtx:        true,
+		Rtcpfbs:    []*sdp.RtcpFeedback{{ID: "nack"}, {ID: "nack", Params: []string{"pli"}}, {ID: "goog-remb"}},
+		Extensions: []string{absSendTime, "urn:example:unknown"},
+	})
+
+	// the native side would not honour it
+	if len(offered.GetExtensions()) != 1 {
+		t.Fatal("unsupported extension offered", offered.GetExtensions())
+	}
+
+	answered := answerMedia(offered, &sdp.Capability{
+		Codecs:     []string{"vp8"},
+		Rtx:        true,
+		Rtcpfbs:    []*sdp.RtcpFeedback{{ID: "nack", Params: []string{"pli"}}, {ID: "ccm", Params: []string{"fir"}}},
+		Extensions: []string{absSendTime},
+	})
+
+	if len(answered.GetCodecs()) != 1 || len(answered.GetExtensions()) != 1 {
+		t.Fatal("wrong answer", answered.GetCodecs(), answered.GetExtensions())
+	}
+
+	for _, codec := range answered.GetCodecs() {
+		if codec.GetCodec() != "vp8" || !codec.HasRTX() {
+			t.Fatal("wrong codec", codec.GetCodec())
+		}
+		// only the rtcp-fb offered and supported
+		rtcpfbs := codec.GetRTCPFeedbacks()
+		if len(rtcpfbs) != 1 || rtcpfbs[0].GetID() != "nack" || len(rtcpfbs[0].GetParams()) != 1 {
+			t.Fatal("wrong rtcp-fb", rtcpfbs)
+		}
+	}
+}
+
+func Test_SortedStreams(t *testing.T) {
+
+	info := sdp.NewSDPInfo()
+	for _, id := range []string{"c", "a", "b"} {
+		info.AddStream(sdp.NewStreamInfo(id))
+	}
+
+	streams := sortedStreams(info)
+	if len(streams) != 3 || streams[0].GetID() != "a" || streams[2].GetID() != "c" {
+		t.Fatal("streams not sorted")
+	}
+}
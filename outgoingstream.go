@@ -1,27 +1,28 @@
 package mediaserver
 
 import (
+	"sort"
 	"strings"
 	"sync"
 
-	native "github.com/notedit/media-server-go/wrapper"
 	"github.com/notedit/sdp"
 )
 
-// OutgoingStream  represent the media stream sent to a remote peer
+// OutgoingStream  represent the media stream sent to a remote peer, safe for concurrent use
 type OutgoingStream struct {
 	id                  string
-	transport           native.DTLSICETransport
+	transport           transportBackend
 	info                *sdp.StreamInfo
 	muted               bool
 	tracks              map[string]*OutgoingStreamTrack
 	onStopListeners     []func()
 	onAddTrackListeners []func(*OutgoingStreamTrack)
-	l                   sync.Mutex
+	// l guards the fields above, it is never held while calling the tracks or the listeners
+	l sync.Mutex
 }
 
-// NewOutgoingStream create outgoing stream
-func NewOutgoingStream(transport native.DTLSICETransport, info *sdp.StreamInfo) *OutgoingStream {
+// newOutgoingStream create outgoing stream, strict fails on the first track it can not create and stops the stream
+func newOutgoingStream(transport transportBackend, info *sdp.StreamInfo, strict bool) (*OutgoingStream, error) {
 	stream := new(OutgoingStream)
 
 	stream.id = info.GetID()
@@ -29,14 +30,20 @@ func NewOutgoingStream(transport native.DTLSICETransport, info *sdp.StreamInfo)
 	stream.info = info
 	stream.tracks = make(map[string]*OutgoingStreamTrack)
 
-	for _, track := range info.GetTracks() {
-		stream.CreateTrack(track)
-	}
-
 	stream.onStopListeners = make([]func(), 0)
 	stream.onAddTrackListeners = make([]func(*OutgoingStreamTrack), 0)
 
-	return stream
+	for _, track := range info.GetTracks() {
+		if _, err := stream.createTrack(track); err != nil {
+			if strict {
+				stream.Stop()
+				return nil, err
+			}
+			componentLogger("outgoingstream").Warn("can not create track", "stream", stream.id, "error", err)
+		}
+	}
+
+	return stream, nil
 }
 
 // GetID get id
@@ -48,7 +55,7 @@ func (o *OutgoingStream) GetID() string {
 func (o *OutgoingStream) GetStats() map[string]*OutgoingStatss {
 
 	stats := map[string]*OutgoingStatss{}
-	for _, track := range o.tracks {
+	for _, track := range o.GetTracks() {
 		stats[track.GetID()] = track.GetStats()
 	}
 	return stats
@@ -56,19 +63,21 @@ func (o *OutgoingStream) GetStats() map[string]*OutgoingStatss {
 
 // IsMuted Check if the stream is muted or not
 func (o *OutgoingStream) IsMuted() bool {
+	o.l.Lock()
+	defer o.l.Unlock()
 	return o.muted
 }
 
 // Mute Mute/Unmute this stream and all the tracks in it
 func (o *OutgoingStream) Mute(muting bool) {
 
-	for _, track := range o.tracks {
+	for _, track := range o.GetTracks() {
 		track.Mute(muting)
 	}
 
-	if o.muted != muting {
-		o.muted = muting
-	}
+	o.l.Lock()
+	o.muted = muting
+	o.l.Unlock()
 }
 
 // AttachTo Listen media from the incoming stream and send it to the remote peer of the associated transport
@@ -76,44 +85,39 @@ func (o *OutgoingStream) AttachTo(incomingStream *IncomingStream) []*Transponder
 
 	o.Detach()
 	transponders := []*Transponder{}
-	audios := o.GetAudioTracks()
-	if len(audios) > 0 {
-		index := len(audios)
-		tracks := incomingStream.GetAudioTracks()
-		if index < len(tracks) {
-			index = len(tracks)
-		}
 
-		for i, track := range tracks {
-			if i < index {
-				transponders = append(transponders, audios[i].AttachTo(track))
-			}
-		}
+	outgoings, incomings := matchTracks(o.GetAudioTracks(), incomingStream.GetAudioTracks())
+	for i, track := range outgoings {
+		transponders = append(transponders, track.AttachTo(incomings[i]))
 	}
 
-	videos := o.GetVideoTracks()
-	if len(videos) > 0 {
-		index := len(videos)
-		tracks := incomingStream.GetVideoTracks()
-		if index < len(tracks) {
-			index = len(tracks)
-		}
-		for i, track := range tracks {
-			if i < index {
-				transponders = append(transponders, videos[i].AttachTo(track))
-			}
-		}
+	outgoings, incomings = matchTracks(o.GetVideoTracks(), incomingStream.GetVideoTracks())
+	for i, track := range outgoings {
+		transponders = append(transponders, track.AttachTo(incomings[i]))
 	}
 
 	return transponders
 }
 
+// matchTracks pair the tracks by position once sorted by id, so the pairs are the same on every call.
+// The tracks left without a pair are dropped
+func matchTracks(outgoings []*OutgoingStreamTrack, incomings []*IncomingStreamTrack) ([]*OutgoingStreamTrack, []*IncomingStreamTrack) {
+
+	sort.Slice(outgoings, func(i, j int) bool { return outgoings[i].GetID() < outgoings[j].GetID() })
+	sort.Slice(incomings, func(i, j int) bool { return incomings[i].GetID() < incomings[j].GetID() })
+
+	count := len(outgoings)
+	if len(incomings) < count {
+		count = len(incomings)
+	}
+
+	return outgoings[:count], incomings[:count]
+}
+
 // Detach Stop listening for media
 func (o *OutgoingStream) Detach() {
 
-	o.l.Lock()
-	defer o.l.Unlock()
-	for _, track := range o.tracks {
+	for _, track := range o.GetTracks() {
 		track.Detach()
 	}
 }
@@ -185,59 +189,88 @@ func (o *OutgoingStream) RemoveTrack(track *OutgoingStreamTrack) {
 	delete(o.tracks, track.GetID())
 }
 
+// stopTrack remove the track, stop it and release it from the transport
+func (o *OutgoingStream) stopTrack(track *OutgoingStreamTrack) {
+
+	o.l.Lock()
+	transport := o.transport
+	owned := o.tracks[track.GetID()] == track
+	if owned {
+		delete(o.tracks, track.GetID())
+	}
+	o.l.Unlock()
+
+	track.Stop()
+
+	// released by Stop otherwise
+	if owned && transport != nil {
+		transport.removeOutgoingTrack(track)
+	}
+}
+
 // CreateTrack Create new track from a TrackInfo object and add it to this stream
 func (o *OutgoingStream) CreateTrack(track *sdp.TrackInfo) *OutgoingStreamTrack {
 
-	var mediaType native.MediaFrameType = 0
-	if track.GetMedia() == "video" {
-		mediaType = 1
+	outgoingTrack, err := o.createTrack(track)
+	if err != nil {
+		componentLogger("outgoingstream").Warn("can not create track", "stream", o.id, "error", err)
+		return nil
 	}
+	return outgoingTrack
+}
 
-	source := native.NewRTPOutgoingSourceGroup(mediaType)
+// NewTrack same as CreateTrack returning the error, ErrDuplicateTrack when the stream already has a track with this id
+// and ErrInvalidSDP when the track has no ssrc
+func (o *OutgoingStream) NewTrack(track *sdp.TrackInfo) (*OutgoingStreamTrack, error) {
+	return o.createTrack(track)
+}
 
-	source.GetMedia().SetSsrc(track.GetSSRCS()[0])
+// createTrack ErrDuplicateTrack when the stream already has a track with this id and ErrInvalidSDP when the track has no ssrc
+func (o *OutgoingStream) createTrack(track *sdp.TrackInfo) (*OutgoingStreamTrack, error) {
 
-	fid := track.GetSourceGroup("FID")
-	fec_fr := track.GetSourceGroup("FEC-FR")
+	if track == nil {
+		return nil, newError(ErrInvalidArgument, "Track info can not be nil")
+	}
 
-	if fid != nil {
-		source.GetRtx().SetSsrc(fid.GetSSRCs()[1])
-	} else {
-		source.GetRtx().SetSsrc(0)
+	if len(track.GetSSRCS()) == 0 {
+		return nil, newError(ErrInvalidSDP, "Track "+track.GetID()+" has no ssrc")
 	}
 
-	if fec_fr != nil {
-		source.GetFec().SetSsrc(fec_fr.GetSSRCs()[1])
-	} else {
-		source.GetFec().SetSsrc(0)
+	// held until the track is added, so the same id can not be created twice
+	o.l.Lock()
+
+	if o.transport == nil {
+		o.l.Unlock()
+		return nil, newError(ErrStopped, "Stream is stopped")
 	}
 
 	if _, ok := o.tracks[track.GetID()]; ok {
-		return nil
+		o.l.Unlock()
+		return nil, newError(ErrDuplicateTrack, "Track id already present in stream")
 	}
 
-	o.transport.AddOutgoingSourceGroup(source)
-
-	outgoingTrack := newOutgoingStreamTrack(track.GetMedia(), track.GetID(), native.TransportToSender(o.transport), source)
+	outgoingTrack := o.transport.newOutgoingTrack(track)
 
 	// TODO
 	// runtime.SetFinalizer(source, func(source native.RTPOutgoingSourceGroup) {
 	// 	o.transport.RemoveOutgoingSourceGroup(source)
 	// })
 
-	o.l.Lock()
 	o.tracks[outgoingTrack.GetID()] = outgoingTrack
+	listeners := append([]func(*OutgoingStreamTrack){}, o.onAddTrackListeners...)
 	o.l.Unlock()
 
-	for _, addTrackFunc := range o.onAddTrackListeners {
+	for _, addTrackFunc := range listeners {
 		addTrackFunc(outgoingTrack)
 	}
 
-	return outgoingTrack
+	return outgoingTrack, nil
 }
 
 // OnTrack new outgoing track listener
 func (o *OutgoingStream) OnTrack(listener func(*OutgoingStreamTrack)) {
+	o.l.Lock()
+	defer o.l.Unlock()
 	o.onAddTrackListeners = append(o.onAddTrackListeners, listener)
 }
 
@@ -245,16 +278,19 @@ func (o *OutgoingStream) OnTrack(listener func(*OutgoingStreamTrack)) {
 // Stop stop the remote stream
 func (o *OutgoingStream) Stop() {
 
-	if o.transport == nil {
+	o.l.Lock()
+	transport := o.transport
+	tracks := o.tracks
+	o.transport = nil
+	o.tracks = make(map[string]*OutgoingStreamTrack, 0)
+	o.l.Unlock()
+
+	if transport == nil {
 		return
 	}
 
-	for _, track := range o.tracks {
+	for _, track := range tracks {
 		track.Stop()
-		track.DeleteOutgoingSourceGroup(o.transport)
+		transport.removeOutgoingTrack(track)
 	}
-
-	o.tracks = make(map[string]*OutgoingStreamTrack, 0)
-
-	o.transport = nil
 }
@@ -0,0 +1,15 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	native "github.com/notedit/media-server-go/wrapper"
+	"github.com/notedit/sdp"
+)
+
+// NewOutgoingStream create outgoing stream, the tracks that can not be created are skipped
+func NewOutgoingStream(transport native.DTLSICETransport, info *sdp.StreamInfo) *OutgoingStream {
+	stream, _ := newOutgoingStream(&nativeTransport{transport: transport}, info, false)
+	return stream
+}
@@ -1,27 +1,50 @@
 package mediaserver
 
 import (
+	"sync"
 	"time"
 
-	native "github.com/notedit/media-server-go/wrapper"
 	"github.com/notedit/sdp"
 )
 
-// OutgoingStreamTrack Audio or Video track of a media stream sent to a remote peer
+// OutgoingStreamTrack Audio or Video track of a media stream sent to a remote peer, safe for concurrent use
 type OutgoingStreamTrack struct {
+	// ops serializes AttachTo, Detach, Mute, SetPlaceholder, Switch and Stop, it is held while calling the transponder
+	ops sync.Mutex
+	// l guards the fields below, it is never held while calling out
+	l sync.Mutex
+	// inOp an operation holds ops, the listeners called meanwhile are queued until it is done
+	inOp   bool
+	queued []func()
+
 	id              string
 	media           string
 	muted           bool
-	sender          native.RTPSenderFacade
-	source          native.RTPOutgoingSourceGroup
+	sender          senderBackend
+	source          outgoingSourceBackend
 	transpoder      *Transponder
 	trackInfo       *sdp.TrackInfo
 	statss          *OutgoingStatss
 	onMuteListeners []func(bool)
 	onStopListeners []func()
 	// todo outercallback
+
+	switchTimeout       time.Duration
+	onSwitchedListeners []SwitchedListener
+
+	placeholder *Placeholder
+	// attached the track forwarded when not muted, the placeholder may be sent instead
+	attached *IncomingStreamTrack
+	// leakGuard logs the sender and the source if the track is collected without being stopped
+	leakGuard *nativeLeakGuard
 }
 
+// DefaultSwitchTimeout how long Switch waits for a key frame on the new track
+const DefaultSwitchTimeout = 2 * time.Second
+
+// SwitchedListener listener, called when a switch is completed, or with an error when it is given up
+type SwitchedListener func(incomingTrack *IncomingStreamTrack, err error)
+
 // OutgoingStats stats info
 type OutgoingStats struct {
 	NumPackets     uint
@@ -39,53 +62,20 @@ type OutgoingStatss struct {
 	timestamp int64
 }
 
-func getStatsFromOutgoingSource(source native.RTPOutgoingSource) *OutgoingStats {
-
-	stats := &OutgoingStats{
-		NumPackets:     source.GetNumPackets(),
-		NumRTCPPackets: source.GetNumRTCPPackets(),
-		TotalBytes:     source.GetTotalBytes(),
-		TotalRTCPBytes: source.GetTotalRTCPBytes(),
-		Bitrate:        source.GetBitrate(),
-	}
-
-	return stats
-}
-
-// NewOutgoingStreamTrack create outgoing stream track
-func newOutgoingStreamTrack(media string, id string, sender native.RTPSenderFacade, source native.RTPOutgoingSourceGroup) *OutgoingStreamTrack {
+// newBackendOutgoingStreamTrack create a track without source, sending through the sender
+func newBackendOutgoingStreamTrack(media string, id string, sender senderBackend) *OutgoingStreamTrack {
 
 	track := &OutgoingStreamTrack{}
 	track.id = id
 	track.media = media
 	track.sender = sender
 	track.muted = false
-	track.source = source
 	track.trackInfo = sdp.NewTrackInfo(id, media)
 
-	track.trackInfo.AddSSRC(source.GetMedia().GetSsrc())
-
-	if source.GetRtx().GetSsrc() > 0 {
-		track.trackInfo.AddSSRC(source.GetRtx().GetSsrc())
-	}
-
-	if source.GetFec().GetSsrc() > 0 {
-		track.trackInfo.AddSSRC(source.GetFec().GetSsrc())
-	}
-
-	if source.GetRtx().GetSsrc() > 0 {
-		sourceGroup := sdp.NewSourceGroupInfo("FID", []uint{source.GetMedia().GetSsrc(), source.GetRtx().GetSsrc()})
-		track.trackInfo.AddSourceGroup(sourceGroup)
-	}
-
-	if source.GetFec().GetSsrc() > 0 {
-		sourceGroup := sdp.NewSourceGroupInfo("FEC-FR", []uint{source.GetMedia().GetSsrc(), source.GetFec().GetSsrc()})
-		track.trackInfo.AddSourceGroup(sourceGroup)
-	}
-
-
 	track.onMuteListeners = make([]func(bool), 0)
 	track.onStopListeners = make([]func(), 0)
+	track.onSwitchedListeners = make([]SwitchedListener, 0)
+	track.switchTimeout = DefaultSwitchTimeout
 
 	return track
 }
@@ -108,112 +98,382 @@ func (o *OutgoingStreamTrack) GetTrackInfo() *sdp.TrackInfo {
 // GetStats get stats info
 func (o *OutgoingStreamTrack) GetStats() *OutgoingStatss {
 
+	o.l.Lock()
+	defer o.l.Unlock()
+
 	if o.statss == nil {
 		o.statss = &OutgoingStatss{}
 	}
 
-	if time.Now().UnixNano()-o.statss.timestamp > 200000000 {
-		o.statss.Media = getStatsFromOutgoingSource(o.source.GetMedia())
-		o.statss.Rtx = getStatsFromOutgoingSource(o.source.GetRtx())
-		o.statss.Fec = getStatsFromOutgoingSource(o.source.GetFec())
+	if o.source != nil && time.Now().UnixNano()-o.statss.timestamp > 200000000 {
+		o.statss = o.source.stats()
 		o.statss.timestamp = time.Now().UnixNano()
 	}
 
-	return o.statss
-}
-
-// GetSSRCs get ssrcs map
-func (o *OutgoingStreamTrack) GetSSRCs() map[string]native.RTPOutgoingSource {
-
-	return map[string]native.RTPOutgoingSource{
-		"media": o.source.GetMedia(),
-		"rtx":   o.source.GetRtx(),
-		"fec":   o.source.GetFec(),
-	}
+	// the stats are replaced, not updated, on refresh
+	stats := *o.statss
+	return &stats
 }
 
 // IsMuted Check if the track is muted or not
 func (o *OutgoingStreamTrack) IsMuted() bool {
+
+	o.l.Lock()
+	defer o.l.Unlock()
+
 	return o.muted
 }
 
-// Mute Mute/Unmute the track
+// Mute Mute/Unmute the track, the placeholder is sent while muted if there is one
 func (o *OutgoingStreamTrack) Mute(muting bool) {
 
+	o.lockOps()
+	defer o.unlockOps()
+
 	if o.transpoder != nil {
-		o.transpoder.Mute(muting)
+		if o.placeholder != nil {
+			o.forward(muting)
+		} else {
+			o.transpoder.Mute(muting)
+		}
 	}
 
-	if o.muted != muting {
-		o.muted = muting
-
-		for _, mutefunc := range o.onMuteListeners {
-			mutefunc(muting)
-		}
+	o.l.Lock()
+	changed := o.muted != muting
+	o.muted = muting
+	listeners := append([]func(bool){}, o.onMuteListeners...)
+	o.l.Unlock()
+
+	if changed {
+		o.notify(func() {
+			for _, mutefunc := range listeners {
+				mutefunc(muting)
+			}
+		})
 	}
 }
 
 // AttachTo Listen media from the incoming stream track and send it to the remote peer of the associated transport
 func (o *OutgoingStreamTrack) AttachTo(incomingTrack *IncomingStreamTrack) *Transponder {
 
+	o.lockOps()
+	defer o.unlockOps()
+
+	return o.attachTo(incomingTrack)
+}
+
+// attachTo needs ops, nil when the track is stopped
+func (o *OutgoingStreamTrack) attachTo(incomingTrack *IncomingStreamTrack) *Transponder {
+
 	// detach first
-	o.Detach()
+	o.stopTransponder()
 
-	transponder := native.NewRTPStreamTransponderFacade(o.source, o.sender)
+	if o.sender == nil {
+		return nil
+	}
 
-	o.transpoder = NewTransponder(transponder)
+	transponder := o.newTransponder()
+
+	o.l.Lock()
+	o.attached = incomingTrack
+	o.transpoder = transponder
+	o.l.Unlock()
+
+	if o.placeholder != nil {
+		o.forward(o.muted)
+		return transponder
+	}
 
 	if o.muted {
+		transponder.Mute(o.muted)
+	}
+
+	transponder.SetIncomingTrack(incomingTrack)
+
+	return transponder
+}
+
+// SetPlaceholder send the placeholder while the track is muted or not attached, nil to stop sending it.
+// The placeholder is not stopped with the track
+func (o *OutgoingStreamTrack) SetPlaceholder(placeholder *Placeholder) error {
+
+	if placeholder != nil && placeholder.GetIncomingStreamTrack().GetMedia() != o.media {
+		return newError(ErrInvalidArgument, "can not use a "+placeholder.GetIncomingStreamTrack().GetMedia()+" placeholder")
+	}
+
+	o.lockOps()
+	defer o.unlockOps()
+
+	if o.sender == nil {
+		return newError(ErrStopped, "track is stopped")
+	}
+
+	o.l.Lock()
+	previous := o.placeholder
+	o.placeholder = placeholder
+	attached := o.attached
+	o.l.Unlock()
+
+	if placeholder == nil {
+		if previous == nil || o.transpoder == nil {
+			return nil
+		}
+		if attached == nil {
+			o.stopTransponder()
+			return nil
+		}
+		if o.transpoder.GetIncomingTrack() != attached {
+			o.transpoder.SetIncomingTrack(attached)
+		}
 		o.transpoder.Mute(o.muted)
+		return nil
 	}
 
-	o.transpoder.SetIncomingTrack(incomingTrack)
+	if o.transpoder == nil {
+		transponder := o.newTransponder()
+		o.l.Lock()
+		o.transpoder = transponder
+		o.l.Unlock()
+	}
 
-	return o.transpoder
+	o.forward(o.muted)
+
+	return nil
+}
+
+// GetPlaceholder get the placeholder
+func (o *OutgoingStreamTrack) GetPlaceholder() *Placeholder {
+
+	o.l.Lock()
+	defer o.l.Unlock()
+
+	return o.placeholder
 }
 
-// Detach Stop forwarding any previous attached track
+// forward send the attached track, or the placeholder when muted or not attached, needs ops
+func (o *OutgoingStreamTrack) forward(muted bool) {
+
+	o.l.Lock()
+	incomingTrack := o.attached
+	o.l.Unlock()
+
+	if muted || incomingTrack == nil {
+		incomingTrack = o.placeholder.GetIncomingStreamTrack()
+	}
+
+	if o.transpoder.GetIncomingTrack() != incomingTrack {
+		o.transpoder.SetIncomingTrack(incomingTrack)
+	}
+
+	o.transpoder.Mute(false)
+}
+
+// Switch forward the new track instead of the attached one without a glitch.
+// The attached track keeps being forwarded until the new one sends a key frame, OnSwitched listeners are called then
+func (o *OutgoingStreamTrack) Switch(incomingTrack *IncomingStreamTrack) error {
+
+	if incomingTrack == nil {
+		return newError(ErrInvalidArgument, "track can not be nil")
+	}
+
+	if incomingTrack.GetMedia() != o.media {
+		return newError(ErrInvalidTrack, "can not switch to a "+incomingTrack.GetMedia()+" track")
+	}
+
+	if incomingTrack.GetFirstEncoding() == nil {
+		return newError(ErrInvalidTrack, "track has no encoding")
+	}
+
+	o.lockOps()
+	defer o.unlockOps()
+
+	if o.sender == nil {
+		return newError(ErrStopped, "track is stopped")
+	}
+
+	if o.transpoder == nil {
+		o.attachTo(incomingTrack)
+		o.notify(func() { o.switched(incomingTrack, nil) })
+		return nil
+	}
+
+	// the placeholder keeps being sent, the track will be forwarded on unmute
+	if o.muted && o.placeholder != nil {
+		o.l.Lock()
+		o.attached = incomingTrack
+		o.l.Unlock()
+		o.notify(func() { o.switched(incomingTrack, nil) })
+		return nil
+	}
+
+	o.l.Lock()
+	timeout := o.switchTimeout
+	o.l.Unlock()
+
+	// called right away when the switch is overridden or the transponder stopped, or later from the key frame or timeout
+	transponder := o.transpoder
+	return transponder.SwitchIncomingTrack(incomingTrack, timeout, func(err error) {
+		o.l.Lock()
+		if err == nil && o.transpoder == transponder {
+			o.attached = incomingTrack
+		}
+		o.l.Unlock()
+		o.notify(func() { o.switched(incomingTrack, err) })
+	})
+}
+
+// SetSwitchTimeout set how long Switch waits for a key frame on the new track
+func (o *OutgoingStreamTrack) SetSwitchTimeout(timeout time.Duration) {
+
+	o.l.Lock()
+	defer o.l.Unlock()
+
+	o.switchTimeout = timeout
+}
+
+// OnSwitched register a listener for switch completion
+func (o *OutgoingStreamTrack) OnSwitched(listener SwitchedListener) {
+
+	o.l.Lock()
+	defer o.l.Unlock()
+
+	o.onSwitchedListeners = append(o.onSwitchedListeners, listener)
+}
+
+func (o *OutgoingStreamTrack) switched(incomingTrack *IncomingStreamTrack, err error) {
+
+	o.l.Lock()
+	listeners := append([]SwitchedListener{}, o.onSwitchedListeners...)
+	o.l.Unlock()
+
+	for _, listener := range listeners {
+		listener(incomingTrack, err)
+	}
+}
+
+// lockOps start an operation, the listeners called until unlockOps are queued
+func (o *OutgoingStreamTrack) lockOps() {
+
+	o.ops.Lock()
+
+	o.l.Lock()
+	o.inOp = true
+	o.l.Unlock()
+}
+
+// unlockOps end the operation and call the queued listeners
+func (o *OutgoingStreamTrack) unlockOps() {
+
+	o.l.Lock()
+	o.inOp = false
+	queued := o.queued
+	o.queued = nil
+	o.l.Unlock()
+
+	o.ops.Unlock()
+
+	for _, listener := range queued {
+		listener()
+	}
+}
+
+// notify call the listener now, or once the running operation is done so it can use the track
+func (o *OutgoingStreamTrack) notify(listener func()) {
+
+	o.l.Lock()
+	if o.inOp {
+		o.queued = append(o.queued, listener)
+		o.l.Unlock()
+		return
+	}
+	o.l.Unlock()
+
+	listener()
+}
+
+// Detach Stop forwarding any previous attached track, the placeholder is sent if there is one
 func (o *OutgoingStreamTrack) Detach() {
 
+	o.lockOps()
+	defer o.unlockOps()
+
+	o.l.Lock()
+	o.attached = nil
+	o.l.Unlock()
+
 	if o.transpoder == nil {
 		return
 	}
 
-	o.transpoder.Stop()
+	if o.placeholder != nil {
+		o.forward(true)
+		return
+	}
+
+	o.stopTransponder()
+}
+
+// newTransponder needs ops
+func (o *OutgoingStreamTrack) newTransponder() *Transponder {
+
+	o.l.Lock()
+	source := o.source
+	sender := o.sender
+	o.l.Unlock()
+
+	return newBackendTransponder(sender.newTransponder(source))
+}
+
+// stopTransponder needs ops
+func (o *OutgoingStreamTrack) stopTransponder() {
 
+	transponder := o.transpoder
+	if transponder == nil {
+		return
+	}
+
+	o.l.Lock()
 	o.transpoder = nil
+	o.l.Unlock()
+
+	transponder.Stop()
 }
 
 // GetTransponder Get attached transpoder for this track
 func (o *OutgoingStreamTrack) GetTransponder() *Transponder {
+
+	o.l.Lock()
+	defer o.l.Unlock()
+
 	return o.transpoder
 }
 
 func (o *OutgoingStreamTrack) OnMute(mute func(bool)) {
+
+	o.l.Lock()
+	defer o.l.Unlock()
+
 	o.onMuteListeners = append(o.onMuteListeners, mute)
 }
 
 // Stop Removes the track from the outgoing stream and also detaches from any attached incoming track
 func (o *OutgoingStreamTrack) Stop() {
 
+	o.lockOps()
+	defer o.unlockOps()
+
 	if o.sender == nil {
 		return
 	}
 
-	if o.transpoder != nil { // maybe = nil at onTransponderStopped
-		o.transpoder.Stop()
-		o.transpoder = nil
-	}
+	o.stopTransponder()
 
-	native.DeleteRTPSenderFacade(o.sender)
+	o.l.Lock()
+	sender := o.sender
 	o.sender = nil
-}
+	o.attached = nil
+	o.placeholder = nil
+	o.l.Unlock()
 
-func (o *OutgoingStreamTrack) DeleteOutgoingSourceGroup(transport native.DTLSICETransport)  {
-	if o.source != nil {
-		transport.RemoveOutgoingSourceGroup(o.source)
-		native.DeleteRTPOutgoingSourceGroup(o.source)
-		o.source = nil
-	}
+	sender.delete()
 }
-
@@ -0,0 +1,87 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	native "github.com/notedit/media-server-go/wrapper"
+	"github.com/notedit/sdp"
+)
+
+func getStatsFromOutgoingSource(source native.RTPOutgoingSource) *OutgoingStats {
+
+	stats := &OutgoingStats{
+		NumPackets:     source.GetNumPackets(),
+		NumRTCPPackets: source.GetNumRTCPPackets(),
+		TotalBytes:     source.GetTotalBytes(),
+		TotalRTCPBytes: source.GetTotalRTCPBytes(),
+		Bitrate:        source.GetBitrate(),
+	}
+
+	return stats
+}
+
+// NewOutgoingStreamTrack create outgoing stream track
+func newOutgoingStreamTrack(media string, id string, sender native.RTPSenderFacade, source native.RTPOutgoingSourceGroup) *OutgoingStreamTrack {
+
+	track := newBackendOutgoingStreamTrack(media, id, newNativeSender(sender))
+	track.source = &nativeOutgoingSource{group: source}
+
+	// the track owns the sender, the source is tracked by its creator
+	track.leakGuard = newNativeLeakGuard("OutgoingStreamTrack", sender, source)
+
+	track.trackInfo.AddSSRC(source.GetMedia().GetSsrc())
+
+	if source.GetRtx().GetSsrc() > 0 {
+		track.trackInfo.AddSSRC(source.GetRtx().GetSsrc())
+	}
+
+	if source.GetFec().GetSsrc() > 0 {
+		track.trackInfo.AddSSRC(source.GetFec().GetSsrc())
+	}
+
+	if source.GetRtx().GetSsrc() > 0 {
+		sourceGroup := sdp.NewSourceGroupInfo("FID", []uint{source.GetMedia().GetSsrc(), source.GetRtx().GetSsrc()})
+		track.trackInfo.AddSourceGroup(sourceGroup)
+	}
+
+	if source.GetFec().GetSsrc() > 0 {
+		sourceGroup := sdp.NewSourceGroupInfo("FEC-FR", []uint{source.GetMedia().GetSsrc(), source.GetFec().GetSsrc()})
+		track.trackInfo.AddSourceGroup(sourceGroup)
+	}
+
+	return track
+}
+
+// GetSSRCs get ssrcs map
+func (o *OutgoingStreamTrack) GetSSRCs() map[string]native.RTPOutgoingSource {
+
+	o.l.Lock()
+	defer o.l.Unlock()
+
+	group := outgoingSourceGroup(o.source)
+	if group == nil {
+		return map[string]native.RTPOutgoingSource{}
+	}
+
+	return map[string]native.RTPOutgoingSource{
+		"media": group.GetMedia(),
+		"rtx":   group.GetRtx(),
+		"fec":   group.GetFec(),
+	}
+}
+
+// DeleteOutgoingSourceGroup remove the source group from the transport and release it
+func (o *OutgoingStreamTrack) DeleteOutgoingSourceGroup(transport native.DTLSICETransport) {
+
+	o.l.Lock()
+	source := outgoingSourceGroup(o.source)
+	o.source = nil
+	o.l.Unlock()
+
+	if source != nil {
+		transport.RemoveOutgoingSourceGroup(source)
+		untrackNative(source)
+		native.DeleteRTPOutgoingSourceGroup(source)
+	}
+}
@@ -0,0 +1,63 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"testing"
+
+	"github.com/notedit/sdp"
+)
+
+func Test_OutgoingStreamTrackSwitchErrors(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	iceInfo := sdp.ICEInfoGenerate(true)
+	dtlsInfo := sdp.NewDTLSInfo(sdp.SETUPACTPASS, "sha-256", "F2:AA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F")
+	sdpInfo := sdp.NewSDPInfo()
+	sdpInfo.SetICE(iceInfo)
+	sdpInfo.SetDTLS(dtlsInfo)
+
+	transport := endpoint.CreateTransport(sdpInfo, nil)
+	defer transport.Stop()
+
+	outgoingTrack := transport.CreateOutgoingStreamTrack("video", "videotrack", map[string]uint{})
+
+	if err := outgoingTrack.Switch(nil); err == nil {
+		t.Error("switched to nil track")
+	}
+
+	audioTrack := transport.CreateIncomingStreamTrack("audio", "audiotrack", map[string]uint{})
+	if err := outgoingTrack.Switch(audioTrack); err == nil {
+		t.Error("switched to audio track")
+	}
+
+	// no encoding must not panic
+	if err := outgoingTrack.Switch(&IncomingStreamTrack{media: "video"}); err == nil {
+		t.Error("switched to track without encoding")
+	}
+
+	videoTrack := transport.CreateIncomingStreamTrack("video", "videotrack", map[string]uint{})
+
+	switched := 0
+	outgoingTrack.OnSwitched(func(track *IncomingStreamTrack, err error) {
+		if err == nil && track == videoTrack {
+			switched++
+		}
+	})
+
+	// nothing attached yet, switch right away
+	if err := outgoingTrack.Switch(videoTrack); err != nil || switched != 1 {
+		t.Error("can not switch", err)
+	}
+
+	if outgoingTrack.GetTransponder().GetIncomingTrack() != videoTrack {
+		t.Error("track not attached")
+	}
+
+	if err := outgoingTrack.GetTransponder().SetIncomingTrack(&IncomingStreamTrack{media: "video"}); err == nil {
+		t.Error("set track without encoding")
+	}
+}
@@ -0,0 +1,55 @@
+package mediaserver
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_OutgoingStreamTrackQueuedListeners(t *testing.T) {
+
+	track := &OutgoingStreamTrack{}
+
+	called := 0
+	track.lockOps()
+	track.notify(func() { called++ })
+	if called != 0 {
+		t.Fatal("listener called during the operation")
+	}
+	track.unlockOps()
+	if called != 1 {
+		t.Fatal("queued listener not called")
+	}
+
+	// a listener can use the track, the operation is done
+	track.notify(func() {
+		track.lockOps()
+		called++
+		track.unlockOps()
+	})
+	if called != 2 {
+		t.Fatal("listener not called right away")
+	}
+
+	// listeners raised from other goroutines during the operations
+	var wg sync.WaitGroup
+	var count int32
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			track.lockOps()
+			track.notify(func() { atomic.AddInt32(&count, 1) })
+			track.unlockOps()
+		}()
+		go func() {
+			defer wg.Done()
+			track.notify(func() { atomic.AddInt32(&count, 1) })
+		}()
+	}
+	wg.Wait()
+
+	if atomic.LoadInt32(&count) != 100 {
+		t.Fatal("listeners lost", count)
+	}
+}
@@ -0,0 +1,259 @@
+package mediaserver
+
+import (
+	"errors"
+
+	"github.com/notedit/sdp"
+)
+
+// Participant a peer of a room, its incoming streams are published to the others
+// and the streams of the others are sent to it
+type Participant struct {
+	id            string
+	room          *Room
+	transport     roomTransport
+	published     map[string]*IncomingStream
+	subscriptions map[string]*subscription
+	left          bool
+	// tracks of the published streams, watched until they are stopped
+	watched map[*IncomingStreamTrack]*IncomingStream
+
+	onRenegotiationNeededListeners []func()
+}
+
+// subscription an incoming stream of a publisher forwarded to the transport of a subscriber
+type subscription struct {
+	publisher *Participant
+	incoming  *IncomingStream
+	outgoing  *OutgoingStream
+	// outgoing tracks and the incoming track they forward, by incoming track id
+	tracks    map[string]*OutgoingStreamTrack
+	forwarded map[string]*IncomingStreamTrack
+}
+
+func newParticipant(id string, room *Room, transport roomTransport) *Participant {
+	return &Participant{
+		id:                             id,
+		room:                           room,
+		transport:                      transport,
+		published:                      make(map[string]*IncomingStream),
+		subscriptions:                  make(map[string]*subscription),
+		watched:                        make(map[*IncomingStreamTrack]*IncomingStream),
+		onRenegotiationNeededListeners: make([]func(), 0),
+	}
+}
+
+// subscriptionKey outgoing stream id of a published stream, stream ids are only unique within a transport
+func subscriptionKey(publisher *Participant, stream *IncomingStream) string {
+	return publisher.id + "-" + stream.GetID()
+}
+
+// GetID get participant id
+func (p *Participant) GetID() string {
+	return p.id
+}
+
+// GetRoom get the room
+func (p *Participant) GetRoom() *Room {
+	return p.room
+}
+
+// GetPublishedStreams get the streams published by this participant
+func (p *Participant) GetPublishedStreams() []*IncomingStream {
+	p.room.lock.Lock()
+	defer p.room.lock.Unlock()
+	streams := []*IncomingStream{}
+	for _, stream := range p.published {
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// GetSubscribedStreams get the streams sent to this participant
+func (p *Participant) GetSubscribedStreams() []*OutgoingStream {
+	p.room.lock.Lock()
+	defer p.room.lock.Unlock()
+	streams := []*OutgoingStream{}
+	for _, sub := range p.subscriptions {
+		streams = append(streams, sub.outgoing)
+	}
+	return streams
+}
+
+// Publish publish an incoming stream of the participant transport, only needed when auto publish is disabled
+func (p *Participant) Publish(stream *IncomingStream) error {
+
+	p.room.lock.Lock()
+
+	if p.left {
+		p.room.unlock()
+		return newError(ErrStopped, "participant has left the room")
+	}
+
+	p.room.publish(p, stream)
+
+	p.room.unlock()
+	return nil
+}
+
+// Unpublish stop sending the stream to the other participants
+func (p *Participant) Unpublish(stream *IncomingStream) {
+
+	p.room.lock.Lock()
+
+	p.room.unpublish(p, stream)
+
+	p.room.unlock()
+}
+
+// Subscribe receive a stream published by another participant, regardless of the room policy
+func (p *Participant) Subscribe(publisher *Participant, stream *IncomingStream) (*OutgoingStream, error) {
+
+	p.room.lock.Lock()
+
+	if p.left || publisher.left {
+		p.room.unlock()
+		return nil, newError(ErrStopped, "participant has left the room")
+	}
+
+	if _, ok := publisher.published[stream.GetID()]; !ok {
+		p.room.unlock()
+		return nil, errors.New("stream is not published")
+	}
+
+	outgoing, err := p.subscribe(publisher, stream)
+
+	p.room.unlock()
+	return outgoing, err
+}
+
+// Unsubscribe stop receiving a stream published by another participant
+func (p *Participant) Unsubscribe(publisher *Participant, stream *IncomingStream) {
+
+	p.room.lock.Lock()
+
+	p.unsubscribe(subscriptionKey(publisher, stream))
+
+	p.room.unlock()
+}
+
+// OnRenegotiationNeeded register a listener called when streams are added to or removed from the participant transport
+func (p *Participant) OnRenegotiationNeeded(listener func()) {
+	p.room.lock.Lock()
+	defer p.room.lock.Unlock()
+	p.onRenegotiationNeededListeners = append(p.onRenegotiationNeededListeners, listener)
+}
+
+// Leave leave the room, the published and received streams are removed but the transport is not stopped
+func (p *Participant) Leave() {
+	p.room.leave(p)
+}
+
+// subscribe create the outgoing stream and attach its tracks, the room lock must be held
+func (p *Participant) subscribe(publisher *Participant, stream *IncomingStream) (*OutgoingStream, error) {
+
+	key := subscriptionKey(publisher, stream)
+
+	if sub, ok := p.subscriptions[key]; ok {
+		return sub.outgoing, nil
+	}
+
+	outgoing := p.transport.CreateOutgoingStream(sdp.NewStreamInfo(key))
+	if outgoing == nil {
+		return nil, errors.New("can not create outgoing stream " + key)
+	}
+
+	sub := &subscription{
+		publisher: publisher,
+		incoming:  stream,
+		outgoing:  outgoing,
+		tracks:    make(map[string]*OutgoingStreamTrack),
+		forwarded: make(map[string]*IncomingStreamTrack),
+	}
+
+	for _, track := range stream.GetTracks() {
+		sub.addTrack(track)
+	}
+
+	p.subscriptions[key] = sub
+
+	p.renegotiationNeeded()
+
+	return outgoing, nil
+}
+
+// unsubscribe detach and remove the outgoing stream, the room lock must be held
+func (p *Participant) unsubscribe(key string) {
+
+	sub, ok := p.subscriptions[key]
+	if !ok {
+		return
+	}
+
+	delete(p.subscriptions, key)
+
+	sub.stop(p.transport)
+
+	p.renegotiationNeeded()
+}
+
+func (p *Participant) renegotiationNeeded() {
+
+	for _, listener := range p.onRenegotiationNeededListeners {
+		p.room.events = append(p.room.events, listener)
+	}
+}
+
+// addTrack forward one more track of the incoming stream, false if it was already
+func (s *subscription) addTrack(track *IncomingStreamTrack) bool {
+
+	if _, ok := s.tracks[track.GetID()]; ok {
+		return false
+	}
+
+	info := sdp.NewTrackInfo(track.GetID(), track.GetMedia())
+	info.AddSSRC(NextSSRC())
+
+	outgoing := s.outgoing.CreateTrack(info)
+	if outgoing == nil {
+		return false
+	}
+
+	outgoing.AttachTo(track)
+
+	s.tracks[track.GetID()] = outgoing
+	s.forwarded[track.GetID()] = track
+
+	return true
+}
+
+// removeTrack stop forwarding a track of the incoming stream, false if it was not
+func (s *subscription) removeTrack(track *IncomingStreamTrack) bool {
+
+	// a track with the same id added since
+	if s.forwarded[track.GetID()] != track {
+		return false
+	}
+
+	outgoing := s.tracks[track.GetID()]
+
+	delete(s.tracks, track.GetID())
+	delete(s.forwarded, track.GetID())
+
+	outgoing.Detach()
+	s.outgoing.stopTrack(outgoing)
+
+	return true
+}
+
+func (s *subscription) stop(transport roomTransport) {
+
+	s.outgoing.Detach()
+
+	transport.RemoveOutgoingStream(s.outgoing)
+
+	s.outgoing.Stop()
+
+	s.tracks = nil
+	s.forwarded = nil
+}
@@ -0,0 +1,10 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+// GetTransport get the transport of the participant
+func (p *Participant) GetTransport() *Transport {
+	transport, _ := p.transport.(*Transport)
+	return transport
+}
@@ -0,0 +1,170 @@
+package mediaserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notedit/media-server-go/packetizer"
+	"github.com/notedit/sdp"
+)
+
+const placeholderMTU = 1200
+
+// PlaceholderAsset pre-encoded frames looped by a Placeholder
+type PlaceholderAsset struct {
+	// Codec name as in the sdp, "h264", "vp8" or "opus"
+	Codec string
+	// Frames annex b for h264, raw frames for the others. Video frames must be key frames
+	Frames [][]byte
+	// FrameDuration time between two frames
+	FrameDuration time.Duration
+	// ClockRate rtp clock rate
+	ClockRate uint32
+}
+
+// BlackVideoAsset a 320x240 black h264 picture, once per second
+func BlackVideoAsset() *PlaceholderAsset {
+	return &PlaceholderAsset{
+		Codec: "h264",
+		// consecutive IDR pictures need different ids
+		Frames:        [][]byte{blackH264Frame(20, 15, 0), blackH264Frame(20, 15, 1)},
+		FrameDuration: time.Second,
+		ClockRate:     90000,
+	}
+}
+
+// SilenceAudioAsset a 20ms opus silence frame
+func SilenceAudioAsset() *PlaceholderAsset {
+	return &PlaceholderAsset{
+		Codec:         "opus",
+		Frames:        [][]byte{{0xf8, 0xff, 0xfe}},
+		FrameDuration: 20 * time.Millisecond,
+		ClockRate:     48000,
+	}
+}
+
+// placeholderSession the session a placeholder pushes its packets to, a MediaFrameSession
+type placeholderSession interface {
+	GetIncomingStreamTrack() *IncomingStreamTrack
+	Push(rtp []byte)
+	Stop()
+}
+
+// Placeholder loop an asset through an incoming track, sent by outgoing tracks while muted or not attached.
+// A placeholder can be shared by many outgoing tracks
+type Placeholder struct {
+	asset       *PlaceholderAsset
+	session     placeholderSession
+	packetizer  packetizer.Packetizer
+	payloadType byte
+	ssrc        uint32
+	seq         uint16
+	timestamp   uint32
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// newPlaceholder check the asset and packetize it for the media, the session is set by NewPlaceholder
+func newPlaceholder(media *sdp.MediaInfo, asset *PlaceholderAsset) (*Placeholder, error) {
+
+	if media == nil || asset == nil {
+		return nil, errors.New("media and asset can not be nil")
+	}
+
+	if len(asset.Frames) == 0 || asset.FrameDuration <= 0 || asset.ClockRate == 0 {
+		return nil, errors.New("invalid placeholder asset")
+	}
+
+	codec := media.GetCodec(asset.Codec)
+	if codec == nil {
+		return nil, errors.New("codec " + asset.Codec + " not found in media")
+	}
+
+	placeholder := &Placeholder{
+		asset:       asset,
+		payloadType: byte(codec.GetType()),
+		ssrc:        uint32(NextSSRC()),
+		stop:        make(chan struct{}),
+	}
+
+	switch strings.ToLower(asset.Codec) {
+	case "h264":
+		placeholder.packetizer = &packetizer.H264Packetier{}
+	case "vp8":
+		placeholder.packetizer = &packetizer.VP8Packetier{}
+	case "opus":
+		placeholder.packetizer = &packetizer.OpusPacketier{}
+	default:
+		return nil, errors.New("unsupported placeholder codec " + asset.Codec)
+	}
+
+	return placeholder, nil
+}
+
+// GetIncomingStreamTrack get the track to forward
+func (p *Placeholder) GetIncomingStreamTrack() *IncomingStreamTrack {
+	return p.session.GetIncomingStreamTrack()
+}
+
+func (p *Placeholder) run() {
+
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.asset.FrameDuration)
+	defer ticker.Stop()
+
+	step := uint32(p.asset.FrameDuration * time.Duration(p.asset.ClockRate) / time.Second)
+
+	for i := 0; ; i++ {
+
+		p.push(p.asset.Frames[i%len(p.asset.Frames)])
+		p.timestamp += step
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// push packetize a frame and send it through the media frame session
+func (p *Placeholder) push(frame []byte) {
+
+	payloads := p.packetizer.Packetize(frame, placeholderMTU)
+
+	for i, payload := range payloads {
+
+		packet := make([]byte, 12+len(payload))
+		packet[0] = 0x80
+		packet[1] = p.payloadType
+		if i == len(payloads)-1 {
+			packet[1] |= 0x80
+		}
+		binary.BigEndian.PutUint16(packet[2:], p.seq)
+		binary.BigEndian.PutUint32(packet[4:], p.timestamp)
+		binary.BigEndian.PutUint32(packet[8:], p.ssrc)
+		copy(packet[12:], payload)
+
+		p.seq++
+
+		p.session.Push(packet)
+	}
+}
+
+// Stop stop looping, the outgoing tracks using it must not be muted anymore
+func (p *Placeholder) Stop() {
+
+	if p.session == nil {
+		return
+	}
+
+	close(p.stop)
+	p.wg.Wait()
+
+	p.session.Stop()
+	p.session = nil
+}
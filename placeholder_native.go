@@ -0,0 +1,30 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"errors"
+
+	"github.com/notedit/sdp"
+)
+
+// NewPlaceholder create a placeholder for the media, the asset codec must be in the media codecs
+func NewPlaceholder(media *sdp.MediaInfo, asset *PlaceholderAsset) (*Placeholder, error) {
+
+	placeholder, err := newPlaceholder(media, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	session := NewMediaFrameSession(media)
+	if session == nil {
+		return nil, errors.New("can not create media frame session")
+	}
+	placeholder.session = session
+
+	placeholder.wg.Add(1)
+	go placeholder.run()
+
+	return placeholder, nil
+}
@@ -0,0 +1,171 @@
+package mediaserver
+
+// bitWriter write the exp-golomb coded fields of an h264 rbsp
+type bitWriter struct {
+	data  []byte
+	nbits uint
+}
+
+func (w *bitWriter) writeBit(bit uint) {
+	if w.nbits%8 == 0 {
+		w.data = append(w.data, 0)
+	}
+	if bit != 0 {
+		w.data[len(w.data)-1] |= 0x80 >> (w.nbits % 8)
+	}
+	w.nbits++
+}
+
+func (w *bitWriter) writeBits(value uint, n uint) {
+	for i := n; i > 0; i-- {
+		w.writeBit((value >> (i - 1)) & 1)
+	}
+}
+
+// writeUE unsigned exp-golomb
+func (w *bitWriter) writeUE(value uint) {
+	value++
+	n := uint(0)
+	for v := value; v > 1; v >>= 1 {
+		n++
+	}
+	w.writeBits(0, n)
+	w.writeBits(value, n+1)
+}
+
+// writeSE signed exp-golomb
+func (w *bitWriter) writeSE(value int) {
+	if value > 0 {
+		w.writeUE(uint(2*value - 1))
+	} else {
+		w.writeUE(uint(-2 * value))
+	}
+}
+
+func (w *bitWriter) byteAligned() bool {
+	return w.nbits%8 == 0
+}
+
+// writeTrailingBits rbsp_trailing_bits
+func (w *bitWriter) writeTrailingBits() {
+	w.writeBit(1)
+	for !w.byteAligned() {
+		w.writeBit(0)
+	}
+}
+
+// h264NAL add the nal header and the emulation prevention bytes to the rbsp
+func h264NAL(header byte, rbsp []byte) []byte {
+
+	nal := []byte{0, 0, 0, 1, header}
+	zeros := 0
+
+	for _, b := range rbsp {
+		if zeros == 2 && b <= 3 {
+			nal = append(nal, 3)
+			zeros = 0
+		}
+		nal = append(nal, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+
+	return nal
+}
+
+// blackH264Frame encode an annex b constrained baseline IDR of a black picture.
+// The first macroblock is sent raw as I_PCM, the others are DC predicted from it without residual
+func blackH264Frame(widthInMbs uint, heightInMbs uint, idrPicID uint) []byte {
+
+	// sequence parameter set
+	sps := &bitWriter{}
+	sps.writeBits(66, 8)   // profile_idc baseline
+	sps.writeBits(0xe0, 8) // constraint_set0,1,2
+	sps.writeBits(31, 8)   // level_idc 3.1
+	sps.writeUE(0)         // seq_parameter_set_id
+	sps.writeUE(0)         // log2_max_frame_num_minus4
+	sps.writeUE(2)         // pic_order_cnt_type
+	sps.writeUE(1)         // max_num_ref_frames
+	sps.writeBit(0)        // gaps_in_frame_num_value_allowed_flag
+	sps.writeUE(widthInMbs - 1)
+	sps.writeUE(heightInMbs - 1)
+	sps.writeBit(1) // frame_mbs_only_flag
+	sps.writeBit(1) // direct_8x8_inference_flag
+	sps.writeBit(0) // frame_cropping_flag
+	sps.writeBit(0) // vui_parameters_present_flag
+	sps.writeTrailingBits()
+
+	// picture parameter set
+	pps := &bitWriter{}
+	pps.writeUE(0)      // pic_parameter_set_id
+	pps.writeUE(0)      // seq_parameter_set_id
+	pps.writeBit(0)     // entropy_coding_mode_flag, cavlc
+	pps.writeBit(0)     // bottom_field_pic_order_in_frame_present_flag
+	pps.writeUE(0)      // num_slice_groups_minus1
+	pps.writeUE(0)      // num_ref_idx_l0_default_active_minus1
+	pps.writeUE(0)      // num_ref_idx_l1_default_active_minus1
+	pps.writeBit(0)     // weighted_pred_flag
+	pps.writeBits(0, 2) // weighted_bipred_idc
+	pps.writeSE(0)      // pic_init_qp_minus26
+	pps.writeSE(0)      // pic_init_qs_minus26
+	pps.writeSE(0)      // chroma_qp_index_offset
+	pps.writeBit(1)     // deblocking_filter_control_present_flag
+	pps.writeBit(0)     // constrained_intra_pred_flag
+	pps.writeBit(0)     // redundant_pic_cnt_present_flag
+	pps.writeTrailingBits()
+
+	// one I slice with the whole picture
+	slice := &bitWriter{}
+	slice.writeUE(0)        // first_mb_in_slice
+	slice.writeUE(7)        // slice_type I
+	slice.writeUE(0)        // pic_parameter_set_id
+	slice.writeBits(0, 4)   // frame_num
+	slice.writeUE(idrPicID) // idr_pic_id
+	slice.writeBit(0)       // no_output_of_prior_pics_flag
+	slice.writeBit(0)       // long_term_reference_flag
+	slice.writeSE(0)        // slice_qp_delta
+	slice.writeUE(1)        // disable_deblocking_filter_idc
+
+	for y := uint(0); y < heightInMbs; y++ {
+		for x := uint(0); x < widthInMbs; x++ {
+
+			if x == 0 && y == 0 {
+				slice.writeUE(25) // I_PCM
+				for !slice.byteAligned() {
+					slice.writeBit(0)
+				}
+				// black luma, neutral chroma
+				for i := 0; i < 256; i++ {
+					slice.writeBits(16, 8)
+				}
+				for i := 0; i < 128; i++ {
+					slice.writeBits(128, 8)
+				}
+				continue
+			}
+
+			slice.writeUE(3) // I_16x16_2_0_0, DC prediction without coded coefficients
+			slice.writeUE(0) // intra_chroma_pred_mode DC
+			slice.writeSE(0) // mb_qp_delta
+
+			// Intra16x16DCLevel coeff_token for TotalCoeff 0, the table depends on the neighbours,
+			// which count as 16 coefficients when they are the I_PCM macroblock
+			if (x == 1 && y == 0) || (x == 0 && y == 1) {
+				slice.writeBits(3, 6)
+			} else {
+				slice.writeBit(1)
+			}
+		}
+	}
+
+	slice.writeTrailingBits()
+
+	frame := h264NAL(0x67, sps.data)
+	frame = append(frame, h264NAL(0x68, pps.data)...)
+	frame = append(frame, h264NAL(0x65, slice.data)...)
+
+	return frame
+}
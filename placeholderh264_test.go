@@ -0,0 +1,51 @@
+package mediaserver
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_BitWriter(t *testing.T) {
+
+	w := &bitWriter{}
+	w.writeUE(0)  // 1
+	w.writeUE(3)  // 00100
+	w.writeSE(-1) // 011
+	w.writeSE(1)  // 010
+	w.writeTrailingBits()
+
+	// 1001 0001 1010 1000
+	if !bytes.Equal(w.data, []byte{0x91, 0xa8}) {
+		t.Fatalf("wrong bits %x", w.data)
+	}
+}
+
+func Test_BlackH264Frame(t *testing.T) {
+
+	frame := blackH264Frame(20, 15, 0)
+
+	nals := bytes.Split(frame, []byte{0, 0, 0, 1})
+	if len(nals) != 4 || len(nals[0]) != 0 {
+		t.Fatal("wrong nal count", len(nals))
+	}
+
+	for i, nalType := range []byte{7, 8, 5} {
+		nal := nals[i+1]
+		if nal[0]&0x1f != nalType {
+			t.Fatal("wrong nal type", nal[0]&0x1f)
+		}
+		// no start code emulation
+		if bytes.Contains(nal, []byte{0, 0, 1}) || bytes.Contains(nal, []byte{0, 0, 0}) {
+			t.Fatal("start code emulation in nal", nalType)
+		}
+	}
+
+	if bytes.Equal(frame, blackH264Frame(20, 15, 1)) {
+		t.Fatal("idr_pic_id not written")
+	}
+
+	asset := BlackVideoAsset()
+	if len(asset.Frames) != 2 || asset.ClockRate != 90000 {
+		t.Fatal("wrong black video asset")
+	}
+}
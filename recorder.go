@@ -1,42 +1,112 @@
 package mediaserver
 
 import (
+	"context"
 	"strconv"
+	"sync"
 	"time"
-
-	native "github.com/notedit/media-server-go/wrapper"
 )
 
-// Recorder represent a file recorder
+// Recorder represent a file recorder, safe for concurrent use
 type Recorder struct {
+	filename   string
 	tracks     map[string]*RecorderTrack
-	recorder   native.MP4RecorderFacade
+	recorder   recorderBackend
 	ticker     *time.Ticker
 	refresher  *Refresher
 	maxTrackId int
+	// l guards the fields above
+	l sync.Mutex
+}
+
+type recorderOptions struct {
+	waitForIntra bool
+	refresh      time.Duration
+}
+
+// RecorderOption configure a Recorder, see NewRecorderWithOptions
+type RecorderOption func(*recorderOptions)
+
+// WithWaitForIntra start recording each track on its first key frame
+func WithWaitForIntra(wait bool) RecorderOption {
+	return func(o *recorderOptions) {
+		o.waitForIntra = wait
+	}
+}
+
+// WithRefreshPeriod request a key frame of the recorded tracks every period
+func WithRefreshPeriod(period time.Duration) RecorderOption {
+	return func(o *recorderOptions) {
+		o.refresh = period
+	}
+}
+
+func newRecorderOptions(options []RecorderOption) *recorderOptions {
+	opts := &recorderOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	return opts
 }
 
-// NewRecorder create a new recorder
-func NewRecorder(filename string, waitForIntra bool, refresh int) *Recorder {
+func newRecorder(filename string, refresh time.Duration, backend recorderBackend) *Recorder {
 	recorder := &Recorder{}
-	recorder.recorder = native.NewMP4RecorderFacade()
-	recorder.recorder.Create(filename)
-	recorder.recorder.Record(waitForIntra)
+	recorder.filename = filename
+	recorder.recorder = backend
 	recorder.tracks = map[string]*RecorderTrack{}
 	recorder.maxTrackId = 1
 
 	if refresh > 0 {
-		recorder.refresher = NewRefresher(refresh)
+		recorder.refresher = NewRefresherWithContext(context.Background(), refresh)
 	}
 
 	return recorder
 }
 
-// Record start record an incoming track
-func (r *Recorder) Record(incoming *IncomingStreamTrack) {
+// GetFilename get the file recorded to
+func (r *Recorder) GetFilename() string {
+	return r.filename
+}
+
+// IsRecording false once stopped
+func (r *Recorder) IsRecording() bool {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return r.recorder != nil
+}
 
-	for _, encoding := range incoming.GetEncodings() {
-		encoding.GetDepacketizer().AddMediaListener(r.recorder)
+// GetTracks get the recorded tracks
+func (r *Recorder) GetTracks() []*RecorderTrack {
+	r.l.Lock()
+	defer r.l.Unlock()
+	tracks := []*RecorderTrack{}
+	for _, track := range r.tracks {
+		tracks = append(tracks, track)
+	}
+	return tracks
+}
+
+// Record start record an incoming track, ErrStopped once the recorder is stopped
+func (r *Recorder) Record(incoming *IncomingStreamTrack) error {
+
+	if incoming == nil {
+		return newError(ErrInvalidArgument, "Track can not be nil")
+	}
+
+	encodings := incoming.GetEncodings()
+	if len(encodings) == 0 {
+		return newError(ErrInvalidTrack, "Track has no encoding")
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if r.recorder == nil {
+		return newError(ErrStopped, "Recorder is stopped")
+	}
+
+	for _, encoding := range encodings {
+		r.recorder.addEncoding(encoding)
 
 		r.maxTrackId += 1
 		recorderTrack := NewRecorderTrack(strconv.Itoa(r.maxTrackId), incoming, encoding)
@@ -46,35 +116,50 @@ func (r *Recorder) Record(incoming *IncomingStreamTrack) {
 	if r.refresher != nil {
 		r.refresher.Add(incoming)
 	}
+
+	return nil
 }
 
-// RecordStream start record an incoming stream
-func (r *Recorder) RecordStream(incoming *IncomingStream) {
+// RecordStream start record all the tracks of an incoming stream, with the error of the first track it can not record
+func (r *Recorder) RecordStream(incoming *IncomingStream) error {
 
+	if incoming == nil {
+		return newError(ErrInvalidArgument, "Stream can not be nil")
+	}
+
+	var err error
 	for _, track := range incoming.GetTracks() {
-		r.Record(track)
+		if recordErr := r.Record(track); recordErr != nil && err == nil {
+			err = recordErr
+		}
 	}
+
+	return err
 }
 
 // Stop  stop the recorder
 func (r *Recorder) Stop() {
 
-	if r.recorder == nil {
+	r.l.Lock()
+	recorder := r.recorder
+	refresher := r.refresher
+	tracks := r.tracks
+	r.recorder = nil
+	r.refresher = nil
+	r.tracks = map[string]*RecorderTrack{}
+	r.l.Unlock()
+
+	if recorder == nil {
 		return
 	}
 
-	for _, track := range r.tracks {
+	for _, track := range tracks {
 		track.Stop()
 	}
 
-	if r.refresher != nil {
-		r.refresher.Stop()
+	if refresher != nil {
+		refresher.Stop()
 	}
 
-	r.recorder.Close()
-
-	native.DeleteMP4RecorderFacade(r.recorder)
-
-	r.refresher = nil
-	r.recorder = nil
+	recorder.close()
 }
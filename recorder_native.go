@@ -0,0 +1,42 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"time"
+)
+
+// NewRecorder create a new recorder, refresh in ms. See NewRecorderWithOptions for the errors
+func NewRecorder(filename string, waitForIntra bool, refresh int) *Recorder {
+
+	recorder := newRecorder(filename, time.Duration(refresh)*time.Millisecond, newNativeRecorder())
+	recorder.recorder.create(filename)
+	recorder.recorder.record(waitForIntra)
+	return recorder
+}
+
+// NewRecorderWithOptions create a new recorder, like NewRecorderWithOptions(filename, WithWaitForIntra(true)).
+// ErrNative when the file can not be created or the recording started
+func NewRecorderWithOptions(filename string, options ...RecorderOption) (*Recorder, error) {
+
+	if filename == "" {
+		return nil, newError(ErrInvalidArgument, "Filename can not be empty")
+	}
+
+	opts := newRecorderOptions(options)
+
+	recorder := newRecorder(filename, opts.refresh, newNativeRecorder())
+
+	if !recorder.recorder.create(filename) {
+		recorder.Stop()
+		return nil, newError(ErrNative, "Can not create "+filename)
+	}
+
+	if !recorder.recorder.record(opts.waitForIntra) {
+		recorder.Stop()
+		return nil, newError(ErrNative, "Can not record "+filename)
+	}
+
+	return recorder, nil
+}
@@ -1,5 +1,9 @@
 package mediaserver
 
+import (
+	"sync"
+)
+
 type RecorderTrackStopListener func()
 
 // RecorderTrack  a track to record
@@ -7,6 +11,7 @@ type RecorderTrack struct {
 	id       string
 	track    *IncomingStreamTrack
 	encoding *Encoding
+	l        sync.Mutex
 }
 
 // NewRecorderTrack create a new recorder track
@@ -27,17 +32,24 @@ func (r *RecorderTrack) GetID() string {
 
 // GetTrack get internal IncomingStreamTrack
 func (r *RecorderTrack) GetTrack() *IncomingStreamTrack {
+	r.l.Lock()
+	defer r.l.Unlock()
 	return r.track
 }
 
 // GetEncoding get encoding info
 func (r *RecorderTrack) GetEncoding() *Encoding {
+	r.l.Lock()
+	defer r.l.Unlock()
 	return r.encoding
 }
 
 // Stop stop the recorder track
 func (r *RecorderTrack) Stop() {
 
+	r.l.Lock()
+	defer r.l.Unlock()
+
 	if r.track == nil {
 		return
 	}
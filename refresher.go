@@ -1,59 +1,204 @@
 package mediaserver
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
+// maxRefreshBackoff how many times the period a track waits at most when its key frame requests are not answered
+const maxRefreshBackoff = 8
+
+// Refresher request key frames periodically on video tracks.
+// The intervals adapt to each track: the next request is a period after the last key frame, requested or not,
+// and the interval doubles, up to maxRefreshBackoff periods, while the requests get no key frame.
+// The embedded mutex guards the tracks
 type Refresher struct {
-	period int
-	tracks map[string]*IncomingStreamTrack
-	ticker *time.Ticker
+	period time.Duration
+	tracks map[*IncomingStreamTrack]*refreshedTrack
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 	sync.Mutex
+
+	// refresh request the key frame, replaced in tests
+	refresh func(*IncomingStreamTrack)
+}
+
+type refreshedTrack struct {
+	track     *IncomingStreamTrack
+	period    time.Duration
+	cancel    context.CancelFunc
+	listeners []stopper
+	// keyFrames signals the key frames to the refresh goroutine, they are not queued
+	keyFrames chan struct{}
 }
 
+// NewRefresher create a refresher, period in ms
 func NewRefresher(period int) *Refresher {
-	refresher := &Refresher{}
-	refresher.tracks = map[string]*IncomingStreamTrack{}
-	refresher.period = period
+	return NewRefresherWithContext(context.Background(), time.Duration(period)*time.Millisecond)
+}
+
+// NewRefresherWithContext create a refresher stopped when the context is done
+func NewRefresherWithContext(ctx context.Context, period time.Duration) *Refresher {
+
+	refresher := &Refresher{
+		period:  period,
+		tracks:  make(map[*IncomingStreamTrack]*refreshedTrack),
+		refresh: (*IncomingStreamTrack).Refresh,
+	}
+
+	refresher.ctx, refresher.cancel = context.WithCancel(ctx)
+
+	// release the key frame listeners when the parent context is done
+	go func() {
+		<-refresher.ctx.Done()
+		refresher.Stop()
+	}()
 
 	return refresher
 }
 
+// Add refresh the track with the refresher period, audio tracks are ignored
 func (r *Refresher) Add(incom *IncomingStreamTrack) {
+	r.AddWithPeriod(incom, r.period)
+}
 
-	if incom.GetMedia() == "video" {
-		r.Lock()
-		r.tracks[incom.GetID()] = incom
-		r.Unlock()
+// AddWithPeriod refresh the track with its own period, or change the period of a track already added
+func (r *Refresher) AddWithPeriod(incom *IncomingStreamTrack, period time.Duration) {
+
+	if incom.GetMedia() != "video" || period <= 0 {
+		return
 	}
 
-	if r.ticker == nil {
-		r.ticker = time.NewTicker(time.Duration(r.period) * time.Millisecond)
-		go func() {
-			for _ = range r.ticker.C {
-				for _, track := range r.tracks {
-					track.Refresh()
-				}
-			}
-		}()
+	r.Lock()
+	defer r.Unlock()
+
+	if r.ctx.Err() != nil {
+		return
 	}
+
+	if refreshed, ok := r.tracks[incom]; ok {
+		if refreshed.period == period {
+			return
+		}
+		r.remove(refreshed)
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+
+	refreshed := &refreshedTrack{
+		track:     incom,
+		period:    period,
+		cancel:    cancel,
+		keyFrames: make(chan struct{}, 1),
+	}
+
+	for _, encoding := range incom.GetEncodings() {
+		refreshed.listeners = append(refreshed.listeners, encoding.backend.listen(listenKeyFrames, func(mediaFrame) {
+			refreshed.keyFrame()
+		}))
+	}
+
+	r.tracks[incom] = refreshed
+
+	r.wg.Add(1)
+	go r.run(ctx, refreshed)
 }
 
+// AddStream refresh all the video tracks of the stream
 func (r *Refresher) AddStream(incoming *IncomingStream) {
 
-	for _, track := range incoming.GetTracks() {
-		if track.GetMedia() == "video" {
-			r.Add(track)
+	for _, track := range incoming.GetVideoTracks() {
+		r.Add(track)
+	}
+}
+
+// Remove stop refreshing the track
+func (r *Refresher) Remove(incom *IncomingStreamTrack) {
+
+	r.Lock()
+	defer r.Unlock()
+
+	if refreshed, ok := r.tracks[incom]; ok {
+		r.remove(refreshed)
+	}
+}
+
+// RemoveStream stop refreshing the tracks of the stream
+func (r *Refresher) RemoveStream(incoming *IncomingStream) {
+
+	for _, track := range incoming.GetVideoTracks() {
+		r.Remove(track)
+	}
+}
+
+// remove needs the lock
+func (r *Refresher) remove(refreshed *refreshedTrack) {
+
+	refreshed.cancel()
+
+	for _, listener := range refreshed.listeners {
+		listener.Stop()
+	}
+
+	delete(r.tracks, refreshed.track)
+}
+
+func (r *Refresher) run(ctx context.Context, refreshed *refreshedTrack) {
+
+	defer r.wg.Done()
+
+	interval := refreshed.period
+	// unanswered the last request got no key frame yet
+	unanswered := false
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-refreshed.keyFrames:
+			// as good as a requested one, wait a whole period from it
+			interval = refreshed.period
+			unanswered = false
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(interval)
+		case <-timer.C:
+			r.refresh(refreshed.track)
+			if unanswered && interval < maxRefreshBackoff*refreshed.period {
+				interval *= 2
+			}
+			unanswered = true
+			timer.Reset(interval)
 		}
 	}
 }
 
+// keyFrame signal a key frame, called from the native thread
+func (t *refreshedTrack) keyFrame() {
+	select {
+	case t.keyFrames <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stop refreshing all the tracks, waiting for the pending refreshes
 func (r *Refresher) Stop() {
 
-	if r.ticker != nil {
-		r.ticker.Stop()
-		r.ticker = nil
+	r.Lock()
+
+	r.cancel()
+
+	for _, refreshed := range r.tracks {
+		r.remove(refreshed)
 	}
-	r.tracks = nil
+
+	r.Unlock()
+
+	r.wg.Wait()
 }
@@ -0,0 +1,156 @@
+package mediaserver
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRefresher(ctx context.Context, period time.Duration, refreshes *int32) *Refresher {
+
+	refresher := NewRefresherWithContext(ctx, period)
+	refresher.refresh = func(track *IncomingStreamTrack) {
+		atomic.AddInt32(refreshes, 1)
+	}
+	return refresher
+}
+
+func Test_RefresherAddRemove(t *testing.T) {
+
+	var refreshes int32
+	refresher := newTestRefresher(context.Background(), 10*time.Millisecond, &refreshes)
+
+	video := &IncomingStreamTrack{media: "video"}
+	audio := &IncomingStreamTrack{media: "audio"}
+
+	refresher.Add(video)
+	refresher.Add(audio)
+
+	time.Sleep(55 * time.Millisecond)
+	refresher.Remove(video)
+
+	count := atomic.LoadInt32(&refreshes)
+	if count < 3 {
+		t.Fatal("track not refreshed", count)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt32(&refreshes) != count {
+		t.Fatal("track refreshed after remove")
+	}
+
+	refresher.Stop()
+}
+
+func Test_RefresherSkipRecentKeyFrame(t *testing.T) {
+
+	var refreshes int32
+	refresher := newTestRefresher(context.Background(), time.Second, &refreshes)
+	defer refresher.Stop()
+
+	track := &IncomingStreamTrack{media: "video"}
+	refresher.AddWithPeriod(track, 20*time.Millisecond)
+
+	refresher.Lock()
+	refreshed := refresher.tracks[track]
+	refresher.Unlock()
+
+	// the sender keeps sending key frames by itself
+	for i := 0; i < 10; i++ {
+		refreshed.keyFrame()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&refreshes) != 0 {
+		t.Fatal("track refreshed despite recent key frames")
+	}
+}
+
+func Test_RefresherBackoff(t *testing.T) {
+
+	var refreshes int32
+	refresher := newTestRefresher(context.Background(), time.Second, &refreshes)
+	defer refresher.Stop()
+
+	track := &IncomingStreamTrack{media: "video"}
+	refresher.AddWithPeriod(track, 10*time.Millisecond)
+
+	refresher.Lock()
+	refreshed := refresher.tracks[track]
+	refresher.Unlock()
+
+	// no key frame answers, requested after 10, 20, 40 and 80ms
+	time.Sleep(120 * time.Millisecond)
+
+	count := atomic.LoadInt32(&refreshes)
+	if count < 2 || count > 5 {
+		t.Fatal("requests not backed off", count)
+	}
+
+	// the next request is a period after the key frame, not the backed off interval
+	refreshed.keyFrame()
+	time.Sleep(30 * time.Millisecond)
+
+	if atomic.LoadInt32(&refreshes) == count {
+		t.Fatal("interval not reset by the key frame")
+	}
+}
+
+func Test_RefresherContext(t *testing.T) {
+
+	var refreshes int32
+	ctx, cancel := context.WithCancel(context.Background())
+	refresher := newTestRefresher(ctx, 5*time.Millisecond, &refreshes)
+
+	refresher.Add(&IncomingStreamTrack{media: "video"})
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	count := atomic.LoadInt32(&refreshes)
+	time.Sleep(20 * time.Millisecond)
+
+	if atomic.LoadInt32(&refreshes) != count {
+		t.Fatal("refresher still running after the context is done")
+	}
+
+	// nothing is added once stopped
+	refresher.Add(&IncomingStreamTrack{media: "video"})
+
+	refresher.Lock()
+	defer refresher.Unlock()
+	if len(refresher.tracks) != 0 {
+		t.Fatal("track added to a stopped refresher")
+	}
+}
+
+func Test_RefresherConcurrent(t *testing.T) {
+
+	var refreshes int32
+	refresher := newTestRefresher(context.Background(), time.Millisecond, &refreshes)
+
+	tracks := make([]*IncomingStreamTrack, 20)
+	for i := range tracks {
+		tracks[i] = &IncomingStreamTrack{media: "video"}
+	}
+
+	var wg sync.WaitGroup
+	for _, track := range tracks {
+		wg.Add(1)
+		go func(track *IncomingStreamTrack) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				refresher.AddWithPeriod(track, time.Duration(1+i%3)*time.Millisecond)
+				time.Sleep(time.Millisecond)
+				refresher.Remove(track)
+			}
+			refresher.Add(track)
+		}(track)
+	}
+
+	wg.Wait()
+	refresher.Stop()
+	refresher.Stop()
+}
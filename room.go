@@ -0,0 +1,389 @@
+package mediaserver
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/notedit/sdp"
+)
+
+type (
+	// SubscribePolicy decide if the subscriber receives a stream published by another participant
+	SubscribePolicy func(subscriber *Participant, publisher *Participant, stream *IncomingStream) bool
+	// ParticipantListener listener for participants joining or leaving a room
+	ParticipantListener func(participant *Participant)
+	// RoomTrackListener listener for tracks published in a room
+	RoomTrackListener func(participant *Participant, track *IncomingStreamTrack, stream *IncomingStream)
+)
+
+// roomTransport the transport of a participant, the Transport outside the tests
+type roomTransport interface {
+	GetIncomingStreams() []*IncomingStream
+	OnIncomingTrack(listener IncomingTrackListener)
+	OnStop(listener TransportStopListener)
+	CreateOutgoingStream(streamInfo *sdp.StreamInfo) *OutgoingStream
+	RemoveOutgoingStream(outgoingStream *OutgoingStream)
+}
+
+// Room fan out the streams published by each participant to the transports of the others
+type Room struct {
+	id           string
+	participants map[string]*Participant
+	autoPublish  bool
+	policy       SubscribePolicy
+	stopped      bool
+
+	onJoinListeners  []ParticipantListener
+	onLeaveListeners []ParticipantListener
+	onTrackListeners []RoomTrackListener
+
+	// events raised while locked, fired once unlocked
+	events []func()
+	lock   sync.Mutex
+}
+
+// NewRoom create a room, streams are published and subscribed automatically
+func NewRoom(id string) *Room {
+	room := &Room{
+		id:               id,
+		participants:     make(map[string]*Participant),
+		autoPublish:      true,
+		onJoinListeners:  make([]ParticipantListener, 0),
+		onLeaveListeners: make([]ParticipantListener, 0),
+		onTrackListeners: make([]RoomTrackListener, 0),
+	}
+	return room
+}
+
+// GetID get room id
+func (r *Room) GetID() string {
+	return r.id
+}
+
+// SetAutoPublish publish the incoming streams of the participants as soon as they are created, true by default.
+// When disabled streams are published with Participant.Publish
+func (r *Room) SetAutoPublish(autoPublish bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.autoPublish = autoPublish
+}
+
+// SetSubscribePolicy set the policy deciding who receives what, nil subscribes everybody to everything
+func (r *Room) SetSubscribePolicy(policy SubscribePolicy) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.policy = policy
+}
+
+// OnParticipantJoined register a listener for participants joining
+func (r *Room) OnParticipantJoined(listener ParticipantListener) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.onJoinListeners = append(r.onJoinListeners, listener)
+}
+
+// OnParticipantLeft register a listener for participants leaving
+func (r *Room) OnParticipantLeft(listener ParticipantListener) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.onLeaveListeners = append(r.onLeaveListeners, listener)
+}
+
+// OnTrack register a listener for published tracks
+func (r *Room) OnTrack(listener RoomTrackListener) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.onTrackListeners = append(r.onTrackListeners, listener)
+}
+
+// join add a participant with its transport, the Transport outside the tests
+func (r *Room) join(id string, transport roomTransport) (*Participant, error) {
+
+	r.lock.Lock()
+
+	if r.stopped {
+		r.lock.Unlock()
+		return nil, newError(ErrStopped, "room is stopped")
+	}
+
+	if _, ok := r.participants[id]; ok {
+		r.lock.Unlock()
+		return nil, fmt.Errorf("participant %s already joined", id)
+	}
+
+	participant := newParticipant(id, r, transport)
+
+	r.participants[id] = participant
+
+	for _, listener := range r.onJoinListeners {
+		listener := listener
+		r.events = append(r.events, func() { listener(participant) })
+	}
+
+	// registered before looking at the streams of the transport, a track announced meanwhile would be missed otherwise.
+	// The listeners wait for the room lock, publishing twice is a no-op
+	transport.OnIncomingTrack(func(track *IncomingStreamTrack, stream *IncomingStream) {
+		r.onIncomingTrack(participant, track, stream)
+	})
+
+	transport.OnStop(func() {
+		r.leave(participant)
+	})
+
+	// receive what is already there
+	for _, publisher := range r.participants {
+		if publisher == participant {
+			continue
+		}
+		for _, stream := range publisher.published {
+			r.subscribe(participant, publisher, stream)
+		}
+	}
+
+	if r.autoPublish {
+		for _, stream := range transport.GetIncomingStreams() {
+			r.publish(participant, stream)
+		}
+	}
+
+	r.unlock()
+
+	return participant, nil
+}
+
+// GetParticipant get a participant by id
+func (r *Room) GetParticipant(id string) *Participant {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.participants[id]
+}
+
+// GetParticipants get all the participants
+func (r *Room) GetParticipants() []*Participant {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	participants := []*Participant{}
+	for _, participant := range r.participants {
+		participants = append(participants, participant)
+	}
+	return participants
+}
+
+// Stop make every participant leave, their transports are not stopped
+func (r *Room) Stop() {
+
+	r.lock.Lock()
+
+	if r.stopped {
+		r.lock.Unlock()
+		return
+	}
+
+	r.stopped = true
+
+	for _, participant := range r.participants {
+		r.removeParticipant(participant)
+	}
+
+	r.unlock()
+}
+
+// unlock release the lock and fire the events raised meanwhile
+func (r *Room) unlock() {
+
+	events := r.events
+	r.events = nil
+
+	r.lock.Unlock()
+
+	for _, event := range events {
+		event()
+	}
+}
+
+func (r *Room) leave(participant *Participant) {
+
+	r.lock.Lock()
+
+	if !participant.left {
+		r.removeParticipant(participant)
+	}
+
+	r.unlock()
+}
+
+func (r *Room) onIncomingTrack(participant *Participant, track *IncomingStreamTrack, stream *IncomingStream) {
+
+	r.lock.Lock()
+
+	// bare tracks are not published
+	if participant.left || !r.autoPublish || stream == nil {
+		r.unlock()
+		return
+	}
+
+	if _, ok := participant.published[stream.GetID()]; !ok {
+		r.publish(participant, stream)
+		r.unlock()
+		return
+	}
+
+	// a new track on a published stream, the tracks it had when published are already there
+	if !r.watchTrack(participant, track, stream) {
+		r.unlock()
+		return
+	}
+
+	r.fireTrack(participant, track, stream)
+
+	for _, subscriber := range r.participants {
+		if sub, ok := subscriber.subscriptions[subscriptionKey(participant, stream)]; ok {
+			if sub.addTrack(track) {
+				subscriber.renegotiationNeeded()
+			}
+		}
+	}
+
+	r.unlock()
+}
+
+func (r *Room) publish(participant *Participant, stream *IncomingStream) {
+
+	if _, ok := participant.published[stream.GetID()]; ok {
+		return
+	}
+
+	participant.published[stream.GetID()] = stream
+
+	// registered unlocked, the listener runs right away when the stream is already stopped
+	r.events = append(r.events, func() {
+		stream.OnStop(func() {
+			r.onStreamStopped(participant, stream)
+		})
+	})
+
+	for _, track := range stream.GetTracks() {
+		if r.watchTrack(participant, track, stream) {
+			r.fireTrack(participant, track, stream)
+		}
+	}
+
+	for _, subscriber := range r.participants {
+		if subscriber == participant {
+			continue
+		}
+		r.subscribe(subscriber, participant, stream)
+	}
+}
+
+func (r *Room) unpublish(participant *Participant, stream *IncomingStream) {
+
+	if _, ok := participant.published[stream.GetID()]; !ok {
+		return
+	}
+
+	delete(participant.published, stream.GetID())
+
+	for track, published := range participant.watched {
+		if published == stream {
+			delete(participant.watched, track)
+		}
+	}
+
+	for _, subscriber := range r.participants {
+		subscriber.unsubscribe(subscriptionKey(participant, stream))
+	}
+}
+
+// watchTrack stop forwarding the track of a published stream once it is stopped, like when it is removed by a renegotiation.
+// false if the track is already watched
+func (r *Room) watchTrack(participant *Participant, track *IncomingStreamTrack, stream *IncomingStream) bool {
+
+	if _, ok := participant.watched[track]; ok {
+		return false
+	}
+
+	participant.watched[track] = stream
+
+	r.events = append(r.events, func() {
+		track.OnStop(func() {
+			r.onTrackStopped(participant, track, stream)
+		})
+	})
+
+	return true
+}
+
+func (r *Room) onStreamStopped(participant *Participant, stream *IncomingStream) {
+
+	r.lock.Lock()
+
+	// published again since
+	if participant.published[stream.GetID()] == stream {
+		r.unpublish(participant, stream)
+	}
+
+	r.unlock()
+}
+
+func (r *Room) onTrackStopped(participant *Participant, track *IncomingStreamTrack, stream *IncomingStream) {
+
+	r.lock.Lock()
+
+	if participant.published[stream.GetID()] != stream {
+		r.unlock()
+		return
+	}
+
+	delete(participant.watched, track)
+
+	for _, subscriber := range r.participants {
+		if sub, ok := subscriber.subscriptions[subscriptionKey(participant, stream)]; ok {
+			if sub.removeTrack(track) {
+				subscriber.renegotiationNeeded()
+			}
+		}
+	}
+
+	r.unlock()
+}
+
+// subscribe apply the policy and forward the stream to the subscriber
+func (r *Room) subscribe(subscriber *Participant, publisher *Participant, stream *IncomingStream) {
+
+	if r.policy != nil && !r.policy(subscriber, publisher, stream) {
+		return
+	}
+
+	if _, err := subscriber.subscribe(publisher, stream); err != nil {
+		componentLogger("room").Error("room subscribe error", "err", err)
+	}
+}
+
+func (r *Room) removeParticipant(participant *Participant) {
+
+	for _, stream := range participant.published {
+		r.unpublish(participant, stream)
+	}
+
+	for key := range participant.subscriptions {
+		participant.unsubscribe(key)
+	}
+
+	participant.left = true
+
+	delete(r.participants, participant.id)
+
+	for _, listener := range r.onLeaveListeners {
+		listener := listener
+		r.events = append(r.events, func() { listener(participant) })
+	}
+}
+
+func (r *Room) fireTrack(participant *Participant, track *IncomingStreamTrack, stream *IncomingStream) {
+
+	for _, listener := range r.onTrackListeners {
+		listener := listener
+		r.events = append(r.events, func() { listener(participant, track, stream) })
+	}
+}
@@ -0,0 +1,14 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+// Join add a participant with its transport. The participant leaves when the transport is stopped
+func (r *Room) Join(id string, transport *Transport) (*Participant, error) {
+
+	if transport == nil {
+		return nil, newError(ErrInvalidArgument, "transport can not be nil")
+	}
+
+	return r.join(id, transport)
+}
@@ -0,0 +1,47 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/notedit/sdp"
+)
+
+func Test_RoomJoinTransport(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	room := NewRoom("room")
+
+	if _, err := room.Join("nobody", nil); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatal("joined without transport", err)
+	}
+
+	offer, err := sdp.Parse(sdpStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := endpoint.CreateTransport(offer, nil)
+
+	alice, err := room.Join("alice", transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if alice.GetTransport() != transport {
+		t.Fatal("wrong transport")
+	}
+
+	transport.Stop()
+
+	if room.GetParticipant("alice") != nil {
+		t.Fatal("alice did not leave")
+	}
+
+	room.Stop()
+}
@@ -0,0 +1,346 @@
+package mediaserver
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/notedit/sdp"
+)
+
+// fakeRoomTransport in-memory roomTransport, the tests create and announce its incoming streams
+type fakeRoomTransport struct {
+	backend        *fakeTransport
+	incoming       []*IncomingStream
+	outgoing       []*OutgoingStream
+	trackListeners []IncomingTrackListener
+	stopListeners  []TransportStopListener
+	l              sync.Mutex
+}
+
+func newFakeRoomTransport() *fakeRoomTransport {
+	return &fakeRoomTransport{backend: &fakeTransport{}}
+}
+
+func (f *fakeRoomTransport) GetIncomingStreams() []*IncomingStream {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return append([]*IncomingStream{}, f.incoming...)
+}
+
+func (f *fakeRoomTransport) OnIncomingTrack(listener IncomingTrackListener) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.trackListeners = append(f.trackListeners, listener)
+}
+
+func (f *fakeRoomTransport) OnStop(listener TransportStopListener) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.stopListeners = append(f.stopListeners, listener)
+}
+
+func (f *fakeRoomTransport) CreateOutgoingStream(streamInfo *sdp.StreamInfo) *OutgoingStream {
+
+	stream, err := newOutgoingStream(f.backend, streamInfo, false)
+	if err != nil {
+		return nil
+	}
+
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.outgoing = append(f.outgoing, stream)
+	return stream
+}
+
+func (f *fakeRoomTransport) RemoveOutgoingStream(outgoingStream *OutgoingStream) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	for i, stream := range f.outgoing {
+		if stream == outgoingStream {
+			f.outgoing = append(f.outgoing[:i], f.outgoing[i+1:]...)
+			return
+		}
+	}
+}
+
+func (f *fakeRoomTransport) getOutgoingStreams() []*OutgoingStream {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return append([]*OutgoingStream{}, f.outgoing...)
+}
+
+// createIncomingStream a stream created by the application, it is not announced
+func (f *fakeRoomTransport) createIncomingStream(t *testing.T, info *sdp.StreamInfo) *IncomingStream {
+
+	stream, err := newIncomingStream(f.backend, &fakeReceiver{}, info, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.l.Lock()
+	f.incoming = append(f.incoming, stream)
+	f.l.Unlock()
+
+	return stream
+}
+
+func (f *fakeRoomTransport) removeIncomingStream(incomingStream *IncomingStream) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	for i, stream := range f.incoming {
+		if stream == incomingStream {
+			f.incoming = append(f.incoming[:i], f.incoming[i+1:]...)
+			return
+		}
+	}
+}
+
+// announce a track of the stream received from the remote side
+func (f *fakeRoomTransport) announce(track *IncomingStreamTrack, stream *IncomingStream) {
+
+	f.l.Lock()
+	listeners := append([]IncomingTrackListener{}, f.trackListeners...)
+	f.l.Unlock()
+
+	for _, listener := range listeners {
+		listener(track, stream)
+	}
+}
+
+// receiveIncomingStream a stream announced by the remote side, track by track
+func (f *fakeRoomTransport) receiveIncomingStream(t *testing.T, info *sdp.StreamInfo) *IncomingStream {
+
+	stream := f.createIncomingStream(t, info)
+	for _, track := range stream.GetTracks() {
+		f.announce(track, stream)
+	}
+	return stream
+}
+
+func (f *fakeRoomTransport) stop() {
+
+	f.l.Lock()
+	listeners := append([]TransportStopListener{}, f.stopListeners...)
+	f.l.Unlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+func newRoomStreamInfo(t *testing.T) *sdp.StreamInfo {
+
+	offer, err := sdp.Parse(sdpStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return offer.GetFirstStream()
+}
+
+func Test_RoomFanOut(t *testing.T) {
+
+	room := NewRoom("room")
+
+	joined := 0
+	left := 0
+	tracks := 0
+
+	room.OnParticipantJoined(func(participant *Participant) { joined++ })
+	room.OnParticipantLeft(func(participant *Participant) { left++ })
+	room.OnTrack(func(participant *Participant, track *IncomingStreamTrack, stream *IncomingStream) { tracks++ })
+
+	aliceTransport := newFakeRoomTransport()
+	alice, err := room.join("alice", aliceTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bobTransport := newFakeRoomTransport()
+	bob, err := room.join("bob", bobTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := room.join("bob", bobTransport); err == nil {
+		t.Fatal("duplicated participant")
+	}
+
+	renegotiations := 0
+	bob.OnRenegotiationNeeded(func() { renegotiations++ })
+
+	stream := aliceTransport.receiveIncomingStream(t, newRoomStreamInfo(t))
+
+	if len(alice.GetPublishedStreams()) != 1 || tracks != len(stream.GetTracks()) {
+		t.Fatal("stream not published")
+	}
+
+	subscribed := bob.GetSubscribedStreams()
+	if len(subscribed) != 1 || len(subscribed[0].GetTracks()) != len(stream.GetTracks()) || len(bobTransport.getOutgoingStreams()) != 1 {
+		t.Fatal("stream not forwarded to bob")
+	}
+
+	if renegotiations != 1 {
+		t.Fatal("renegotiation not requested")
+	}
+
+	for _, track := range subscribed[0].GetTracks() {
+		transponder := track.GetTransponder()
+		if transponder == nil || stream.GetTrack(transponder.GetIncomingTrack().GetID()) != transponder.GetIncomingTrack() {
+			t.Fatal("track not attached")
+		}
+	}
+
+	// alice receives nothing from herself
+	if len(alice.GetSubscribedStreams()) != 0 {
+		t.Fatal("alice subscribed to her own stream")
+	}
+
+	// a track added to the published stream by a renegotiation
+	info := sdp.NewTrackInfo("screen", "video")
+	info.AddSSRC(NextSSRC())
+	screen, err := stream.NewTrack(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceTransport.announce(screen, stream)
+
+	if subscribed[0].GetTrack("screen") == nil || tracks != 3 || renegotiations != 2 {
+		t.Fatal("new track not forwarded to bob", tracks, renegotiations)
+	}
+
+	aliceTransport.stop()
+
+	if room.GetParticipant("alice") != nil || left != 1 {
+		t.Fatal("alice did not leave")
+	}
+
+	if len(bob.GetSubscribedStreams()) != 0 || len(bobTransport.getOutgoingStreams()) != 0 || len(subscribed[0].GetTracks()) != 0 {
+		t.Fatal("bob still receives alice stream")
+	}
+
+	if joined != 2 || renegotiations != 3 {
+		t.Fatal("wrong events", joined, renegotiations)
+	}
+
+	room.Stop()
+
+	if _, err := room.join("carol", newFakeRoomTransport()); !errors.Is(err, ErrStopped) {
+		t.Fatal("joined a stopped room", err)
+	}
+}
+
+func Test_RoomJoinPublished(t *testing.T) {
+
+	room := NewRoom("room")
+
+	// the streams created before joining are published on join
+	aliceTransport := newFakeRoomTransport()
+	aliceTransport.createIncomingStream(t, newRoomStreamInfo(t))
+	alice, _ := room.join("alice", aliceTransport)
+
+	if len(alice.GetPublishedStreams()) != 1 {
+		t.Fatal("stream not published on join")
+	}
+
+	// and sent to the participants joining later
+	bob, _ := room.join("bob", newFakeRoomTransport())
+
+	if len(bob.GetSubscribedStreams()) != 1 {
+		t.Fatal("published stream not sent to bob")
+	}
+
+	room.Stop()
+
+	if len(room.GetParticipants()) != 0 || len(bob.GetSubscribedStreams()) != 0 {
+		t.Fatal("participants still in the stopped room")
+	}
+}
+
+func Test_RoomSubscribePolicy(t *testing.T) {
+
+	room := NewRoom("room")
+
+	// only the host receives streams
+	room.SetSubscribePolicy(func(subscriber *Participant, publisher *Participant, stream *IncomingStream) bool {
+		return subscriber.GetID() == "host"
+	})
+
+	hostTransport := newFakeRoomTransport()
+	host, _ := room.join("host", hostTransport)
+
+	guestTransport := newFakeRoomTransport()
+	guest, _ := room.join("guest", guestTransport)
+
+	hostStream := hostTransport.receiveIncomingStream(t, newRoomStreamInfo(t))
+	guestTransport.receiveIncomingStream(t, newRoomStreamInfo(t))
+
+	if len(host.GetSubscribedStreams()) != 1 || len(guest.GetSubscribedStreams()) != 0 {
+		t.Fatal("policy not applied")
+	}
+
+	// explicit subscriptions bypass the policy
+	if _, err := guest.Subscribe(host, hostStream); err != nil || len(guest.GetSubscribedStreams()) != 1 {
+		t.Fatal("can not subscribe", err)
+	}
+
+	guest.Leave()
+
+	if len(host.GetSubscribedStreams()) != 0 || len(hostTransport.getOutgoingStreams()) != 0 {
+		t.Fatal("guest stream still forwarded")
+	}
+
+	if _, err := guest.Subscribe(host, hostStream); !errors.Is(err, ErrStopped) {
+		t.Fatal("subscribed after leaving", err)
+	}
+
+	room.Stop()
+}
+
+func Test_RoomStoppedStreamsAndTracks(t *testing.T) {
+
+	room := NewRoom("room")
+	room.SetAutoPublish(false)
+
+	aliceTransport := newFakeRoomTransport()
+	alice, _ := room.join("alice", aliceTransport)
+
+	bobTransport := newFakeRoomTransport()
+	bob, _ := room.join("bob", bobTransport)
+
+	renegotiations := 0
+	bob.OnRenegotiationNeeded(func() { renegotiations++ })
+
+	stream := aliceTransport.receiveIncomingStream(t, newRoomStreamInfo(t))
+	if len(alice.GetPublishedStreams()) != 0 {
+		t.Fatal("stream published without auto publish")
+	}
+
+	if err := alice.Publish(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	subscribed := bob.GetSubscribedStreams()
+	if len(subscribed) != 1 || len(subscribed[0].GetTracks()) != 2 {
+		t.Fatal("stream not forwarded to bob")
+	}
+
+	// a track gone away, like after a renegotiation
+	video := stream.GetVideoTracks()[0]
+	stream.RemoveTrack(video)
+	video.Stop()
+
+	if len(subscribed[0].GetTracks()) != 1 || len(subscribed[0].GetVideoTracks()) != 0 || renegotiations != 2 {
+		t.Fatal("stopped track still forwarded", renegotiations)
+	}
+
+	aliceTransport.removeIncomingStream(stream)
+	stream.Stop()
+
+	if len(alice.GetPublishedStreams()) != 0 || len(bob.GetSubscribedStreams()) != 0 || len(bobTransport.getOutgoingStreams()) != 0 {
+		t.Fatal("stopped stream still published")
+	}
+
+	room.Stop()
+}
@@ -0,0 +1,201 @@
+package mediaserver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/notedit/sdp"
+)
+
+// Header extension uris understood by the native rtp stack
+var supportedHeaderExtensions = map[string]bool{
+	"urn:ietf:params:rtp-hdrext:ssrc-audio-level":                               true,
+	"urn:ietf:params:rtp-hdrext:toffset":                                        true,
+	"http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time":                true,
+	"urn:3gpp:video-orientation":                                                true,
+	"http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01": true,
+	"http://www.webrtc.org/experiments/rtp-hdrext/playout-delay":                true,
+	"urn:ietf:params:rtp-hdrext:framemarking":                                   true,
+	"http://tools.ietf.org/html/draft-ietf-avtext-framemarking-07":              true,
+	"urn:ietf:params:rtp-hdrext:sdes:mid":                                       true,
+	"urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id":                             true,
+	"urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id":                    true,
+}
+
+// RTCPFeedbackParameters a rtcp-fb entry of a codec
+type RTCPFeedbackParameters struct {
+	ID     string
+	Params []string
+}
+
+// RTPCodecParameters a negotiated codec, RTX is 0 when there is no retransmission payload.
+// FEC is set for the forward error correction codecs, red, ulpfec and flexfec.
+// The native side only gets the codec, payload types and RTX, Params (the fmtp) and RTCPFeedbacks are checked but stay in the sdp
+type RTPCodecParameters struct {
+	Codec         string
+	PayloadType   int
+	RTX           int
+	FEC           bool
+	Params        map[string]string
+	RTCPFeedbacks []*RTCPFeedbackParameters
+}
+
+// RTPHeaderExtensionParameters a negotiated header extension
+type RTPHeaderExtensionParameters struct {
+	ID  int
+	URI string
+}
+
+// RTPParameters the rtp properties of one media, ordered by payload type and extension id
+type RTPParameters struct {
+	Codecs           []*RTPCodecParameters
+	HeaderExtensions []*RTPHeaderExtensionParameters
+}
+
+// isFECCodec check if the codec is a forward error correction one
+func isFECCodec(codec string) bool {
+	switch strings.ToLower(codec) {
+	case "red", "ulpfec", "flexfec-03":
+		return true
+	}
+	return false
+}
+
+// NewRTPParameters create the rtp parameters from a media info.
+// All the header extensions are kept, Validate rejects the ones the native side does not understand,
+// see DropUnsupportedHeaderExtensions
+func NewRTPParameters(media *sdp.MediaInfo) *RTPParameters {
+
+	if media == nil {
+		return nil
+	}
+
+	params := &RTPParameters{
+		Codecs:           []*RTPCodecParameters{},
+		HeaderExtensions: []*RTPHeaderExtensionParameters{},
+	}
+
+	for _, codec := range media.GetCodecs() {
+
+		parameters := &RTPCodecParameters{
+			Codec:         codec.GetCodec(),
+			PayloadType:   codec.GetType(),
+			FEC:           isFECCodec(codec.GetCodec()),
+			Params:        map[string]string{},
+			RTCPFeedbacks: []*RTCPFeedbackParameters{},
+		}
+
+		if codec.HasRTX() {
+			parameters.RTX = codec.GetRTX()
+		}
+
+		for k, v := range codec.GetParams() {
+			parameters.Params[k] = v
+		}
+
+		for _, rtcpfb := range codec.GetRTCPFeedbacks() {
+			parameters.RTCPFeedbacks = append(parameters.RTCPFeedbacks, &RTCPFeedbackParameters{
+				ID:     rtcpfb.GetID(),
+				Params: append([]string{}, rtcpfb.GetParams()...),
+			})
+		}
+
+		params.Codecs = append(params.Codecs, parameters)
+	}
+
+	for id, uri := range media.GetExtensions() {
+		params.HeaderExtensions = append(params.HeaderExtensions, &RTPHeaderExtensionParameters{
+			ID:  id,
+			URI: uri,
+		})
+	}
+
+	sort.Slice(params.Codecs, func(i, j int) bool { return params.Codecs[i].PayloadType < params.Codecs[j].PayloadType })
+	sort.Slice(params.HeaderExtensions, func(i, j int) bool { return params.HeaderExtensions[i].ID < params.HeaderExtensions[j].ID })
+
+	return params
+}
+
+// DropUnsupportedHeaderExtensions remove the header extensions the native side does not understand and return their uris.
+// A remote offer usually has some, they are not sent once the answer leaves them out
+func (p *RTPParameters) DropUnsupportedHeaderExtensions() []string {
+
+	if p == nil {
+		return nil
+	}
+
+	dropped := []string{}
+	extensions := []*RTPHeaderExtensionParameters{}
+
+	for _, extension := range p.HeaderExtensions {
+		if extension != nil && !supportedHeaderExtensions[extension.URI] {
+			dropped = append(dropped, extension.URI)
+			continue
+		}
+		extensions = append(extensions, extension)
+	}
+
+	p.HeaderExtensions = extensions
+	return dropped
+}
+
+// Validate check the parameters before they are handed to the native side, ErrInvalidSDP when they are wrong
+func (p *RTPParameters) Validate() error {
+
+	if p == nil {
+		return nil
+	}
+
+	pts := map[int]string{}
+
+	usePT := func(pt int, name string) error {
+		if pt < 0 || pt > 127 {
+			return newError(ErrInvalidSDP, fmt.Sprintf("invalid payload type %d for %s", pt, name))
+		}
+		if used, ok := pts[pt]; ok {
+			return newError(ErrInvalidSDP, fmt.Sprintf("duplicate payload type %d for %s and %s", pt, used, name))
+		}
+		pts[pt] = name
+		return nil
+	}
+
+	for _, codec := range p.Codecs {
+		if codec == nil || codec.Codec == "" {
+			return newError(ErrInvalidSDP, "codec name can not be empty")
+		}
+		if err := usePT(codec.PayloadType, codec.Codec); err != nil {
+			return err
+		}
+		if codec.RTX != 0 {
+			if err := usePT(codec.RTX, codec.Codec+" rtx"); err != nil {
+				return err
+			}
+		}
+		for _, rtcpfb := range codec.RTCPFeedbacks {
+			if rtcpfb == nil || rtcpfb.ID == "" {
+				return newError(ErrInvalidSDP, "empty rtcp-fb for "+codec.Codec)
+			}
+		}
+	}
+
+	ids := map[int]string{}
+
+	for _, extension := range p.HeaderExtensions {
+		if extension == nil {
+			return newError(ErrInvalidSDP, "header extension can not be nil")
+		}
+		if extension.ID < 1 || extension.ID > 255 {
+			return newError(ErrInvalidSDP, fmt.Sprintf("invalid header extension id %d for %s", extension.ID, extension.URI))
+		}
+		if used, ok := ids[extension.ID]; ok {
+			return newError(ErrInvalidSDP, fmt.Sprintf("duplicate header extension id %d for %s and %s", extension.ID, used, extension.URI))
+		}
+		if !supportedHeaderExtensions[extension.URI] {
+			return newError(ErrInvalidSDP, "unknown header extension uri "+extension.URI)
+		}
+		ids[extension.ID] = extension.URI
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"fmt"
+
+	native "github.com/notedit/media-server-go/wrapper"
+)
+
+// setProperties encode the parameters into the native properties under prefix ("audio.", "video." or ""),
+// the native side has no fmtp or rtcp-fb properties
+func (p *RTPParameters) setProperties(properties native.PropertiesFacade, prefix string) {
+
+	for num, codec := range p.Codecs {
+		item := fmt.Sprintf("%scodecs.%d", prefix, num)
+		properties.SetPropertyStr(item+".codec", codec.Codec)
+		properties.SetPropertyInt(item+".pt", codec.PayloadType)
+		if codec.RTX != 0 {
+			properties.SetPropertyInt(item+".rtx", codec.RTX)
+		}
+	}
+	properties.SetPropertyInt(prefix+"codecs.length", len(p.Codecs))
+
+	for num, extension := range p.HeaderExtensions {
+		item := fmt.Sprintf("%sext.%d", prefix, num)
+		properties.SetPropertyInt(item+".id", extension.ID)
+		properties.SetPropertyStr(item+".uri", extension.URI)
+	}
+	properties.SetPropertyInt(prefix+"ext.length", len(p.HeaderExtensions))
+}
+
+// newRTPProperties validate the audio and video parameters and encode them, the caller must delete the properties
+func newRTPProperties(audio *RTPParameters, video *RTPParameters) (native.PropertiesFacade, error) {
+
+	if err := audio.Validate(); err != nil {
+		return nil, wrapError(ErrInvalidSDP, "audio", err)
+	}
+
+	if err := video.Validate(); err != nil {
+		return nil, wrapError(ErrInvalidSDP, "video", err)
+	}
+
+	properties := native.NewPropertiesFacade()
+
+	if audio != nil {
+		audio.setProperties(properties, "audio.")
+	}
+
+	if video != nil {
+		video.setProperties(properties, "video.")
+	}
+
+	return properties, nil
+}
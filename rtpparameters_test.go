@@ -0,0 +1,234 @@
+package mediaserver
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/notedit/sdp"
+)
+
+const sdpStr = "v=1\r\n" +
+	"o=- 4327261771880257373 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=1 1\r\n" +
+	"a=group:BUNDLE audio video\r\n" +
+	"a=msid-semantic: WMS xIKmAwWv4ft4ULxNJGhkHzvPaCkc8EKo4SGj\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111 103 104 9 0 8 106 105 13 110 112 113 126\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=rtcp:9 IN IP4 0.0.0.0\r\n" +
+	"a=ice-ufrag:ez5G\r\n" +
+	"a=ice-pwd:1F1qS++jzWLSQi0qQDZkX/QV\r\n" +
+	"a=candidate:1 1 UDP 33554431 35.188.215.104 59110 typ host\r\n" +
+	"a=fingerprint:sha-256 D2:FA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F\r\n" +
+	"a=setup:actpass\r\n" +
+	"a=connection:new\r\n" +
+	"a=mid:audio\r\n" +
+	"a=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level\r\n" +
+	"a=sendrecv\r\n" +
+	"a=rtcp-mux\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"a=rtcp-fb:111 transport-cc\r\n" +
+	"a=fmtp:111 minptime=10;useinbandfec=1\r\n" +
+	"a=rtpmap:103 ISAC/16000\r\n" +
+	"a=rtpmap:104 ISAC/32000\r\n" +
+	"a=rtpmap:9 G722/8000\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n" +
+	"a=rtpmap:8 PCMA/8000\r\n" +
+	"a=rtpmap:106 CN/32000\r\n" +
+	"a=rtpmap:105 CN/16000\r\n" +
+	"a=rtpmap:13 CN/8000\r\n" +
+	"a=rtpmap:110 telephone-event/48000\r\n" +
+	"a=rtpmap:112 telephone-event/32000\r\n" +
+	"a=rtpmap:113 telephone-event/16000\r\n" +
+	"a=rtpmap:126 telephone-event/8000\r\n" +
+	"a=ssrc:3510681183 cname:loqPWNg7JMmrFUnr\r\n" +
+	"a=ssrc:3510681183 msid:xIKmAwWv4ft4ULxNJGhkHzvPaCkc8EKo4SGj 7ea47500-22eb-4815-a899-c74ef321b6ee\r\n" +
+	"a=ssrc:3510681183 mslabel:xIKmAwWv4ft4ULxNJGhkHzvPaCkc8EKo4SGj\r\n" +
+	"a=ssrc:3510681183 label:7ea47500-22eb-4815-a899-c74ef321b6ee\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96 98 100 102 127 125 97 99 101 124\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=connection:new\r\n" +
+	"a=rtcp:9 IN IP4 0.0.0.0\r\n" +
+	"a=ice-ufrag:ez5G\r\n" +
+	"a=ice-pwd:1F1qS++jzWLSQi0qQDZkX/QV\r\n" +
+	"a=candidate:1 1 UDP 33554431 35.188.215.104 59110 typ host\r\n" +
+	"a=fingerprint:sha-256 D2:FA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F\r\n" +
+	"a=setup:actpass\r\n" +
+	"a=mid:video\r\n" +
+	"a=extmap:2 urn:ietf:params:rtp-hdrext:toffset\r\n" +
+	"a=extmap:3 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time\r\n" +
+	"a=extmap:4 urn:3gpp:video-orientation\r\n" +
+	"a=extmap:5 http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01\r\n" +
+	"a=extmap:6 http://www.webrtc.org/experiments/rtp-hdrext/playout-delay\r\n" +
+	"a=sendrecv\r\n" +
+	"a=rtcp-mux\r\n" +
+	"a=rtcp-rsize\r\n" +
+	"a=rtpmap:96 VP8/90000\r\n" +
+	"a=rtcp-fb:96 ccm fir\r\n" +
+	"a=rtcp-fb:96 nack\r\n" +
+	"a=rtcp-fb:96 nack pli\r\n" +
+	"a=rtcp-fb:96 goog-remb\r\n" +
+	"a=rtcp-fb:96 transport-cc\r\n" +
+	"a=rtpmap:98 VP9/90000\r\n" +
+	"a=rtcp-fb:98 ccm fir\r\n" +
+	"a=rtcp-fb:98 nack\r\n" +
+	"a=rtcp-fb:98 nack pli\r\n" +
+	"a=rtcp-fb:98 goog-remb\r\n" +
+	"a=rtcp-fb:98 transport-cc\r\n" +
+	"a=rtpmap:100 H264/90000\r\n" +
+	"a=rtcp-fb:100 ccm fir\r\n" +
+	"a=rtcp-fb:100 nack\r\n" +
+	"a=rtcp-fb:100 nack pli\r\n" +
+	"a=rtcp-fb:100 goog-remb\r\n" +
+	"a=rtcp-fb:100 transport-cc\r\n" +
+	"a=fmtp:100 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f\r\n" +
+	"a=rtpmap:102 red/90000\r\n" +
+	"a=rtpmap:127 ulpfec/90000\r\n" +
+	"a=rtpmap:125 flexfec-03/90000\r\n" +
+	"a=rtcp-fb:125 ccm fir\r\n" +
+	"a=rtcp-fb:125 nack\r\n" +
+	"a=rtcp-fb:125 nack pli\r\n" +
+	"a=rtcp-fb:125 goog-remb\r\n" +
+	"a=rtcp-fb:125 transport-cc\r\n" +
+	"a=fmtp:125 repair-window=10000000\r\n" +
+	"a=rtpmap:97 rtx/90000\r\n" +
+	"a=fmtp:97 apt=96\r\n" +
+	"a=rtpmap:99 rtx/90000\r\n" +
+	"a=fmtp:99 apt=98\r\n" +
+	"a=rtpmap:101 rtx/90000\r\n" +
+	"a=fmtp:101 apt=100\r\n" +
+	"a=rtpmap:124 rtx/90000\r\n" +
+	"a=fmtp:124 apt=102\r\n" +
+	"a=ssrc-group:FID 3004364195 1126032854\r\n" +
+	"a=ssrc-group:FEC-FR 3004364195 1080772241\r\n" +
+	"a=ssrc:3004364195 cname:loqPWNg7JMmrFUnr\r\n" +
+	"a=ssrc:3004364195 msid:xIKmAwWv4ft4ULxNJGhkHzvPaCkc8EKo4SGj cf093ab0-0b28-4930-8fe1-7ca8d529be25\r\n" +
+	"a=ssrc:3004364195 mslabel:xIKmAwWv4ft4ULxNJGhkHzvPaCkc8EKo4SGj\r\n" +
+	"a=ssrc:3004364195 label:cf093ab0-0b28-4930-8fe1-7ca8d529be25\r\n" +
+	"a=ssrc:1126032854 cname:loqPWNg7JMmrFUnr\r\n" +
+	"a=ssrc:1126032854 msid:xIKmAwWv4ft4ULxNJGhkHzvPaCkc8EKo4SGj cf093ab0-0b28-4930-8fe1-7ca8d529be25\r\n" +
+	"a=ssrc:1126032854 mslabel:xIKmAwWv4ft4ULxNJGhkHzvPaCkc8EKo4SGj\r\n" +
+	"a=ssrc:1126032854 label:cf093ab0-0b28-4930-8fe1-7ca8d529be25\r\n" +
+	"a=ssrc:1080772241 cname:loqPWNg7JMmrFUnr\r\n" +
+	"a=ssrc:1080772241 msid:xIKmAwWv4ft4ULxNJGhkHzvPaCkc8EKo4SGj cf093ab0-0b28-4930-8fe1-7ca8d529be25\r\n" +
+	"a=ssrc:1080772241 mslabel:xIKmAwWv4ft4ULxNJGhkHzvPaCkc8EKo4SGj\r\n" +
+	"a=ssrc:1080772241 label:cf093ab0-0b28-4930-8fe1-7ca8d529be25\r\n"
+
+func Test_RTPParametersFromMediaInfo(t *testing.T) {
+
+	offer, err := sdp.Parse(sdpStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := NewRTPParameters(offer.GetMedia("video"))
+
+	if err := params.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	for i := 1; i < len(params.Codecs); i++ {
+		if params.Codecs[i-1].PayloadType >= params.Codecs[i].PayloadType {
+			t.Error("codecs should be ordered by payload type")
+		}
+	}
+
+	vp8 := params.Codecs[0]
+	if vp8.PayloadType != 96 || vp8.RTX != 97 {
+		t.Error("vp8 codec parameters mismatch")
+	}
+
+	if len(vp8.RTCPFeedbacks) != 5 || vp8.FEC {
+		t.Error("vp8 rtcp-fb mismatch", len(vp8.RTCPFeedbacks))
+	}
+
+	for _, codec := range params.Codecs {
+		switch codec.PayloadType {
+		case 100:
+			if codec.Params["packetization-mode"] != "1" {
+				t.Error("h264 fmtp mismatch", codec.Params)
+			}
+		case 102, 127, 125:
+			if !codec.FEC {
+				t.Error(codec.Codec, "should be a fec codec")
+			}
+		}
+	}
+}
+
+func Test_RTPParametersValidate(t *testing.T) {
+
+	cases := map[string]*RTPParameters{
+		"duplicate pt": {
+			Codecs: []*RTPCodecParameters{
+				{Codec: "vp8", PayloadType: 96},
+				{Codec: "vp9", PayloadType: 96},
+			},
+		},
+		"rtx clashes with codec": {
+			Codecs: []*RTPCodecParameters{
+				{Codec: "vp8", PayloadType: 96, RTX: 98},
+				{Codec: "vp9", PayloadType: 98},
+			},
+		},
+		"invalid pt": {
+			Codecs: []*RTPCodecParameters{
+				{Codec: "opus", PayloadType: 128},
+			},
+		},
+		"unknown extension": {
+			HeaderExtensions: []*RTPHeaderExtensionParameters{
+				{ID: 1, URI: "urn:example:unknown"},
+			},
+		},
+		"empty rtcp-fb": {
+			Codecs: []*RTPCodecParameters{
+				{Codec: "vp8", PayloadType: 96, RTCPFeedbacks: []*RTCPFeedbackParameters{{ID: ""}}},
+			},
+		},
+		"duplicate extension id": {
+			HeaderExtensions: []*RTPHeaderExtensionParameters{
+				{ID: 1, URI: "urn:ietf:params:rtp-hdrext:toffset"},
+				{ID: 1, URI: "urn:ietf:params:rtp-hdrext:ssrc-audio-level"},
+			},
+		},
+	}
+
+	for name, params := range cases {
+		if err := params.Validate(); !errors.Is(err, ErrInvalidSDP) {
+			t.Errorf("%s: expected validation error, got %v", name, err)
+		}
+	}
+}
+
+func Test_RTPParametersUnknownExtension(t *testing.T) {
+
+	media := sdp.NewMediaInfo("video", "video")
+	media.AddExtension(1, "urn:ietf:params:rtp-hdrext:toffset")
+	media.AddExtension(2, "urn:example:unknown")
+
+	params := NewRTPParameters(media)
+
+	if len(params.HeaderExtensions) != 2 {
+		t.Fatal("header extensions dropped", params.HeaderExtensions)
+	}
+
+	err := params.Validate()
+	if !errors.Is(err, ErrInvalidSDP) || !strings.Contains(err.Error(), "urn:example:unknown") {
+		t.Fatal("unknown header extension accepted", err)
+	}
+
+	dropped := params.DropUnsupportedHeaderExtensions()
+	if len(dropped) != 1 || dropped[0] != "urn:example:unknown" {
+		t.Fatal("wrong dropped header extensions", dropped)
+	}
+
+	if len(params.HeaderExtensions) != 1 || params.HeaderExtensions[0].URI != "urn:ietf:params:rtp-hdrext:toffset" {
+		t.Fatal("wrong header extensions", params.HeaderExtensions)
+	}
+
+	if err := params.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,189 @@
+package mediaserver
+
+import (
+	"strconv"
+
+	"github.com/notedit/sdp"
+)
+
+// StatsType type of a stats entry, as in the W3C webrtc-stats RTCStatsType
+type StatsType string
+
+const (
+	// StatsTypeInboundRTP a ssrc received on the transport
+	StatsTypeInboundRTP StatsType = "inbound-rtp"
+	// StatsTypeOutboundRTP a ssrc sent on the transport
+	StatsTypeOutboundRTP StatsType = "outbound-rtp"
+	// StatsTypeRemoteInboundRTP what the remote peer reports of a ssrc sent on the transport
+	StatsTypeRemoteInboundRTP StatsType = "remote-inbound-rtp"
+	// StatsTypeCandidatePair the selected ice candidate pair
+	StatsTypeCandidatePair StatsType = "candidate-pair"
+	// StatsTypeTransport the dtls transport
+	StatsTypeTransport StatsType = "transport"
+)
+
+// audioClockRate rtp clock rate of the audio codecs, opus always uses 48khz
+const audioClockRate = 48000
+
+// RTCStats fields common to all the stats entries.
+// Timestamp is in milliseconds since the unix epoch, the same for all the entries of a report
+type RTCStats struct {
+	ID        string    `json:"id"`
+	Type      StatsType `json:"type"`
+	Timestamp float64   `json:"timestamp"`
+}
+
+// InboundRTPStreamStats stats of a received media ssrc
+type InboundRTPStreamStats struct {
+	RTCStats
+	SSRC        uint   `json:"ssrc"`
+	Kind        string `json:"kind"`
+	TransportID string `json:"transportId"`
+	// StreamIdentifier TrackIdentifier msid of the stream and of the track
+	StreamIdentifier string `json:"streamIdentifier"`
+	TrackIdentifier  string `json:"trackIdentifier"`
+	Rid              string `json:"rid,omitempty"`
+
+	PacketsReceived              uint `json:"packetsReceived"`
+	BytesReceived                uint `json:"bytesReceived"`
+	PacketsLost                  uint `json:"packetsLost"`
+	PacketsDiscarded             uint `json:"packetsDiscarded"`
+	RetransmittedPacketsReceived uint `json:"retransmittedPacketsReceived"`
+	FecPacketsReceived           uint `json:"fecPacketsReceived"`
+	// Jitter in seconds
+	Jitter float64 `json:"jitter"`
+	// FractionLost of the packets expected since the previous report
+	FractionLost float64 `json:"fractionLost"`
+	NackCount    uint    `json:"nackCount"`
+	PliCount     uint    `json:"pliCount"`
+	// RoundTripTime in seconds, measured with the sender reports
+	RoundTripTime float64 `json:"roundTripTime"`
+	Bitrate       uint    `json:"bitrate"`
+
+	FrameWidth      int     `json:"frameWidth,omitempty"`
+	FrameHeight     int     `json:"frameHeight,omitempty"`
+	FramesPerSecond float64 `json:"framesPerSecond,omitempty"`
+}
+
+// OutboundRTPStreamStats stats of a sent media ssrc
+type OutboundRTPStreamStats struct {
+	RTCStats
+	SSRC        uint   `json:"ssrc"`
+	Kind        string `json:"kind"`
+	TransportID string `json:"transportId"`
+	// StreamIdentifier TrackIdentifier msid of the stream and of the track
+	StreamIdentifier string `json:"streamIdentifier"`
+	TrackIdentifier  string `json:"trackIdentifier"`
+	RemoteID         string `json:"remoteId"`
+
+	PacketsSent              uint `json:"packetsSent"`
+	BytesSent                uint `json:"bytesSent"`
+	RetransmittedPacketsSent uint `json:"retransmittedPacketsSent"`
+	RetransmittedBytesSent   uint `json:"retransmittedBytesSent"`
+	Bitrate                  uint `json:"bitrate"`
+}
+
+// RemoteInboundRTPStreamStats what the remote peer reports of a sent media ssrc.
+// The native transport only measures the round trip time of the whole transport
+type RemoteInboundRTPStreamStats struct {
+	RTCStats
+	SSRC        uint   `json:"ssrc"`
+	Kind        string `json:"kind"`
+	TransportID string `json:"transportId"`
+	LocalID     string `json:"localId"`
+	// RoundTripTime in seconds
+	RoundTripTime float64 `json:"roundTripTime"`
+}
+
+// CandidatePairStats stats of the selected candidate pair
+type CandidatePairStats struct {
+	RTCStats
+	TransportID       string `json:"transportId"`
+	LocalCandidateID  string `json:"localCandidateId"`
+	RemoteCandidateID string `json:"remoteCandidateId"`
+	LocalAddress      string `json:"localAddress"`
+	LocalPort         int    `json:"localPort"`
+	RemoteAddress     string `json:"remoteAddress"`
+	RemotePort        int    `json:"remotePort"`
+	RemoteType        string `json:"remoteCandidateType"`
+	State             string `json:"state"`
+	Nominated         bool   `json:"nominated"`
+
+	RequestsSent      int64 `json:"requestsSent"`
+	RequestsReceived  int64 `json:"requestsReceived"`
+	ResponsesSent     int64 `json:"responsesSent"`
+	ResponsesReceived int64 `json:"responsesReceived"`
+	// CurrentRoundTripTime in seconds
+	CurrentRoundTripTime float64 `json:"currentRoundTripTime"`
+	// AvailableOutgoingBitrate the sender side estimation, 0 if none yet
+	AvailableOutgoingBitrate uint `json:"availableOutgoingBitrate"`
+}
+
+// TransportStats stats of the dtls transport, summed over all its ssrcs
+type TransportStats struct {
+	RTCStats
+	DTLSState               string `json:"dtlsState"`
+	SelectedCandidatePairID string `json:"selectedCandidatePairId,omitempty"`
+	PacketsSent             uint   `json:"packetsSent"`
+	PacketsReceived         uint   `json:"packetsReceived"`
+	BytesSent               uint   `json:"bytesSent"`
+	BytesReceived           uint   `json:"bytesReceived"`
+}
+
+// StatsReport stats of a transport and of all its streams, read at the same time
+type StatsReport struct {
+	Timestamp        float64                        `json:"timestamp"`
+	Transport        *TransportStats                `json:"transport"`
+	CandidatePair    *CandidatePairStats            `json:"candidatePair,omitempty"`
+	InboundRTP       []*InboundRTPStreamStats       `json:"inboundRtp"`
+	OutboundRTP      []*OutboundRTPStreamStats      `json:"outboundRtp"`
+	RemoteInboundRTP []*RemoteInboundRTPStreamStats `json:"remoteInboundRtp"`
+}
+
+// lossCounters cumulative counters of a received ssrc, kept between reports to compute the fraction lost
+type lossCounters struct {
+	lost     uint
+	received uint
+}
+
+// fractionLost fraction of the packets lost between two reports, from the start if the counters were reset
+func fractionLost(prev lossCounters, cur lossCounters) float64 {
+
+	if cur.lost < prev.lost || cur.received < prev.received {
+		prev = lossCounters{}
+	}
+
+	lost := cur.lost - prev.lost
+	expected := lost + cur.received - prev.received
+
+	if expected == 0 {
+		return 0
+	}
+
+	return float64(lost) / float64(expected)
+}
+
+// jitterSeconds convert an interarrival jitter in rtp timestamp units
+func jitterSeconds(jitter uint, media string) float64 {
+
+	if media == "video" {
+		return float64(jitter) / videoClockRate
+	}
+
+	return float64(jitter) / audioClockRate
+}
+
+func msToSeconds(ms uint) float64 {
+	return float64(ms) / 1000
+}
+
+func mediaKindName(media string) string {
+	if media == "video" {
+		return "Video"
+	}
+	return "Audio"
+}
+
+func candidateStatsID(candidate *sdp.CandidateInfo) string {
+	return "RTCIceCandidate_" + candidate.GetFoundation() + "_" + candidate.GetAddress() + "_" + strconv.Itoa(candidate.GetPort())
+}
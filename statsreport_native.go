@@ -0,0 +1,227 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"strconv"
+	"time"
+
+	native "github.com/notedit/media-server-go/wrapper"
+	"github.com/notedit/sdp"
+)
+
+// GetStatsReport get the stats of the transport and of all its streams, modelled on the W3C webrtc-stats report.
+// Counters are read from the native sources, bypassing the cache of the track GetStats.
+// The fraction lost is computed since the previous call
+func (t *Transport) GetStatsReport() *StatsReport {
+
+	t.Lock()
+	prevLoss := t.statsReportLoss
+	t.Unlock()
+
+	report, loss := t.getStatsReport(prevLoss)
+	if report == nil {
+		return nil
+	}
+
+	t.Lock()
+	t.statsReportLoss = loss
+	t.Unlock()
+
+	return report
+}
+
+// getStatsReport get the report with the fraction lost since prevLoss, and the counters to pass next time.
+// Each consumer keeps its own counters, so they do not move each other's baseline
+func (t *Transport) getStatsReport(prevLoss map[uint]lossCounters) (*StatsReport, map[uint]lossCounters) {
+
+	now := float64(time.Now().UnixNano()) / float64(time.Millisecond)
+
+	t.Lock()
+
+	if t.transport == nil {
+		t.Unlock()
+		return nil, nil
+	}
+
+	transportID := "RTCTransport_" + t.username
+	dtlsState := t.dtlsState
+	targetBitrate := t.targetBitrate
+
+	var local, remote *sdp.CandidateInfo
+	if t.selectedCandidate != nil && len(t.localCandidates) > 0 {
+		local, remote = t.localCandidates[0], t.selectedCandidate
+	}
+
+	incomings := []*IncomingStream{}
+	for _, stream := range t.incomingStreams {
+		incomings = append(incomings, stream)
+	}
+	outgoings := []*OutgoingStream{}
+	for _, stream := range t.outgoingStreams {
+		outgoings = append(outgoings, stream)
+	}
+
+	t.Unlock()
+
+	var rtt uint
+	if err := t.withTransport(func(transport native.DTLSICETransport) error {
+		rtt = transport.GetRTT()
+		return nil
+	}); err != nil {
+		return nil, nil
+	}
+
+	report := &StatsReport{
+		Timestamp:        now,
+		InboundRTP:       []*InboundRTPStreamStats{},
+		OutboundRTP:      []*OutboundRTPStreamStats{},
+		RemoteInboundRTP: []*RemoteInboundRTPStreamStats{},
+	}
+
+	transportStats := &TransportStats{
+		RTCStats:  RTCStats{ID: transportID, Type: StatsTypeTransport, Timestamp: now},
+		DTLSState: dtlsState,
+	}
+
+	loss := make(map[uint]lossCounters)
+
+	for _, stream := range incomings {
+		for _, track := range stream.GetTracks() {
+
+			infos := map[string]*encodingInfoTracker{}
+			if track.GetMedia() == "video" {
+				infos = track.getEncodingInfos()
+			}
+
+			for _, encoding := range track.GetEncodings() {
+
+				group := encoding.GetSource()
+				group.Update()
+
+				media := group.GetMedia()
+				ssrc := media.GetSsrc()
+
+				counters := lossCounters{lost: media.GetLostPackets(), received: media.GetNumPackets()}
+				loss[ssrc] = counters
+
+				inbound := &InboundRTPStreamStats{
+					RTCStats:                     RTCStats{ID: "RTCInboundRTP" + mediaKindName(track.GetMedia()) + "Stream_" + strconv.FormatUint(uint64(ssrc), 10), Type: StatsTypeInboundRTP, Timestamp: now},
+					SSRC:                         ssrc,
+					Kind:                         track.GetMedia(),
+					TransportID:                  transportID,
+					StreamIdentifier:             stream.GetID(),
+					TrackIdentifier:              track.GetID(),
+					Rid:                          encoding.GetID(),
+					PacketsReceived:              media.GetNumPackets(),
+					BytesReceived:                media.GetTotalBytes(),
+					PacketsLost:                  media.GetLostPackets(),
+					PacketsDiscarded:             media.GetDropPackets(),
+					RetransmittedPacketsReceived: group.GetRtx().GetNumPackets(),
+					FecPacketsReceived:           group.GetFec().GetNumPackets(),
+					Jitter:                       jitterSeconds(media.GetJitter(), track.GetMedia()),
+					FractionLost:                 fractionLost(prevLoss[ssrc], counters),
+					NackCount:                    media.GetTotalNACKs(),
+					PliCount:                     media.GetTotalPLIs(),
+					RoundTripTime:                msToSeconds(group.GetRtt()),
+					Bitrate:                      media.GetBitrate(),
+				}
+
+				if tracker, ok := infos[encoding.GetID()]; ok {
+					info := tracker.getInfo()
+					inbound.FrameWidth = info.width
+					inbound.FrameHeight = info.height
+					inbound.FramesPerSecond = info.frameRate
+				}
+
+				report.InboundRTP = append(report.InboundRTP, inbound)
+
+				for _, source := range []native.RTPIncomingSource{media, group.GetRtx(), group.GetFec()} {
+					transportStats.PacketsReceived += source.GetNumPackets()
+					transportStats.BytesReceived += source.GetTotalBytes()
+				}
+			}
+		}
+	}
+
+	for _, stream := range outgoings {
+		for _, track := range stream.GetTracks() {
+
+			group := outgoingSourceGroup(track.source)
+			if group == nil {
+				continue
+			}
+			group.Update()
+
+			media := group.GetMedia()
+			ssrc := media.GetSsrc()
+			kind := mediaKindName(track.GetMedia())
+
+			outboundID := "RTCOutboundRTP" + kind + "Stream_" + strconv.FormatUint(uint64(ssrc), 10)
+			remoteID := "RTCRemoteInboundRtp" + kind + "Stream_" + strconv.FormatUint(uint64(ssrc), 10)
+
+			report.OutboundRTP = append(report.OutboundRTP, &OutboundRTPStreamStats{
+				RTCStats:                 RTCStats{ID: outboundID, Type: StatsTypeOutboundRTP, Timestamp: now},
+				SSRC:                     ssrc,
+				Kind:                     track.GetMedia(),
+				TransportID:              transportID,
+				StreamIdentifier:         stream.GetID(),
+				TrackIdentifier:          track.GetID(),
+				RemoteID:                 remoteID,
+				PacketsSent:              media.GetNumPackets(),
+				BytesSent:                media.GetTotalBytes(),
+				RetransmittedPacketsSent: group.GetRtx().GetNumPackets(),
+				RetransmittedBytesSent:   group.GetRtx().GetTotalBytes(),
+				Bitrate:                  media.GetBitrate(),
+			})
+
+			report.RemoteInboundRTP = append(report.RemoteInboundRTP, &RemoteInboundRTPStreamStats{
+				RTCStats:      RTCStats{ID: remoteID, Type: StatsTypeRemoteInboundRTP, Timestamp: now},
+				SSRC:          ssrc,
+				Kind:          track.GetMedia(),
+				TransportID:   transportID,
+				LocalID:       outboundID,
+				RoundTripTime: msToSeconds(rtt),
+			})
+
+			for _, source := range []native.RTPOutgoingSource{media, group.GetRtx(), group.GetFec()} {
+				transportStats.PacketsSent += source.GetNumPackets()
+				transportStats.BytesSent += source.GetTotalBytes()
+			}
+		}
+	}
+
+	if local != nil && remote != nil {
+
+		iceStats := t.GetICEStats()
+
+		pair := &CandidatePairStats{
+			RTCStats:                 RTCStats{Type: StatsTypeCandidatePair, Timestamp: now},
+			TransportID:              transportID,
+			LocalCandidateID:         candidateStatsID(local),
+			RemoteCandidateID:        candidateStatsID(remote),
+			LocalAddress:             local.GetAddress(),
+			LocalPort:                local.GetPort(),
+			RemoteAddress:            remote.GetAddress(),
+			RemotePort:               remote.GetPort(),
+			RemoteType:               remote.GetType(),
+			State:                    "succeeded",
+			Nominated:                true,
+			RequestsSent:             iceStats.RequestsSent,
+			RequestsReceived:         iceStats.RequestsReceived,
+			ResponsesSent:            iceStats.ResponsesSent,
+			ResponsesReceived:        iceStats.ResponsesReceived,
+			CurrentRoundTripTime:     msToSeconds(rtt),
+			AvailableOutgoingBitrate: targetBitrate,
+		}
+		pair.ID = "RTCIceCandidatePair_" + pair.LocalCandidateID + "_" + pair.RemoteCandidateID
+
+		report.CandidatePair = pair
+		transportStats.SelectedCandidatePairID = pair.ID
+	}
+
+	report.Transport = transportStats
+
+	return report, loss
+}
@@ -0,0 +1,71 @@
+package mediaserver
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_FractionLost(t *testing.T) {
+
+	if fraction := fractionLost(lossCounters{}, lossCounters{lost: 10, received: 90}); fraction != 0.1 {
+		t.Fatal("wrong fraction lost", fraction)
+	}
+
+	// only the packets since the previous report count
+	if fraction := fractionLost(lossCounters{lost: 10, received: 90}, lossCounters{lost: 15, received: 185}); fraction != 0.05 {
+		t.Fatal("wrong fraction lost", fraction)
+	}
+
+	if fraction := fractionLost(lossCounters{lost: 10, received: 90}, lossCounters{lost: 10, received: 90}); fraction != 0 {
+		t.Fatal("wrong fraction lost", fraction)
+	}
+
+	// counters reset, like a reused ssrc
+	if fraction := fractionLost(lossCounters{lost: 10, received: 90}, lossCounters{lost: 1, received: 3}); fraction != 0.25 {
+		t.Fatal("wrong fraction lost", fraction)
+	}
+}
+
+func Test_JitterSeconds(t *testing.T) {
+
+	if jitter := jitterSeconds(900, "video"); jitter != 0.01 {
+		t.Fatal("wrong video jitter", jitter)
+	}
+	if jitter := jitterSeconds(480, "audio"); jitter != 0.01 {
+		t.Fatal("wrong audio jitter", jitter)
+	}
+}
+
+func Test_StatsReportJSON(t *testing.T) {
+
+	report := &StatsReport{
+		Timestamp: 1000,
+		Transport: &TransportStats{
+			RTCStats:  RTCStats{ID: "RTCTransport_a:b", Type: StatsTypeTransport, Timestamp: 1000},
+			DTLSState: "connected",
+		},
+		InboundRTP: []*InboundRTPStreamStats{{
+			RTCStats: RTCStats{ID: "RTCInboundRTPVideoStream_1", Type: StatsTypeInboundRTP, Timestamp: 1000},
+			SSRC:     1,
+			Kind:     "video",
+			Jitter:   0.01,
+		}},
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{`"type":"inbound-rtp"`, `"type":"transport"`, `"dtlsState":"connected"`, `"jitter":0.01`, `"ssrc":1`} {
+		if !strings.Contains(string(data), field) {
+			t.Fatal("missing", field, string(data))
+		}
+	}
+
+	// unknown sizes are not reported
+	if strings.Contains(string(data), "frameWidth") {
+		t.Fatal("zero frame width reported", string(data))
+	}
+}
@@ -1,8 +1,14 @@
+//go:build cgo
+// +build cgo
+
 package mediaserver
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/gofrs/uuid"
 	"github.com/notedit/sdp"
@@ -10,7 +16,23 @@ import (
 	native "github.com/notedit/media-server-go/wrapper"
 )
 
-// StreamerSession represent a rtp session
+// SDES crypto suites supported by the bundled libsrtp
+const (
+	SRTPAESCM128HMACSHA180 = "AES_CM_128_HMAC_SHA1_80"
+	SRTPAESCM128HMACSHA132 = "AES_CM_128_HMAC_SHA1_32"
+	SRTPAEADAES128GCM      = "AEAD_AES_128_GCM"
+	SRTPAEADAES256GCM      = "AEAD_AES_256_GCM"
+)
+
+// master key + master salt length for each suite
+var sdesKeyLengths = map[string]int{
+	SRTPAESCM128HMACSHA180: 30,
+	SRTPAESCM128HMACSHA132: 30,
+	SRTPAEADAES128GCM:      28,
+	SRTPAEADAES256GCM:      44,
+}
+
+// StreamerSession represent a rtp session, safe for concurrent use
 type StreamerSession struct {
 	id              string
 	local           bool
@@ -20,53 +42,148 @@ type StreamerSession struct {
 	outgoing        *OutgoingStreamTrack
 	session         native.RTPSessionFacade
 	onStopListeners []func()
+	// l guards session, held for reading while calling it
+	l sync.RWMutex
+	// leakGuard logs the native session if the session is collected without being stopped
+	leakGuard *nativeLeakGuard
 }
 
-// NewStreamerSession new StreamerSession with auto selectd port
-func NewStreamerSession(media *sdp.MediaInfo) *StreamerSession {
+type sdesCrypto struct {
+	suite string
+	key   string
+}
 
-	streamerSession := &StreamerSession{}
-	var mediaType native.MediaFrameType = 0
-	if strings.ToLower(media.GetType()) == "video" {
-		mediaType = 1
+type streamerSessionOptions struct {
+	localPort    int
+	localCrypto  *sdesCrypto
+	remoteCrypto *sdesCrypto
+}
+
+// StreamerSessionOption configure a StreamerSession
+type StreamerSessionOption func(*streamerSessionOptions)
+
+// WithLocalPort receive on the port instead of an auto selected one
+func WithLocalPort(port int) StreamerSessionOption {
+	return func(o *streamerSessionOptions) {
+		o.localPort = port
 	}
-	session := native.NewRTPSessionFacade(mediaType)
+}
 
-	streamerSession.id = uuid.Must(uuid.NewV4()).String()
+// WithLocalCrypto protect the rtp we send with SRTP keyed by SDES.
+// key can be the base64 key or the key params of an a=crypto line ("inline:...")
+func WithLocalCrypto(suite string, key string) StreamerSessionOption {
+	return func(o *streamerSessionOptions) {
+		o.localCrypto = &sdesCrypto{suite: suite, key: key}
+	}
+}
 
-	properties := native.NewPropertiesFacade()
+// WithRemoteCrypto decrypt the rtp we receive with SRTP keyed by SDES.
+// key can be the base64 key or the key params of an a=crypto line ("inline:...")
+func WithRemoteCrypto(suite string, key string) StreamerSessionOption {
+	return func(o *streamerSessionOptions) {
+		o.remoteCrypto = &sdesCrypto{suite: suite, key: key}
+	}
+}
 
-	if media != nil {
-		num := 0
-		for _, codec := range media.GetCodecs() {
-			item := fmt.Sprintf("codecs.%d", num)
-			properties.SetPropertyStr(item+".codec", codec.GetCodec())
-			properties.SetPropertyInt(item+".pt", codec.GetType())
-			if codec.HasRTX() {
-				properties.SetPropertyInt(item+".rtx", codec.GetRTX())
-			}
-			num = num + 1
-		}
-		properties.SetPropertyInt("codecs.length", num)
+// GenerateSDESCrypto create a random SDES crypto info for the suite, ready to be put on an a=crypto line
+func GenerateSDESCrypto(tag int, suite string) (*sdp.CryptoInfo, error) {
+
+	length, ok := sdesKeyLengths[suite]
+	if !ok {
+		return nil, fmt.Errorf("unsupported crypto suite %s", suite)
 	}
 
-	session.Init(properties)
+	key := make([]byte, length)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
 
-	native.DeletePropertiesFacade(properties)
+	return sdp.NewCryptoInfo(tag, suite, "inline:"+base64.StdEncoding.EncodeToString(key), ""), nil
+}
 
-	streamerSession.session = session
+// sdesKey strip the "inline:" method and the lifetime/mki params from the key params
+func sdesKey(keyParams string) string {
 
-	streamerSession.incoming = NewIncomingStreamTrack(media.GetType(), media.GetType(), native.SessionToReceiver(session), map[string]native.RTPIncomingSourceGroup{"": session.GetIncomingSourceGroup()})
+	key := strings.TrimPrefix(keyParams, "inline:")
 
-	streamerSession.outgoing = newOutgoingStreamTrack(media.GetType(), media.GetType(), native.SessionToSender(session), session.GetOutgoingSourceGroup())
+	if idx := strings.Index(key, "|"); idx >= 0 {
+		key = key[:idx]
+	}
+	return key
+}
 
-	streamerSession.onStopListeners = make([]func(), 0)
+func (c *sdesCrypto) validate() error {
 
-	return streamerSession
+	length, ok := sdesKeyLengths[c.suite]
+	if !ok {
+		return fmt.Errorf("unsupported crypto suite %s", c.suite)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(sdesKey(c.key))
+	if err != nil {
+		return fmt.Errorf("invalid crypto key: %v", err)
+	}
+
+	if len(key) != length {
+		return fmt.Errorf("invalid crypto key length %d for suite %s", len(key), c.suite)
+	}
+	return nil
+}
+
+// NewStreamerSession new StreamerSession with auto selectd port, unless WithLocalPort is given.
+// See NewStreamerSessionWithOptions for the errors
+func NewStreamerSession(media *sdp.MediaInfo, options ...StreamerSessionOption) *StreamerSession {
+	return logStreamerSessionError(newStreamerSession(media, options))
 }
 
 // NewStreamerSessionWithLocalPort  create streamer session with pre selected port
-func NewStreamerSessionWithLocalPort(port int, media *sdp.MediaInfo) *StreamerSession {
+func NewStreamerSessionWithLocalPort(port int, media *sdp.MediaInfo, options ...StreamerSessionOption) *StreamerSession {
+	return logStreamerSessionError(newStreamerSession(media, withLocalPort(port, options)))
+}
+
+// NewStreamerSessionWithOptions new StreamerSession with auto selectd port, unless WithLocalPort is given.
+// ErrInvalidSDP when the media can not be used and ErrInvalidArgument when the crypto options are wrong
+func NewStreamerSessionWithOptions(media *sdp.MediaInfo, options ...StreamerSessionOption) (*StreamerSession, error) {
+	return newStreamerSession(media, options)
+}
+
+// withLocalPort the port first, so a WithLocalPort of the options wins
+func withLocalPort(port int, options []StreamerSessionOption) []StreamerSessionOption {
+	return append([]StreamerSessionOption{WithLocalPort(port)}, options...)
+}
+
+func logStreamerSessionError(session *StreamerSession, err error) *StreamerSession {
+	if err != nil {
+		componentLogger("streamersession").Error("can not create streamer session", "err", err)
+		return nil
+	}
+	return session
+}
+
+func newStreamerSession(media *sdp.MediaInfo, options []StreamerSessionOption) (*StreamerSession, error) {
+
+	if media == nil {
+		return nil, newError(ErrInvalidSDP, "media can not be nil")
+	}
+
+	opts := &streamerSessionOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	for _, crypto := range []*sdesCrypto{opts.localCrypto, opts.remoteCrypto} {
+		if crypto == nil {
+			continue
+		}
+		if err := crypto.validate(); err != nil {
+			return nil, wrapError(ErrInvalidArgument, "streamer session crypto error", err)
+		}
+	}
+
+	params := NewRTPParameters(media)
+	if err := params.Validate(); err != nil {
+		return nil, wrapError(ErrInvalidSDP, "streamer session rtp parameters error", err)
+	}
 
 	streamerSession := &StreamerSession{}
 	var mediaType native.MediaFrameType = 0
@@ -74,40 +191,53 @@ func NewStreamerSessionWithLocalPort(port int, media *sdp.MediaInfo) *StreamerSe
 		mediaType = 1
 	}
 	session := native.NewRTPSessionFacade(mediaType)
+	trackNative("RTPSessionFacade", session)
 
 	streamerSession.id = uuid.Must(uuid.NewV4()).String()
 
 	properties := native.NewPropertiesFacade()
 
-	if media != nil {
-		num := 0
-		for _, codec := range media.GetCodecs() {
-			item := fmt.Sprintf("codecs.%d", num)
-			properties.SetPropertyStr(item+".codec", codec.GetCodec())
-			properties.SetPropertyInt(item+".pt", codec.GetType())
-			if codec.HasRTX() {
-				properties.SetPropertyInt(item+".rtx", codec.GetRTX())
-			}
-			num = num + 1
-		}
-		properties.SetPropertyInt("codecs.length", num)
+	if params != nil {
+		params.setProperties(properties, "")
 	}
 
-	session.SetLocalPort(port)
+	if opts.localPort > 0 {
+		session.SetLocalPort(opts.localPort)
+	}
+
+	// srtp must be ready before the session starts receiving
+	if opts.localCrypto != nil && session.SetLocalCryptoSDES(opts.localCrypto.suite, sdesKey(opts.localCrypto.key)) == 0 {
+		native.DeletePropertiesFacade(properties)
+		untrackNative(session)
+		native.DeleteRTPSessionFacade(session)
+		return nil, newError(ErrNative, "can not set local srtp crypto")
+	}
+
+	if opts.remoteCrypto != nil && session.SetRemoteCryptoSDES(opts.remoteCrypto.suite, sdesKey(opts.remoteCrypto.key)) == 0 {
+		native.DeletePropertiesFacade(properties)
+		untrackNative(session)
+		native.DeleteRTPSessionFacade(session)
+		return nil, newError(ErrNative, "can not set remote srtp crypto")
+	}
 
 	session.Init(properties)
 
 	native.DeletePropertiesFacade(properties)
 
 	streamerSession.session = session
+	streamerSession.leakGuard = newNativeLeakGuard("StreamerSession", session)
 
-	streamerSession.incoming = NewIncomingStreamTrack(media.GetType(), media.GetType(), native.SessionToReceiver(session), map[string]native.RTPIncomingSourceGroup{"": session.GetIncomingSourceGroup()})
+	receiver := native.SessionToReceiver(session)
+	trackNative("RTPReceiverFacade", receiver)
+
+	streamerSession.incoming = NewIncomingStreamTrack(media.GetType(), media.GetType(), receiver, map[string]native.RTPIncomingSourceGroup{"": session.GetIncomingSourceGroup()})
+	streamerSession.incoming.ownsReceiver = true
 
 	streamerSession.outgoing = newOutgoingStreamTrack(media.GetType(), media.GetType(), native.SessionToSender(session), session.GetOutgoingSourceGroup())
 
 	streamerSession.onStopListeners = make([]func(), 0)
 
-	return streamerSession
+	return streamerSession, nil
 }
 
 // GetID get id
@@ -116,11 +246,20 @@ func (s *StreamerSession) GetID() string {
 }
 
 func (s *StreamerSession) GetLocalPort() int {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	if s.session == nil {
+		return 0
+	}
 	return s.session.GetLocalPort()
 }
 
 func (s *StreamerSession) SetRemotePort(ip string, port int) {
-	s.session.SetRemotePort(ip, port)
+	s.l.RLock()
+	defer s.l.RUnlock()
+	if s.session != nil {
+		s.session.SetRemotePort(ip, port)
+	}
 }
 
 // GetIncomingStreamTrack get asso incoming track,
@@ -136,7 +275,12 @@ func (s *StreamerSession) GetOutgoingStreamTrack() *OutgoingStreamTrack {
 // Stop it
 func (s *StreamerSession) Stop() {
 
-	if s.session == nil {
+	s.l.Lock()
+	session := s.session
+	s.session = nil
+	s.l.Unlock()
+
+	if session == nil {
 		return
 	}
 
@@ -148,9 +292,8 @@ func (s *StreamerSession) Stop() {
 		s.outgoing.Stop()
 	}
 
-	s.session.End()
+	session.End()
 
-	native.DeleteRTPSessionFacade(s.session)
-
-	s.session = nil
+	untrackNative(session)
+	native.DeleteRTPSessionFacade(session)
 }
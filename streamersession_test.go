@@ -0,0 +1,105 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/notedit/sdp"
+)
+
+func newOpusMediaInfo() *sdp.MediaInfo {
+	media := sdp.NewMediaInfo("audio", "audio")
+	media.AddCodec(sdp.NewCodecInfo("opus", 111))
+	return media
+}
+
+func newRTPPacket(seq uint16, timestamp uint32, ssrc uint32) []byte {
+	packet := make([]byte, 12+20)
+	packet[0] = 0x80
+	packet[1] = 111
+	binary.BigEndian.PutUint16(packet[2:], seq)
+	binary.BigEndian.PutUint32(packet[4:], timestamp)
+	binary.BigEndian.PutUint32(packet[8:], ssrc)
+	return packet
+}
+
+func Test_StreamerSessionSDESLoopback(t *testing.T) {
+
+	crypto1, err := GenerateSDESCrypto(1, SRTPAESCM128HMACSHA180)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crypto2, err := GenerateSDESCrypto(1, SRTPAESCM128HMACSHA180)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := NewStreamerSession(newOpusMediaInfo(),
+		WithLocalCrypto(crypto1.GetCipherSuite(), crypto1.GetKeyParams()),
+		WithRemoteCrypto(crypto2.GetCipherSuite(), crypto2.GetKeyParams()))
+
+	receiver := NewStreamerSession(newOpusMediaInfo(),
+		WithLocalCrypto(crypto2.GetCipherSuite(), crypto2.GetKeyParams()),
+		WithRemoteCrypto(crypto1.GetCipherSuite(), crypto1.GetKeyParams()))
+
+	if sender == nil || receiver == nil {
+		t.Fatal("can not create srtp streamer session")
+	}
+
+	defer sender.Stop()
+	defer receiver.Stop()
+
+	sender.SetRemotePort("127.0.0.1", receiver.GetLocalPort())
+	receiver.SetRemotePort("127.0.0.1", sender.GetLocalPort())
+
+	source := NewMediaFrameSession(newOpusMediaInfo())
+	defer source.Stop()
+
+	sender.GetOutgoingStreamTrack().AttachTo(source.GetIncomingStreamTrack())
+
+	for i := 0; i < 50; i++ {
+		source.Push(newRTPPacket(uint16(i), uint32(i*960), 12345678))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	stats := receiver.GetIncomingStreamTrack().GetStats()
+
+	if stats[""] == nil || stats[""].Media.NumPackets == 0 {
+		t.Error("no srtp packets received")
+	}
+}
+
+func Test_StreamerSessionInvalidCrypto(t *testing.T) {
+
+	session := NewStreamerSession(newOpusMediaInfo(), WithLocalCrypto(SRTPAESCM128HMACSHA180, "inline:tooshort"))
+
+	if session != nil {
+		session.Stop()
+		t.Error("invalid crypto key should be rejected")
+	}
+
+	session = NewStreamerSession(newOpusMediaInfo(), WithLocalCrypto("NULL_CIPHER", "inline:tooshort"))
+
+	if session != nil {
+		session.Stop()
+		t.Error("unknown crypto suite should be rejected")
+	}
+}
+
+func Test_NewStreamerSessionWithOptions(t *testing.T) {
+
+	_, err := NewStreamerSessionWithOptions(newOpusMediaInfo(), WithLocalCrypto("NULL_CIPHER", "inline:tooshort"))
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Error("unknown crypto suite should be an invalid argument", err)
+	}
+
+	if _, err := NewStreamerSessionWithOptions(nil); !errors.Is(err, ErrInvalidSDP) {
+		t.Error("nil media should be an invalid sdp", err)
+	}
+}
@@ -0,0 +1,81 @@
+package mediaserver
+
+import (
+	"time"
+)
+
+// SwitchingPolicy hysteresis used by Transponder.SetTargetBitrate to avoid flapping between layers
+// when the bandwidth estimation moves around the bitrate of a layer
+type SwitchingPolicy struct {
+	// UpgradeDelay how long a higher layer has to fit the target bitrate before moving up to it
+	UpgradeDelay time.Duration
+	// DowngradeThreshold move down only when the target bitrate falls under this fraction of the current layer bitrate, 1 moves down as soon as it does not fit
+	DowngradeThreshold float64
+	// Headroom percentage of extra bitrate a higher layer needs over its own bitrate to be chosen
+	Headroom uint
+	// MinSwitchInterval minimum time between two switches
+	MinSwitchInterval time.Duration
+	// KeyFrameTimeout how long to wait for a key frame on the target encoding before giving up an upswitch, 0 switches encodings right away
+	KeyFrameTimeout time.Duration
+}
+
+// DefaultSwitchingPolicy get a policy fitting most video conferencing cases
+func DefaultSwitchingPolicy() *SwitchingPolicy {
+	return &SwitchingPolicy{
+		UpgradeDelay:       3 * time.Second,
+		DowngradeThreshold: 0.85,
+		Headroom:           10,
+		MinSwitchInterval:  time.Second,
+		KeyFrameTimeout:    2 * time.Second,
+	}
+}
+
+// switchState what the policy needs to remember between two target bitrates
+type switchState struct {
+	lastSwitch   time.Time
+	upgradeSince time.Time
+}
+
+// upgradeBitrate the bitrate a layer may use once the headroom is taken out of the target
+func (p *SwitchingPolicy) upgradeBitrate(bitrate uint) uint {
+	return uint(uint64(bitrate) * 100 / uint64(100+p.Headroom))
+}
+
+// choose the layer to forward given the current one, the best one fitting the target bitrate
+// and the best one fitting it with the headroom. It returns the current layer while the hysteresis holds
+func (p *SwitchingPolicy) choose(now time.Time, state *switchState, current *Layer, best *Layer, upgrade *Layer, bitrate uint) *Layer {
+
+	// nothing to stick to
+	if current == nil {
+		state.upgradeSince = time.Time{}
+		return best
+	}
+
+	switch {
+	case best == nil || best.Bitrate < current.Bitrate:
+		state.upgradeSince = time.Time{}
+		// still close enough
+		if float64(bitrate) >= p.DowngradeThreshold*float64(current.Bitrate) {
+			return current
+		}
+		if now.Sub(state.lastSwitch) < p.MinSwitchInterval {
+			return current
+		}
+		return best
+	case upgrade == nil || upgrade.Bitrate <= current.Bitrate:
+		state.upgradeSince = time.Time{}
+		return current
+	}
+
+	if state.upgradeSince.IsZero() {
+		state.upgradeSince = now
+	}
+
+	if now.Sub(state.upgradeSince) < p.UpgradeDelay || now.Sub(state.lastSwitch) < p.MinSwitchInterval {
+		return current
+	}
+
+	state.upgradeSince = time.Time{}
+
+	return upgrade
+}
@@ -0,0 +1,123 @@
+package mediaserver
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_SwitchingPolicyUpgradeDelay(t *testing.T) {
+
+	policy := DefaultSwitchingPolicy()
+	state := &switchState{}
+
+	layers := newSimulcastLayers().Layers
+	low, mid := layers[0], layers[1]
+
+	now := time.Now()
+
+	if layer := policy.choose(now, state, low, mid, mid, 1000000); layer != low {
+		t.Fatal("upgraded before the delay")
+	}
+
+	if layer := policy.choose(now.Add(policy.UpgradeDelay/2), state, low, mid, mid, 1000000); layer != low {
+		t.Fatal("upgraded before the delay")
+	}
+
+	if layer := policy.choose(now.Add(policy.UpgradeDelay), state, low, mid, mid, 1000000); layer != mid {
+		t.Fatal("did not upgrade after the delay")
+	}
+}
+
+func Test_SwitchingPolicyUpgradeReset(t *testing.T) {
+
+	policy := DefaultSwitchingPolicy()
+	state := &switchState{}
+
+	layers := newSimulcastLayers().Layers
+	low, mid := layers[0], layers[1]
+
+	now := time.Now()
+
+	policy.choose(now, state, low, mid, mid, 1000000)
+
+	// the estimation drops in between, the delay starts again
+	policy.choose(now.Add(time.Second), state, low, low, low, 300000)
+
+	if layer := policy.choose(now.Add(policy.UpgradeDelay), state, low, mid, mid, 1000000); layer != low {
+		t.Fatal("upgrade delay was not reset")
+	}
+}
+
+func Test_SwitchingPolicyHeadroom(t *testing.T) {
+
+	policy := DefaultSwitchingPolicy()
+	policy.UpgradeDelay = 0
+	policy.MinSwitchInterval = 0
+
+	state := &switchState{}
+
+	layers := newSimulcastLayers().Layers
+	low, mid := layers[0], layers[1]
+
+	// mid fits but not with the headroom on top
+	upgrade := low
+	if policy.upgradeBitrate(410000) >= mid.Bitrate {
+		t.Fatal("headroom not applied")
+	}
+
+	if layer := policy.choose(time.Now(), state, low, mid, upgrade, 410000); layer != low {
+		t.Fatal("upgraded without headroom")
+	}
+}
+
+func Test_SwitchingPolicyDowngradeThreshold(t *testing.T) {
+
+	policy := DefaultSwitchingPolicy()
+	state := &switchState{}
+
+	layers := newSimulcastLayers().Layers
+	low, mid := layers[0], layers[1]
+
+	now := time.Now()
+
+	// 90% of mid, within the threshold
+	if layer := policy.choose(now, state, mid, low, low, 360000); layer != mid {
+		t.Fatal("downgraded within the threshold")
+	}
+
+	// under the threshold but too soon after last switch
+	state.lastSwitch = now
+	if layer := policy.choose(now.Add(policy.MinSwitchInterval/2), state, mid, low, low, 300000); layer != mid {
+		t.Fatal("downgraded before the min switch interval")
+	}
+
+	if layer := policy.choose(now.Add(policy.MinSwitchInterval), state, mid, low, low, 300000); layer != low {
+		t.Fatal("did not downgrade")
+	}
+
+	// nothing fits at all
+	if layer := policy.choose(now.Add(policy.MinSwitchInterval), state, mid, nil, nil, 100000); layer != nil {
+		t.Fatal("expected no layer")
+	}
+}
+
+func Test_FindLayer(t *testing.T) {
+
+	svc := newSVCLayers().Layers
+
+	layer := findLayer(svc, "", 1, 2)
+	if layer == nil || layer.SpatialLayerId != 1 || layer.TemporalLayerId != 2 {
+		t.Fatal("wrong svc layer")
+	}
+
+	if findLayer(svc, "", MaxLayerId, MaxLayerId) != nil {
+		t.Fatal("unexpected svc layer")
+	}
+
+	simulcast := newSimulcastLayers().Layers
+
+	layer = findLayer(simulcast, "mid", MaxLayerId, MaxLayerId)
+	if layer == nil || layer.EncodingId != "mid" {
+		t.Fatal("wrong simulcast layer")
+	}
+}
@@ -0,0 +1,151 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	native "github.com/notedit/media-server-go/wrapper"
+	"github.com/notedit/sdp"
+)
+
+// Transceiver represent an unified plan media section of a transport, identified by its mid.
+// It carries at most one outgoing track and one incoming track.
+// Its fields are guarded by the negotiation lock of the transport
+type Transceiver struct {
+	mid            string
+	media          string
+	transport      *Transport
+	local          *sdp.MediaInfo
+	remote         *sdp.MediaInfo
+	recv           bool
+	stopped        bool
+	released       bool
+	incoming       *IncomingStreamTrack
+	incomingStream *IncomingStream
+	outgoing       *OutgoingStreamTrack
+	outgoingStream *OutgoingStream
+}
+
+func newTransceiver(transport *Transport, mid string, media string) *Transceiver {
+	return &Transceiver{
+		mid:       mid,
+		media:     media,
+		transport: transport,
+	}
+}
+
+// GetMid get the media id
+func (tr *Transceiver) GetMid() string {
+	return tr.mid
+}
+
+// GetMedia get media type "audio" or "video"
+func (tr *Transceiver) GetMedia() string {
+	tr.transport.negotiation.Lock()
+	defer tr.transport.negotiation.Unlock()
+	return tr.media
+}
+
+// GetDirection get the direction of the media section from our side
+func (tr *Transceiver) GetDirection() sdp.Direction {
+	tr.transport.negotiation.Lock()
+	defer tr.transport.negotiation.Unlock()
+	return tr.direction()
+}
+
+// direction needs the negotiation lock
+func (tr *Transceiver) direction() sdp.Direction {
+
+	if tr.stopped {
+		return sdp.INACTIVE
+	}
+
+	send := tr.outgoing != nil
+	// answering we can only send if the remote side is willing to receive
+	if send && tr.remote != nil {
+		direction := tr.remote.GetDirection()
+		send = direction == sdp.SENDRECV || direction == sdp.RECVONLY
+	}
+
+	// offering we are always ready to receive
+	recv := tr.recv || tr.remote == nil
+
+	switch {
+	case send && recv:
+		return sdp.SENDRECV
+	case send:
+		return sdp.SENDONLY
+	case recv:
+		return sdp.RECVONLY
+	}
+	return sdp.INACTIVE
+}
+
+// IsStopped check if the transceiver has been stopped
+func (tr *Transceiver) IsStopped() bool {
+	tr.transport.negotiation.Lock()
+	defer tr.transport.negotiation.Unlock()
+	return tr.stopped
+}
+
+// GetIncomingTrack get the track received on this media section, if any
+func (tr *Transceiver) GetIncomingTrack() *IncomingStreamTrack {
+	tr.transport.negotiation.Lock()
+	defer tr.transport.negotiation.Unlock()
+	return tr.incoming
+}
+
+// GetOutgoingTrack get the track sent on this media section, if any
+func (tr *Transceiver) GetOutgoingTrack() *OutgoingStreamTrack {
+	tr.transport.negotiation.Lock()
+	defer tr.transport.negotiation.Unlock()
+	return tr.outgoing
+}
+
+// Stop stop the transceiver and its tracks, the media section will be inactive on next negotiation
+// and its mid can be reused once that negotiation is done
+func (tr *Transceiver) Stop() {
+	tr.transport.negotiation.Lock()
+	defer tr.transport.negotiation.Unlock()
+	tr.stop()
+}
+
+// stop needs the negotiation lock
+func (tr *Transceiver) stop() {
+
+	if tr.stopped {
+		return
+	}
+
+	tr.stopped = true
+
+	if tr.incoming != nil {
+		tr.incomingStream.RemoveTrack(tr.incoming)
+		tr.transport.removeIncomingTrack(tr.incoming)
+	}
+
+	if tr.outgoing != nil {
+		tr.outgoingStream.RemoveTrack(tr.outgoing)
+		tr.outgoing.Stop()
+		outgoing := tr.outgoing
+		tr.transport.withTransport(func(transport native.DTLSICETransport) error {
+			outgoing.DeleteOutgoingSourceGroup(transport)
+			return nil
+		})
+	}
+
+	tr.incoming = nil
+	tr.incomingStream = nil
+	tr.outgoing = nil
+	tr.outgoingStream = nil
+}
+
+// reuse take the mid of a released transceiver for a new media section
+func (tr *Transceiver) reuse(media string) {
+	tr.media = media
+	tr.local = nil
+	tr.remote = nil
+	tr.recv = false
+	tr.stopped = false
+	tr.released = false
+}
@@ -4,13 +4,15 @@ import (
 	"errors"
 	"math"
 	"sort"
-
-	native "github.com/notedit/media-server-go/wrapper"
+	"sync"
+	"time"
 )
 
 type BitrateTraversal string
 
 const (
+	// TraversalDefault try the layers from the highest bitrate down. GetActiveLayers reports them by ascending
+	// bitrate, so taking them as reported always picked the lowest layer under the target
 	TraversalDefault               BitrateTraversal = "default"
 	TraversalSpatialTemporal       BitrateTraversal = "spatial-temporal"
 	TraversalTemporalSpatial       BitrateTraversal = "temporal-spatial"
@@ -18,94 +20,272 @@ const (
 	TraversalZigZagTemporalSpatial BitrateTraversal = "zig-zag-temporal-spatial"
 )
 
-// Transponder
+// Transponder forward an incoming track to an outgoing track, safe for concurrent use
 type Transponder struct {
 	muted              bool
 	track              *IncomingStreamTrack
-	transponder        native.RTPStreamTransponderFacade
+	transponder        transponderBackend
 	encodingId         string
 	spatialLayerId     int
 	temporalLayerId    int
 	maxSpatialLayerId  int
 	maxTemporalLayerId int
+	maxWidth           int
+	maxHeight          int
+	maxFrameRate       float64
 	onStopListeners    []func()
+
+	// what was asked for, before the caps
+	wantedSpatialLayerId  int
+	wantedTemporalLayerId int
+
+	// switching hysteresis, nil switches right away
+	policy      *SwitchingPolicy
+	switchState switchState
+	pending     *pendingSwitch
+	trackSwitch *pendingTrackSwitch
+	// lock guards all the fields and the native transponder, it is held while calling the incoming track
+	// but never while calling its attach listeners or the switch callbacks
+	lock sync.Mutex
 }
 
-func NewTransponder(transponderFacade native.RTPStreamTransponderFacade) *Transponder {
+// pendingTrackSwitch a new incoming track waiting for its key frame
+type pendingTrackSwitch struct {
+	track  *IncomingStreamTrack
+	waiter stopper
+	timer  *time.Timer
+	done   func(error)
+}
+
+// pendingSwitch an upswitch waiting for a key frame on the target encoding
+type pendingSwitch struct {
+	layer  *Layer
+	since  time.Time
+	waiter stopper
+}
+
+func newBackendTransponder(backend transponderBackend) *Transponder {
 	transponder := new(Transponder)
 	transponder.muted = false
 
-	transponder.transponder = transponderFacade
+	transponder.transponder = backend
 	transponder.spatialLayerId = MaxLayerId
 	transponder.temporalLayerId = MaxLayerId
 	transponder.maxSpatialLayerId = MaxLayerId
 	transponder.maxTemporalLayerId = MaxLayerId
+	transponder.wantedSpatialLayerId = MaxLayerId
+	transponder.wantedTemporalLayerId = MaxLayerId
 
 	transponder.onStopListeners = make([]func(), 0)
 
 	return transponder
 }
 
+// SetIncomingTrack forward the first encoding of the track, right away
 func (t *Transponder) SetIncomingTrack(incomingTrack *IncomingStreamTrack) error {
 
+	if incomingTrack == nil {
+		return newError(ErrInvalidArgument, "Track can not be nil")
+	}
+
+	// get first encoding
+	encoding := incomingTrack.GetFirstEncoding()
+	if encoding == nil {
+		return newError(ErrInvalidTrack, "Track has no encoding")
+	}
+
+	t.lock.Lock()
+
 	if t.transponder == nil {
-		return errors.New("Transponder is already closed")
+		t.lock.Unlock()
+		return newError(ErrStopped, "Transponder is already closed")
 	}
 
-	if incomingTrack == nil {
-		return errors.New("Track can not be nil")
+	t.cancelSwitch()
+	trackSwitch := t.takeTrackSwitch()
+	previous := t.setIncomingTrack(incomingTrack, encoding)
+
+	t.lock.Unlock()
+
+	if trackSwitch != nil {
+		trackSwitch.done(errors.New("Switch overridden"))
 	}
 
-	if t.track != nil {
-		t.track.Detached()
+	if previous != nil {
+		previous.Detached()
 	}
 
-	t.track = incomingTrack
+	incomingTrack.Attached()
 
-	// we need make sure first encoding id not nil
-	// todo check
-	// get first encoding
-	encoding := t.track.GetFirstEncoding()
-	if encoding == nil {
-		panic("encoding is nil")
+	return nil
+}
+
+// setIncomingTrack forward the encoding of the track, the lock must be held.
+// The previous track is returned, its Detached must be called without the lock
+func (t *Transponder) setIncomingTrack(incomingTrack *IncomingStreamTrack, encoding *Encoding) *IncomingStreamTrack {
+
+	previous := t.track
+	if previous != nil {
+		previous.maxBitrate.removeTransponder(t)
 	}
 
-	t.transponder.SetIncoming(encoding.GetSource(), incomingTrack.receiver)
+	t.track = incomingTrack
+
+	t.transponder.setIncoming(encoding, incomingTrack.keyFrames.getReceiver())
 
 	t.encodingId = encoding.GetID()
 
+	// caps survive the track change, they are applied on next selection
 	t.spatialLayerId = MaxLayerId
 	t.temporalLayerId = MaxLayerId
-	t.maxSpatialLayerId = MaxLayerId
-	t.maxTemporalLayerId = MaxLayerId
+	t.wantedSpatialLayerId = MaxLayerId
+	t.wantedTemporalLayerId = MaxLayerId
+
+	incomingTrack.maxBitrate.addTransponder(t)
+
+	return previous
+}
 
-	t.track.Attached()
+// SwitchIncomingTrack switch to the new track once it has sent a key frame, the current track is forwarded meanwhile.
+// done is called when the switch is completed, or with an error if no key frame arrived before the timeout, keeping the current track
+func (t *Transponder) SwitchIncomingTrack(incomingTrack *IncomingStreamTrack, timeout time.Duration, done func(error)) error {
+
+	if incomingTrack == nil {
+		return newError(ErrInvalidArgument, "Track can not be nil")
+	}
+
+	encoding := incomingTrack.GetFirstEncoding()
+	if encoding == nil {
+		return newError(ErrInvalidTrack, "Track has no encoding")
+	}
+
+	if done == nil {
+		done = func(error) {}
+	}
+
+	t.lock.Lock()
+
+	if t.transponder == nil {
+		t.lock.Unlock()
+		return newError(ErrStopped, "Transponder is already closed")
+	}
+
+	// nothing to keep, or audio where any frame will do
+	if t.track == nil || incomingTrack.GetMedia() != "video" {
+		t.lock.Unlock()
+		if err := t.SetIncomingTrack(incomingTrack); err != nil {
+			return err
+		}
+		done(nil)
+		return nil
+	}
+
+	// already waiting for this track
+	if t.trackSwitch != nil && t.trackSwitch.track == incomingTrack {
+		previousDone := t.trackSwitch.done
+		t.trackSwitch.done = func(err error) {
+			previousDone(err)
+			done(err)
+		}
+		t.lock.Unlock()
+		return nil
+	}
+
+	trackSwitch := &pendingTrackSwitch{
+		track: incomingTrack,
+		done:  done,
+	}
+
+	previous := t.takeTrackSwitch()
+	t.trackSwitch = trackSwitch
+	trackSwitch.waiter = encoding.backend.listen(listenKeyFrameOnce, func(mediaFrame) {
+		t.completeTrackSwitch(trackSwitch, nil)
+	})
+	trackSwitch.timer = time.AfterFunc(timeout, func() {
+		t.completeTrackSwitch(trackSwitch, errors.New("No key frame received on new track"))
+	})
+	t.lock.Unlock()
+
+	if previous != nil {
+		previous.done(errors.New("Switch overridden"))
+	}
+
+	//Request an iframe on the new track
+	incomingTrack.keyFrames.request(encoding.backend.ssrc(), true)
 
 	return nil
 }
 
+// completeTrackSwitch move to the new track, or give up on error
+func (t *Transponder) completeTrackSwitch(trackSwitch *pendingTrackSwitch, err error) {
+
+	t.lock.Lock()
+	if t.trackSwitch != trackSwitch {
+		t.lock.Unlock()
+		return
+	}
+	t.takeTrackSwitch()
+	t.lock.Unlock()
+
+	if err == nil {
+		err = t.SetIncomingTrack(trackSwitch.track)
+	}
+
+	trackSwitch.done(err)
+}
+
+// takeTrackSwitch stop waiting for the pending track switch and return it, the lock must be held
+func (t *Transponder) takeTrackSwitch() *pendingTrackSwitch {
+
+	trackSwitch := t.trackSwitch
+	if trackSwitch == nil {
+		return nil
+	}
+
+	trackSwitch.waiter.Stop()
+	trackSwitch.timer.Stop()
+
+	t.trackSwitch = nil
+
+	return trackSwitch
+}
+
+// GetIncomingTrack get the forwarded track
 func (t *Transponder) GetIncomingTrack() *IncomingStreamTrack {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	return t.track
 }
 
 // GetAvailableLayers   Get available encodings and layers
 func (t *Transponder) GetAvailableLayers() *ActiveLayersInfo {
-	if t.track != nil {
-		return t.track.GetActiveLayers()
+	if track := t.GetIncomingTrack(); track != nil {
+		return track.GetActiveLayers()
 	}
 	return nil
 }
 
+// IsMuted check if the transponder is muted
 func (t *Transponder) IsMuted() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	return t.muted
 }
 
+// Mute stop or resume forwarding
 func (t *Transponder) Mute(muting bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.mute(muting)
+}
+
+// mute needs the lock
+func (t *Transponder) mute(muting bool) {
 
 	if t.muted != muting {
 		t.muted = muting
 		if t.transponder != nil {
-			t.transponder.Mute(muting)
+			t.transponder.mute(muting)
 		}
 	}
 }
@@ -117,202 +297,517 @@ func getSpatialLayerId(layer *Layer) int {
 	return layer.SimulcastIdx
 }
 
+// SetTargetBitrate select the encoding and layers which best fit the bitrate and the caps.
+// If no layer fits, the lowest one is used unless strict is set, in which case the track is muted
 func (t *Transponder) SetTargetBitrate(bitrate uint, traversal BitrateTraversal, strict bool) uint {
 
-	if t.track == nil {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.track == nil || t.transponder == nil {
 		return 0
 	}
 
-	var current uint
-	var encodingId string
-	var encodingIdMin string
+	layers := t.track.GetActiveLayers().Layers
+
+	if len(layers) == 0 {
+		t.mute(false)
+		return 0
+	}
+
+	layer, lowest := t.pickLayer(layers, bitrate, traversal)
+
+	if t.policy != nil {
+
+		now := time.Now()
+		current := findLayer(layers, t.encodingId, t.spatialLayerId, t.temporalLayerId)
+		// nothing is being forwarded, no need to stick to it
+		if t.muted {
+			current = nil
+		}
+		upgrade, _ := t.pickLayer(layers, t.policy.upgradeBitrate(bitrate), traversal)
+
+		// keep waiting for the key frame while the target still fits
+		if t.pending != nil {
+			if current != nil && upgrade != nil && upgrade.EncodingId == t.pending.layer.EncodingId && now.Sub(t.pending.since) < t.policy.KeyFrameTimeout {
+				t.pending.layer = upgrade
+				return current.Bitrate
+			}
+			t.cancelSwitch()
+		}
+
+		layer = t.policy.choose(now, &t.switchState, current, layer, upgrade, bitrate)
+
+		if current != nil && layer == current {
+			return current.Bitrate
+		}
+
+		// moving up to another encoding, do it once it has sent us a key frame
+		if current != nil && layer != nil && layer.EncodingId != t.encodingId && layer.Bitrate > current.Bitrate && t.policy.KeyFrameTimeout > 0 {
+			t.waitKeyFrame(layer, now)
+			return current.Bitrate
+		}
+
+		t.switchState.lastSwitch = now
+	}
+
+	// Check if we have been able to find a layer that matched the target bitrate
+	if layer == nil {
+
+		if strict || lowest == nil {
+			t.mute(true)
+			return 0
+		}
+
+		layer = lowest
+	}
+
+	t.mute(false)
+	t.selectEncoding(layers, layer.EncodingId)
+	t.selectLayer(layers, layer.SpatialLayerId, layer.TemporalLayerId)
+	return layer.Bitrate
+}
 
-	spatialLayerId := MaxLayerId
-	temporalLayerId := MaxLayerId
+// SetSwitchingPolicy set the hysteresis used by SetTargetBitrate, nil switches as soon as the target bitrate changes
+func (t *Transponder) SetSwitchingPolicy(policy *SwitchingPolicy) {
 
-	min := uint(math.MaxInt32)
+	t.lock.Lock()
+	defer t.lock.Unlock()
 
-	spatialLayerIdMin := MaxLayerId
-	temporalLayerIdMin := MaxLayerId
+	t.cancelSwitch()
+
+	t.policy = policy
+	t.switchState = switchState{}
+}
+
+// GetSwitchingPolicy get the hysteresis used by SetTargetBitrate
+func (t *Transponder) GetSwitchingPolicy() *SwitchingPolicy {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.policy
+}
+
+// waitKeyFrame request a key frame on the encoding of the layer and switch to it when it arrives, needs the lock
+func (t *Transponder) waitKeyFrame(layer *Layer, now time.Time) {
+
+	encoding := t.track.GetEncoding(layer.EncodingId)
+	if encoding == nil {
+		return
+	}
+
+	pending := &pendingSwitch{
+		layer: layer,
+		since: now,
+	}
+
+	pending.waiter = encoding.backend.listen(listenKeyFrameOnce, func(mediaFrame) {
+		t.completeSwitch(pending)
+	})
+
+	t.pending = pending
+
+	//Request an iframe on the target ssrc
+	t.track.keyFrames.request(encoding.backend.ssrc(), true)
+}
+
+// completeSwitch move to the pending layer once its key frame has arrived
+func (t *Transponder) completeSwitch(pending *pendingSwitch) {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.pending != pending || t.transponder == nil || t.track == nil {
+		return
+	}
+
+	t.cancelSwitch()
 
-	var orderfunc func(i int, j int) bool
 	layers := t.track.GetActiveLayers().Layers
 
-	if len(layers) == 0 {
-		t.Mute(false)
-		return 0
+	t.mute(false)
+	t.selectEncoding(layers, pending.layer.EncodingId)
+	t.selectLayer(layers, pending.layer.SpatialLayerId, pending.layer.TemporalLayerId)
+
+	t.switchState.lastSwitch = time.Now()
+}
+
+// cancelSwitch drop the pending upswitch, if any
+func (t *Transponder) cancelSwitch() {
+
+	if t.pending == nil {
+		return
+	}
+
+	t.pending.waiter.Stop()
+	t.pending = nil
+}
+
+// findLayer get the layer being forwarded
+func findLayer(layers []*Layer, encodingId string, spatialLayerId int, temporalLayerId int) *Layer {
+
+	for _, layer := range layers {
+		if layer.EncodingId != encodingId {
+			continue
+		}
+		if layer.SpatialLayerId != MaxLayerId && layer.SpatialLayerId != spatialLayerId {
+			continue
+		}
+		if layer.TemporalLayerId != MaxLayerId && layer.TemporalLayerId != temporalLayerId {
+			continue
+		}
+		return layer
 	}
+	return nil
+}
+
+// pickLayer get the best allowed layer under the bitrate following the traversal order, and the lowest allowed one
+func (t *Transponder) pickLayer(layers []*Layer, bitrate uint, traversal BitrateTraversal) (*Layer, *Layer) {
+
+	ordered := make([]*Layer, len(layers))
+	copy(ordered, layers)
+
+	var orderfunc func(a *Layer, b *Layer) bool
 
 	switch traversal {
 	case TraversalSpatialTemporal:
-		orderfunc = func(i, j int) bool {
-			a := layers[i]
-			b := layers[j]
-
-			ret1 := getSpatialLayerId(b)*MaxLayerId + b.TemporalLayerId
-			ret2 := getSpatialLayerId(a)*MaxLayerId + a.TemporalLayerId
-			if ret1-ret2 < 0 {
-				return true
-			}
-			return false
+		orderfunc = func(a, b *Layer) bool {
+			return getSpatialLayerId(a)*MaxLayerId+a.TemporalLayerId > getSpatialLayerId(b)*MaxLayerId+b.TemporalLayerId
 		}
-		break
 	case TraversalZigZagSpatialTemporal:
-		orderfunc = func(i, j int) bool {
-			a := layers[i]
-			b := layers[j]
-
-			ret1 := (getSpatialLayerId(b)+b.TemporalLayerId+1)*MaxLayerId - b.TemporalLayerId
-			ret2 := (getSpatialLayerId(a)+a.TemporalLayerId+1)*MaxLayerId - a.TemporalLayerId
-			if ret1-ret2 < 0 {
-				return true
-			}
-			return false
+		orderfunc = func(a, b *Layer) bool {
+			return (getSpatialLayerId(a)+a.TemporalLayerId+1)*MaxLayerId-a.TemporalLayerId > (getSpatialLayerId(b)+b.TemporalLayerId+1)*MaxLayerId-b.TemporalLayerId
 		}
-		break
 	case TraversalTemporalSpatial:
-		orderfunc = func(i, j int) bool {
-			a := layers[i]
-			b := layers[j]
-
-			ret1 := b.TemporalLayerId*MaxLayerId + getSpatialLayerId(b)
-			ret2 := a.TemporalLayerId*MaxLayerId + getSpatialLayerId(a)
-			if ret1-ret2 < 0 {
-				return true
-			}
-			return false
+		orderfunc = func(a, b *Layer) bool {
+			return a.TemporalLayerId*MaxLayerId+getSpatialLayerId(a) > b.TemporalLayerId*MaxLayerId+getSpatialLayerId(b)
 		}
-		break
 	case TraversalZigZagTemporalSpatial:
-		orderfunc = func(i, j int) bool {
-			a := layers[i]
-			b := layers[j]
-
-			ret1 := (getSpatialLayerId(b)+b.TemporalLayerId+1)*MaxLayerId - getSpatialLayerId(b)
-			ret2 := (getSpatialLayerId(a)+a.TemporalLayerId+1)*MaxLayerId - getSpatialLayerId(a)
-			if ret1-ret2 < 0 {
-				return true
-			}
-			return false
+		orderfunc = func(a, b *Layer) bool {
+			return (getSpatialLayerId(a)+a.TemporalLayerId+1)*MaxLayerId-getSpatialLayerId(a) > (getSpatialLayerId(b)+b.TemporalLayerId+1)*MaxLayerId-getSpatialLayerId(b)
 		}
-		break
 	default:
-		break
+		// highest bitrate first, not as reported, see TraversalDefault
+		orderfunc = func(a, b *Layer) bool {
+			return a.Bitrate > b.Bitrate
+		}
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool { return orderfunc(ordered[i], ordered[j]) })
+
+	var best *Layer
+	var lowest *Layer
+
+	for _, layer := range ordered {
+
+		if !t.isLayerAllowed(layer) {
+			continue
+		}
+
+		if best == nil && layer.Bitrate <= bitrate && layer.Bitrate > 0 {
+			best = layer
+		}
+
+		if layer.Bitrate > 0 && (lowest == nil || layer.Bitrate < lowest.Bitrate) {
+			lowest = layer
+		}
 	}
 
-	if orderfunc != nil {
-		sort.SliceStable(layers, orderfunc)
+	return best, lowest
+}
+
+// allowedBitrates get the distinct bitrates of the layers allowed by the caps, ascending
+func (t *Transponder) allowedBitrates() []uint {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.track == nil {
+		return nil
+	}
+
+	bitrates := make([]uint, 0)
+	seen := make(map[uint]bool)
+
+	for _, layer := range t.track.GetActiveLayers().Layers {
+		if layer.Bitrate == 0 || seen[layer.Bitrate] || !t.isLayerAllowed(layer) {
+			continue
+		}
+		seen[layer.Bitrate] = true
+		bitrates = append(bitrates, layer.Bitrate)
 	}
 
+	sort.Slice(bitrates, func(i, j int) bool { return bitrates[i] < bitrates[j] })
+
+	return bitrates
+}
+
+// isLayerAllowed check the layer against the layer, resolution and frame rate caps, unknown values are not capped
+func (t *Transponder) isLayerAllowed(layer *Layer) bool {
+
+	if spatialLayerId := getSpatialLayerId(layer); spatialLayerId != MaxLayerId && spatialLayerId > t.maxSpatialLayerId {
+		return false
+	}
+
+	if layer.TemporalLayerId != MaxLayerId && layer.TemporalLayerId > t.maxTemporalLayerId {
+		return false
+	}
+
+	return !t.exceedsResolution(layer) && !t.exceedsFrameRate(layer)
+}
+
+func (t *Transponder) exceedsResolution(layer *Layer) bool {
+	return (t.maxWidth > 0 && layer.Width > t.maxWidth) || (t.maxHeight > 0 && layer.Height > t.maxHeight)
+}
+
+func (t *Transponder) exceedsFrameRate(layer *Layer) bool {
+	return t.maxFrameRate > 0 && layer.FrameRate > t.maxFrameRate
+}
+
+// capLayers lower the requested svc layers of an encoding until they fit the caps
+func (t *Transponder) capLayers(layers []*Layer, encodingId string, spatialLayerId int, temporalLayerId int) (int, int) {
+
+	spatialLayerId = Min(spatialLayerId, t.maxSpatialLayerId)
+	temporalLayerId = Min(temporalLayerId, t.maxTemporalLayerId)
+
 	for _, layer := range layers {
 
-		if layer.Bitrate <= bitrate && layer.Bitrate > current && t.maxSpatialLayerId >= layer.SpatialLayerId && t.maxSpatialLayerId >= layer.TemporalLayerId {
-			encodingId = layer.EncodingId
-			spatialLayerId = layer.SpatialLayerId
-			temporalLayerId = layer.TemporalLayerId
-			current = layer.Bitrate
-			break
+		if layer.EncodingId != encodingId {
+			continue
 		}
 
-		if layer.Bitrate > 0 && layer.Bitrate < min && t.maxSpatialLayerId >= layer.SpatialLayerId {
-			encodingIdMin = layer.EncodingId
-			spatialLayerIdMin = layer.SpatialLayerId
-			temporalLayerIdMin = layer.TemporalLayerId
-			min = layer.Bitrate
+		// the resolution comes with the spatial layer and the frame rate with the temporal one
+		if layer.SpatialLayerId != MaxLayerId && layer.SpatialLayerId <= spatialLayerId && t.exceedsResolution(layer) {
+			spatialLayerId = Max(layer.SpatialLayerId-1, 0)
+		}
+
+		if layer.TemporalLayerId != MaxLayerId && layer.TemporalLayerId <= temporalLayerId && t.exceedsFrameRate(layer) {
+			temporalLayerId = Max(layer.TemporalLayerId-1, 0)
 		}
 	}
 
-	// Check if we have been able to find a layer that matched the target bitrate
-	if current <= 0 {
-
-		if strict == false {
-			t.Mute(false)
-			t.SelectEncoding(encodingIdMin)
-			t.SelectLayer(spatialLayerIdMin, temporalLayerIdMin)
-			return min
-		} else {
-			t.Mute(true)
-			return 0
+	return spatialLayerId, temporalLayerId
+}
+
+// isEncodingAllowed check if any layer of the encoding fits the caps, encodings without layer info are allowed
+func (t *Transponder) isEncodingAllowed(layers []*Layer, encodingId string) bool {
+
+	found := false
+
+	for _, layer := range layers {
+		if layer.EncodingId != encodingId {
+			continue
+		}
+		if t.isLayerAllowed(layer) {
+			return true
 		}
+		found = true
+	}
+
+	return !found
+}
+
+// SelectEncoding by id, ErrEncodingNotFound when the track has no such encoding
+// and ErrInvalidArgument when the maximum layers, resolution or frame rate exclude it
+func (t *Transponder) SelectEncoding(encodingId string) error {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.transponder == nil {
+		return newError(ErrStopped, "Transponder is already closed")
 	}
-	t.Mute(false)
-	t.SelectEncoding(encodingId)
-	t.SelectLayer(spatialLayerId, temporalLayerId)
-	return current
+
+	if t.track == nil {
+		return newError(ErrInvalidTrack, "Transponder has no track")
+	}
+
+	return t.selectEncoding(t.track.GetActiveLayers().Layers, encodingId)
 }
 
-// SelectEncoding by id
-func (t *Transponder) SelectEncoding(encodingId string) {
+// selectEncoding needs the lock, a track and the native transponder
+func (t *Transponder) selectEncoding(layers []*Layer, encodingId string) error {
 
 	if t.encodingId == encodingId {
-		return
+		return nil
 	}
 	encoding := t.track.GetEncoding(encodingId)
 	if encoding == nil {
-		return
+		return newError(ErrEncodingNotFound, "Encoding "+encodingId+" not found")
+	}
+
+	if !t.isEncodingAllowed(layers, encodingId) {
+		return newError(ErrInvalidArgument, "Encoding "+encodingId+" exceeds the maximum layers, resolution or frame rate")
 	}
-	t.transponder.SetIncoming(encoding.GetSource(), t.track.receiver)
+
+	t.transponder.setIncoming(encoding, t.track.keyFrames.getReceiver())
 	t.encodingId = encodingId
+
+	return nil
 }
 
 // GetSelectedEncoding get selected encoding id
 func (t *Transponder) GetSelectedEncoding() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	return t.encodingId
 }
 
 // GetSelectedSpatialLayerId  return int
 func (t *Transponder) GetSelectedSpatialLayerId() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	return t.spatialLayerId
 }
 
 // GetSelectedTemporalLayerId  return int
 func (t *Transponder) GetSelectedTemporalLayerId() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	return t.temporalLayerId
 }
 
 // SelectLayer Select SVC temporatl and spatial layers. Only available for VP9 media.
 func (t *Transponder) SelectLayer(spatialLayerId, temporalLayerId int) {
 
-	spatialLayerId = Min(spatialLayerId, t.maxSpatialLayerId)
-	temporalLayerId = Min(temporalLayerId, t.maxTemporalLayerId)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var layers []*Layer
+	if t.track != nil {
+		layers = t.track.GetActiveLayers().Layers
+	}
+
+	t.selectLayer(layers, spatialLayerId, temporalLayerId)
+}
+
+// selectLayer needs the lock
+func (t *Transponder) selectLayer(layers []*Layer, spatialLayerId, temporalLayerId int) {
+
+	t.wantedSpatialLayerId = spatialLayerId
+	t.wantedTemporalLayerId = temporalLayerId
+
+	if t.track != nil {
+		spatialLayerId, temporalLayerId = t.capLayers(layers, t.encodingId, spatialLayerId, temporalLayerId)
+	} else {
+		spatialLayerId = Min(spatialLayerId, t.maxSpatialLayerId)
+		temporalLayerId = Min(temporalLayerId, t.maxTemporalLayerId)
+	}
 
 	if t.spatialLayerId == spatialLayerId && t.temporalLayerId == temporalLayerId {
 		return
 	}
 
-	t.transponder.SelectLayer(spatialLayerId, temporalLayerId)
+	if t.transponder == nil {
+		return
+	}
+
+	t.transponder.selectLayer(spatialLayerId, temporalLayerId)
 
 	t.spatialLayerId = spatialLayerId
 	t.temporalLayerId = temporalLayerId
 }
 
+// SetMaximumLayers cap the spatial and temporal layers, use MaxLayerId to remove a cap.
+// The current selection is lowered right away if it is above the caps
 func (t *Transponder) SetMaximumLayers(maxSpatialLayerId, maxTemporalLayerId int) {
 
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
 	if maxSpatialLayerId < 0 || maxTemporalLayerId < 0 {
 		return
 	}
 
 	t.maxSpatialLayerId = maxSpatialLayerId
 	t.maxTemporalLayerId = maxTemporalLayerId
+
+	t.applyCaps()
 }
 
+// SetMaximumResolution cap the forwarded resolution, 0 removes the cap.
+// Only layers whose resolution is known can be capped
+func (t *Transponder) SetMaximumResolution(width, height int) {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if width < 0 || height < 0 {
+		return
+	}
+
+	t.maxWidth = width
+	t.maxHeight = height
+
+	t.applyCaps()
+}
+
+// SetMaximumFrameRate cap the forwarded frame rate, 0 removes the cap.
+// Only layers whose frame rate is known can be capped
+func (t *Transponder) SetMaximumFrameRate(frameRate float64) {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if frameRate < 0 {
+		return
+	}
+
+	t.maxFrameRate = frameRate
+
+	t.applyCaps()
+}
+
+// applyCaps lower the current selection if it is above the caps, needs the lock
+func (t *Transponder) applyCaps() {
+
+	if t.track == nil || t.transponder == nil {
+		return
+	}
+
+	layers := t.track.GetActiveLayers().Layers
+
+	if !t.isEncodingAllowed(layers, t.encodingId) {
+		// move to the best encoding still allowed
+		if best, _ := t.pickLayer(layers, math.MaxInt32, TraversalDefault); best != nil {
+			t.selectEncoding(layers, best.EncodingId)
+		}
+	}
+
+	t.selectLayer(layers, t.wantedSpatialLayerId, t.wantedTemporalLayerId)
+}
 
 // Stop stop this transponder
 func (t *Transponder) Stop() {
 
+	t.lock.Lock()
+
 	if t.transponder == nil {
+		t.lock.Unlock()
 		return
 	}
 
-	if t.track != nil {
-		t.track.Detached()
-	}
+	t.cancelSwitch()
+	trackSwitch := t.takeTrackSwitch()
 
-	t.transponder.Close()
+	transponder := t.transponder
+	track := t.track
 
-	native.DeleteRTPStreamTransponderFacade(t.transponder)
+	if track != nil {
+		track.maxBitrate.removeTransponder(t)
+	}
 
 	t.transponder = nil
-
 	t.track = nil
+
+	t.lock.Unlock()
+
+	if trackSwitch != nil {
+		trackSwitch.done(newError(ErrStopped, "Transponder is stopped"))
+	}
+
+	if track != nil {
+		track.Detached()
+	}
+
+	transponder.close()
 }
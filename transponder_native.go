@@ -0,0 +1,12 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	native "github.com/notedit/media-server-go/wrapper"
+)
+
+func NewTransponder(transponderFacade native.RTPStreamTransponderFacade) *Transponder {
+	return newBackendTransponder(newNativeTransponder(transponderFacade))
+}
@@ -0,0 +1,73 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/notedit/sdp"
+)
+
+func Test_TransponderConcurrentUse(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	offer, _ := sdp.Parse(sdpStr)
+	transport := endpoint.CreateTransport(offer, nil)
+	transport.SetRemoteProperties(offer.GetMedia("audio"), offer.GetMedia("video"))
+	transport.SetLocalProperties(offer.GetMedia("audio"), offer.GetMedia("video"))
+	defer transport.Stop()
+
+	first := transport.CreateIncomingStream(offer.GetFirstStream()).GetVideoTracks()[0]
+	second := transport.CreateIncomingStreamTrack("video", "second", map[string]uint{})
+
+	outgoing := transport.CreateOutgoingStreamTrack("video", "video", map[string]uint{})
+	transponder := outgoing.AttachTo(first)
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				switch (g + i) % 6 {
+				case 0:
+					transponder.SetIncomingTrack(first)
+				case 1:
+					transponder.SwitchIncomingTrack(second, time.Millisecond, func(error) {})
+				case 2:
+					transponder.SetTargetBitrate(uint(i)*50000, TraversalDefault, false)
+				case 3:
+					transponder.SetMaximumLayers(i%3, MaxLayerId)
+					transponder.SelectLayer(i%3, i%3)
+				case 4:
+					transponder.Mute(i%2 == 0)
+					transponder.IsMuted()
+				case 5:
+					transponder.GetSelectedEncoding()
+					transponder.GetSelectedSpatialLayerId()
+					transponder.GetAvailableLayers()
+					first.GetStats()
+				}
+			}
+		}(g)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		time.Sleep(5 * time.Millisecond)
+		outgoing.Stop()
+	}()
+
+	wg.Wait()
+
+	if transponder.GetIncomingTrack() != nil || first.IsAttached() || second.IsAttached() {
+		t.Fatal("track still attached after stop")
+	}
+}
@@ -0,0 +1,351 @@
+package mediaserver
+
+import (
+	"errors"
+	"testing"
+)
+
+// three svc spatial layers with three temporal layers each, 1280x720@30 on top
+func newSVCLayers() *ActiveLayersInfo {
+
+	info := &ActiveLayersInfo{}
+
+	for spatial := 0; spatial < 3; spatial++ {
+		for temporal := 0; temporal < 3; temporal++ {
+			info.Layers = append(info.Layers, &Layer{
+				EncodingId:      "",
+				SpatialLayerId:  spatial,
+				TemporalLayerId: temporal,
+				SimulcastIdx:    0,
+				Bitrate:         uint((spatial+1)*300000 + temporal*100000),
+				Width:           320 << uint(spatial),
+				Height:          180 << uint(spatial),
+				FrameRate:       7.5 * float64(int(1)<<uint(temporal)),
+			})
+		}
+	}
+	return info
+}
+
+// three simulcast encodings without svc layers
+func newSimulcastLayers() *ActiveLayersInfo {
+
+	info := &ActiveLayersInfo{}
+
+	for idx, rid := range []string{"low", "mid", "high"} {
+		info.Layers = append(info.Layers, &Layer{
+			EncodingId:      rid,
+			SpatialLayerId:  MaxLayerId,
+			TemporalLayerId: MaxLayerId,
+			SimulcastIdx:    idx,
+			Bitrate:         uint(200000 * (idx*idx + 1)),
+			Width:           320 << uint(idx),
+			Height:          180 << uint(idx),
+		})
+	}
+	return info
+}
+
+func newCappedTransponder() *Transponder {
+	return &Transponder{
+		maxSpatialLayerId:  MaxLayerId,
+		maxTemporalLayerId: MaxLayerId,
+	}
+}
+
+func Test_TransponderPickLayerNoCaps(t *testing.T) {
+
+	transponder := newCappedTransponder()
+
+	best, lowest := transponder.pickLayer(newSVCLayers().Layers, 10000000, TraversalDefault)
+
+	if best == nil || best.SpatialLayerId != 2 || best.TemporalLayerId != 2 {
+		t.Error("highest layer should be selected without caps")
+	}
+
+	if lowest == nil || lowest.SpatialLayerId != 0 || lowest.TemporalLayerId != 0 {
+		t.Error("lowest layer mismatch")
+	}
+
+	best, _ = transponder.pickLayer(newSVCLayers().Layers, 650000, TraversalDefault)
+
+	if best == nil || best.Bitrate != 600000 {
+		t.Error("best layer under the target bitrate should be selected")
+	}
+}
+
+func Test_TransponderPickLayerTemporalCap(t *testing.T) {
+
+	transponder := newCappedTransponder()
+	transponder.maxTemporalLayerId = 0
+
+	for _, traversal := range []BitrateTraversal{TraversalDefault, TraversalSpatialTemporal, TraversalTemporalSpatial,
+		TraversalZigZagSpatialTemporal, TraversalZigZagTemporalSpatial} {
+
+		best, _ := transponder.pickLayer(newSVCLayers().Layers, 10000000, traversal)
+
+		if best == nil || best.TemporalLayerId != 0 {
+			t.Errorf("%s: temporal cap should be honoured", traversal)
+		}
+	}
+}
+
+func Test_TransponderPickLayerSpatialCap(t *testing.T) {
+
+	transponder := newCappedTransponder()
+	transponder.maxSpatialLayerId = 1
+
+	best, _ := transponder.pickLayer(newSVCLayers().Layers, 10000000, TraversalDefault)
+
+	if best == nil || best.SpatialLayerId != 1 || best.TemporalLayerId != 2 {
+		t.Error("spatial cap should be honoured")
+	}
+
+	// simulcast index is capped like a spatial layer
+	best, _ = transponder.pickLayer(newSimulcastLayers().Layers, 10000000, TraversalDefault)
+
+	if best == nil || best.EncodingId != "mid" {
+		t.Error("simulcast encodings above the spatial cap should be skipped")
+	}
+}
+
+func Test_TransponderPickLayerResolutionAndFrameRateCaps(t *testing.T) {
+
+	transponder := newCappedTransponder()
+	transponder.maxWidth = 640
+	transponder.maxFrameRate = 15
+
+	best, lowest := transponder.pickLayer(newSVCLayers().Layers, 10000000, TraversalDefault)
+
+	if best == nil || best.Width > 640 || best.FrameRate > 15 {
+		t.Fatal("resolution and frame rate caps should be honoured")
+	}
+
+	if best.SpatialLayerId != 1 || best.TemporalLayerId != 1 {
+		t.Error("best layer within caps should be selected")
+	}
+
+	if lowest == nil || lowest.Bitrate != 300000 {
+		t.Error("lowest layer mismatch")
+	}
+
+	if transponder.isEncodingAllowed(newSimulcastLayers().Layers, "high") {
+		t.Error("encoding above the resolution cap should not be allowed")
+	}
+
+	if !transponder.isEncodingAllowed(newSimulcastLayers().Layers, "unknown") {
+		t.Error("encoding without layer info should be allowed")
+	}
+}
+
+func Test_TransponderPickLayerNothingAllowed(t *testing.T) {
+
+	transponder := newCappedTransponder()
+	transponder.maxWidth = 100
+
+	best, lowest := transponder.pickLayer(newSVCLayers().Layers, 10000000, TraversalDefault)
+
+	if best != nil || lowest != nil {
+		t.Error("no layer should be selected when all are above the caps")
+	}
+}
+
+func Test_TransponderCapLayers(t *testing.T) {
+
+	transponder := newCappedTransponder()
+	layers := newSVCLayers().Layers
+
+	spatial, temporal := transponder.capLayers(layers, "", MaxLayerId, MaxLayerId)
+	if spatial != MaxLayerId || temporal != MaxLayerId {
+		t.Error("layers should not be capped without caps")
+	}
+
+	transponder.maxTemporalLayerId = 1
+	spatial, temporal = transponder.capLayers(layers, "", 2, 2)
+	if spatial != 2 || temporal != 1 {
+		t.Error("temporal layer should be capped")
+	}
+
+	transponder.maxTemporalLayerId = MaxLayerId
+	transponder.maxHeight = 360
+	transponder.maxFrameRate = 10
+	spatial, temporal = transponder.capLayers(layers, "", 2, 2)
+	if spatial != 1 || temporal != 0 {
+		t.Error("resolution and frame rate caps should lower the layers")
+	}
+}
+
+// an svc encoding with two spatial and two temporal layers, aggregated to 100k 150k 300k and 450k
+func newFakeSVCTrack() *IncomingStreamTrack {
+	return newFakeIncomingStreamTrack("video", "svc", map[string]*fakeEncoding{
+		"": newFakeEncoding(1, 450000,
+			&Layer{SpatialLayerId: 0, TemporalLayerId: 0, Bitrate: 100000},
+			&Layer{SpatialLayerId: 0, TemporalLayerId: 1, Bitrate: 50000},
+			&Layer{SpatialLayerId: 1, TemporalLayerId: 0, Bitrate: 200000},
+			&Layer{SpatialLayerId: 1, TemporalLayerId: 1, Bitrate: 100000},
+		),
+	})
+}
+
+// three simulcast encodings without svc layers, 320x180 640x360 and 1280x720
+func newFakeSimulcastTrack() *IncomingStreamTrack {
+	track := newFakeIncomingStreamTrack("video", "simulcast", map[string]*fakeEncoding{
+		"low":  newFakeEncoding(1, 200000),
+		"mid":  newFakeEncoding(2, 500000),
+		"high": newFakeEncoding(3, 1200000),
+	})
+	setFakeEncodingInfo(track, "low", 320, 180, 30)
+	setFakeEncodingInfo(track, "mid", 640, 360, 30)
+	setFakeEncodingInfo(track, "high", 1280, 720, 30)
+	return track
+}
+
+func Test_TransponderSetTargetBitrateLayers(t *testing.T) {
+
+	outgoing, sender := newFakeOutgoingStreamTrack("video", "out")
+	transponder := outgoing.AttachTo(newFakeSVCTrack())
+	fake := sender.last()
+	defer outgoing.Stop()
+
+	steps := []struct {
+		bitrate   uint
+		traversal BitrateTraversal
+		strict    bool
+		maxLayers [2]int
+		selected  uint
+		spatial   int
+		temporal  int
+	}{
+		{320000, TraversalDefault, false, [2]int{MaxLayerId, MaxLayerId}, 300000, 1, 0},
+		{1000000, TraversalDefault, false, [2]int{MaxLayerId, MaxLayerId}, 450000, 1, 1},
+		// the temporal layers are tried first
+		{320000, TraversalTemporalSpatial, false, [2]int{MaxLayerId, MaxLayerId}, 150000, 0, 1},
+		{320000, TraversalSpatialTemporal, false, [2]int{MaxLayerId, MaxLayerId}, 300000, 1, 0},
+		// nothing fits, the lowest layer is kept
+		{50000, TraversalDefault, false, [2]int{MaxLayerId, MaxLayerId}, 100000, 0, 0},
+		{1000000, TraversalDefault, false, [2]int{0, MaxLayerId}, 150000, 0, 1},
+		{1000000, TraversalDefault, false, [2]int{MaxLayerId, 0}, 300000, 1, 0},
+	}
+
+	for i, step := range steps {
+
+		transponder.SetMaximumLayers(step.maxLayers[0], step.maxLayers[1])
+
+		selected := transponder.SetTargetBitrate(step.bitrate, step.traversal, step.strict)
+		spatial, temporal := fake.selectedLayers()
+
+		if selected != step.selected || spatial != step.spatial || temporal != step.temporal {
+			t.Fatal("wrong layer", i, selected, spatial, temporal)
+		}
+
+		if transponder.GetSelectedSpatialLayerId() != spatial || transponder.GetSelectedTemporalLayerId() != temporal {
+			t.Fatal("selection not reported", i, transponder.GetSelectedSpatialLayerId(), transponder.GetSelectedTemporalLayerId())
+		}
+	}
+
+	if selected := transponder.SetTargetBitrate(50000, TraversalDefault, true); selected != 0 || !transponder.IsMuted() {
+		t.Fatal("not muted when strict", selected)
+	}
+}
+
+func Test_TransponderSetTargetBitrateEncodings(t *testing.T) {
+
+	outgoing, sender := newFakeOutgoingStreamTrack("video", "out")
+	transponder := outgoing.AttachTo(newFakeSimulcastTrack())
+	fake := sender.last()
+	defer outgoing.Stop()
+
+	if selected := transponder.SetTargetBitrate(600000, TraversalDefault, false); selected != 500000 || fake.forwarding() != "mid" {
+		t.Fatal("wrong encoding", selected, fake.forwarding())
+	}
+
+	if selected := transponder.SetTargetBitrate(2000000, TraversalDefault, false); selected != 1200000 || fake.forwarding() != "high" {
+		t.Fatal("wrong encoding", selected, fake.forwarding())
+	}
+
+	// the current encoding is lowered right away once above the cap
+	transponder.SetMaximumResolution(640, 360)
+	if fake.forwarding() != "mid" || transponder.GetSelectedEncoding() != "mid" {
+		t.Fatal("encoding above the resolution cap kept", fake.forwarding())
+	}
+
+	if selected := transponder.SetTargetBitrate(2000000, TraversalDefault, false); selected != 500000 || fake.forwarding() != "mid" {
+		t.Fatal("resolution cap not honoured", selected, fake.forwarding())
+	}
+
+	if selected := transponder.SetTargetBitrate(100000, TraversalDefault, false); selected != 200000 || fake.forwarding() != "low" {
+		t.Fatal("lowest encoding not used", selected, fake.forwarding())
+	}
+
+	transponder.SetMaximumResolution(0, 0)
+	if selected := transponder.SetTargetBitrate(2000000, TraversalDefault, false); selected != 1200000 || fake.forwarding() != "high" {
+		t.Fatal("resolution cap not removed", selected, fake.forwarding())
+	}
+}
+
+func Test_TransponderSelectEncoding(t *testing.T) {
+
+	outgoing, sender := newFakeOutgoingStreamTrack("video", "out")
+	transponder := outgoing.AttachTo(newFakeSimulcastTrack())
+	fake := sender.last()
+	defer outgoing.Stop()
+
+	if err := transponder.SelectEncoding("mid"); err != nil || fake.forwarding() != "mid" || transponder.GetSelectedEncoding() != "mid" {
+		t.Fatal("encoding not selected", err, fake.forwarding())
+	}
+
+	if err := transponder.SelectEncoding("unknown"); !errors.Is(err, ErrEncodingNotFound) || fake.forwarding() != "mid" {
+		t.Fatal("unknown encoding selected", err, fake.forwarding())
+	}
+
+	transponder.SetMaximumResolution(640, 360)
+
+	if err := transponder.SelectEncoding("high"); !errors.Is(err, ErrInvalidArgument) || fake.forwarding() != "mid" {
+		t.Fatal("encoding above the resolution cap selected", err, fake.forwarding())
+	}
+
+	if err := transponder.SelectEncoding("low"); err != nil || fake.forwarding() != "low" {
+		t.Fatal("encoding under the resolution cap not selected", err, fake.forwarding())
+	}
+
+	outgoing.Stop()
+
+	if err := transponder.SelectEncoding("mid"); !errors.Is(err, ErrStopped) {
+		t.Fatal("encoding selected once stopped", err)
+	}
+}
+
+func Test_TransponderSelectLayer(t *testing.T) {
+
+	outgoing, sender := newFakeOutgoingStreamTrack("video", "out")
+	transponder := outgoing.AttachTo(newFakeSVCTrack())
+	fake := sender.last()
+	defer outgoing.Stop()
+
+	transponder.SelectLayer(0, 1)
+	if spatial, temporal := fake.selectedLayers(); spatial != 0 || temporal != 1 {
+		t.Fatal("layer not selected", spatial, temporal)
+	}
+
+	transponder.SetMaximumLayers(1, 0)
+
+	// lowered to the caps
+	if spatial, temporal := fake.selectedLayers(); spatial != 0 || temporal != 0 {
+		t.Fatal("selection above the caps kept", spatial, temporal)
+	}
+
+	transponder.SelectLayer(1, 1)
+	if spatial, temporal := fake.selectedLayers(); spatial != 1 || temporal != 0 {
+		t.Fatal("caps not honoured", spatial, temporal)
+	}
+
+	// the wanted layers come back once the caps are removed
+	transponder.SetMaximumLayers(MaxLayerId, MaxLayerId)
+	if spatial, temporal := fake.selectedLayers(); spatial != 1 || temporal != 1 {
+		t.Fatal("wanted layers not restored", spatial, temporal)
+	}
+
+	if transponder.GetSelectedSpatialLayerId() != 1 || transponder.GetSelectedTemporalLayerId() != 1 {
+		t.Fatal("selection not reported", transponder.GetSelectedSpatialLayerId(), transponder.GetSelectedTemporalLayerId())
+	}
+}
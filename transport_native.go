@@ -0,0 +1,1132 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gofrs/uuid"
+	native "github.com/notedit/media-server-go/wrapper"
+	"github.com/notedit/sdp"
+)
+
+type senderSideEstimatorListener interface {
+	native.SenderSideEstimatorListener
+	deleteSenderSideEstimatorListener()
+}
+
+type goSenderSideEstimatorListener struct {
+	native.SenderSideEstimatorListener
+}
+
+func (r *goSenderSideEstimatorListener) deleteSenderSideEstimatorListener() {
+	native.DeleteDirectorSenderSideEstimatorListener(r.SenderSideEstimatorListener)
+}
+
+type overwrittenSenderSideEstimatorListener struct {
+	p         native.SenderSideEstimatorListener
+	transport *Transport
+}
+
+func (p *overwrittenSenderSideEstimatorListener) OnTargetBitrateRequested(bitrate uint) {
+	p.transport.onTargetBitrate(bitrate)
+}
+
+type dtlsICETransportListener interface {
+	native.DTLSICETransportListener
+	deleteDTLSICETransportListener()
+}
+
+type goDTLSICETransportListener struct {
+	native.DTLSICETransportListener
+}
+
+func (d *goDTLSICETransportListener) deleteDTLSICETransportListener() {
+	native.DeleteDTLSICETransportListener(d.DTLSICETransportListener)
+}
+
+type overwrittenDTLSICETransportListener struct {
+	p         native.DTLSICETransportListener
+	transport *Transport
+}
+
+func (p *overwrittenDTLSICETransportListener) OnDTLSStateChange(state uint) {
+	componentLogger("transport").Debug("dtls state change", "state", state)
+	p.transport.onDTLSStateChange(state)
+}
+
+func (p *overwrittenDTLSICETransportListener) OnICECandidateActivated(ip string, port uint, priority uint) {
+	p.transport.onICECandidateActivated(ip, int(port), int(priority))
+}
+
+// Transport represent a connection between a local ICE candidate and a remote set of ICE candidates over a single DTLS session,
+// safe for concurrent use
+type Transport struct {
+	localIce         *sdp.ICEInfo
+	localDtls        *sdp.DTLSInfo
+	localCandidates  []*sdp.CandidateInfo
+	remoteIce        *sdp.ICEInfo
+	remoteDtls       *sdp.DTLSInfo
+	remoteCandidates []*sdp.CandidateInfo
+	bundle           native.RTPBundleTransport
+	transport        native.DTLSICETransport
+	connection       native.RTPBundleTransportConnection
+	dtlsState        string
+
+	username             string
+	incomingStreams      map[string]*IncomingStream
+	outgoingStreams      map[string]*OutgoingStream
+	incomingStreamTracks map[string]*IncomingStreamTrack
+	outgoingStreamTracks map[string]*OutgoingStreamTrack
+
+	iceStats *ICEStats
+	// statsReportLoss counters of the received ssrcs at the last stats report
+	statsReportLoss map[uint]lossCounters
+
+	senderSideListener       senderSideEstimatorListener
+	dtlsICEListener          dtlsICETransportListener
+	outDTLSStateListener     DTLSStateListener
+	onIncomingTrackListeners []IncomingTrackListener
+	onOutgoingTrackListeners []OutgoingTrackListener
+
+	mdnsPolicy                       MDNSCandidatePolicy
+	pendingCandidates                []*sdp.CandidateInfo
+	remoteEndOfCandidates            bool
+	selectedCandidate                *sdp.CandidateInfo
+	onCandidatePairSelectedListeners []CandidatePairSelectedListener
+
+	targetBitrate            uint
+	onTargetBitrateListeners []TargetBitrateListener
+	onStopListeners          []TransportStopListener
+
+	capabilities map[string]*sdp.Capability
+	transceivers []*Transceiver
+	sdpVersion   int
+	stopped      bool
+	// Mutex guards the fields above, it is never held while calling the native transport, the streams or the listeners
+	sync.Mutex
+	// nativeLock held for reading while calling bundle, connection and transport, for writing by Stop while removing them
+	nativeLock sync.RWMutex
+	// creating serializes the creation of streams, so the same id can not be created twice
+	creating sync.Mutex
+	// negotiation serializes Answer, CreateUpdatedAnswer, CreateUpdatedOffer and SetRemoteAnswer, it guards the transceivers
+	negotiation sync.Mutex
+	// endpoint owning the bundle, if any
+	endpoint *Endpoint
+	// leakGuard logs the native transport if the transport is collected without being stopped
+	leakGuard *nativeLeakGuard
+}
+
+// NewTransport create a new transport
+func NewTransport(bundle native.RTPBundleTransport, remoteIce *sdp.ICEInfo, remoteDtls *sdp.DTLSInfo, remoteCandidates []*sdp.CandidateInfo,
+	localIce *sdp.ICEInfo, localDtls *sdp.DTLSInfo, localCandidates []*sdp.CandidateInfo, disableSTUNKeepAlive bool) *Transport {
+
+	opts := &transportOptions{disableSTUNKeepAlive: disableSTUNKeepAlive}
+	transport, err := newTransport(bundle, remoteIce, remoteDtls, remoteCandidates, localIce, localDtls, localCandidates, opts)
+	if err != nil {
+		componentLogger("transport").Error("can not create transport", "error", err)
+		return nil
+	}
+	return transport
+}
+
+// newTransport create a new transport, ErrNative when the bundle refuses the ice transport, like for a duplicate username,
+// or when the dump can not be started
+func newTransport(bundle native.RTPBundleTransport, remoteIce *sdp.ICEInfo, remoteDtls *sdp.DTLSInfo, remoteCandidates []*sdp.CandidateInfo,
+	localIce *sdp.ICEInfo, localDtls *sdp.DTLSInfo, localCandidates []*sdp.CandidateInfo, opts *transportOptions) (*Transport, error) {
+
+	transport := new(Transport)
+	transport.remoteIce = remoteIce
+	transport.remoteDtls = remoteDtls
+	transport.localIce = localIce
+	transport.localDtls = localDtls
+	transport.bundle = bundle
+	transport.dtlsState = "new"
+
+	properties := native.NewPropertiesFacade()
+
+	properties.SetPropertyStr("ice.localUsername", localIce.GetUfrag())
+	properties.SetPropertyStr("ice.localPassword", localIce.GetPassword())
+	properties.SetPropertyStr("ice.remoteUsername", remoteIce.GetUfrag())
+	properties.SetPropertyStr("ice.remotePassword", remoteIce.GetPassword())
+
+	properties.SetPropertyStr("dtls.setup", remoteDtls.GetSetup().String())
+	properties.SetPropertyStr("dtls.hash", remoteDtls.GetHash())
+	properties.SetPropertyStr("dtls.fingerprint", remoteDtls.GetFingerprint())
+
+	properties.SetPropertyBool("disableSTUNKeepAlive", opts.disableSTUNKeepAlive)
+
+	transport.username = localIce.GetUfrag() + ":" + remoteIce.GetUfrag()
+	transport.connection = bundle.AddICETransport(transport.username, properties)
+	if transport.connection == nil || transport.connection.Swigcptr() == 0 {
+		native.DeletePropertiesFacade(properties)
+		return nil, newError(ErrNative, "Can not add ice transport "+transport.username)
+	}
+	transport.transport = transport.connection.GetTransport()
+
+	// before the dtls handshake
+	if len(opts.srtpProfiles) > 0 {
+		transport.transport.SetSRTPProtectionProfiles(strings.Join(opts.srtpProfiles, ":"))
+	}
+
+	trackNative("DTLSICETransport", transport.transport)
+	transport.leakGuard = newNativeLeakGuard("Transport", transport.transport)
+
+	transport.iceStats = &ICEStats{}
+
+	native.DeletePropertiesFacade(properties)
+
+	sseListener := &overwrittenSenderSideEstimatorListener{transport: transport}
+	p := native.NewDirectorSenderSideEstimatorListener(sseListener)
+	sseListener.p = p
+
+	transport.senderSideListener = &goSenderSideEstimatorListener{SenderSideEstimatorListener: p}
+	transport.transport.SetSenderSideEstimatorListener(transport.senderSideListener)
+
+	dtlsListener := &overwrittenDTLSICETransportListener{transport: transport}
+	dtlsl := native.NewDirectorDTLSICETransportListener(dtlsListener)
+	dtlsListener.p = dtlsl
+
+	transport.dtlsICEListener = &goDTLSICETransportListener{DTLSICETransportListener: dtlsl}
+	transport.transport.SetListener(transport.dtlsICEListener)
+
+	var address string
+	var port int
+	for _, candidate := range remoteCandidates {
+		if candidate.GetType() == "relay" {
+			address = candidate.GetRelAddr()
+			port = candidate.GetRelPort()
+		} else {
+			address = candidate.GetAddress()
+			port = candidate.GetPort()
+		}
+		bundle.AddRemoteCandidate(transport.username, address, uint16(port))
+	}
+
+	transport.localCandidates = localCandidates
+	transport.remoteCandidates = remoteCandidates
+
+	transport.incomingStreams = make(map[string]*IncomingStream)
+	transport.outgoingStreams = make(map[string]*OutgoingStream)
+
+	transport.incomingStreamTracks = make(map[string]*IncomingStreamTrack)
+	transport.outgoingStreamTracks = make(map[string]*OutgoingStreamTrack)
+
+	transport.onIncomingTrackListeners = make([]IncomingTrackListener, 0)
+	transport.onOutgoingTrackListeners = make([]OutgoingTrackListener, 0)
+	transport.onCandidatePairSelectedListeners = make([]CandidatePairSelectedListener, 0)
+	transport.onTargetBitrateListeners = make([]TargetBitrateListener, 0)
+	transport.onStopListeners = make([]TransportStopListener, 0)
+
+	if opts.bandwidthProbing {
+		transport.SetBandwidthProbing(true)
+	}
+
+	if opts.maxProbingBitrate > 0 {
+		transport.SetMaxProbingBitrate(opts.maxProbingBitrate)
+	}
+
+	if opts.dump != nil && !transport.Dump(opts.dump.filename, opts.dump.incoming, opts.dump.outgoing, opts.dump.rtcp) {
+		transport.Stop()
+		return nil, newError(ErrNative, "Can not dump to "+opts.dump.filename)
+	}
+
+	return transport, nil
+}
+
+// Dump  dump incoming and outgoint rtp and rtcp packets into a pcap file
+func (t *Transport) Dump(filename string, incoming bool, outgoing bool, rtcp bool) bool {
+	ret := 0
+	t.withTransport(func(transport native.DTLSICETransport) error {
+		ret = transport.Dump(filename, incoming, outgoing, rtcp)
+		return nil
+	})
+	if ret == 0 {
+		return false
+	}
+	return true
+}
+
+// withTransport call f while the native transport can not be removed, ErrStopped once it is
+func (t *Transport) withTransport(f func(transport native.DTLSICETransport) error) error {
+
+	t.nativeLock.RLock()
+	defer t.nativeLock.RUnlock()
+
+	if t.transport == nil {
+		return newError(ErrStopped, "Transport is stopped")
+	}
+
+	return f(t.transport)
+}
+
+// isStopped check if Stop has been called
+func (t *Transport) isStopped() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.stopped
+}
+
+// SetBandwidthProbing Enable/Disable bitrate probing
+// This will send padding only RTX packets to allow bandwidth estimation algortithm to probe bitrate beyonf current sent values.
+// The ammoung of probing bitrate would be limited by the sender bitrate estimation and the limit set on the setMaxProbing Bitrate.
+func (t *Transport) SetBandwidthProbing(probe bool) {
+	t.withTransport(func(transport native.DTLSICETransport) error {
+		transport.SetBandwidthProbing(probe)
+		return nil
+	})
+}
+
+// SetMaxProbingBitrate Set the maximum bitrate to be used if probing is enabled.
+func (t *Transport) SetMaxProbingBitrate(bitrate uint) {
+	t.withTransport(func(transport native.DTLSICETransport) error {
+		transport.SetMaxProbingBitrate(bitrate)
+		return nil
+	})
+}
+
+// GetID get the transport id, the local and remote ice usernames
+func (t *Transport) GetID() string {
+	t.Lock()
+	defer t.Unlock()
+	return t.username
+}
+
+// GetDTLSState  get dtls state
+func (t *Transport) GetDTLSState() string {
+	t.Lock()
+	defer t.Unlock()
+	return t.dtlsState
+}
+
+// GetICEStats  get ice stats, the last ones once the transport is stopped
+func (t *Transport) GetICEStats() *ICEStats {
+
+	t.withTransport(func(transport native.DTLSICETransport) error {
+		stats := ICEStats{
+			RequestsSent:      t.connection.GetIceRequestsSent(),
+			RequestsReceived:  t.connection.GetIceRequestsReceived(),
+			ResponsesSent:     t.connection.GetIceResponsesSent(),
+			ResponsesReceived: t.connection.GetIceResponsesReceived(),
+		}
+		t.Lock()
+		*t.iceStats = stats
+		t.Unlock()
+		return nil
+	})
+
+	t.Lock()
+	defer t.Unlock()
+
+	stats := *t.iceStats
+	return &stats
+}
+
+// SetRemoteProperties  Set remote RTP properties
+func (t *Transport) SetRemoteProperties(audio *sdp.MediaInfo, video *sdp.MediaInfo) error {
+	return t.SetRemoteRTPParameters(NewRTPParameters(audio), NewRTPParameters(video))
+}
+
+// SetRemoteRTPParameters Set remote RTP parameters, they are validated before reaching the native transport
+func (t *Transport) SetRemoteRTPParameters(audio *RTPParameters, video *RTPParameters) error {
+
+	properties, err := newRTPProperties(audio, video)
+	if err != nil {
+		return err
+	}
+	defer native.DeletePropertiesFacade(properties)
+
+	return t.withTransport(func(transport native.DTLSICETransport) error {
+		transport.SetRemoteProperties(properties)
+		return nil
+	})
+}
+
+// SetLocalProperties Set local RTP properties
+func (t *Transport) SetLocalProperties(audio *sdp.MediaInfo, video *sdp.MediaInfo) error {
+	return t.SetLocalRTPParameters(NewRTPParameters(audio), NewRTPParameters(video))
+}
+
+// SetLocalRTPParameters Set local RTP parameters, they are validated before reaching the native transport
+func (t *Transport) SetLocalRTPParameters(audio *RTPParameters, video *RTPParameters) error {
+
+	properties, err := newRTPProperties(audio, video)
+	if err != nil {
+		return err
+	}
+	defer native.DeletePropertiesFacade(properties)
+
+	return t.withTransport(func(transport native.DTLSICETransport) error {
+		transport.SetLocalProperties(properties)
+		return nil
+	})
+}
+
+// SetCapabilities set the capabilities used to answer and offer, keyed by media type
+func (t *Transport) SetCapabilities(capabilities map[string]*sdp.Capability) {
+	t.Lock()
+	defer t.Unlock()
+	t.capabilities = capabilities
+}
+
+// removeIncomingTrack unregister the track sources from the native transport and stop it
+func (t *Transport) removeIncomingTrack(track *IncomingStreamTrack) {
+
+	t.withTransport(func(transport native.DTLSICETransport) error {
+		for _, encoding := range track.GetEncodings() {
+			transport.RemoveIncomingSourceGroup(encoding.GetSource())
+		}
+		return nil
+	})
+
+	track.Stop()
+}
+
+// GetLocalDTLSInfo Get transport local DTLS info
+func (t *Transport) GetLocalDTLSInfo() *sdp.DTLSInfo {
+
+	return t.localDtls
+}
+
+// GetLocalICEInfo Get transport local ICE info
+func (t *Transport) GetLocalICEInfo() *sdp.ICEInfo {
+
+	return t.localIce
+}
+
+// GetLocalCandidates Get local ICE candidates for this transport
+func (t *Transport) GetLocalCandidates() []*sdp.CandidateInfo {
+
+	return t.localCandidates
+}
+
+// GetRemoteCandidates Get remote ICE candidates for this transport
+func (t *Transport) GetRemoteCandidates() []*sdp.CandidateInfo {
+	t.Lock()
+	defer t.Unlock()
+	return append([]*sdp.CandidateInfo{}, t.remoteCandidates...)
+}
+
+// AddRemoteCandidate register a remote candidate info. Only needed for ice-lite to ice-lite endpoints
+func (t *Transport) AddRemoteCandidate(candidate *sdp.CandidateInfo) {
+	t.addRemoteCandidate(candidate)
+}
+
+// AddRemoteCandidateString register a trickled remote candidate line ("candidate:...").
+// An empty line or "end-of-candidates" marks the end of the remote candidates.
+// TCP, relay and rtcp candidates are refused, mDNS ones follow the transport mDNS policy
+func (t *Transport) AddRemoteCandidateString(candidate string) error {
+
+	line := strings.TrimPrefix(strings.TrimSpace(candidate), "a=")
+
+	if line == "" || line == "end-of-candidates" {
+		t.Lock()
+		t.remoteEndOfCandidates = true
+		t.Unlock()
+		return nil
+	}
+
+	info, err := ParseCandidate(line)
+	if err != nil {
+		return err
+	}
+
+	if err := checkRemoteCandidate(info); err != nil {
+		return err
+	}
+
+	if isMDNSCandidate(info) {
+
+		t.Lock()
+		defer t.Unlock()
+
+		if t.mdnsPolicy != MDNSCandidateDefer {
+			return fmt.Errorf("mdns candidate %s rejected", info.GetAddress())
+		}
+
+		t.pendingCandidates = append(t.pendingCandidates, info)
+		return nil
+	}
+
+	return t.addRemoteCandidate(info)
+}
+
+// SetMDNSCandidatePolicy set what to do with mDNS remote candidates, they are rejected by default
+func (t *Transport) SetMDNSCandidatePolicy(policy MDNSCandidatePolicy) {
+	t.Lock()
+	defer t.Unlock()
+	t.mdnsPolicy = policy
+}
+
+// GetPendingRemoteCandidates get the mDNS candidates waiting to be resolved
+func (t *Transport) GetPendingRemoteCandidates() []*sdp.CandidateInfo {
+	t.Lock()
+	defer t.Unlock()
+	return append([]*sdp.CandidateInfo{}, t.pendingCandidates...)
+}
+
+// ResolveMDNSCandidate register the pending candidates of a mDNS name with the resolved address
+func (t *Transport) ResolveMDNSCandidate(name string, address string) error {
+
+	t.Lock()
+	resolved := []*sdp.CandidateInfo{}
+	pending := []*sdp.CandidateInfo{}
+	for _, candidate := range t.pendingCandidates {
+		if strings.EqualFold(candidate.GetAddress(), name) {
+			resolved = append(resolved, candidate)
+		} else {
+			pending = append(pending, candidate)
+		}
+	}
+	t.pendingCandidates = pending
+	t.Unlock()
+
+	if len(resolved) == 0 {
+		return fmt.Errorf("no pending candidate for %s", name)
+	}
+
+	for _, candidate := range resolved {
+		info := sdp.NewCandidateInfo(candidate.GetFoundation(), candidate.GetComponentID(), candidate.GetTransport(),
+			candidate.GetPriority(), address, candidate.GetPort(), candidate.GetType(), candidate.GetRelAddr(), candidate.GetRelPort())
+		if err := t.addRemoteCandidate(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsRemoteEndOfCandidates check if the remote peer has signalled the end of its candidates
+func (t *Transport) IsRemoteEndOfCandidates() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.remoteEndOfCandidates
+}
+
+// OnCandidatePairSelected register candidate pair selected listener
+func (t *Transport) OnCandidatePairSelected(listener CandidatePairSelectedListener) {
+	t.Lock()
+	defer t.Unlock()
+	t.onCandidatePairSelectedListeners = append(t.onCandidatePairSelectedListeners, listener)
+}
+
+// GetSelectedCandidatePair get the local and remote candidates in use, nil until the remote peer nominates one
+func (t *Transport) GetSelectedCandidatePair() (*sdp.CandidateInfo, *sdp.CandidateInfo) {
+	t.Lock()
+	defer t.Unlock()
+	if t.selectedCandidate == nil || len(t.localCandidates) == 0 {
+		return nil, nil
+	}
+	return t.localCandidates[0], t.selectedCandidate
+}
+
+// onICECandidateActivated called from the native side when a remote candidate is nominated
+func (t *Transport) onICECandidateActivated(ip string, port int, priority int) {
+
+	t.Lock()
+
+	if t.bundle == nil || len(t.localCandidates) == 0 {
+		t.Unlock()
+		return
+	}
+
+	var remote *sdp.CandidateInfo
+	for _, candidate := range t.remoteCandidates {
+		if candidate.GetAddress() == ip && candidate.GetPort() == port {
+			remote = candidate
+			break
+		}
+	}
+
+	if remote == nil {
+		// learnt from the connectivity checks
+		remote = sdp.NewCandidateInfo("prflx", 1, "UDP", priority, ip, port, "prflx", "", 0)
+	}
+
+	t.selectedCandidate = remote
+	local := t.localCandidates[0]
+	listeners := append([]CandidatePairSelectedListener{}, t.onCandidatePairSelectedListeners...)
+
+	t.Unlock()
+
+	for _, listener := range listeners {
+		listener(local, remote)
+	}
+}
+
+func (t *Transport) addRemoteCandidate(candidate *sdp.CandidateInfo) error {
+
+	var address string
+	var port int
+
+	if candidate.GetType() == "relay" {
+		address = candidate.GetRelAddr()
+		port = candidate.GetRelPort()
+	} else {
+		address = candidate.GetAddress()
+		port = candidate.GetPort()
+	}
+
+	ret := 0
+	if err := t.withTransport(func(transport native.DTLSICETransport) error {
+		ret = t.bundle.AddRemoteCandidate(t.username, address, uint16(port))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if ret != 0 {
+		return fmt.Errorf("can not add remote candidate %s:%d", address, port)
+	}
+
+	t.Lock()
+	t.remoteCandidates = append(t.remoteCandidates, candidate)
+	t.Unlock()
+	return nil
+}
+
+// CreateOutgoingStream Create new outgoing stream in this transport using StreamInfo
+func (t *Transport) CreateOutgoingStream(streamInfo *sdp.StreamInfo) *OutgoingStream {
+
+	outgoingStream, err := t.createOutgoingStream(streamInfo)
+	if err != nil {
+		componentLogger("transport").Warn("can not create outgoing stream", "error", err)
+		return nil
+	}
+	return outgoingStream
+}
+
+// NewOutgoingStream same as CreateOutgoingStream returning the error,
+// ErrDuplicateStream when the transport already has an outgoing stream with this id and ErrStopped when the transport is stopped
+func (t *Transport) NewOutgoingStream(streamInfo *sdp.StreamInfo) (*OutgoingStream, error) {
+	return t.createOutgoingStream(streamInfo)
+}
+
+// createOutgoingStream ErrDuplicateStream when the transport already has an outgoing stream with this id
+func (t *Transport) createOutgoingStream(streamInfo *sdp.StreamInfo) (*OutgoingStream, error) {
+
+	if streamInfo == nil {
+		return nil, newError(ErrInvalidSDP, "Stream info can not be nil")
+	}
+
+	var outgoingStream *OutgoingStream
+	err := t.withTransport(func(transport native.DTLSICETransport) error {
+
+		t.creating.Lock()
+		defer t.creating.Unlock()
+
+		if t.GetOutgoingStream(streamInfo.GetID()) != nil {
+			return newError(ErrDuplicateStream, "Stream id already present in transport")
+		}
+
+		stream, err := newOutgoingStream(&nativeTransport{transport: transport}, streamInfo.Clone(), true)
+		if err != nil {
+			return err
+		}
+
+		t.Lock()
+		if t.stopped {
+			t.Unlock()
+			stream.Stop()
+			return newError(ErrStopped, "Transport is stopped")
+		}
+		t.outgoingStreams[stream.GetID()] = stream
+		t.Unlock()
+
+		outgoingStream = stream
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	outgoingStream.OnTrack(func(track *OutgoingStreamTrack) {
+		for _, trackFunc := range t.outgoingTrackListeners() {
+			trackFunc(track, outgoingStream)
+		}
+	})
+
+	for _, track := range outgoingStream.GetTracks() {
+		for _, trackFunc := range t.outgoingTrackListeners() {
+			trackFunc(track, outgoingStream)
+		}
+	}
+
+	return outgoingStream, nil
+}
+
+// CreateOutgoingStreamWithID  alias CreateOutgoingStream
+func (t *Transport) CreateOutgoingStreamWithID(streamID string, audio bool, video bool) *OutgoingStream {
+
+	streamInfo := sdp.NewStreamInfo(streamID)
+	if audio {
+		audioTrack := sdp.NewTrackInfo(uuid.Must(uuid.NewV4()).String(), "audio")
+		ssrc := NextSSRC()
+		audioTrack.AddSSRC(ssrc)
+		streamInfo.AddTrack(audioTrack)
+	}
+
+	if video {
+		videoTrack := sdp.NewTrackInfo(uuid.Must(uuid.NewV4()).String(), "video")
+		ssrc := NextSSRC()
+		videoTrack.AddSSRC(ssrc)
+		streamInfo.AddTrack(videoTrack)
+	}
+
+	stream := t.CreateOutgoingStream(streamInfo)
+	return stream
+}
+
+// CreateOutgoingStreamTrack Create new outgoing track in this transport
+func (t *Transport) CreateOutgoingStreamTrack(media string, trackId string, ssrcs map[string]uint) *OutgoingStreamTrack {
+
+	outgoingTrack, err := t.createOutgoingStreamTrack(media, trackId, ssrcs)
+	if err != nil {
+		componentLogger("transport").Warn("can not create outgoing track", "error", err)
+		return nil
+	}
+	return outgoingTrack
+}
+
+// NewOutgoingStreamTrack same as CreateOutgoingStreamTrack returning the error,
+// ErrInvalidArgument when media is not audio or video and ErrStopped when the transport is stopped
+func (t *Transport) NewOutgoingStreamTrack(media string, trackId string, ssrcs map[string]uint) (*OutgoingStreamTrack, error) {
+	return t.createOutgoingStreamTrack(media, trackId, ssrcs)
+}
+
+// createOutgoingStreamTrack ErrInvalidArgument when media is not audio or video
+func (t *Transport) createOutgoingStreamTrack(media string, trackId string, ssrcs map[string]uint) (*OutgoingStreamTrack, error) {
+
+	if err := t.checkTrackMedia(media); err != nil {
+		return nil, err
+	}
+
+	var mediaType native.MediaFrameType = 0
+	if media == "video" {
+		mediaType = 1
+	}
+
+	if trackId == "" {
+		trackId = uuid.Must(uuid.NewV4()).String()
+	}
+
+	var outgoingTrack *OutgoingStreamTrack
+	err := t.withTransport(func(transport native.DTLSICETransport) error {
+
+		source := native.NewRTPOutgoingSourceGroup(mediaType)
+		trackNative("RTPOutgoingSourceGroup", source)
+
+		if ssrc, ok := ssrcs["media"]; ok {
+			source.GetMedia().SetSsrc(ssrc)
+		} else {
+			source.GetMedia().SetSsrc(NextSSRC())
+		}
+
+		if ssrc, ok := ssrcs["rtx"]; ok {
+			source.GetRtx().SetSsrc(ssrc)
+		} else {
+			source.GetRtx().SetSsrc(NextSSRC())
+		}
+
+		if ssrc, ok := ssrcs["fec"]; ok {
+			source.GetFec().SetSsrc(ssrc)
+		} else {
+			source.GetFec().SetSsrc(NextSSRC())
+		}
+
+		// todo error handle
+		transport.AddOutgoingSourceGroup(source)
+
+		outgoingTrack = newOutgoingStreamTrack(media, trackId, native.TransportToSender(transport), source)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trackFunc := range t.outgoingTrackListeners() {
+		trackFunc(outgoingTrack, nil)
+	}
+
+	return outgoingTrack, nil
+}
+
+// CreateIncomingStream Create an incoming stream object from the media stream info objet
+func (t *Transport) CreateIncomingStream(streamInfo *sdp.StreamInfo) *IncomingStream {
+
+	incomingStream, err := t.createIncomingStream(streamInfo, false)
+	if err != nil {
+		componentLogger("transport").Warn("can not create incoming stream", "error", err)
+		return nil
+	}
+	return incomingStream
+}
+
+// NewIncomingStream same as CreateIncomingStream returning the error, a track that can not be created fails the stream.
+// ErrDuplicateStream when the transport already has an incoming stream with this id, ErrDuplicateTrack and ErrInvalidSDP for the tracks
+func (t *Transport) NewIncomingStream(streamInfo *sdp.StreamInfo) (*IncomingStream, error) {
+	return t.createIncomingStream(streamInfo, true)
+}
+
+// createIncomingStream create and register the incoming stream without firing the listeners,
+// ErrDuplicateStream when the transport already has an incoming stream with this id.
+// strict fails on the first track that can not be created, otherwise the track is skipped
+func (t *Transport) createIncomingStream(streamInfo *sdp.StreamInfo, strict bool) (*IncomingStream, error) {
+
+	if streamInfo == nil {
+		return nil, newError(ErrInvalidSDP, "Stream info can not be nil")
+	}
+
+	var incomingStream *IncomingStream
+	err := t.withTransport(func(transport native.DTLSICETransport) error {
+
+		t.creating.Lock()
+		defer t.creating.Unlock()
+
+		if t.GetIncomingStream(streamInfo.GetID()) != nil {
+			return newError(ErrDuplicateStream, "Stream id already present in transport")
+		}
+
+		receiver := native.TransportToReceiver(transport)
+		trackNative("RTPReceiverFacade", receiver)
+
+		stream, err := newIncomingStream(&nativeTransport{transport: transport}, &nativeReceiver{receiver: receiver}, streamInfo, strict)
+		if err != nil {
+			// deleted by the stream
+			return err
+		}
+
+		t.Lock()
+		if t.stopped {
+			t.Unlock()
+			stream.Stop()
+			return newError(ErrStopped, "Transport is stopped")
+		}
+		t.incomingStreams[stream.GetID()] = stream
+		t.Unlock()
+
+		incomingStream = stream
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return incomingStream, nil
+}
+
+// CreateIncomingStreamTrack Create new incoming stream in this transport. TODO: Simulcast is still not supported
+// You can use IncomingStream's CreateTrack
+func (t *Transport) CreateIncomingStreamTrack(media string, trackId string, ssrcs map[string]uint) *IncomingStreamTrack {
+
+	incomingTrack, err := t.createIncomingStreamTrack(media, trackId, ssrcs)
+	if err != nil {
+		componentLogger("transport").Warn("can not create incoming track", "error", err)
+		return nil
+	}
+	return incomingTrack
+}
+
+// NewIncomingStreamTrack same as CreateIncomingStreamTrack returning the error,
+// ErrInvalidArgument when media is not audio or video and ErrStopped when the transport is stopped
+func (t *Transport) NewIncomingStreamTrack(media string, trackId string, ssrcs map[string]uint) (*IncomingStreamTrack, error) {
+	return t.createIncomingStreamTrack(media, trackId, ssrcs)
+}
+
+// createIncomingStreamTrack ErrInvalidArgument when media is not audio or video
+func (t *Transport) createIncomingStreamTrack(media string, trackId string, ssrcs map[string]uint) (*IncomingStreamTrack, error) {
+
+	if err := t.checkTrackMedia(media); err != nil {
+		return nil, err
+	}
+
+	var mediaType native.MediaFrameType = 0
+	if media == "video" {
+		mediaType = 1
+	}
+
+	if trackId == "" {
+		trackId = uuid.Must(uuid.NewV4()).String()
+	}
+
+	var incomingTrack *IncomingStreamTrack
+	err := t.withTransport(func(transport native.DTLSICETransport) error {
+
+		source := native.NewRTPIncomingSourceGroup(mediaType, transport.GetTimeService())
+		trackNative("RTPIncomingSourceGroup", source)
+
+		if ssrc, ok := ssrcs["media"]; ok {
+			source.GetMedia().SetSsrc(ssrc)
+		} else {
+			source.GetMedia().SetSsrc(NextSSRC())
+		}
+
+		if ssrc, ok := ssrcs["rtx"]; ok {
+			source.GetRtx().SetSsrc(ssrc)
+		} else {
+			source.GetRtx().SetSsrc(NextSSRC())
+		}
+
+		if ssrc, ok := ssrcs["fec"]; ok {
+			source.GetFec().SetSsrc(ssrc)
+		} else {
+			source.GetFec().SetSsrc(NextSSRC())
+		}
+
+		transport.AddIncomingSourceGroup(source)
+
+		sources := map[string]native.RTPIncomingSourceGroup{"": source}
+
+		receiver := native.TransportToReceiver(transport)
+		trackNative("RTPReceiverFacade", receiver)
+
+		incomingTrack = NewIncomingStreamTrack(media, trackId, receiver, sources)
+		incomingTrack.ownsReceiver = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trackFunc := range t.incomingTrackListeners() {
+		trackFunc(incomingTrack, nil)
+	}
+
+	return incomingTrack, nil
+}
+
+// checkTrackMedia check the transport can create a track of this media
+func (t *Transport) checkTrackMedia(media string) error {
+
+	if t.isStopped() {
+		return newError(ErrStopped, "Transport is stopped")
+	}
+
+	if media != "audio" && media != "video" {
+		return newError(ErrInvalidArgument, "Unknown media "+media)
+	}
+
+	return nil
+}
+
+func (t *Transport) RemoveIncomingStream(incomingStream *IncomingStream) {
+
+	t.Lock()
+	delete(t.incomingStreams, incomingStream.GetID())
+	t.Unlock()
+}
+
+// RemoveOutgoingStream remove the stream from the transport, its tracks will not be announced on next negotiation
+func (t *Transport) RemoveOutgoingStream(outgoingStream *OutgoingStream) {
+
+	t.Lock()
+	delete(t.outgoingStreams, outgoingStream.GetID())
+	t.Unlock()
+}
+
+// GetIncomingStreams get all incoming streams
+func (t *Transport) GetIncomingStreams() []*IncomingStream {
+	t.Lock()
+	defer t.Unlock()
+	incomings := []*IncomingStream{}
+	for _, stream := range t.incomingStreams {
+		incomings = append(incomings, stream)
+	}
+	return incomings
+}
+
+// GetIncomingStream  get one incoming stream
+func (t *Transport) GetIncomingStream(streamId string) *IncomingStream {
+	t.Lock()
+	defer t.Unlock()
+	return t.incomingStreams[streamId]
+}
+
+// GetOutgoingStreams get all outgoing streams
+func (t *Transport) GetOutgoingStreams() []*OutgoingStream {
+	t.Lock()
+	defer t.Unlock()
+	outgoings := []*OutgoingStream{}
+	for _, stream := range t.outgoingStreams {
+		outgoings = append(outgoings, stream)
+	}
+	return outgoings
+}
+
+// GetOutgoingStream get one outgoing stream
+func (t *Transport) GetOutgoingStream(streamId string) *OutgoingStream {
+	t.Lock()
+	defer t.Unlock()
+	return t.outgoingStreams[streamId]
+}
+
+
+// OnIncomingTrack register incoming track
+func (t *Transport) OnIncomingTrack(listener IncomingTrackListener) {
+	t.Lock()
+	defer t.Unlock()
+	t.onIncomingTrackListeners = append(t.onIncomingTrackListeners, listener)
+}
+
+// incomingTrackListeners copy of the listeners, to call them unlocked
+func (t *Transport) incomingTrackListeners() []IncomingTrackListener {
+	t.Lock()
+	defer t.Unlock()
+	return append([]IncomingTrackListener{}, t.onIncomingTrackListeners...)
+}
+
+// OnOutgoingTrack register outgoing track
+func (t *Transport) OnOutgoingTrack(listener OutgoingTrackListener) {
+	t.Lock()
+	defer t.Unlock()
+	t.onOutgoingTrackListeners = append(t.onOutgoingTrackListeners, listener)
+}
+
+// outgoingTrackListeners copy of the listeners, to call them unlocked
+func (t *Transport) outgoingTrackListeners() []OutgoingTrackListener {
+	t.Lock()
+	defer t.Unlock()
+	return append([]OutgoingTrackListener{}, t.onOutgoingTrackListeners...)
+}
+
+// OnTargetBitrate register a listener for the sender side bandwidth estimation
+func (t *Transport) OnTargetBitrate(listener TargetBitrateListener) {
+	t.Lock()
+	defer t.Unlock()
+	t.onTargetBitrateListeners = append(t.onTargetBitrateListeners, listener)
+}
+
+// GetTargetBitrate get the last sender side bandwidth estimation, 0 if none yet
+func (t *Transport) GetTargetBitrate() uint {
+	t.Lock()
+	defer t.Unlock()
+	return t.targetBitrate
+}
+
+func (t *Transport) onTargetBitrate(bitrate uint) {
+
+	t.Lock()
+	t.targetBitrate = bitrate
+	listeners := make([]TargetBitrateListener, len(t.onTargetBitrateListeners))
+	copy(listeners, t.onTargetBitrateListeners)
+	t.Unlock()
+
+	for _, listener := range listeners {
+		listener(bitrate)
+	}
+}
+
+// OnStop register a listener called when the transport is stopped, before its streams are
+func (t *Transport) OnStop(listener TransportStopListener) {
+	t.Lock()
+	defer t.Unlock()
+	t.onStopListeners = append(t.onStopListeners, listener)
+}
+
+// dtlsStates names of the native dtls states, as in RTCDtlsTransportState
+var dtlsStates = []string{"new", "connecting", "connected", "closed", "failed"}
+
+func (t *Transport) onDTLSStateChange(state uint) {
+
+	t.Lock()
+	if state < uint(len(dtlsStates)) {
+		t.dtlsState = dtlsStates[state]
+	}
+	dtlsState := t.dtlsState
+	listener := t.outDTLSStateListener
+	t.Unlock()
+
+	if listener != nil {
+		listener(dtlsState)
+	}
+}
+
+// OnDTLSICEState  OnDTLSICEState
+func (t *Transport) OnDTLSICEState(listener DTLSStateListener) {
+	t.Lock()
+	defer t.Unlock()
+	t.outDTLSStateListener = listener
+}
+
+
+func (t *Transport) GetLastActiveTime() uint64 {
+
+	var lastActive uint64
+	t.withTransport(func(transport native.DTLSICETransport) error {
+		lastActive = transport.GetLastActiveTime()
+		return nil
+	})
+	return lastActive
+}
+
+
+// Stop stop this transport, the stop listeners are called first, then the streams are stopped
+// and the native transport is removed once the native calls in flight are done
+func (t *Transport) Stop() {
+
+	t.Lock()
+	if t.stopped {
+		t.Unlock()
+		return
+	}
+	t.stopped = true
+	stopListeners := t.onStopListeners
+	t.onStopListeners = nil
+	t.Unlock()
+
+	for _, listener := range stopListeners {
+		listener()
+	}
+
+	t.Lock()
+	incomings := t.incomingStreams
+	outgoings := t.outgoingStreams
+	t.incomingStreams = nil
+	t.outgoingStreams = nil
+	t.transceivers = nil
+	t.Unlock()
+
+	for _, incoming := range incomings {
+		incoming.Stop()
+	}
+
+	for _, outgoing := range outgoings {
+		outgoing.Stop()
+	}
+
+	t.nativeLock.Lock()
+	defer t.nativeLock.Unlock()
+
+	if t.senderSideListener != nil {
+		t.senderSideListener.deleteSenderSideEstimatorListener()
+		t.senderSideListener = nil
+	}
+
+	if t.dtlsICEListener != nil {
+		t.dtlsICEListener.deleteDTLSICETransportListener()
+		t.dtlsICEListener = nil
+	}
+
+	untrackNative(t.transport)
+
+	if t.endpoint != nil {
+		// the endpoint does not delete the bundle meanwhile, nor once the transport is removed from it without its ice transport
+		t.endpoint.nativeLock.RLock()
+		t.endpoint.removeTransport(t)
+		if t.endpoint.bundle != nil {
+			t.bundle.RemoveICETransport(t.username)
+		}
+		t.endpoint.nativeLock.RUnlock()
+	} else {
+		t.bundle.RemoveICETransport(t.username)
+	}
+
+	t.Lock()
+	t.connection = nil
+	t.transport = nil
+	t.username = ""
+	t.bundle = nil
+	t.Unlock()
+}
@@ -0,0 +1,656 @@
+//go:build cgo
+// +build cgo
+
+package mediaserver
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/notedit/sdp"
+)
+
+func Test_TransportCreate(t *testing.T) {
+
+	EnableLog(true)
+	endpoint := NewEndpoint("127.0.0.1")
+
+	iceInfo := sdp.GenerateICEInfo(true)
+	dtlsInfo := sdp.NewDTLSInfo(sdp.SETUPACTPASS, "sha-256", "F2:AA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F")
+	sdpInfo := sdp.NewSDPInfo()
+	sdpInfo.SetICE(iceInfo)
+	sdpInfo.SetDTLS(dtlsInfo)
+	transport := endpoint.CreateTransport(sdpInfo, nil)
+
+	if transport == nil {
+		t.Error("can not create transport")
+	}
+	t.Log("yes")
+}
+
+func Test_CreateIncomingTrack(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	iceInfo := sdp.ICEInfoGenerate(true)
+	dtlsInfo := sdp.NewDTLSInfo(sdp.SETUPACTPASS, "sha-256", "F2:AA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F")
+	sdpInfo := sdp.NewSDPInfo()
+	sdpInfo.SetICE(iceInfo)
+	sdpInfo.SetDTLS(dtlsInfo)
+
+	transport := endpoint.CreateTransport(sdpInfo, nil)
+
+	incomingTrack := transport.CreateIncomingStreamTrack("audio", "audiotrack", map[string]uint{})
+
+	if incomingTrack.GetID() != "audiotrack" {
+		t.Error("create incoming track error")
+	}
+	t.Log("yes")
+}
+
+func Test_IncomingStreamCreateTrack(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	sdpInfo := sdp.NewSDPInfo()
+	sdpInfo.SetICE(sdp.ICEInfoGenerate(true))
+	sdpInfo.SetDTLS(sdp.NewDTLSInfo(sdp.SETUPACTPASS, "sha-256", "F2:AA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F"))
+
+	transport := endpoint.CreateTransport(sdpInfo, nil)
+	stream := transport.CreateIncomingStream(sdp.NewStreamInfo("stream"))
+
+	track := sdp.NewTrackInfo("video", "video")
+	for rid, ssrc := range map[string]string{"a": "1234", "b": "abc"} {
+		encoding := sdp.NewTrackEncodingInfo(rid, false)
+		encoding.AddParam("ssrc", ssrc)
+		track.AddEncoding(encoding)
+	}
+
+	if _, err := stream.NewTrack(track); !errors.Is(err, ErrInvalidSDP) {
+		t.Fatal("invalid remote ssrc should be rejected", err)
+	}
+
+	// the encoding with the invalid ssrc is skipped
+	incomingTrack := stream.CreateTrack(track)
+	if incomingTrack == nil || len(incomingTrack.GetEncodings()) != 1 {
+		t.Fatal("track should be created without the invalid encoding")
+	}
+
+	if _, err := stream.createTrack(track, false); !errors.Is(err, ErrDuplicateTrack) {
+		t.Error("duplicated track should be rejected", err)
+	}
+}
+
+func Test_CreateOutgoingTrack(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	iceInfo := sdp.ICEInfoGenerate(true)
+	dtlsInfo := sdp.NewDTLSInfo(sdp.SETUPACTPASS, "sha-256", "F2:AA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F")
+	sdpInfo := sdp.NewSDPInfo()
+	sdpInfo.SetICE(iceInfo)
+	sdpInfo.SetDTLS(dtlsInfo)
+
+	transport := endpoint.CreateTransport(sdpInfo, nil)
+	outgoingTrack := transport.CreateOutgoingStreamTrack("video", "videotrack", map[string]uint{})
+
+	if outgoingTrack.GetID() != "videotrack" {
+		t.Error("create outgoing track error")
+	}
+
+	if _, err := transport.NewOutgoingStreamTrack("data", "datatrack", map[string]uint{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Error("wrong media should be rejected", err)
+	}
+	if _, err := transport.NewIncomingStreamTrack("data", "datatrack", map[string]uint{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Error("wrong media should be rejected", err)
+	}
+
+	streamInfo := sdp.NewStreamInfo("stream")
+	if _, err := transport.NewOutgoingStream(streamInfo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.NewOutgoingStream(streamInfo); !errors.Is(err, ErrDuplicateStream) {
+		t.Error("duplicated stream should be rejected", err)
+	}
+
+	stream := transport.GetOutgoingStream("stream")
+	trackInfo := sdp.NewTrackInfo("audio", "audio")
+	trackInfo.AddSSRC(NextSSRC())
+	if _, err := stream.NewTrack(trackInfo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.NewTrack(trackInfo); !errors.Is(err, ErrDuplicateTrack) {
+		t.Error("duplicated track should be rejected", err)
+	}
+
+	transport.Stop()
+
+	if _, err := transport.NewOutgoingStream(sdp.NewStreamInfo("other")); !errors.Is(err, ErrStopped) {
+		t.Error("stream created on a stopped transport", err)
+	}
+}
+
+func Test_TransportStop(t *testing.T) {
+
+	EnableLog(false)
+	endpoint := NewEndpoint("127.0.0.1")
+
+	iceInfo := sdp.ICEInfoGenerate(true)
+	dtlsInfo := sdp.NewDTLSInfo(sdp.SETUPACTPASS, "sha-256", "F2:AA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F")
+	sdpInfo := sdp.NewSDPInfo()
+	sdpInfo.SetICE(iceInfo)
+	sdpInfo.SetDTLS(dtlsInfo)
+
+	transport := endpoint.CreateTransport(sdpInfo, nil)
+
+	transport.Stop()
+}
+
+func Test_TransportCreateStream(t *testing.T) {
+
+	EnableLog(false)
+
+	endpoint := NewEndpoint("127.0.0.1")
+
+	offer, error := sdp.Parse(string(sdpStr))
+
+	if error != nil {
+		log.Printf("%s", error)
+		return
+	}
+
+	transport := endpoint.CreateTransport(offer, nil)
+
+	transport.SetRemoteProperties(offer.GetMedia("audio"), offer.GetMedia("video"))
+
+	streamInfo := offer.GetFirstStream()
+
+	incoming := transport.CreateIncomingStream(streamInfo)
+
+	fmt.Println(incoming)
+
+	transport.Stop()
+
+}
+
+func Test_EndpointAnswer(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	offer, err := sdp.Parse(sdpStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capabilities := map[string]*sdp.Capability{
+		"audio": {
+			Codecs:     []string{"opus"},
+			Extensions: []string{"urn:ietf:params:rtp-hdrext:ssrc-audio-level"},
+		},
+		"video": {
+			Codecs:     []string{"vp8"},
+			Rtx:        true,
+			Rtcpfbs:    []*sdp.RtcpFeedback{{ID: "nack"}, {ID: "nack", Params: []string{"pli"}}, {ID: "ccm", Params: []string{"fir"}}, {ID: "goog-lntf"}},
+			Extensions: []string{"urn:3gpp:video-orientation"},
+		},
+	}
+
+	tracks := 0
+	transport, answer, err := endpoint.Answer(offer, capabilities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Stop()
+
+	transport.OnIncomingTrack(func(track *IncomingStreamTrack, stream *IncomingStream) {
+		tracks++
+	})
+
+	video := answer.GetMedia("video")
+	if len(video.GetCodecs()) != 1 || video.GetCodecForType(96) == nil {
+		t.Fatal("vp8 should be the only answered video codec")
+	}
+
+	for _, rtcpfb := range video.GetCodecForType(96).GetRTCPFeedbacks() {
+		if rtcpfb.GetID() == "goog-lntf" {
+			t.Error("rtcp-fb not offered should not be answered")
+		}
+	}
+
+	if len(video.GetExtensions()) != 1 {
+		t.Error("only supported and offered extensions should be answered")
+	}
+
+	if len(transport.GetIncomingStreams()) != 1 {
+		t.Fatal("offered stream should be created")
+	}
+
+	// renegotiate without the stream
+	reoffer := offer.Clone()
+	reoffer.RemoveStream(offer.GetFirstStream())
+
+	if _, err := transport.Answer(reoffer); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transport.GetIncomingStreams()) != 0 {
+		t.Error("removed stream should be stopped")
+	}
+
+	// and add it back
+	if _, err := transport.Answer(offer); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transport.GetIncomingStreams()) != 1 || tracks != 2 {
+		t.Error("re-added stream should be created")
+	}
+
+	// streams created by the application are not announced
+	local := sdp.NewStreamInfo("local")
+	track := sdp.NewTrackInfo("local-video", "video")
+	track.AddSSRC(NextSSRC())
+	local.AddTrack(track)
+	if transport.CreateIncomingStream(local) == nil {
+		t.Fatal("can not create incoming stream")
+	}
+
+	if tracks != 2 {
+		t.Error("incoming track listeners should only fire for negotiated streams")
+	}
+}
+
+func Test_TransportRenegotiation(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	remote := NewEndpoint("127.0.0.1")
+	defer remote.Stop()
+
+	offer, err := sdp.Parse(sdpStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capabilities := map[string]*sdp.Capability{
+		"audio": {Codecs: []string{"opus"}},
+		"video": {Codecs: []string{"vp8"}, Rtx: true},
+	}
+
+	transport, answer, err := endpoint.Answer(offer, capabilities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Stop()
+
+	if len(transport.GetTransceivers()) != 2 || transport.GetTransceiver("video") == nil {
+		t.Fatal("a transceiver should be created for each offered mid")
+	}
+
+	if answer.GetMediaByID("video").GetDirection() != sdp.RECVONLY {
+		t.Error("nothing to send, video should be recvonly")
+	}
+
+	// the offered sections can carry the new tracks
+	transport.CreateOutgoingStreamWithID("stream1", true, true)
+
+	answer = transport.CreateUpdatedAnswer()
+
+	if answer.GetMediaByID("video").GetDirection() != sdp.SENDRECV || answer.GetTrackByMediaID("video") == nil {
+		t.Error("video track should be sent on the offered section")
+	}
+
+	// a second video track needs a new section
+	second := transport.CreateOutgoingStreamWithID("stream2", false, true)
+
+	updated := transport.CreateUpdatedOffer()
+
+	if len(updated.GetMedias()) != 3 || updated.GetTrackByMediaID("2") == nil {
+		t.Fatal("new track should get a new media section")
+	}
+
+	_, remoteAnswer, err := remote.Answer(updated, capabilities)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := transport.SetRemoteAnswer(remoteAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	// stop it and check the mid is reused by the next track
+	transport.GetTransceiver("2").Stop()
+
+	updated = transport.CreateUpdatedOffer()
+	if updated.GetMediaByID("2").GetDirection() != sdp.INACTIVE {
+		t.Error("stopped section should be inactive")
+	}
+
+	if len(second.GetTracks()) != 0 {
+		t.Error("stopping the transceiver should remove its track")
+	}
+
+	if err := transport.SetRemoteAnswer(remoteAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	transport.CreateOutgoingStreamWithID("stream3", false, true)
+
+	updated = transport.CreateUpdatedOffer()
+	if len(updated.GetMedias()) != 3 || updated.GetTrackByMediaID("2") == nil {
+		t.Error("mid of the stopped section should be reused")
+	}
+}
+
+func Test_IncomingTrackLazyUpstream(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	offer, _ := sdp.Parse(sdpStr)
+	transport := endpoint.CreateTransport(offer, nil)
+	transport.SetRemoteProperties(offer.GetMedia("audio"), offer.GetMedia("video"))
+	transport.SetLocalProperties(offer.GetMedia("audio"), offer.GetMedia("video"))
+	defer transport.Stop()
+
+	incoming := transport.CreateIncomingStream(offer.GetFirstStream())
+	track := incoming.GetVideoTracks()[0]
+
+	incoming.SetLazyUpstream(true)
+	if !track.IsLazyUpstream() || track.IsAttached() {
+		t.Fatal("wrong lazy state")
+	}
+
+	outgoing := transport.CreateOutgoingStreamTrack("video", "video", map[string]uint{})
+	outgoing.AttachTo(track)
+
+	if !track.IsAttached() {
+		t.Fatal("track not attached")
+	}
+
+	outgoing.Detach()
+
+	if track.IsAttached() {
+		t.Fatal("track still attached")
+	}
+
+	// the counter is safe to use concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			track.Attached()
+			track.Detached()
+		}()
+	}
+	wg.Wait()
+
+	if track.IsAttached() {
+		t.Fatal("unbalanced counter")
+	}
+}
+
+func Test_TransportConcurrentUse(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	offer, _ := sdp.Parse(sdpStr)
+	transport := endpoint.CreateTransport(offer, nil)
+	transport.SetRemoteProperties(offer.GetMedia("audio"), offer.GetMedia("video"))
+	transport.SetLocalProperties(offer.GetMedia("audio"), offer.GetMedia("video"))
+	defer transport.Stop()
+
+	incoming := transport.CreateIncomingStream(offer.GetFirstStream())
+	videoTrack := incoming.GetVideoTracks()[0]
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				outgoing := transport.CreateOutgoingStreamWithID(fmt.Sprintf("stream-%d-%d", g, i), true, true)
+				if outgoing == nil {
+					t.Error("can not create outgoing stream")
+					return
+				}
+				outgoing.AttachTo(incoming)
+				for _, track := range outgoing.GetVideoTracks() {
+					track.Mute(i%2 == 0)
+					track.Switch(videoTrack)
+					track.GetTransponder().SetTargetBitrate(uint(i)*100000, TraversalDefault, false)
+				}
+				outgoing.GetStats()
+				incoming.GetStats()
+				transport.GetStatsReport()
+				transport.GetICEStats()
+				outgoing.Detach()
+				transport.RemoveOutgoingStream(outgoing)
+				outgoing.Stop()
+			}
+		}(g)
+	}
+
+	// only one of the streams with the same id is created
+	created := make(chan *IncomingStream, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info := sdp.NewStreamInfo("duplicate")
+			track := sdp.NewTrackInfo("video", "video")
+			track.AddSSRC(NextSSRC())
+			info.AddTrack(track)
+			stream, err := transport.NewIncomingStream(info)
+			if err == nil {
+				created <- stream
+			} else if !errors.Is(err, ErrDuplicateStream) {
+				t.Error("unexpected error", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(created)
+
+	if len(created) != 1 {
+		t.Fatal("duplicate stream created", len(created))
+	}
+
+	if len(transport.GetOutgoingStreams()) != 0 || len(transport.GetIncomingStreams()) != 2 {
+		t.Fatal("wrong streams left")
+	}
+
+	if videoTrack.IsAttached() {
+		t.Fatal("track still attached")
+	}
+}
+
+func Test_TransportConcurrentStop(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+	defer endpoint.Stop()
+
+	offer, _ := sdp.Parse(sdpStr)
+	transport := endpoint.CreateTransport(offer, nil)
+	transport.SetRemoteProperties(offer.GetMedia("audio"), offer.GetMedia("video"))
+
+	incoming := transport.CreateIncomingStream(offer.GetFirstStream())
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				outgoing, err := transport.createOutgoingStream(sdp.NewStreamInfo(fmt.Sprintf("stream-%d-%d", g, i)))
+				if errors.Is(err, ErrStopped) {
+					return
+				}
+				if err != nil {
+					t.Error("unexpected error", err)
+					return
+				}
+				outgoing.AttachTo(incoming)
+				if _, err := transport.createIncomingStreamTrack("video", "", map[string]uint{}); err != nil && !errors.Is(err, ErrStopped) {
+					t.Error("unexpected error", err)
+				}
+				transport.GetStatsReport()
+			}
+		}(g)
+	}
+
+	for g := 0; g < 2; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transport.Stop()
+		}()
+	}
+
+	wg.Wait()
+
+	if len(transport.GetIncomingStreams()) != 0 || len(transport.GetOutgoingStreams()) != 0 {
+		t.Fatal("streams left after stop")
+	}
+
+	if transport.GetStatsReport() != nil {
+		t.Fatal("stats of a stopped transport")
+	}
+}
+
+func Test_EndpointConcurrentStop(t *testing.T) {
+
+	endpoint := NewEndpoint("127.0.0.1")
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				sdpInfo := sdp.NewSDPInfo()
+				sdpInfo.SetICE(sdp.ICEInfoGenerate(true))
+				sdpInfo.SetDTLS(sdp.NewDTLSInfo(sdp.SETUPACTPASS, "sha-256", "F2:AA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F"))
+				transport, err := endpoint.CreateTransportWithOptions(sdpInfo, nil)
+				if errors.Is(err, ErrStopped) {
+					return
+				}
+				if err != nil {
+					t.Error("unexpected error", err)
+					return
+				}
+				if i%2 == 0 {
+					transport.Stop()
+				}
+			}
+		}()
+	}
+
+	// a transport still stopping while the endpoint deletes the bundle
+	sdpInfo := sdp.NewSDPInfo()
+	sdpInfo.SetICE(sdp.ICEInfoGenerate(true))
+	sdpInfo.SetDTLS(sdp.NewDTLSInfo(sdp.SETUPACTPASS, "sha-256", "F2:AA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F"))
+	lingering, err := endpoint.CreateTransportWithOptions(sdpInfo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopping := make(chan struct{})
+	endpointStopped := make(chan struct{})
+	lingering.OnStop(func() {
+		close(stopping)
+		<-endpointStopped
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		lingering.Stop()
+	}()
+
+	<-stopping
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		endpoint.Stop()
+		close(endpointStopped)
+	}()
+
+	wg.Wait()
+
+	if len(endpoint.GetTransports()) != 0 {
+		t.Fatal("transports left after stop")
+	}
+}
+
+func Test_TransportNoNativeLeak(t *testing.T) {
+
+	EnableNativeTracking(false)
+	defer DisableNativeTracking()
+
+	endpoint := NewEndpoint("127.0.0.1")
+
+	offer, _ := sdp.Parse(sdpStr)
+	transport := endpoint.CreateTransport(offer, nil)
+	transport.SetRemoteProperties(offer.GetMedia("audio"), offer.GetMedia("video"))
+	transport.SetLocalProperties(offer.GetMedia("audio"), offer.GetMedia("video"))
+
+	incoming := transport.CreateIncomingStream(offer.GetFirstStream())
+	outgoing := transport.CreateOutgoingStreamWithID("leak", true, true)
+	outgoing.AttachTo(incoming)
+
+	counts := GetNativeObjectCounts()
+	for _, kind := range []string{"RTPBundleTransport", "DTLSICETransport", "RTPIncomingSourceGroup", "StreamTrackDepacketizer", "RTPOutgoingSourceGroup", "RTPStreamTransponderFacade"} {
+		if counts[kind] == 0 {
+			t.Fatal("native objects not tracked", kind, counts)
+		}
+	}
+
+	transport.Stop()
+	endpoint.Stop()
+
+	if GetNativeObjectCount() != 0 {
+		t.Fatal("native objects leaked", GetNativeObjectCounts())
+	}
+}
+
+func Test_CreateTransportWithOptions(t *testing.T) {
+
+	endpoint := NewEndpointWithOptions("127.0.0.1", WithIceTimeout(5*time.Second))
+	defer endpoint.Stop()
+
+	offer, _ := sdp.Parse(sdpStr)
+
+	transport, err := endpoint.CreateTransportWithOptions(offer, nil,
+		WithDisableSTUNKeepAlive(true),
+		WithSRTPProtectionProfiles(SRTPProfileAEADAES128GCM, SRTPProfileAES128CMSHA180),
+		WithBandwidthProbing(true),
+		WithMaxProbingBitrate(500000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport.Stop()
+
+	if _, err := endpoint.CreateTransportWithOptions(offer, nil, WithSRTPProtectionProfiles("SRTP_NULL_NULL")); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatal("unsupported profile accepted", err)
+	}
+
+	// the positional keep alive flag still works
+	if transport := endpoint.CreateTransport(offer, nil, true); transport == nil {
+		t.Fatal("can not create transport")
+	} else {
+		transport.Stop()
+	}
+}
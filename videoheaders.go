@@ -0,0 +1,522 @@
+package mediaserver
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errShortHeader = errors.New("header too short")
+
+// bitReader read the exp-golomb coded fields of an h264 rbsp, and the plain fields of a vp9 header
+type bitReader struct {
+	data []byte
+	pos  uint
+}
+
+func (r *bitReader) readBit() (uint, error) {
+	if r.pos >= uint(len(r.data))*8 {
+		return 0, errShortHeader
+	}
+	bit := uint(r.data[r.pos/8]>>(7-r.pos%8)) & 1
+	r.pos++
+	return bit, nil
+}
+
+func (r *bitReader) readBits(n uint) (uint, error) {
+	value := uint(0)
+	for i := uint(0); i < n; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		value = value<<1 | bit
+	}
+	return value, nil
+}
+
+// readUE unsigned exp-golomb
+func (r *bitReader) readUE() (uint, error) {
+	zeros := uint(0)
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 1 {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, errors.New("invalid exp-golomb code")
+		}
+	}
+	value, err := r.readBits(zeros)
+	if err != nil {
+		return 0, err
+	}
+	return (1 << zeros) - 1 + value, nil
+}
+
+// readSE signed exp-golomb
+func (r *bitReader) readSE() (int, error) {
+	value, err := r.readUE()
+	if err != nil {
+		return 0, err
+	}
+	if value%2 == 1 {
+		return int(value+1) / 2, nil
+	}
+	return -int(value / 2), nil
+}
+
+// h264NALUnits split an annex b or a 4 bytes length prefixed frame
+func h264NALUnits(data []byte) [][]byte {
+
+	nals := [][]byte{}
+
+	if len(data) > 3 && data[0] == 0 && data[1] == 0 && (data[2] == 1 || (data[2] == 0 && data[3] == 1)) {
+		start := -1
+		for i := 0; i+2 < len(data); i++ {
+			if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+				continue
+			}
+			if start >= 0 {
+				end := i
+				// the zero of a 4 bytes start code belongs to the next nal
+				if end > start && data[end-1] == 0 {
+					end--
+				}
+				nals = append(nals, data[start:end])
+			}
+			start = i + 3
+			i += 2
+		}
+		if start >= 0 && start < len(data) {
+			nals = append(nals, data[start:])
+		}
+		return nals
+	}
+
+	for len(data) > 4 {
+		size := int(binary.BigEndian.Uint32(data))
+		if size == 0 || size > len(data)-4 {
+			break
+		}
+		nals = append(nals, data[4:4+size])
+		data = data[4+size:]
+	}
+
+	return nals
+}
+
+// h264RBSP remove the emulation prevention bytes
+func h264RBSP(nal []byte) []byte {
+
+	rbsp := make([]byte, 0, len(nal))
+	zeros := 0
+
+	for _, b := range nal {
+		if zeros == 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		rbsp = append(rbsp, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+
+	return rbsp
+}
+
+// parseH264FrameSize get the picture size from the sps of an h264 frame
+func parseH264FrameSize(frame []byte) (int, int, error) {
+
+	for _, nal := range h264NALUnits(frame) {
+		if len(nal) > 0 && nal[0]&0x1f == 7 {
+			return parseH264SPS(nal[1:])
+		}
+	}
+
+	return 0, 0, errors.New("no sps in frame")
+}
+
+// parseH264SPS get the cropped picture size from a sps, without the nal header
+func parseH264SPS(sps []byte) (int, int, error) {
+
+	r := &bitReader{data: h264RBSP(sps)}
+
+	profile, err := r.readBits(8)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// constraint flags and level
+	r.readBits(16)
+	r.readUE() // seq_parameter_set_id
+
+	chromaFormat := uint(1)
+	separateColourPlane := uint(0)
+
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormat, _ = r.readUE()
+		if chromaFormat == 3 {
+			separateColourPlane, _ = r.readBit()
+		}
+		r.readUE()  // bit_depth_luma_minus8
+		r.readUE()  // bit_depth_chroma_minus8
+		r.readBit() // qpprime_y_zero_transform_bypass_flag
+		scalingMatrix, _ := r.readBit()
+		if scalingMatrix == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				present, err := r.readBit()
+				if err != nil {
+					return 0, 0, err
+				}
+				if present == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				last, next := 8, 8
+				for j := 0; j < size; j++ {
+					if next != 0 {
+						delta, err := r.readSE()
+						if err != nil {
+							return 0, 0, err
+						}
+						next = (last + delta + 256) % 256
+					}
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+
+	r.readUE() // log2_max_frame_num_minus4
+
+	pocType, err := r.readUE()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch pocType {
+	case 0:
+		r.readUE() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.readBit() // delta_pic_order_always_zero_flag
+		r.readSE()  // offset_for_non_ref_pic
+		r.readSE()  // offset_for_top_to_bottom_field
+		cycle, err := r.readUE()
+		if err != nil || cycle > 255 {
+			return 0, 0, errors.New("invalid sps")
+		}
+		for i := uint(0); i < cycle; i++ {
+			r.readSE()
+		}
+	}
+
+	r.readUE()  // max_num_ref_frames
+	r.readBit() // gaps_in_frame_num_value_allowed_flag
+
+	widthInMbs, _ := r.readUE()
+	heightInMapUnits, _ := r.readUE()
+
+	frameMbsOnly, err := r.readBit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if frameMbsOnly == 0 {
+		r.readBit() // mb_adaptive_frame_field_flag
+	}
+	r.readBit() // direct_8x8_inference_flag
+
+	width := int(widthInMbs+1) * 16
+	height := int(2-frameMbsOnly) * int(heightInMapUnits+1) * 16
+
+	cropping, err := r.readBit()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if cropping == 1 {
+		left, _ := r.readUE()
+		right, _ := r.readUE()
+		top, _ := r.readUE()
+		bottom, err := r.readUE()
+		if err != nil {
+			return 0, 0, err
+		}
+
+		cropX, cropY := 1, int(2-frameMbsOnly)
+		if chromaFormat != 0 && separateColourPlane == 0 {
+			if chromaFormat == 1 || chromaFormat == 2 {
+				cropX = 2
+			}
+			if chromaFormat == 1 {
+				cropY *= 2
+			}
+		}
+
+		width -= cropX * int(left+right)
+		height -= cropY * int(top+bottom)
+	}
+
+	if width <= 0 || height <= 0 {
+		return 0, 0, errors.New("invalid sps size")
+	}
+
+	return width, height, nil
+}
+
+// parseVP8FrameSize get the picture size from a vp8 key frame
+func parseVP8FrameSize(frame []byte) (int, int, error) {
+
+	if len(frame) < 10 {
+		return 0, 0, errShortHeader
+	}
+
+	if frame[0]&0x01 != 0 {
+		return 0, 0, errors.New("not a key frame")
+	}
+
+	if frame[3] != 0x9d || frame[4] != 0x01 || frame[5] != 0x2a {
+		return 0, 0, errors.New("invalid vp8 start code")
+	}
+
+	width := int(binary.LittleEndian.Uint16(frame[6:]) & 0x3fff)
+	height := int(binary.LittleEndian.Uint16(frame[8:]) & 0x3fff)
+
+	return width, height, nil
+}
+
+// vp9Frames split a vp9 superframe, a plain frame is returned as is
+func vp9Frames(data []byte) [][]byte {
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	marker := data[len(data)-1]
+	if marker&0xe0 != 0xc0 {
+		return [][]byte{data}
+	}
+
+	count := int(marker&0x07) + 1
+	bytes := int((marker>>3)&0x03) + 1
+	indexSize := 2 + bytes*count
+
+	if len(data) < indexSize || data[len(data)-indexSize] != marker {
+		return [][]byte{data}
+	}
+
+	frames := [][]byte{}
+	index := data[len(data)-indexSize+1:]
+	offset := 0
+
+	for i := 0; i < count; i++ {
+		size := 0
+		for b := 0; b < bytes; b++ {
+			size |= int(index[i*bytes+b]) << (8 * uint(b))
+		}
+		if offset+size > len(data)-indexSize {
+			break
+		}
+		frames = append(frames, data[offset:offset+size])
+		offset += size
+	}
+
+	return frames
+}
+
+// vp9References the sizes of the vp9 reference slots, set by the frames of a key picture
+type vp9References struct {
+	// keyFrame the key frame of the picture was parsed, the slots are known
+	keyFrame bool
+	sizes    [8][2]int
+}
+
+// parseVP9FrameSizes get the picture size of each spatial layer from a vp9 key picture.
+// Only the base layer is a key frame, the upper layers are sized from their inter or intra only header.
+// The sizes of the layers parsed before an invalid one are kept
+func parseVP9FrameSizes(data []byte, refs *vp9References) ([][2]int, error) {
+
+	sizes := [][2]int{}
+
+	for _, frame := range vp9Frames(data) {
+		width, height, err := parseVP9FrameSize(frame, refs)
+		if err != nil {
+			if len(sizes) > 0 {
+				break
+			}
+			return nil, err
+		}
+		sizes = append(sizes, [2]int{width, height})
+	}
+
+	if len(sizes) == 0 {
+		return nil, errShortHeader
+	}
+
+	return sizes, nil
+}
+
+// parseVP9FrameSize get the picture size from the uncompressed header of a vp9 frame.
+// The key frame sets every reference slot, the following frames of the picture use and refresh them
+func parseVP9FrameSize(frame []byte, refs *vp9References) (int, int, error) {
+
+	r := &bitReader{data: frame}
+
+	marker, err := r.readBits(2)
+	if err != nil {
+		return 0, 0, err
+	}
+	if marker != 2 {
+		return 0, 0, errors.New("invalid vp9 frame marker")
+	}
+
+	low, _ := r.readBit()
+	high, _ := r.readBit()
+	profile := high<<1 | low
+	if profile == 3 {
+		r.readBit() // reserved_zero
+	}
+
+	showExisting, _ := r.readBit()
+	if showExisting == 1 {
+		return 0, 0, errors.New("no vp9 frame header")
+	}
+
+	frameType, _ := r.readBit()
+	showFrame, _ := r.readBit()
+	errorResilient, _ := r.readBit()
+
+	if frameType == 0 {
+		if err := readVP9SyncCode(r); err != nil {
+			return 0, 0, err
+		}
+		readVP9ColorConfig(r, profile)
+		width, height, err := readVP9FrameSize(r)
+		if err != nil {
+			return 0, 0, err
+		}
+		refs.keyFrame = true
+		refs.refresh(0xff, width, height)
+		return width, height, nil
+	}
+
+	if !refs.keyFrame {
+		return 0, 0, errors.New("not a key frame")
+	}
+
+	intraOnly := uint(0)
+	if showFrame == 0 {
+		intraOnly, _ = r.readBit()
+	}
+	if errorResilient == 0 {
+		r.readBits(2) // reset_frame_context
+	}
+
+	if intraOnly == 1 {
+		if err := readVP9SyncCode(r); err != nil {
+			return 0, 0, err
+		}
+		if profile > 0 {
+			readVP9ColorConfig(r, profile)
+		}
+		flags, _ := r.readBits(8)
+		width, height, err := readVP9FrameSize(r)
+		if err != nil {
+			return 0, 0, err
+		}
+		refs.refresh(flags, width, height)
+		return width, height, nil
+	}
+
+	flags, _ := r.readBits(8)
+
+	refIdx := [3]uint{}
+	for i := range refIdx {
+		refIdx[i], _ = r.readBits(3)
+		r.readBit() // ref_frame_sign_bias
+	}
+
+	// frame_size_with_refs, the size of a reference or an explicit one
+	for i := range refIdx {
+		found, err := r.readBit()
+		if err != nil {
+			return 0, 0, err
+		}
+		if found == 1 {
+			size := refs.sizes[refIdx[i]]
+			refs.refresh(flags, size[0], size[1])
+			return size[0], size[1], nil
+		}
+	}
+
+	width, height, err := readVP9FrameSize(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	refs.refresh(flags, width, height)
+	return width, height, nil
+}
+
+// refresh set the size of the slots in the refresh_frame_flags
+func (refs *vp9References) refresh(flags uint, width int, height int) {
+	for i := range refs.sizes {
+		if flags&(1<<uint(i)) != 0 {
+			refs.sizes[i] = [2]int{width, height}
+		}
+	}
+}
+
+func readVP9SyncCode(r *bitReader) error {
+
+	sync, err := r.readBits(24)
+	if err != nil {
+		return err
+	}
+	if sync != 0x498342 {
+		return errors.New("invalid vp9 sync code")
+	}
+	return nil
+}
+
+func readVP9ColorConfig(r *bitReader, profile uint) {
+
+	if profile >= 2 {
+		r.readBit() // ten_or_twelve_bit
+	}
+	colorSpace, _ := r.readBits(3)
+	if colorSpace != 7 {
+		r.readBit() // color_range
+		if profile == 1 || profile == 3 {
+			r.readBits(3) // subsampling_x, subsampling_y, reserved_zero
+		}
+	} else if profile == 1 || profile == 3 {
+		r.readBit() // reserved_zero
+	}
+}
+
+func readVP9FrameSize(r *bitReader) (int, int, error) {
+
+	width, _ := r.readBits(16)
+	height, err := r.readBits(16)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(width) + 1, int(height) + 1, nil
+}
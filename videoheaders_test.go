@@ -0,0 +1,241 @@
+package mediaserver
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseH264FrameSize(t *testing.T) {
+
+	width, height, err := parseH264FrameSize(blackH264Frame(20, 15, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 320 || height != 240 {
+		t.Fatal("wrong size", width, height)
+	}
+
+	// 1280x720 high profile sps, cropped from 1280x736
+	sps := []byte{0x64, 0x00, 0x1f, 0xac, 0xd9, 0x40, 0x50, 0x05, 0xbb, 0x01, 0x10, 0x00, 0x00, 0x03, 0x00, 0x10, 0x00, 0x00, 0x03, 0x03, 0xc0, 0xf1, 0x83, 0x19, 0x60}
+	frame := append([]byte{0, 0, 0, byte(len(sps) + 1), 0x67}, sps...)
+
+	width, height, err = parseH264FrameSize(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 1280 || height != 720 {
+		t.Fatal("wrong size", width, height)
+	}
+
+	if _, _, err := parseH264FrameSize([]byte{0, 0, 0, 1, 0x65, 0x88}); err == nil {
+		t.Fatal("size of a frame without sps")
+	}
+}
+
+func Test_ParseVP8FrameSize(t *testing.T) {
+
+	frame := []byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0x80, 0x02, 0xe0, 0x01}
+
+	width, height, err := parseVP8FrameSize(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 640 || height != 480 {
+		t.Fatal("wrong size", width, height)
+	}
+
+	frame[0] |= 0x01
+	if _, _, err := parseVP8FrameSize(frame); err == nil {
+		t.Fatal("size of a delta frame")
+	}
+}
+
+func vp9KeyFrame(width uint, height uint) []byte {
+
+	w := &bitWriter{}
+	w.writeBits(2, 2)         // frame_marker
+	w.writeBits(0, 2)         // profile 0
+	w.writeBit(0)             // show_existing_frame
+	w.writeBit(0)             // key frame
+	w.writeBit(1)             // show_frame
+	w.writeBit(0)             // error_resilient_mode
+	w.writeBits(0x498342, 24) // sync code
+	w.writeBits(1, 3)         // color_space
+	w.writeBit(0)             // color_range
+	w.writeBits(width-1, 16)
+	w.writeBits(height-1, 16)
+	w.writeTrailingBits()
+
+	return w.data
+}
+
+// vp9InterFrame an upper spatial layer frame of a key picture, sized explicitly or from the reference slot refIdx
+func vp9InterFrame(width uint, height uint, showFrame uint, refreshFlags uint, refIdx uint, found bool) []byte {
+
+	w := &bitWriter{}
+	w.writeBits(2, 2) // frame_marker
+	w.writeBits(0, 2) // profile 0
+	w.writeBit(0)     // show_existing_frame
+	w.writeBit(1)     // inter frame
+	w.writeBit(showFrame)
+	w.writeBit(0) // error_resilient_mode
+	if showFrame == 0 {
+		w.writeBit(0) // intra_only
+	}
+	w.writeBits(0, 2) // reset_frame_context
+	w.writeBits(refreshFlags, 8)
+	for i := 0; i < 3; i++ {
+		w.writeBits(refIdx, 3) // ref_frame_idx
+		w.writeBit(0)          // ref_frame_sign_bias
+	}
+	if found {
+		w.writeBit(1) // found_ref
+	} else {
+		w.writeBits(0, 3) // found_ref
+		w.writeBits(width-1, 16)
+		w.writeBits(height-1, 16)
+	}
+	w.writeBit(0) // render_and_frame_size_different
+	w.writeTrailingBits()
+
+	return w.data
+}
+
+// vp9Superframe join the frames of a picture with 1 byte sizes
+func vp9Superframe(frames ...[]byte) []byte {
+
+	marker := byte(0xc0 | (len(frames) - 1))
+	superframe := []byte{}
+	for _, frame := range frames {
+		superframe = append(superframe, frame...)
+	}
+	superframe = append(superframe, marker)
+	for _, frame := range frames {
+		superframe = append(superframe, byte(len(frame)))
+	}
+	return append(superframe, marker)
+}
+
+// vp9KeyPicture three spatial layers, only the base one is a key frame, each layer references the one below
+func vp9KeyPicture() [][]byte {
+	return [][]byte{
+		vp9KeyFrame(320, 180),
+		vp9InterFrame(640, 360, 0, 0x02, 0, false),
+		vp9InterFrame(1280, 720, 1, 0x04, 1, false),
+	}
+}
+
+func Test_ParseVP9FrameSizes(t *testing.T) {
+
+	sizes, err := parseVP9FrameSizes(vp9KeyFrame(1280, 720), &vp9References{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 1 || sizes[0] != [2]int{1280, 720} {
+		t.Fatal("wrong sizes", sizes)
+	}
+
+	sizes, err = parseVP9FrameSizes(vp9Superframe(vp9KeyPicture()...), &vp9References{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != [2]int{320, 180} || sizes[1] != [2]int{640, 360} || sizes[2] != [2]int{1280, 720} {
+		t.Fatal("wrong sizes", sizes)
+	}
+
+	// a quality layer, sized from the slot refreshed by the layer below
+	layers := vp9KeyPicture()[:2]
+	layers = append(layers, vp9InterFrame(0, 0, 1, 0x04, 1, true))
+	sizes, err = parseVP9FrameSizes(vp9Superframe(layers...), &vp9References{})
+	if err != nil || len(sizes) != 3 || sizes[2] != [2]int{640, 360} {
+		t.Fatal("wrong sizes", sizes, err)
+	}
+
+	// the base size is kept when an upper layer can not be parsed
+	layers = vp9KeyPicture()
+	layers[1] = layers[1][:2]
+	sizes, err = parseVP9FrameSizes(vp9Superframe(layers...), &vp9References{})
+	if err != nil || len(sizes) != 1 || sizes[0] != [2]int{320, 180} {
+		t.Fatal("base size not kept", sizes, err)
+	}
+
+	// an inter picture has no key frame to start from
+	if _, err := parseVP9FrameSizes(vp9InterFrame(640, 360, 1, 0x01, 0, false), &vp9References{}); err == nil {
+		t.Fatal("size of an inter picture")
+	}
+}
+
+func Test_EncodingInfoTrackerVP9Layers(t *testing.T) {
+
+	tracker := &encodingInfoTracker{}
+
+	// the layer frames of the key picture arrive apart, with the same timestamp
+	for i, frame := range vp9KeyPicture() {
+		tracker.onFrame(videoCodecVP9, 3000, i == 0, frame)
+	}
+
+	info := tracker.getInfo()
+
+	if len(info.spatialSizes) != 3 || info.spatialSizes[0] != [2]int{320, 180} || info.spatialSizes[2] != [2]int{1280, 720} {
+		t.Fatal("wrong sizes", info.spatialSizes)
+	}
+	if info.width != 1280 || info.height != 720 {
+		t.Fatal("wrong size", info.width, info.height)
+	}
+
+	// the next pictures are not sized
+	tracker.onFrame(videoCodecVP9, 6000, false, vp9InterFrame(1280, 720, 1, 0x04, 2, true))
+	tracker.onFrame(videoCodecVP9, 6000, false, vp9InterFrame(640, 360, 1, 0x04, 2, false))
+
+	if info := tracker.getInfo(); len(info.spatialSizes) != 3 || info.width != 1280 {
+		t.Fatal("sized from an inter picture", info.spatialSizes)
+	}
+}
+
+func Test_EncodingInfoTracker(t *testing.T) {
+
+	tracker := &encodingInfoTracker{}
+	keyFrame := blackH264Frame(20, 15, 0)
+
+	// 30 fps for 5 seconds, a key frame every 2 seconds
+	for i := 0; i <= 150; i++ {
+		ts := uint32(i * videoClockRate / 30)
+		if i%60 == 0 {
+			tracker.onFrame(videoCodecH264, ts, true, keyFrame)
+		} else {
+			tracker.onFrame(videoCodecH264, ts, false, nil)
+		}
+	}
+
+	info := tracker.getInfo()
+
+	if info.width != 320 || info.height != 240 {
+		t.Fatal("wrong size", info.width, info.height)
+	}
+	if info.frameRate < 29.5 || info.frameRate > 30.5 {
+		t.Fatal("wrong frame rate", info.frameRate)
+	}
+	if info.keyFrameInterval != 2*time.Second {
+		t.Fatal("wrong key frame interval", info.keyFrameInterval)
+	}
+}
+
+func Test_EncodingInfoLayerInfo(t *testing.T) {
+
+	info := encodingInfo{
+		width:        1280,
+		height:       720,
+		frameRate:    30,
+		spatialSizes: [][2]int{{320, 180}, {640, 360}, {1280, 720}},
+	}
+
+	width, height, frameRate := info.layerInfo(1, 0, 2)
+	if width != 640 || height != 360 || frameRate != 7.5 {
+		t.Fatal("wrong layer info", width, height, frameRate)
+	}
+
+	width, height, frameRate = info.layerInfo(MaxLayerId, MaxLayerId, 2)
+	if width != 1280 || height != 720 || frameRate != 30 {
+		t.Fatal("wrong layer info", width, height, frameRate)
+	}
+}
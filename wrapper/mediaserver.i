@@ -20,6 +20,7 @@
 #include "../media-server/include/rtp/RTPStreamTransponder.h"
 #include "../media-server/include/ActiveSpeakerDetector.h"
 #include "../media-server/include/EventLoop.h"
+#include "mediaserver_log.h"
 
 
 using RTPBundleTransportConnection = RTPBundleTransport::Connection;
@@ -63,12 +64,14 @@ public:
 		//Enable log
 		Log("-EnableLog [%d]\n",flag);
 		Logger::EnableLog(flag);
+		LogEnableLog(flag);
 	}
 	
 	static void EnableDebug(bool flag)
 	{
 		//Enable debug
 		Logger::EnableDebug(flag);
+		LogEnableDebug(flag);
 	}
 	
 	static void EnableUltraDebug(bool flag)
@@ -76,6 +79,7 @@ public:
 		//Enable debug
 		Log("-EnableUltraDebug [%d]\n",flag);
 		Logger::EnableUltraDebug(flag);
+		LogEnableUltraDebug(flag);
 	}
 	
 	static bool SetPortRange(int minPort, int maxPort)
@@ -196,6 +200,41 @@ public:
 };
 
 
+class KeyFrameRequestListener {
+public:
+	KeyFrameRequestListener()
+	{
+
+	}
+	virtual ~KeyFrameRequestListener() {
+
+	}
+	virtual void onKeyFrameRequest(uint32_t ssrc){
+
+	}
+};
+
+//Receiver given to the transponders, so key frame requests of the subscribers go through the listener
+class KeyFrameRequestReceiver :
+	public RTPReceiver
+{
+public:
+	KeyFrameRequestReceiver(KeyFrameRequestListener* listener)
+	{
+		this->listener = listener;
+	}
+
+	virtual int SendPLI(DWORD ssrc) override
+	{
+		if (listener)
+			listener->onKeyFrameRequest(ssrc);
+		return 1;
+	}
+private:
+	KeyFrameRequestListener* listener;
+};
+
+
 
 
 class MediaFrameSessionFacade :
@@ -400,6 +439,7 @@ public:
 	RTPReceiverFacade(DTLSICETransport* transport)
 	{
 		receiver = transport;
+		this->transport = transport;
 	}
 
 	RTPReceiverFacade(RTPSessionFacade* session)
@@ -412,15 +452,59 @@ public:
         receiver = session;
     }
 
+	RTPReceiverFacade(KeyFrameRequestListener* listener)
+	{
+		proxy.reset(new KeyFrameRequestReceiver(listener));
+		receiver = proxy.get();
+	}
+
 
 	int SendPLI(DWORD ssrc)
 	{
 		return receiver ? receiver->SendPLI(ssrc) : 0;
 	}
+
+	int SendFIR(DWORD ssrc, BYTE seq)
+	{
+		//Only transports can send a fir, fallback to pli
+		if (!transport)
+			return SendPLI(ssrc);
+
+		auto rtcp = RTCPCompoundPacket::Create();
+		auto fb = RTCPPayloadFeedback::Create(RTCPPayloadFeedback::FullIntraRequest,0,0);
+		fb->AddField(std::make_shared<RTCPPayloadFeedback::FullIntraRequestField>(ssrc,seq));
+		rtcp->AddPacket(fb);
+
+		return transport->Send(rtcp);
+	}
+
+	int SendMaxBitrate(DWORD ssrc, DWORD bitrate, bool tmmbr)
+	{
+		//Only transports have rtcp feedback towards the sender
+		if (!transport)
+			return 0;
+
+		auto rtcp = RTCPCompoundPacket::Create();
+
+		if (tmmbr)
+		{
+			auto fb = RTCPRTPFeedback::Create(RTCPRTPFeedback::TempMaxMediaStreamBitrateRequest,0,ssrc);
+			fb->AddField(std::make_shared<RTCPRTPFeedback::TempMaxMediaStreamBitrateField>(ssrc,bitrate,0));
+			rtcp->AddPacket(fb);
+		} else {
+			auto fb = RTCPPayloadFeedback::Create(RTCPPayloadFeedback::ApplicationLayerFeeedbackMessage,0,0);
+			fb->AddField(RTCPPayloadFeedback::ApplicationLayerFeeedbackField::CreateReceiverEstimatedMaxBitrate({ssrc},bitrate));
+			rtcp->AddPacket(fb);
+		}
+
+		return transport->Send(rtcp);
+	}
 	
 	RTPReceiver* get() { return receiver;}
 private:
 	RTPReceiver* receiver;
+	DTLSICETransport* transport = nullptr;
+	std::unique_ptr<KeyFrameRequestReceiver> proxy;
 };
 
 
@@ -449,6 +533,11 @@ RTPReceiverFacade* RTPSessionToReceiver(MediaFrameSessionFacade* session)
 	return new RTPReceiverFacade(session);
 }
 
+RTPReceiverFacade* KeyFrameRequestToReceiver(KeyFrameRequestListener* listener)
+{
+	return new RTPReceiverFacade(listener);
+}
+
 
 
 
@@ -620,8 +709,7 @@ public:
 
  	virtual void onRemoteICECandidateActivated(const std::string& ip, uint16_t port, uint32_t priority) override
  	{
-
- 	    // todo
+ 		onICECandidateActivated(ip, port, priority);
  	}
 
  	virtual void onDTLSStateChanged(const DTLSICETransport::DTLSState state) override 
@@ -651,6 +739,11 @@ public:
 	{
 
 	}
+
+	virtual void onICECandidateActivated(const std::string& ip, uint32_t port, uint32_t priority)
+	{
+
+	}
 };
 
 
@@ -829,6 +922,48 @@ public:
 	
 };
 
+bool MediaFrameIsKeyFrame(const MediaFrame* frame)
+{
+	return frame && frame->GetType()==MediaFrame::Video && ((const VideoFrame*)frame)->IsIntra();
+}
+
+int MediaFrameGetLength(const MediaFrame* frame)
+{
+	return frame ? frame->GetLength() : 0;
+}
+
+int MediaFrameCopyData(const MediaFrame* frame, uint8_t* data, int size)
+{
+	if (!frame || size<=0)
+		return 0;
+	int len = std::min((int)frame->GetLength(), size);
+	memcpy(data, frame->GetData(), len);
+	return len;
+}
+
+uint32_t MediaFrameGetTimestamp(const MediaFrame* frame)
+{
+	return frame ? (uint32_t)frame->GetTimeStamp() : 0;
+}
+
+//1 h264, 2 vp8, 3 vp9, 0 others
+int MediaFrameGetVideoCodec(const MediaFrame* frame)
+{
+	if (!frame || frame->GetType()!=MediaFrame::Video)
+		return 0;
+	switch(((const VideoFrame*)frame)->GetCodec())
+	{
+		case VideoCodec::H264:
+			return 1;
+		case VideoCodec::VP8:
+			return 2;
+		case VideoCodec::VP9:
+			return 3;
+		default:
+			return 0;
+	}
+}
+
 
 class MediaFrameMultiplexer :
 	public RTPIncomingMediaStream::Listener
@@ -944,6 +1079,8 @@ private:
 %feature("director") SenderSideEstimatorListener;
 %feature("director") MediaFrameListenerFacade;
 %feature("director") ActiveTrackListener;
+%feature("director") KeyFrameRequestListener;
+%feature("director") LogListener;
 %feature("director") DTLSICETransportListener;
 
 
@@ -1188,6 +1325,7 @@ public:
 	virtual ~DTLSICETransportListener() {};
 	// swig does not support inter class
 	virtual void onDTLSStateChange(uint32_t state);
+	virtual void onICECandidateActivated(const std::string& ip, uint32_t port, uint32_t priority);
 };
 
 
@@ -1258,6 +1396,8 @@ public:
 	int SetLocalPort(int recvPort);
 	int GetLocalPort();
 	int SetRemotePort(char *ip,int sendPort);
+	int SetLocalCryptoSDES(const char* suite, const char* key64);
+	int SetRemoteCryptoSDES(const char* suite, const char* key64);
 	RTPOutgoingSourceGroup* GetOutgoingSourceGroup();
 	RTPIncomingSourceGroup* GetIncomingSourceGroup();
 	int End();
@@ -1282,6 +1422,8 @@ public:
 	RTPReceiverFacade(RTPSessionFacade* session);
 	RTPReceiver* get();
 	int SendPLI(DWORD ssrc);
+	int SendFIR(DWORD ssrc, BYTE seq);
+	int SendMaxBitrate(DWORD ssrc, DWORD bitrate, bool tmmbr);
 };
 
 
@@ -1290,6 +1432,7 @@ RTPReceiverFacade*	TransportToReceiver(DTLSICETransport* transport);
 RTPSenderFacade*	SessionToSender(RTPSessionFacade* session);
 RTPReceiverFacade*	SessionToReceiver(RTPSessionFacade* session);
 RTPReceiverFacade*  RTPSessionToReceiver(MediaFrameSessionFacade* session);
+RTPReceiverFacade*  KeyFrameRequestToReceiver(KeyFrameRequestListener* listener);
 
 
 class RTPStreamTransponderFacade 
@@ -1385,6 +1528,12 @@ public:
 	virtual void onMediaFrame(const MediaFrame &frame);
 };
 
+bool MediaFrameIsKeyFrame(const MediaFrame* frame);
+int MediaFrameGetLength(const MediaFrame* frame);
+int MediaFrameCopyData(const MediaFrame* frame, uint8_t* data, int size);
+uint32_t MediaFrameGetTimestamp(const MediaFrame* frame);
+int MediaFrameGetVideoCodec(const MediaFrame* frame);
+
 
 class MediaFrameMultiplexer
 {
@@ -1403,6 +1552,22 @@ public:
 	virtual void onActiveTrackchanged(uint32_t id);
 };
 
+class KeyFrameRequestListener {
+public:
+	KeyFrameRequestListener();
+	virtual ~KeyFrameRequestListener() {}
+	virtual void onKeyFrameRequest(uint32_t ssrc);
+};
+
+class LogListener {
+public:
+	LogListener();
+	virtual ~LogListener() {}
+	virtual void onLog(const std::string& line);
+};
+
+void SetLogListener(LogListener* listener);
+
 
 
 
@@ -0,0 +1,137 @@
+//The Log, Debug, UltraDebug, Warning and Error functions of the media server are inline functions of its log.h.
+//They are variadic, so never inlined, and every call goes through a weak symbol. They are defined again here,
+//not inline, so the linker uses these ones and the log lines of the media server go to the LogListener.
+//This file must not include log.h, directly or through the other headers of the media server.
+#include <atomic>
+#include <cstdarg>
+#include <cstdio>
+#include <string>
+#include <vector>
+#include <pthread.h>
+#include <sys/time.h>
+#include <time.h>
+#include "mediaserver_log.h"
+
+static std::atomic<LogListener*> logListener(nullptr);
+static std::atomic<bool> logEnabled(true);
+static std::atomic<bool> debugEnabled(false);
+static std::atomic<bool> ultraDebugEnabled(false);
+
+void SetLogListener(LogListener* listener)
+{
+	logListener = listener;
+}
+
+void LogEnableLog(bool flag)
+{
+	logEnabled = flag;
+}
+
+void LogEnableDebug(bool flag)
+{
+	debugEnabled = flag;
+}
+
+void LogEnableUltraDebug(bool flag)
+{
+	ultraDebugEnabled = flag;
+}
+
+static int LogLines(const char* level,const char* msg,va_list ap)
+{
+	//Thread, time and level, like the media server
+	struct timeval tv;
+	struct tm tm;
+	char datetime[32];
+	char prefix[96];
+	gettimeofday(&tv,nullptr);
+	localtime_r(&tv.tv_sec,&tm);
+	strftime(datetime,sizeof(datetime),"%Y%m%d %H:%M:%S",&tm);
+	snprintf(prefix,sizeof(prefix),"[0x%lx][%s.%03ld][%s]",(long)pthread_self(),datetime,(long)tv.tv_usec/1000,level);
+
+	LogListener* listener = logListener;
+
+	if (!listener)
+	{
+		fputs(prefix,stdout);
+		vfprintf(stdout,msg,ap);
+		fflush(stdout);
+		return 1;
+	}
+
+	va_list copy;
+	va_copy(copy,ap);
+	int len = vsnprintf(nullptr,0,msg,copy);
+	va_end(copy);
+
+	if (len<0)
+		return 0;
+
+	std::vector<char> buffer(len+1);
+	vsnprintf(buffer.data(),buffer.size(),msg,ap);
+
+	//One call per line, each one with the prefix so its level is known
+	std::string message(buffer.data(),len);
+	size_t start = 0;
+	while (start<message.size())
+	{
+		size_t end = message.find('\n',start);
+		if (end==std::string::npos)
+			end = message.size();
+		if (end>start)
+			listener->onLog(prefix+message.substr(start,end-start));
+		start = end+1;
+	}
+	return 1;
+}
+
+int Log(const char* msg, ...)
+{
+	if (!logEnabled)
+		return 0;
+	va_list ap;
+	va_start(ap,msg);
+	int ret = LogLines("LOG",msg,ap);
+	va_end(ap);
+	return ret;
+}
+
+int Debug(const char* msg, ...)
+{
+	if (!debugEnabled)
+		return 0;
+	va_list ap;
+	va_start(ap,msg);
+	int ret = LogLines("DBG",msg,ap);
+	va_end(ap);
+	return ret;
+}
+
+int UltraDebug(const char* msg, ...)
+{
+	if (!ultraDebugEnabled)
+		return 0;
+	va_list ap;
+	va_start(ap,msg);
+	int ret = LogLines("UDBG",msg,ap);
+	va_end(ap);
+	return ret;
+}
+
+int Warning(const char* msg, ...)
+{
+	va_list ap;
+	va_start(ap,msg);
+	int ret = LogLines("WRN",msg,ap);
+	va_end(ap);
+	return ret;
+}
+
+int Error(const char* msg, ...)
+{
+	va_list ap;
+	va_start(ap,msg);
+	int ret = LogLines("ERR",msg,ap);
+	va_end(ap);
+	return ret;
+}
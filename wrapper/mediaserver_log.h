@@ -0,0 +1,29 @@
+#ifndef MEDIASERVER_LOG_H
+#define MEDIASERVER_LOG_H
+
+#include <string>
+
+//Listener of the media server log lines, implemented in go
+class LogListener {
+public:
+	LogListener()
+	{
+
+	}
+	virtual ~LogListener() {
+
+	}
+	virtual void onLog(const std::string& line){
+
+	}
+};
+
+//Give the log lines to the listener instead of printing them to stdout, nullptr to print them again
+void SetLogListener(LogListener* listener);
+
+//What is logged, set along with the Logger of the media server
+void LogEnableLog(bool flag);
+void LogEnableDebug(bool flag);
+void LogEnableUltraDebug(bool flag);
+
+#endif
@@ -365,6 +365,7 @@ static void* Swig_malloc(int c) {
 #include "../media-server/include/rtp/RTPStreamTransponder.h"
 #include "../media-server/include/ActiveSpeakerDetector.h"
 #include "../media-server/include/EventLoop.h"
+#include "mediaserver_log.h"
 
 
 using RTPBundleTransportConnection = RTPBundleTransport::Connection;
@@ -408,12 +409,14 @@ public:
 		//Enable log
 		Log("-EnableLog [%d]\n",flag);
 		Logger::EnableLog(flag);
+		LogEnableLog(flag);
 	}
 	
 	static void EnableDebug(bool flag)
 	{
 		//Enable debug
 		Logger::EnableDebug(flag);
+		LogEnableDebug(flag);
 	}
 	
 	static void EnableUltraDebug(bool flag)
@@ -421,6 +424,7 @@ public:
 		//Enable debug
 		Log("-EnableUltraDebug [%d]\n",flag);
 		Logger::EnableUltraDebug(flag);
+		LogEnableUltraDebug(flag);
 	}
 	
 	static bool SetPortRange(int minPort, int maxPort)
@@ -541,6 +545,41 @@ public:
 };
 
 
+class KeyFrameRequestListener {
+public:
+	KeyFrameRequestListener()
+	{
+
+	}
+	virtual ~KeyFrameRequestListener() {
+
+	}
+	virtual void onKeyFrameRequest(uint32_t ssrc){
+
+	}
+};
+
+//Receiver given to the transponders, so key frame requests of the subscribers go through the listener
+class KeyFrameRequestReceiver :
+	public RTPReceiver
+{
+public:
+	KeyFrameRequestReceiver(KeyFrameRequestListener* listener)
+	{
+		this->listener = listener;
+	}
+
+	virtual int SendPLI(DWORD ssrc) override
+	{
+		if (listener)
+			listener->onKeyFrameRequest(ssrc);
+		return 1;
+	}
+private:
+	KeyFrameRequestListener* listener;
+};
+
+
 
 
 class MediaFrameSessionFacade :
@@ -745,6 +784,7 @@ public:
 	RTPReceiverFacade(DTLSICETransport* transport)
 	{
 		receiver = transport;
+		this->transport = transport;
 	}
 
 	RTPReceiverFacade(RTPSessionFacade* session)
@@ -757,15 +797,59 @@ public:
         receiver = session;
     }
 
+	RTPReceiverFacade(KeyFrameRequestListener* listener)
+	{
+		proxy.reset(new KeyFrameRequestReceiver(listener));
+		receiver = proxy.get();
+	}
+
 
 	int SendPLI(DWORD ssrc)
 	{
 		return receiver ? receiver->SendPLI(ssrc) : 0;
 	}
+
+	int SendFIR(DWORD ssrc, BYTE seq)
+	{
+		//Only transports can send a fir, fallback to pli
+		if (!transport)
+			return SendPLI(ssrc);
+
+		auto rtcp = RTCPCompoundPacket::Create();
+		auto fb = RTCPPayloadFeedback::Create(RTCPPayloadFeedback::FullIntraRequest,0,0);
+		fb->AddField(std::make_shared<RTCPPayloadFeedback::FullIntraRequestField>(ssrc,seq));
+		rtcp->AddPacket(fb);
+
+		return transport->Send(rtcp);
+	}
+
+	int SendMaxBitrate(DWORD ssrc, DWORD bitrate, bool tmmbr)
+	{
+		//Only transports have rtcp feedback towards the sender
+		if (!transport)
+			return 0;
+
+		auto rtcp = RTCPCompoundPacket::Create();
+
+		if (tmmbr)
+		{
+			auto fb = RTCPRTPFeedback::Create(RTCPRTPFeedback::TempMaxMediaStreamBitrateRequest,0,ssrc);
+			fb->AddField(std::make_shared<RTCPRTPFeedback::TempMaxMediaStreamBitrateField>(ssrc,bitrate,0));
+			rtcp->AddPacket(fb);
+		} else {
+			auto fb = RTCPPayloadFeedback::Create(RTCPPayloadFeedback::ApplicationLayerFeeedbackMessage,0,0);
+			fb->AddField(RTCPPayloadFeedback::ApplicationLayerFeeedbackField::CreateReceiverEstimatedMaxBitrate({ssrc},bitrate));
+			rtcp->AddPacket(fb);
+		}
+
+		return transport->Send(rtcp);
+	}
 	
 	RTPReceiver* get() { return receiver;}
 private:
 	RTPReceiver* receiver;
+	DTLSICETransport* transport = nullptr;
+	std::unique_ptr<KeyFrameRequestReceiver> proxy;
 };
 
 
@@ -794,6 +878,11 @@ RTPReceiverFacade* RTPSessionToReceiver(MediaFrameSessionFacade* session)
 	return new RTPReceiverFacade(session);
 }
 
+RTPReceiverFacade* KeyFrameRequestToReceiver(KeyFrameRequestListener* listener)
+{
+	return new RTPReceiverFacade(listener);
+}
+
 
 
 
@@ -965,8 +1054,7 @@ public:
 
  	virtual void onRemoteICECandidateActivated(const std::string& ip, uint16_t port, uint32_t priority) override
  	{
-
- 	    // todo
+ 		onICECandidateActivated(ip, port, priority);
  	}
 
  	virtual void onDTLSStateChanged(const DTLSICETransport::DTLSState state) override 
@@ -996,6 +1084,11 @@ public:
 	{
 
 	}
+
+	virtual void onICECandidateActivated(const std::string& ip, uint32_t port, uint32_t priority)
+	{
+
+	}
 };
 
 
@@ -1174,6 +1267,48 @@ public:
 	
 };
 
+bool MediaFrameIsKeyFrame(const MediaFrame* frame)
+{
+	return frame && frame->GetType()==MediaFrame::Video && ((const VideoFrame*)frame)->IsIntra();
+}
+
+int MediaFrameGetLength(const MediaFrame* frame)
+{
+	return frame ? frame->GetLength() : 0;
+}
+
+int MediaFrameCopyData(const MediaFrame* frame, uint8_t* data, int size)
+{
+	if (!frame || size<=0)
+		return 0;
+	int len = std::min((int)frame->GetLength(), size);
+	memcpy(data, frame->GetData(), len);
+	return len;
+}
+
+uint32_t MediaFrameGetTimestamp(const MediaFrame* frame)
+{
+	return frame ? (uint32_t)frame->GetTimeStamp() : 0;
+}
+
+//1 h264, 2 vp8, 3 vp9, 0 others
+int MediaFrameGetVideoCodec(const MediaFrame* frame)
+{
+	if (!frame || frame->GetType()!=MediaFrame::Video)
+		return 0;
+	switch(((const VideoFrame*)frame)->GetCodec())
+	{
+		case VideoCodec::H264:
+			return 1;
+		case VideoCodec::VP8:
+			return 2;
+		case VideoCodec::VP9:
+			return 3;
+		default:
+			return 0;
+	}
+}
+
 
 class MediaFrameMultiplexer :
 	public RTPIncomingMediaStream::Listener
@@ -1333,6 +1468,18 @@ void SwigDirector_DTLSICETransportListener::onDTLSStateChange(uint32_t state) {
   Swig_DirectorDTLSICETransportListener_callback_onDTLSStateChange_native_3e8e6202ec41eede(go_val, swig_arg2);
 }
 
+extern "C" void Swig_DirectorDTLSICETransportListener_callback_onICECandidateActivated_native_3e8e6202ec41eede(int, _gostring_ arg2, intgo arg3, intgo arg4);
+void SwigDirector_DTLSICETransportListener::onICECandidateActivated(std::string const &ip, uint32_t port, uint32_t priority) {
+  _gostring_ swig_arg2;
+  intgo swig_arg3;
+  intgo swig_arg4;
+  
+  swig_arg2 = Swig_AllocateString((&ip)->data(), (&ip)->length()); 
+  swig_arg3 = (uint32_t)port; 
+  swig_arg4 = (uint32_t)priority; 
+  Swig_DirectorDTLSICETransportListener_callback_onICECandidateActivated_native_3e8e6202ec41eede(go_val, swig_arg2, swig_arg3, swig_arg4);
+}
+
 SwigDirector_SenderSideEstimatorListener::SwigDirector_SenderSideEstimatorListener(int swig_p)
     : SenderSideEstimatorListener(),
       go_val(swig_p), swig_mem(0)
@@ -1385,6 +1532,46 @@ void SwigDirector_ActiveTrackListener::onActiveTrackchanged(uint32_t id) {
   Swig_DirectorActiveTrackListener_callback_onActiveTrackchanged_native_3e8e6202ec41eede(go_val, swig_arg2);
 }
 
+SwigDirector_KeyFrameRequestListener::SwigDirector_KeyFrameRequestListener(int swig_p)
+    : KeyFrameRequestListener(),
+      go_val(swig_p), swig_mem(0)
+{ }
+
+extern "C" void Swiggo_DeleteDirector_KeyFrameRequestListener_native_3e8e6202ec41eede(intgo);
+SwigDirector_KeyFrameRequestListener::~SwigDirector_KeyFrameRequestListener()
+{
+  Swiggo_DeleteDirector_KeyFrameRequestListener_native_3e8e6202ec41eede(go_val);
+  delete swig_mem;
+}
+
+extern "C" void Swig_DirectorKeyFrameRequestListener_callback_onKeyFrameRequest_native_3e8e6202ec41eede(int, intgo arg2);
+void SwigDirector_KeyFrameRequestListener::onKeyFrameRequest(uint32_t ssrc) {
+  intgo swig_arg2;
+  
+  swig_arg2 = (uint32_t)ssrc; 
+  Swig_DirectorKeyFrameRequestListener_callback_onKeyFrameRequest_native_3e8e6202ec41eede(go_val, swig_arg2);
+}
+
+SwigDirector_LogListener::SwigDirector_LogListener(int swig_p)
+    : LogListener(),
+      go_val(swig_p), swig_mem(0)
+{ }
+
+extern "C" void Swiggo_DeleteDirector_LogListener_native_3e8e6202ec41eede(intgo);
+SwigDirector_LogListener::~SwigDirector_LogListener()
+{
+  Swiggo_DeleteDirector_LogListener_native_3e8e6202ec41eede(go_val);
+  delete swig_mem;
+}
+
+extern "C" void Swig_DirectorLogListener_callback_onLog_native_3e8e6202ec41eede(int, _gostring_ arg2);
+void SwigDirector_LogListener::onLog(std::string const &line) {
+  _gostring_ swig_arg2;
+  
+  swig_arg2 = Swig_AllocateString((&line)->data(), (&line)->length()); 
+  Swig_DirectorLogListener_callback_onLog_native_3e8e6202ec41eede(go_val, swig_arg2);
+}
+
 #ifdef __cplusplus
 extern "C" {
 #endif
@@ -4294,6 +4481,25 @@ void _wrap__swig_DirectorDTLSICETransportListener_upcall_OnDTLSStateChange_nativ
 }
 
 
+void _wrap__swig_DirectorDTLSICETransportListener_upcall_OnICECandidateActivated_native_3e8e6202ec41eede(SwigDirector_DTLSICETransportListener *_swig_go_0, _gostring_ _swig_go_1, intgo _swig_go_2, intgo _swig_go_3) {
+  SwigDirector_DTLSICETransportListener *arg1 = (SwigDirector_DTLSICETransportListener *) 0 ;
+  std::string *arg2 = 0 ;
+  uint32_t arg3 ;
+  uint32_t arg4 ;
+  
+  arg1 = *(SwigDirector_DTLSICETransportListener **)&_swig_go_0; 
+  
+  std::string arg2_str(_swig_go_1.p, _swig_go_1.n);
+  arg2 = &arg2_str;
+  
+  arg3 = (uint32_t)_swig_go_2; 
+  arg4 = (uint32_t)_swig_go_3; 
+  
+  arg1->_swig_upcall_onICECandidateActivated((std::string const &)*arg2,arg3,arg4);
+  
+}
+
+
 DTLSICETransportListener *_wrap_new_DTLSICETransportListener_native_3e8e6202ec41eede() {
   DTLSICETransportListener *result = 0 ;
   DTLSICETransportListener *_swig_go_result;
@@ -4327,6 +4533,25 @@ void _wrap_DTLSICETransportListener_onDTLSStateChange_native_3e8e6202ec41eede(DT
 }
 
 
+void _wrap_DTLSICETransportListener_onICECandidateActivated_native_3e8e6202ec41eede(DTLSICETransportListener *_swig_go_0, _gostring_ _swig_go_1, intgo _swig_go_2, intgo _swig_go_3) {
+  DTLSICETransportListener *arg1 = (DTLSICETransportListener *) 0 ;
+  std::string *arg2 = 0 ;
+  uint32_t arg3 ;
+  uint32_t arg4 ;
+  
+  arg1 = *(DTLSICETransportListener **)&_swig_go_0; 
+  
+  std::string arg2_str(_swig_go_1.p, _swig_go_1.n);
+  arg2 = &arg2_str;
+  
+  arg3 = (uint32_t)_swig_go_2; 
+  arg4 = (uint32_t)_swig_go_3; 
+  
+  (arg1)->onICECandidateActivated((std::string const &)*arg2,arg3,arg4);
+  
+}
+
+
 void _wrap_delete_RemoteRateEstimatorListener_native_3e8e6202ec41eede(RemoteRateEstimatorListener *_swig_go_0) {
   RemoteRateEstimatorListener *arg1 = (RemoteRateEstimatorListener *) 0 ;
   
@@ -5143,6 +5368,60 @@ intgo _wrap_RTPSessionFacade_SendPLI_native_3e8e6202ec41eede(RTPSessionFacade *_
 }
 
 
+intgo _wrap_RTPSessionFacade_SetLocalCryptoSDES_native_3e8e6202ec41eede(RTPSessionFacade *_swig_go_0, _gostring_ _swig_go_1, _gostring_ _swig_go_2) {
+  RTPSessionFacade *arg1 = (RTPSessionFacade *) 0 ;
+  char *arg2 = (char *) 0 ;
+  char *arg3 = (char *) 0 ;
+  int result;
+  intgo _swig_go_result;
+  
+  arg1 = *(RTPSessionFacade **)&_swig_go_0; 
+  
+  arg2 = (char *)malloc(_swig_go_1.n + 1);
+  memcpy(arg2, _swig_go_1.p, _swig_go_1.n);
+  arg2[_swig_go_1.n] = '\0';
+  
+  
+  arg3 = (char *)malloc(_swig_go_2.n + 1);
+  memcpy(arg3, _swig_go_2.p, _swig_go_2.n);
+  arg3[_swig_go_2.n] = '\0';
+  
+  
+  result = (int)(arg1)->SetLocalCryptoSDES((char const *)arg2,(char const *)arg3);
+  _swig_go_result = result; 
+  free(arg2); 
+  free(arg3); 
+  return _swig_go_result;
+}
+
+
+intgo _wrap_RTPSessionFacade_SetRemoteCryptoSDES_native_3e8e6202ec41eede(RTPSessionFacade *_swig_go_0, _gostring_ _swig_go_1, _gostring_ _swig_go_2) {
+  RTPSessionFacade *arg1 = (RTPSessionFacade *) 0 ;
+  char *arg2 = (char *) 0 ;
+  char *arg3 = (char *) 0 ;
+  int result;
+  intgo _swig_go_result;
+  
+  arg1 = *(RTPSessionFacade **)&_swig_go_0; 
+  
+  arg2 = (char *)malloc(_swig_go_1.n + 1);
+  memcpy(arg2, _swig_go_1.p, _swig_go_1.n);
+  arg2[_swig_go_1.n] = '\0';
+  
+  
+  arg3 = (char *)malloc(_swig_go_2.n + 1);
+  memcpy(arg3, _swig_go_2.p, _swig_go_2.n);
+  arg3[_swig_go_2.n] = '\0';
+  
+  
+  result = (int)(arg1)->SetRemoteCryptoSDES((char const *)arg2,(char const *)arg3);
+  _swig_go_result = result; 
+  free(arg2); 
+  free(arg3); 
+  return _swig_go_result;
+}
+
+
 void _wrap_delete_RTPSessionFacade_native_3e8e6202ec41eede(RTPSessionFacade *_swig_go_0) {
   RTPSessionFacade *arg1 = (RTPSessionFacade *) 0 ;
   
@@ -5269,6 +5548,25 @@ intgo _wrap_RTPReceiverFacade_SendPLI_native_3e8e6202ec41eede(RTPReceiverFacade
 }
 
 
+intgo _wrap_RTPReceiverFacade_SendMaxBitrate_native_3e8e6202ec41eede(RTPReceiverFacade *_swig_go_0, intgo _swig_go_1, intgo _swig_go_2, bool _swig_go_3) {
+  RTPReceiverFacade *arg1 = (RTPReceiverFacade *) 0 ;
+  uint32_t arg2 ;
+  uint32_t arg3 ;
+  bool arg4 ;
+  int result;
+  intgo _swig_go_result;
+  
+  arg1 = *(RTPReceiverFacade **)&_swig_go_0; 
+  arg2 = (uint32_t)_swig_go_1; 
+  arg3 = (uint32_t)_swig_go_2; 
+  arg4 = (bool)_swig_go_3; 
+  
+  result = (int)(arg1)->SendMaxBitrate(arg2,arg3,arg4);
+  _swig_go_result = result; 
+  return _swig_go_result;
+}
+
+
 void _wrap_delete_RTPReceiverFacade_native_3e8e6202ec41eede(RTPReceiverFacade *_swig_go_0) {
   RTPReceiverFacade *arg1 = (RTPReceiverFacade *) 0 ;
   
@@ -5948,6 +6246,75 @@ void _wrap_MediaFrameListenerFacade_onMediaFrame_native_3e8e6202ec41eede(MediaFr
 }
 
 
+bool _wrap_MediaFrameIsKeyFrame_native_3e8e6202ec41eede(MediaFrame *_swig_go_0) {
+  MediaFrame *arg1 = (MediaFrame *) 0 ;
+  bool result;
+  bool _swig_go_result;
+  
+  arg1 = *(MediaFrame **)&_swig_go_0; 
+  
+  result = (bool)MediaFrameIsKeyFrame((MediaFrame const *)arg1);
+  _swig_go_result = result; 
+  return _swig_go_result;
+}
+
+
+intgo _wrap_MediaFrameGetLength_native_3e8e6202ec41eede(MediaFrame *_swig_go_0) {
+  MediaFrame *arg1 = (MediaFrame *) 0 ;
+  int result;
+  intgo _swig_go_result;
+  
+  arg1 = *(MediaFrame **)&_swig_go_0; 
+  
+  result = (int)MediaFrameGetLength((MediaFrame const *)arg1);
+  _swig_go_result = result; 
+  return _swig_go_result;
+}
+
+
+intgo _wrap_MediaFrameCopyData_native_3e8e6202ec41eede(MediaFrame *_swig_go_0, char *_swig_go_1, intgo _swig_go_2) {
+  MediaFrame *arg1 = (MediaFrame *) 0 ;
+  uint8_t *arg2 = (uint8_t *) 0 ;
+  int arg3 ;
+  int result;
+  intgo _swig_go_result;
+  
+  arg1 = *(MediaFrame **)&_swig_go_0; 
+  arg2 = *(uint8_t **)&_swig_go_1; 
+  arg3 = (int)_swig_go_2; 
+  
+  result = (int)MediaFrameCopyData((MediaFrame const *)arg1,arg2,arg3);
+  _swig_go_result = result; 
+  return _swig_go_result;
+}
+
+
+intgo _wrap_MediaFrameGetTimestamp_native_3e8e6202ec41eede(MediaFrame *_swig_go_0) {
+  MediaFrame *arg1 = (MediaFrame *) 0 ;
+  uint32_t result;
+  intgo _swig_go_result;
+  
+  arg1 = *(MediaFrame **)&_swig_go_0; 
+  
+  result = (uint32_t)MediaFrameGetTimestamp((MediaFrame const *)arg1);
+  _swig_go_result = result; 
+  return _swig_go_result;
+}
+
+
+intgo _wrap_MediaFrameGetVideoCodec_native_3e8e6202ec41eede(MediaFrame *_swig_go_0) {
+  MediaFrame *arg1 = (MediaFrame *) 0 ;
+  int result;
+  intgo _swig_go_result;
+  
+  arg1 = *(MediaFrame **)&_swig_go_0; 
+  
+  result = (int)MediaFrameGetVideoCodec((MediaFrame const *)arg1);
+  _swig_go_result = result; 
+  return _swig_go_result;
+}
+
+
 MediaFrameMultiplexer *_wrap_new_MediaFrameMultiplexer_native_3e8e6202ec41eede(RTPIncomingMediaStream *_swig_go_0) {
   RTPIncomingMediaStream *arg1 = (RTPIncomingMediaStream *) 0 ;
   MediaFrameMultiplexer *result = 0 ;
@@ -6072,6 +6439,187 @@ void _wrap_ActiveTrackListener_onActiveTrackchanged_native_3e8e6202ec41eede(Acti
   
 }
 
+KeyFrameRequestListener *_wrap__swig_NewDirectorKeyFrameRequestListenerKeyFrameRequestListener_native_3e8e6202ec41eede(intgo _swig_go_0) {
+  int arg1 ;
+  KeyFrameRequestListener *result = 0 ;
+  KeyFrameRequestListener *_swig_go_result;
+  
+  arg1 = (int)_swig_go_0; 
+  
+  result = new SwigDirector_KeyFrameRequestListener(arg1);
+  *(KeyFrameRequestListener **)&_swig_go_result = (KeyFrameRequestListener *)result; 
+  return _swig_go_result;
+}
+
+
+void _wrap_DeleteDirectorKeyFrameRequestListener_native_3e8e6202ec41eede(KeyFrameRequestListener *_swig_go_0) {
+  KeyFrameRequestListener *arg1 = (KeyFrameRequestListener *) 0 ;
+  
+  arg1 = *(KeyFrameRequestListener **)&_swig_go_0; 
+  
+  delete arg1;
+  
+}
+
+
+void _wrap__swig_DirectorKeyFrameRequestListener_upcall_OnKeyFrameRequest_native_3e8e6202ec41eede(SwigDirector_KeyFrameRequestListener *_swig_go_0, intgo _swig_go_1) {
+  SwigDirector_KeyFrameRequestListener *arg1 = (SwigDirector_KeyFrameRequestListener *) 0 ;
+  uint32_t arg2 ;
+  
+  arg1 = *(SwigDirector_KeyFrameRequestListener **)&_swig_go_0; 
+  arg2 = (uint32_t)_swig_go_1; 
+  
+  arg1->_swig_upcall_onKeyFrameRequest(arg2);
+  
+}
+
+
+KeyFrameRequestListener *_wrap_new_KeyFrameRequestListener_native_3e8e6202ec41eede() {
+  KeyFrameRequestListener *result = 0 ;
+  KeyFrameRequestListener *_swig_go_result;
+  
+  
+  result = (KeyFrameRequestListener *)new KeyFrameRequestListener();
+  *(KeyFrameRequestListener **)&_swig_go_result = (KeyFrameRequestListener *)result; 
+  return _swig_go_result;
+}
+
+
+void _wrap_delete_KeyFrameRequestListener_native_3e8e6202ec41eede(KeyFrameRequestListener *_swig_go_0) {
+  KeyFrameRequestListener *arg1 = (KeyFrameRequestListener *) 0 ;
+  
+  arg1 = *(KeyFrameRequestListener **)&_swig_go_0; 
+  
+  delete arg1;
+  
+}
+
+
+void _wrap_KeyFrameRequestListener_onKeyFrameRequest_native_3e8e6202ec41eede(KeyFrameRequestListener *_swig_go_0, intgo _swig_go_1) {
+  KeyFrameRequestListener *arg1 = (KeyFrameRequestListener *) 0 ;
+  uint32_t arg2 ;
+  
+  arg1 = *(KeyFrameRequestListener **)&_swig_go_0; 
+  arg2 = (uint32_t)_swig_go_1; 
+  
+  (arg1)->onKeyFrameRequest(arg2);
+  
+}
+
+
+LogListener *_wrap__swig_NewDirectorLogListenerLogListener_native_3e8e6202ec41eede(intgo _swig_go_0) {
+  int arg1 ;
+  LogListener *result = 0 ;
+  LogListener *_swig_go_result;
+  
+  arg1 = (int)_swig_go_0; 
+  
+  result = new SwigDirector_LogListener(arg1);
+  *(LogListener **)&_swig_go_result = (LogListener *)result; 
+  return _swig_go_result;
+}
+
+
+void _wrap_DeleteDirectorLogListener_native_3e8e6202ec41eede(LogListener *_swig_go_0) {
+  LogListener *arg1 = (LogListener *) 0 ;
+  
+  arg1 = *(LogListener **)&_swig_go_0; 
+  
+  delete arg1;
+  
+}
+
+
+void _wrap__swig_DirectorLogListener_upcall_OnLog_native_3e8e6202ec41eede(SwigDirector_LogListener *_swig_go_0, _gostring_ _swig_go_1) {
+  SwigDirector_LogListener *arg1 = (SwigDirector_LogListener *) 0 ;
+  std::string *arg2 = 0 ;
+  
+  arg1 = *(SwigDirector_LogListener **)&_swig_go_0; 
+  
+  std::string arg2_str(_swig_go_1.p, _swig_go_1.n);
+  arg2 = &arg2_str;
+  
+  
+  arg1->_swig_upcall_onLog((std::string const &)*arg2);
+  
+}
+
+
+LogListener *_wrap_new_LogListener_native_3e8e6202ec41eede() {
+  LogListener *result = 0 ;
+  LogListener *_swig_go_result;
+  
+  
+  result = (LogListener *)new LogListener();
+  *(LogListener **)&_swig_go_result = (LogListener *)result; 
+  return _swig_go_result;
+}
+
+
+void _wrap_delete_LogListener_native_3e8e6202ec41eede(LogListener *_swig_go_0) {
+  LogListener *arg1 = (LogListener *) 0 ;
+  
+  arg1 = *(LogListener **)&_swig_go_0; 
+  
+  delete arg1;
+  
+}
+
+
+void _wrap_LogListener_onLog_native_3e8e6202ec41eede(LogListener *_swig_go_0, _gostring_ _swig_go_1) {
+  LogListener *arg1 = (LogListener *) 0 ;
+  std::string *arg2 = 0 ;
+  
+  arg1 = *(LogListener **)&_swig_go_0; 
+  
+  std::string arg2_str(_swig_go_1.p, _swig_go_1.n);
+  arg2 = &arg2_str;
+  
+  
+  (arg1)->onLog((std::string const &)*arg2);
+  
+}
+
+
+void _wrap_SetLogListener_native_3e8e6202ec41eede(LogListener *_swig_go_0) {
+  LogListener *arg1 = (LogListener *) 0 ;
+  
+  arg1 = *(LogListener **)&_swig_go_0; 
+  
+  SetLogListener(arg1);
+  
+}
+
+
+RTPReceiverFacade *_wrap_KeyFrameRequestToReceiver_native_3e8e6202ec41eede(KeyFrameRequestListener *_swig_go_0) {
+  KeyFrameRequestListener *arg1 = (KeyFrameRequestListener *) 0 ;
+  RTPReceiverFacade *result = 0 ;
+  RTPReceiverFacade *_swig_go_result;
+  
+  arg1 = *(KeyFrameRequestListener **)&_swig_go_0; 
+  
+  result = (RTPReceiverFacade *)KeyFrameRequestToReceiver(arg1);
+  *(RTPReceiverFacade **)&_swig_go_result = (RTPReceiverFacade *)result; 
+  return _swig_go_result;
+}
+
+
+intgo _wrap_RTPReceiverFacade_SendFIR_native_3e8e6202ec41eede(RTPReceiverFacade *_swig_go_0, intgo _swig_go_1, char _swig_go_2) {
+  RTPReceiverFacade *arg1 = (RTPReceiverFacade *) 0 ;
+  uint32_t arg2 ;
+  BYTE arg3 ;
+  int result;
+  intgo _swig_go_result;
+  
+  arg1 = *(RTPReceiverFacade **)&_swig_go_0; 
+  arg2 = (uint32_t)_swig_go_1; 
+  arg3 = (BYTE)_swig_go_2; 
+  
+  result = (int)(arg1)->SendFIR(arg2,arg3);
+  _swig_go_result = result; 
+  return _swig_go_result;
+}
+
 
 #ifdef __cplusplus
 }
@@ -24,6 +24,10 @@ class SwigDirector_DTLSICETransportListener : public DTLSICETransportListener
     DTLSICETransportListener::onDTLSStateChange(state);
   }
   virtual void onDTLSStateChange(uint32_t state);
+  void _swig_upcall_onICECandidateActivated(std::string const &ip, uint32_t port, uint32_t priority) {
+    DTLSICETransportListener::onICECandidateActivated(ip,port,priority);
+  }
+  virtual void onICECandidateActivated(std::string const &ip, uint32_t port, uint32_t priority);
  private:
   intgo go_val;
   Swig_memory *swig_mem;
@@ -67,4 +71,32 @@ class SwigDirector_ActiveTrackListener : public ActiveTrackListener
   Swig_memory *swig_mem;
 };
 
+class SwigDirector_KeyFrameRequestListener : public KeyFrameRequestListener
+{
+ public:
+  SwigDirector_KeyFrameRequestListener(int swig_p);
+  virtual ~SwigDirector_KeyFrameRequestListener();
+  void _swig_upcall_onKeyFrameRequest(uint32_t ssrc) {
+    KeyFrameRequestListener::onKeyFrameRequest(ssrc);
+  }
+  virtual void onKeyFrameRequest(uint32_t ssrc);
+ private:
+  intgo go_val;
+  Swig_memory *swig_mem;
+};
+
+class SwigDirector_LogListener : public LogListener
+{
+ public:
+  SwigDirector_LogListener(int swig_p);
+  virtual ~SwigDirector_LogListener();
+  void _swig_upcall_onLog(std::string const &line) {
+    LogListener::onLog(line);
+  }
+  virtual void onLog(std::string const &line);
+ private:
+  intgo go_val;
+  Swig_memory *swig_mem;
+};
+
 #endif
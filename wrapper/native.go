@@ -97,6 +97,14 @@ typedef long long swig_type_66;
 typedef _gostring_ swig_type_67;
 typedef _gostring_ swig_type_68;
 typedef long long swig_type_69;
+typedef _gostring_ swig_type_70;
+typedef _gostring_ swig_type_71;
+typedef _gostring_ swig_type_72;
+typedef _gostring_ swig_type_73;
+typedef _gostring_ swig_type_74;
+typedef _gostring_ swig_type_75;
+typedef _gostring_ swig_type_76;
+typedef _gostring_ swig_type_77;
 extern void _wrap_Swig_free_native_3e8e6202ec41eede(uintptr_t arg1);
 extern uintptr_t _wrap_Swig_malloc_native_3e8e6202ec41eede(swig_intgo arg1);
 extern uintptr_t _wrap_new_Acumulator__SWIG_0_native_3e8e6202ec41eede(swig_intgo arg1, swig_intgo arg2);
@@ -329,6 +337,8 @@ extern void _wrap__swig_DirectorDTLSICETransportListener_upcall_OnDTLSStateChang
 extern uintptr_t _wrap_new_DTLSICETransportListener_native_3e8e6202ec41eede(void);
 extern void _wrap_delete_DTLSICETransportListener_native_3e8e6202ec41eede(uintptr_t arg1);
 extern void _wrap_DTLSICETransportListener_onDTLSStateChange_native_3e8e6202ec41eede(uintptr_t arg1, swig_intgo arg2);
+extern void _wrap__swig_DirectorDTLSICETransportListener_upcall_OnICECandidateActivated_native_3e8e6202ec41eede(uintptr_t, swig_type_74 ip, swig_intgo port, swig_intgo priority);
+extern void _wrap_DTLSICETransportListener_onICECandidateActivated_native_3e8e6202ec41eede(uintptr_t arg1, swig_type_75 arg2, swig_intgo arg3, swig_intgo arg4);
 extern void _wrap_delete_RemoteRateEstimatorListener_native_3e8e6202ec41eede(uintptr_t arg1);
 extern void _wrap_DTLSICETransport_SetListener_native_3e8e6202ec41eede(uintptr_t arg1, uintptr_t arg2);
 extern void _wrap_DTLSICETransport_Start_native_3e8e6202ec41eede(uintptr_t arg1);
@@ -380,6 +390,8 @@ extern uintptr_t _wrap_RTPSessionFacade_GetIncomingSourceGroup_native_3e8e6202ec
 extern swig_intgo _wrap_RTPSessionFacade_End_native_3e8e6202ec41eede(uintptr_t arg1);
 extern swig_intgo _wrap_RTPSessionFacade_Enqueue_native_3e8e6202ec41eede(uintptr_t arg1, uintptr_t arg2);
 extern swig_intgo _wrap_RTPSessionFacade_SendPLI_native_3e8e6202ec41eede(uintptr_t arg1, swig_intgo arg2);
+extern swig_intgo _wrap_RTPSessionFacade_SetLocalCryptoSDES_native_3e8e6202ec41eede(uintptr_t arg1, swig_type_70 arg2, swig_type_71 arg3);
+extern swig_intgo _wrap_RTPSessionFacade_SetRemoteCryptoSDES_native_3e8e6202ec41eede(uintptr_t arg1, swig_type_72 arg2, swig_type_73 arg3);
 extern void _wrap_delete_RTPSessionFacade_native_3e8e6202ec41eede(uintptr_t arg1);
 extern uintptr_t _wrap_RTPSessionFacade_SwigGetRTPReceiver_native_3e8e6202ec41eede(uintptr_t arg1);
 extern uintptr_t _wrap_new_RTPSenderFacade__SWIG_0_native_3e8e6202ec41eede(uintptr_t arg1);
@@ -390,6 +402,7 @@ extern uintptr_t _wrap_new_RTPReceiverFacade__SWIG_0_native_3e8e6202ec41eede(uin
 extern uintptr_t _wrap_new_RTPReceiverFacade__SWIG_1_native_3e8e6202ec41eede(uintptr_t arg1);
 extern uintptr_t _wrap_RTPReceiverFacade_get_native_3e8e6202ec41eede(uintptr_t arg1);
 extern swig_intgo _wrap_RTPReceiverFacade_SendPLI_native_3e8e6202ec41eede(uintptr_t arg1, swig_intgo arg2);
+extern swig_intgo _wrap_RTPReceiverFacade_SendMaxBitrate_native_3e8e6202ec41eede(uintptr_t arg1, swig_intgo arg2, swig_intgo arg3, _Bool arg4);
 extern void _wrap_delete_RTPReceiverFacade_native_3e8e6202ec41eede(uintptr_t arg1);
 extern uintptr_t _wrap_TransportToSender_native_3e8e6202ec41eede(uintptr_t arg1);
 extern uintptr_t _wrap_TransportToReceiver_native_3e8e6202ec41eede(uintptr_t arg1);
@@ -444,6 +457,11 @@ extern void _wrap__swig_DirectorMediaFrameListenerFacade_upcall_OnMediaFrame_nat
 extern uintptr_t _wrap_new_MediaFrameListenerFacade_native_3e8e6202ec41eede(void);
 extern void _wrap_delete_MediaFrameListenerFacade_native_3e8e6202ec41eede(uintptr_t arg1);
 extern void _wrap_MediaFrameListenerFacade_onMediaFrame_native_3e8e6202ec41eede(uintptr_t arg1, uintptr_t arg2);
+extern _Bool _wrap_MediaFrameIsKeyFrame_native_3e8e6202ec41eede(uintptr_t arg1);
+extern swig_intgo _wrap_MediaFrameGetLength_native_3e8e6202ec41eede(uintptr_t arg1);
+extern swig_intgo _wrap_MediaFrameCopyData_native_3e8e6202ec41eede(uintptr_t arg1, swig_voidp arg2, swig_intgo arg3);
+extern swig_intgo _wrap_MediaFrameGetTimestamp_native_3e8e6202ec41eede(uintptr_t arg1);
+extern swig_intgo _wrap_MediaFrameGetVideoCodec_native_3e8e6202ec41eede(uintptr_t arg1);
 extern uintptr_t _wrap_new_MediaFrameMultiplexer_native_3e8e6202ec41eede(uintptr_t arg1);
 extern void _wrap_MediaFrameMultiplexer_AddMediaListener_native_3e8e6202ec41eede(uintptr_t arg1, uintptr_t arg2);
 extern void _wrap_MediaFrameMultiplexer_RemoveMediaListener_native_3e8e6202ec41eede(uintptr_t arg1, uintptr_t arg2);
@@ -455,6 +473,21 @@ extern void _wrap__swig_DirectorActiveTrackListener_upcall_OnActiveTrackchanged_
 extern uintptr_t _wrap_new_ActiveTrackListener_native_3e8e6202ec41eede(void);
 extern void _wrap_delete_ActiveTrackListener_native_3e8e6202ec41eede(uintptr_t arg1);
 extern void _wrap_ActiveTrackListener_onActiveTrackchanged_native_3e8e6202ec41eede(uintptr_t arg1, swig_intgo arg2);
+extern uintptr_t _wrap__swig_NewDirectorKeyFrameRequestListenerKeyFrameRequestListener_native_3e8e6202ec41eede(int);
+extern void _wrap_DeleteDirectorKeyFrameRequestListener_native_3e8e6202ec41eede(uintptr_t arg1);
+extern void _wrap__swig_DirectorKeyFrameRequestListener_upcall_OnKeyFrameRequest_native_3e8e6202ec41eede(uintptr_t, swig_intgo ssrc);
+extern uintptr_t _wrap_new_KeyFrameRequestListener_native_3e8e6202ec41eede(void);
+extern void _wrap_delete_KeyFrameRequestListener_native_3e8e6202ec41eede(uintptr_t arg1);
+extern void _wrap_KeyFrameRequestListener_onKeyFrameRequest_native_3e8e6202ec41eede(uintptr_t arg1, swig_intgo arg2);
+extern uintptr_t _wrap__swig_NewDirectorLogListenerLogListener_native_3e8e6202ec41eede(int);
+extern void _wrap_DeleteDirectorLogListener_native_3e8e6202ec41eede(uintptr_t arg1);
+extern void _wrap__swig_DirectorLogListener_upcall_OnLog_native_3e8e6202ec41eede(uintptr_t, swig_type_76 line);
+extern uintptr_t _wrap_new_LogListener_native_3e8e6202ec41eede(void);
+extern void _wrap_delete_LogListener_native_3e8e6202ec41eede(uintptr_t arg1);
+extern void _wrap_LogListener_onLog_native_3e8e6202ec41eede(uintptr_t arg1, swig_type_77 arg2);
+extern void _wrap_SetLogListener_native_3e8e6202ec41eede(uintptr_t arg1);
+extern uintptr_t _wrap_KeyFrameRequestToReceiver_native_3e8e6202ec41eede(uintptr_t arg1);
+extern swig_intgo _wrap_RTPReceiverFacade_SendFIR_native_3e8e6202ec41eede(uintptr_t arg1, swig_intgo arg2, char arg3);
 #undef intgo
 */
 import "C"
@@ -2552,6 +2585,42 @@ func Swig_DirectorDTLSICETransportListener_callback_onDTLSStateChange_native_3e8
 	swig_p.OnDTLSStateChange(arg2)
 }
 
+type _swig_DirectorInterfaceDTLSICETransportListenerOnICECandidateActivated interface {
+	OnICECandidateActivated(string, uint, uint)
+}
+
+func (swig_p *_swig_DirectorDTLSICETransportListener) OnICECandidateActivated(ip string, port uint, priority uint) {
+	if swig_g, swig_ok := swig_p.v.(_swig_DirectorInterfaceDTLSICETransportListenerOnICECandidateActivated); swig_ok {
+		swig_g.OnICECandidateActivated(ip, port, priority)
+		return
+	}
+	_swig_i_0 := ip
+	_swig_i_1 := port
+	_swig_i_2 := priority
+	C._wrap__swig_DirectorDTLSICETransportListener_upcall_OnICECandidateActivated_native_3e8e6202ec41eede(C.uintptr_t(swig_p.SwigcptrDTLSICETransportListener), *(*C.swig_type_74)(unsafe.Pointer(&_swig_i_0)), C.swig_intgo(_swig_i_1), C.swig_intgo(_swig_i_2))
+	if Swig_escape_always_false {
+		Swig_escape_val = ip
+	}
+}
+
+func DirectorDTLSICETransportListenerOnICECandidateActivated(p DTLSICETransportListener, arg2 string, arg3 uint, arg4 uint) {
+	_swig_i_0 := arg2
+	_swig_i_1 := arg3
+	_swig_i_2 := arg4
+	C._wrap__swig_DirectorDTLSICETransportListener_upcall_OnICECandidateActivated_native_3e8e6202ec41eede(C.uintptr_t(p.(*_swig_DirectorDTLSICETransportListener).SwigcptrDTLSICETransportListener), *(*C.swig_type_74)(unsafe.Pointer(&_swig_i_0)), C.swig_intgo(_swig_i_1), C.swig_intgo(_swig_i_2))
+	if Swig_escape_always_false {
+		Swig_escape_val = arg2
+	}
+}
+
+//export Swig_DirectorDTLSICETransportListener_callback_onICECandidateActivated_native_3e8e6202ec41eede
+func Swig_DirectorDTLSICETransportListener_callback_onICECandidateActivated_native_3e8e6202ec41eede(swig_c int, arg2 string, arg3 uint, arg4 uint) {
+	swig_p := swigDirectorLookup(swig_c).(*_swig_DirectorDTLSICETransportListener)
+	var swig_r_1 string
+	swig_r_1 = swigCopyString(arg2)
+	swig_p.OnICECandidateActivated(swig_r_1, arg3, arg4)
+}
+
 type SwigcptrDTLSICETransportListener uintptr
 
 func (p SwigcptrDTLSICETransportListener) Swigcptr() uintptr {
@@ -2582,11 +2651,23 @@ func (arg1 SwigcptrDTLSICETransportListener) OnDTLSStateChange(arg2 uint) {
 	C._wrap_DTLSICETransportListener_onDTLSStateChange_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0), C.swig_intgo(_swig_i_1))
 }
 
+func (arg1 SwigcptrDTLSICETransportListener) OnICECandidateActivated(arg2 string, arg3 uint, arg4 uint) {
+	_swig_i_0 := arg1
+	_swig_i_1 := arg2
+	_swig_i_2 := arg3
+	_swig_i_3 := arg4
+	C._wrap_DTLSICETransportListener_onICECandidateActivated_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0), *(*C.swig_type_75)(unsafe.Pointer(&_swig_i_1)), C.swig_intgo(_swig_i_2), C.swig_intgo(_swig_i_3))
+	if Swig_escape_always_false {
+		Swig_escape_val = arg2
+	}
+}
+
 type DTLSICETransportListener interface {
 	Swigcptr() uintptr
 	SwigIsDTLSICETransportListener()
 	DirectorInterface() interface{}
 	OnDTLSStateChange(arg2 uint)
+	OnICECandidateActivated(arg2 string, arg3 uint, arg4 uint)
 }
 
 type SwigcptrRemoteRateEstimatorListener uintptr
@@ -3158,6 +3239,36 @@ func (arg1 SwigcptrRTPSessionFacade) SendPLI(arg2 uint) (_swig_ret int) {
 	return swig_r
 }
 
+func (arg1 SwigcptrRTPSessionFacade) SetLocalCryptoSDES(arg2 string, arg3 string) (_swig_ret int) {
+	var swig_r int
+	_swig_i_0 := arg1
+	_swig_i_1 := arg2
+	_swig_i_2 := arg3
+	swig_r = (int)(C._wrap_RTPSessionFacade_SetLocalCryptoSDES_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0), *(*C.swig_type_70)(unsafe.Pointer(&_swig_i_1)), *(*C.swig_type_71)(unsafe.Pointer(&_swig_i_2))))
+	if Swig_escape_always_false {
+		Swig_escape_val = arg2
+	}
+	if Swig_escape_always_false {
+		Swig_escape_val = arg3
+	}
+	return swig_r
+}
+
+func (arg1 SwigcptrRTPSessionFacade) SetRemoteCryptoSDES(arg2 string, arg3 string) (_swig_ret int) {
+	var swig_r int
+	_swig_i_0 := arg1
+	_swig_i_1 := arg2
+	_swig_i_2 := arg3
+	swig_r = (int)(C._wrap_RTPSessionFacade_SetRemoteCryptoSDES_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0), *(*C.swig_type_72)(unsafe.Pointer(&_swig_i_1)), *(*C.swig_type_73)(unsafe.Pointer(&_swig_i_2))))
+	if Swig_escape_always_false {
+		Swig_escape_val = arg2
+	}
+	if Swig_escape_always_false {
+		Swig_escape_val = arg3
+	}
+	return swig_r
+}
+
 func DeleteRTPSessionFacade(arg1 RTPSessionFacade) {
 	_swig_i_0 := arg1.Swigcptr()
 	C._wrap_delete_RTPSessionFacade_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0))
@@ -3189,6 +3300,8 @@ type RTPSessionFacade interface {
 	End() (_swig_ret int)
 	Enqueue(arg2 RTPPacket_shared) (_swig_ret int)
 	SendPLI(arg2 uint) (_swig_ret int)
+	SetLocalCryptoSDES(arg2 string, arg3 string) (_swig_ret int)
+	SetRemoteCryptoSDES(arg2 string, arg3 string) (_swig_ret int)
 	SwigIsRTPSender()
 	SwigGetRTPSender() RTPSender
 	SwigGetRTPReceiver() (_swig_ret RTPReceiver)
@@ -3303,6 +3416,25 @@ func (arg1 SwigcptrRTPReceiverFacade) SendPLI(arg2 uint) (_swig_ret int) {
 	return swig_r
 }
 
+func (arg1 SwigcptrRTPReceiverFacade) SendMaxBitrate(arg2 uint, arg3 uint, arg4 bool) (_swig_ret int) {
+	var swig_r int
+	_swig_i_0 := arg1
+	_swig_i_1 := arg2
+	_swig_i_2 := arg3
+	_swig_i_3 := arg4
+	swig_r = (int)(C._wrap_RTPReceiverFacade_SendMaxBitrate_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0), C.swig_intgo(_swig_i_1), C.swig_intgo(_swig_i_2), C._Bool(_swig_i_3)))
+	return swig_r
+}
+
+func (arg1 SwigcptrRTPReceiverFacade) SendFIR(arg2 uint, arg3 byte) (_swig_ret int) {
+	var swig_r int
+	_swig_i_0 := arg1
+	_swig_i_1 := arg2
+	_swig_i_2 := arg3
+	swig_r = (int)(C._wrap_RTPReceiverFacade_SendFIR_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0), C.swig_intgo(_swig_i_1), C.char(_swig_i_2)))
+	return swig_r
+}
+
 func DeleteRTPReceiverFacade(arg1 RTPReceiverFacade) {
 	_swig_i_0 := arg1.Swigcptr()
 	C._wrap_delete_RTPReceiverFacade_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0))
@@ -3313,6 +3445,8 @@ type RTPReceiverFacade interface {
 	SwigIsRTPReceiverFacade()
 	Get() (_swig_ret RTPReceiver)
 	SendPLI(arg2 uint) (_swig_ret int)
+	SendFIR(arg2 uint, arg3 byte) (_swig_ret int)
+	SendMaxBitrate(arg2 uint, arg3 uint, arg4 bool) (_swig_ret int)
 }
 
 func TransportToSender(arg1 DTLSICETransport) (_swig_ret RTPSenderFacade) {
@@ -3350,6 +3484,123 @@ func RTPSessionToReceiver(arg1 MediaFrameSessionFacade) (_swig_ret RTPReceiverFa
 	return swig_r
 }
 
+func KeyFrameRequestToReceiver(arg1 KeyFrameRequestListener) (_swig_ret RTPReceiverFacade) {
+	var swig_r RTPReceiverFacade
+	_swig_i_0 := arg1.Swigcptr()
+	swig_r = (RTPReceiverFacade)(SwigcptrRTPReceiverFacade(C._wrap_KeyFrameRequestToReceiver_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0))))
+	return swig_r
+}
+
+type _swig_DirectorLogListener struct {
+	SwigcptrLogListener
+	v interface{}
+}
+
+func (p *_swig_DirectorLogListener) Swigcptr() uintptr {
+	return p.SwigcptrLogListener.Swigcptr()
+}
+
+func (p *_swig_DirectorLogListener) SwigIsLogListener() {
+}
+
+func (p *_swig_DirectorLogListener) DirectorInterface() interface{} {
+	return p.v
+}
+
+func NewDirectorLogListener(v interface{}) LogListener {
+	p := &_swig_DirectorLogListener{0, v}
+	p.SwigcptrLogListener = SwigcptrLogListener(C._wrap__swig_NewDirectorLogListenerLogListener_native_3e8e6202ec41eede(C.int(swigDirectorAdd(p))))
+	return p
+}
+
+func DeleteDirectorLogListener(arg1 LogListener) {
+	_swig_i_0 := arg1.Swigcptr()
+	C._wrap_DeleteDirectorLogListener_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0))
+}
+
+//export Swiggo_DeleteDirector_LogListener_native_3e8e6202ec41eede
+func Swiggo_DeleteDirector_LogListener_native_3e8e6202ec41eede(c int) {
+	swigDirectorLookup(c).(*_swig_DirectorLogListener).SwigcptrLogListener = 0
+	swigDirectorDelete(c)
+}
+
+type _swig_DirectorInterfaceLogListenerOnLog interface {
+	OnLog(string)
+}
+
+func (swig_p *_swig_DirectorLogListener) OnLog(line string) {
+	if swig_g, swig_ok := swig_p.v.(_swig_DirectorInterfaceLogListenerOnLog); swig_ok {
+		swig_g.OnLog(line)
+		return
+	}
+	_swig_i_0 := line
+	C._wrap__swig_DirectorLogListener_upcall_OnLog_native_3e8e6202ec41eede(C.uintptr_t(swig_p.SwigcptrLogListener), *(*C.swig_type_76)(unsafe.Pointer(&_swig_i_0)))
+	if Swig_escape_always_false {
+		Swig_escape_val = line
+	}
+}
+
+func DirectorLogListenerOnLog(p LogListener, arg2 string) {
+	_swig_i_0 := arg2
+	C._wrap__swig_DirectorLogListener_upcall_OnLog_native_3e8e6202ec41eede(C.uintptr_t(p.(*_swig_DirectorLogListener).SwigcptrLogListener), *(*C.swig_type_76)(unsafe.Pointer(&_swig_i_0)))
+	if Swig_escape_always_false {
+		Swig_escape_val = arg2
+	}
+}
+
+//export Swig_DirectorLogListener_callback_onLog_native_3e8e6202ec41eede
+func Swig_DirectorLogListener_callback_onLog_native_3e8e6202ec41eede(swig_c int, arg2 string) {
+	swig_p := swigDirectorLookup(swig_c).(*_swig_DirectorLogListener)
+	var swig_r_1 string
+	swig_r_1 = swigCopyString(arg2)
+	swig_p.OnLog(swig_r_1)
+}
+
+type SwigcptrLogListener uintptr
+
+func (p SwigcptrLogListener) Swigcptr() uintptr {
+	return (uintptr)(p)
+}
+
+func (p SwigcptrLogListener) SwigIsLogListener() {
+}
+
+func (p SwigcptrLogListener) DirectorInterface() interface{} {
+	return nil
+}
+
+func NewLogListener() (_swig_ret LogListener) {
+	var swig_r LogListener
+	swig_r = (LogListener)(SwigcptrLogListener(C._wrap_new_LogListener_native_3e8e6202ec41eede()))
+	return swig_r
+}
+
+func DeleteLogListener(arg1 LogListener) {
+	_swig_i_0 := arg1.Swigcptr()
+	C._wrap_delete_LogListener_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0))
+}
+
+func (arg1 SwigcptrLogListener) OnLog(arg2 string) {
+	_swig_i_0 := arg1
+	_swig_i_1 := arg2
+	C._wrap_LogListener_onLog_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0), *(*C.swig_type_77)(unsafe.Pointer(&_swig_i_1)))
+	if Swig_escape_always_false {
+		Swig_escape_val = arg2
+	}
+}
+
+type LogListener interface {
+	Swigcptr() uintptr
+	SwigIsLogListener()
+	DirectorInterface() interface{}
+	OnLog(arg2 string)
+}
+
+func SetLogListener(arg1 LogListener) {
+	_swig_i_0 := arg1.Swigcptr()
+	C._wrap_SetLogListener_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0))
+}
+
 type SwigcptrRTPStreamTransponderFacade uintptr
 
 func (p SwigcptrRTPStreamTransponderFacade) Swigcptr() uintptr {
@@ -3935,6 +4186,43 @@ type MediaFrameListenerFacade interface {
 	OnMediaFrame(arg2 MediaFrame)
 }
 
+func MediaFrameIsKeyFrame(arg1 MediaFrame) (_swig_ret bool) {
+	var swig_r bool
+	_swig_i_0 := arg1.Swigcptr()
+	swig_r = (bool)(C._wrap_MediaFrameIsKeyFrame_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0)))
+	return swig_r
+}
+
+func MediaFrameGetLength(arg1 MediaFrame) (_swig_ret int) {
+	var swig_r int
+	_swig_i_0 := arg1.Swigcptr()
+	swig_r = (int)(C._wrap_MediaFrameGetLength_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0)))
+	return swig_r
+}
+
+func MediaFrameCopyData(arg1 MediaFrame, arg2 *byte, arg3 int) (_swig_ret int) {
+	var swig_r int
+	_swig_i_0 := arg1.Swigcptr()
+	_swig_i_1 := arg2
+	_swig_i_2 := arg3
+	swig_r = (int)(C._wrap_MediaFrameCopyData_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0), C.swig_voidp(_swig_i_1), C.swig_intgo(_swig_i_2)))
+	return swig_r
+}
+
+func MediaFrameGetTimestamp(arg1 MediaFrame) (_swig_ret uint) {
+	var swig_r uint
+	_swig_i_0 := arg1.Swigcptr()
+	swig_r = (uint)(C._wrap_MediaFrameGetTimestamp_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0)))
+	return swig_r
+}
+
+func MediaFrameGetVideoCodec(arg1 MediaFrame) (_swig_ret int) {
+	var swig_r int
+	_swig_i_0 := arg1.Swigcptr()
+	swig_r = (int)(C._wrap_MediaFrameGetVideoCodec_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0)))
+	return swig_r
+}
+
 type SwigcptrMediaFrameMultiplexer uintptr
 
 func (p SwigcptrMediaFrameMultiplexer) Swigcptr() uintptr {
@@ -4075,6 +4363,100 @@ type ActiveTrackListener interface {
 	OnActiveTrackchanged(arg2 uint)
 }
 
+type _swig_DirectorKeyFrameRequestListener struct {
+	SwigcptrKeyFrameRequestListener
+	v interface{}
+}
+
+func (p *_swig_DirectorKeyFrameRequestListener) Swigcptr() uintptr {
+	return p.SwigcptrKeyFrameRequestListener.Swigcptr()
+}
+
+func (p *_swig_DirectorKeyFrameRequestListener) SwigIsKeyFrameRequestListener() {
+}
+
+func (p *_swig_DirectorKeyFrameRequestListener) DirectorInterface() interface{} {
+	return p.v
+}
+
+func NewDirectorKeyFrameRequestListener(v interface{}) KeyFrameRequestListener {
+	p := &_swig_DirectorKeyFrameRequestListener{0, v}
+	p.SwigcptrKeyFrameRequestListener = SwigcptrKeyFrameRequestListener(C._wrap__swig_NewDirectorKeyFrameRequestListenerKeyFrameRequestListener_native_3e8e6202ec41eede(C.int(swigDirectorAdd(p))))
+	return p
+}
+
+func DeleteDirectorKeyFrameRequestListener(arg1 KeyFrameRequestListener) {
+	_swig_i_0 := arg1.Swigcptr()
+	C._wrap_DeleteDirectorKeyFrameRequestListener_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0))
+}
+
+//export Swiggo_DeleteDirector_KeyFrameRequestListener_native_3e8e6202ec41eede
+func Swiggo_DeleteDirector_KeyFrameRequestListener_native_3e8e6202ec41eede(c int) {
+	swigDirectorLookup(c).(*_swig_DirectorKeyFrameRequestListener).SwigcptrKeyFrameRequestListener = 0
+	swigDirectorDelete(c)
+}
+
+type _swig_DirectorInterfaceKeyFrameRequestListenerOnKeyFrameRequest interface {
+	OnKeyFrameRequest(uint)
+}
+
+func (swig_p *_swig_DirectorKeyFrameRequestListener) OnKeyFrameRequest(ssrc uint) {
+	if swig_g, swig_ok := swig_p.v.(_swig_DirectorInterfaceKeyFrameRequestListenerOnKeyFrameRequest); swig_ok {
+		swig_g.OnKeyFrameRequest(ssrc)
+		return
+	}
+	_swig_i_0 := ssrc
+	C._wrap__swig_DirectorKeyFrameRequestListener_upcall_OnKeyFrameRequest_native_3e8e6202ec41eede(C.uintptr_t(swig_p.SwigcptrKeyFrameRequestListener), C.swig_intgo(_swig_i_0))
+}
+
+func DirectorKeyFrameRequestListenerOnKeyFrameRequest(p KeyFrameRequestListener, arg2 uint) {
+	_swig_i_0 := arg2
+	C._wrap__swig_DirectorKeyFrameRequestListener_upcall_OnKeyFrameRequest_native_3e8e6202ec41eede(C.uintptr_t(p.(*_swig_DirectorKeyFrameRequestListener).SwigcptrKeyFrameRequestListener), C.swig_intgo(_swig_i_0))
+}
+
+//export Swig_DirectorKeyFrameRequestListener_callback_onKeyFrameRequest_native_3e8e6202ec41eede
+func Swig_DirectorKeyFrameRequestListener_callback_onKeyFrameRequest_native_3e8e6202ec41eede(swig_c int, arg2 uint) {
+	swig_p := swigDirectorLookup(swig_c).(*_swig_DirectorKeyFrameRequestListener)
+	swig_p.OnKeyFrameRequest(arg2)
+}
+
+type SwigcptrKeyFrameRequestListener uintptr
+
+func (p SwigcptrKeyFrameRequestListener) Swigcptr() uintptr {
+	return (uintptr)(p)
+}
+
+func (p SwigcptrKeyFrameRequestListener) SwigIsKeyFrameRequestListener() {
+}
+
+func (p SwigcptrKeyFrameRequestListener) DirectorInterface() interface{} {
+	return nil
+}
+
+func NewKeyFrameRequestListener() (_swig_ret KeyFrameRequestListener) {
+	var swig_r KeyFrameRequestListener
+	swig_r = (KeyFrameRequestListener)(SwigcptrKeyFrameRequestListener(C._wrap_new_KeyFrameRequestListener_native_3e8e6202ec41eede()))
+	return swig_r
+}
+
+func DeleteKeyFrameRequestListener(arg1 KeyFrameRequestListener) {
+	_swig_i_0 := arg1.Swigcptr()
+	C._wrap_delete_KeyFrameRequestListener_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0))
+}
+
+func (arg1 SwigcptrKeyFrameRequestListener) OnKeyFrameRequest(arg2 uint) {
+	_swig_i_0 := arg1
+	_swig_i_1 := arg2
+	C._wrap_KeyFrameRequestListener_onKeyFrameRequest_native_3e8e6202ec41eede(C.uintptr_t(_swig_i_0), C.swig_intgo(_swig_i_1))
+}
+
+type KeyFrameRequestListener interface {
+	Swigcptr() uintptr
+	SwigIsKeyFrameRequestListener()
+	DirectorInterface() interface{}
+	OnKeyFrameRequest(arg2 uint)
+}
+
 
 type SwigcptrSwigDirector_MediaFrameListenerFacade uintptr
 type SwigDirector_MediaFrameListenerFacade interface {
@@ -4108,6 +4490,14 @@ func (p SwigcptrSwigDirector_ActiveTrackListener) Swigcptr() uintptr {
 	return uintptr(p)
 }
 
+type SwigcptrSwigDirector_KeyFrameRequestListener uintptr
+type SwigDirector_KeyFrameRequestListener interface {
+	Swigcptr() uintptr;
+}
+func (p SwigcptrSwigDirector_KeyFrameRequestListener) Swigcptr() uintptr {
+	return uintptr(p)
+}
+
 type SwigcptrUDPDumper uintptr
 type UDPDumper interface {
 	Swigcptr() uintptr;